@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// AlbumNamer is a backend that suggests album names (Ollama, OpenAI-compatible APIs)
+type AlbumNamer interface {
+	// Model identifies the backend's model in cached suggestions
+	Model() string
+	// Available reports whether the backend answers at all (checked once per run)
+	Available(ctx context.Context) bool
+	// Suggest returns an album name for a folder, given a few of its file paths and the
+	// keywords its files are tagged with (XMP sidecars, may be empty)
+	Suggest(ctx context.Context, folderPath string, sampleFiles, keywords []string) (string, error)
+}
+
+// NewAlbumNamer returns the backend selected by config.NamingProvider
+func NewAlbumNamer(config *Config) AlbumNamer {
+	if config.NamingProvider == NamingOpenAI {
+		return &OpenAINamer{config: config}
+	}
+	if config.VisionModel != "" {
+		return &VisionNamer{text: &OllamaNamer{config: config}}
+	}
+	return &OllamaNamer{config: config}
+}
+
+// Naming requests are bounded by a timeout and retried on transient failures
+const (
+	// defaultNamingTimeout bounds one album name suggestion when no timeout is configured
+	// (generous, the first request may have to load the model)
+	defaultNamingTimeout = time.Minute
+
+	// namingCheckTimeout bounds the availability check, a running backend answers at once
+	namingCheckTimeout = 2 * time.Second
+
+	// Retries of a suggestion that failed with a server error or a dropped connection
+	// (e.g. while the model loads); the delay doubles after each attempt
+	defaultNamingRetries = 2
+	namingRetryDelay     = 500 * time.Millisecond
+)
+
+// albumNamePrompt asks for an album name from the last folder names, a few file names
+// and the folder's keywords
+func albumNamePrompt(folderPath string, sampleFiles, keywords []string) string {
+	// Extract folder names from path
+	parts := strings.Split(folderPath, string(filepath.Separator))
+	var relevantParts []string
+	for _, part := range parts {
+		if part != "" && !strings.HasPrefix(part, ".") &&
+			part != "Volumes" && part != "TimeMachine" {
+			relevantParts = append(relevantParts, part)
+		}
+	}
+
+	// Take last 3 parts
+	if len(relevantParts) > 3 {
+		relevantParts = relevantParts[len(relevantParts)-3:]
+	}
+
+	// Get sample filenames
+	var sampleNames []string
+	for i, f := range sampleFiles {
+		if i >= 5 {
+			break
+		}
+		sampleNames = append(sampleNames, filepath.Base(f))
+	}
+
+	tagged := ""
+	if len(keywords) > 0 {
+		tagged = fmt.Sprintf("\nAnd these keywords the files are tagged with: %s\n", strings.Join(keywords, ", "))
+	}
+
+	return fmt.Sprintf(`Given these folder names from a photo/video path: %s
+
+And these sample filenames: %s
+%s
+Suggest a good album name in format: YYYY-MM Description (e.g., "2005-06 Cyprus Vacation" or "2021-10 Yellowstone Trip")
+
+If you can't determine a date, use just the description (e.g., "Family Photos").
+
+Reply with ONLY the album name, nothing else.`,
+		strings.Join(relevantParts, " / "),
+		strings.Join(sampleNames, ", "),
+		tagged)
+}
+
+// cleanSuggestion strips quotes and chatty prefixes from a model's reply
+func cleanSuggestion(reply string) string {
+	suggestion := strings.TrimSpace(reply)
+	suggestion = strings.Trim(suggestion, `"'`)
+
+	// Remove common prefixes
+	for _, prefix := range []string{"Album name: ", "Suggested album name: ", "I suggest: "} {
+		suggestion = strings.TrimPrefix(suggestion, prefix)
+	}
+
+	return strings.TrimSpace(suggestion)
+}
+
+// namingContext applies the configured suggestion timeout to ctx
+func namingContext(ctx context.Context, config *Config) (context.Context, context.CancelFunc) {
+	timeout := config.NamingTimeout
+	if timeout <= 0 {
+		timeout = defaultNamingTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// postJSON posts a JSON body, retrying 5xx responses and dropped connections up to
+// config.NamingRetries times. A non-200 response is returned as an error.
+func postJSON(ctx context.Context, config *Config, url string, header http.Header, body []byte, what string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header.Clone()
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = http.DefaultClient.Do(req)
+
+		reason := retryReason(resp, err)
+		if reason == "" || attempt >= config.NamingRetries {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		delay := namingRetryDelay << attempt
+		debugLog.Printf("%s: %s, retrying in %v (%d/%d)", what, reason, delay, attempt+1, config.NamingRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s returned status %d: %s", what, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// retryReason describes a transient failure worth retrying (5xx status, connection reset
+// or closed by the server), "" for success and for errors a retry won't fix (4xx, timeout)
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return err.Error()
+		}
+		return ""
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	return ""
+}
+
+// checkAvailable sends a quick GET and reports whether it succeeds
+func checkAvailable(ctx context.Context, url string, header http.Header) bool {
+	ctx, cancel := context.WithTimeout(ctx, namingCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	req.Header = header.Clone()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
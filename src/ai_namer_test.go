@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubOllama points the Ollama endpoints at a test server answering generate requests
+// with generate (the model list always answers)
+func stubOllama(t *testing.T, generate http.HandlerFunc) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[]}`)
+	})
+	mux.HandleFunc("/api/generate", generate)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	savedURL, savedTagsURL := ollamaURL, ollamaTagsURL
+	ollamaURL, ollamaTagsURL = srv.URL+"/api/generate", srv.URL+"/api/tags"
+	t.Cleanup(func() { ollamaURL, ollamaTagsURL = savedURL, savedTagsURL })
+}
+
+// writeOllamaReply streams reply the way Ollama does, one JSON chunk per word
+func writeOllamaReply(w http.ResponseWriter, reply string) {
+	enc := json.NewEncoder(w)
+	for i, word := range splitKeepSpaces(reply) {
+		enc.Encode(ollamaResponse{Response: word})
+		if i == 0 {
+			w.(http.Flusher).Flush()
+		}
+	}
+	enc.Encode(ollamaResponse{Done: true})
+}
+
+// splitKeepSpaces splits s after each space, so the parts join back into s
+func splitKeepSpaces(s string) []string {
+	var parts []string
+	start := 0
+	for i, r := range s {
+		if r == ' ' {
+			parts = append(parts, s[start:i+1])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// namingTestFiles returns three dated photos in each of the named folders below root
+func namingTestFiles(root string, folders ...string) []*MediaFile {
+	date := time.Date(2019, 7, 4, 12, 0, 0, 0, time.Local)
+	var files []*MediaFile
+	for _, folder := range folders {
+		for i := range 3 {
+			files = append(files, &MediaFile{
+				Path:      filepath.Join(root, "scan", folder, fmt.Sprintf("IMG_%d.jpg", i)),
+				Type:      TypePhoto,
+				DateTaken: &date,
+			})
+		}
+	}
+	return files
+}
+
+// albumNames returns the names of albums by their source folder's base name
+func albumNames(albums []*Album) map[string]string {
+	names := make(map[string]string)
+	for _, album := range albums {
+		for _, dir := range album.SourceDirs {
+			names[filepath.Base(dir)] = album.Name
+		}
+	}
+	return names
+}
+
+func TestNamingTimeoutFallsBackToFolderName(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release // Hangs until the test is over
+	})
+	t.Cleanup(func() { close(release) })
+
+	root := t.TempDir()
+	config := &Config{
+		LibraryBase:   filepath.Join(root, "library"),
+		NoDateFolder:  defaultNoDateFolder,
+		AlbumNaming:   AlbumNamingOllama,
+		OllamaModel:   "stub",
+		NamingTimeout: 100 * time.Millisecond,
+		NamingWorkers: 1,
+	}
+	start := time.Now()
+	albums, err := OrganizeIntoAlbums(t.Context(), namingTestFiles(root, "Beach Day", "DCIM"), config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("organizing took %v, want it cut off by the 100ms timeout", elapsed)
+	}
+
+	names := albumNames(albums)
+	for folder, want := range map[string]string{
+		"Beach Day": fallbackAlbumName("Beach Day", "2019-07"),
+		"DCIM":      fallbackAlbumName("DCIM", "2019-07"),
+	} {
+		if names[folder] != want {
+			t.Errorf("%s named %q, want %q", folder, names[folder], want)
+		}
+	}
+	// After a timeout the remaining folders aren't sent to the stuck backend
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d naming requests, want 1", n)
+	}
+}
+
+func TestNamingRetriesTransientFailures(t *testing.T) {
+	var requests atomic.Int32
+	stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "stub" || !req.Stream {
+			t.Errorf("request = %+v, %v", req, err)
+		}
+		switch requests.Add(1) {
+		case 1:
+			http.Error(w, "model is loading", http.StatusInternalServerError)
+		case 2:
+			conn, _, _ := w.(http.Hijacker).Hijack() // Connection dropped
+			conn.Close()
+		default:
+			writeOllamaReply(w, "2019-07 Beach Trip")
+		}
+	})
+	config := &Config{OllamaModel: "stub", NamingRetries: 2}
+	namer := &OllamaNamer{config: config}
+
+	got, err := namer.Suggest(t.Context(), "/photos/Beach Day", []string{"/photos/Beach Day/IMG_1.jpg"}, nil)
+	if err != nil || got != "2019-07 Beach Trip" {
+		t.Errorf("Suggest = %q, %v, want the third attempt's reply", got, err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+}
+
+func TestNamingDoesNotRetryClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, `model "stub" not found`, http.StatusNotFound)
+	})
+	namer := &OllamaNamer{config: &Config{OllamaModel: "stub", NamingRetries: 2}}
+
+	if got, err := namer.Suggest(t.Context(), "/photos/Beach Day", nil, nil); err == nil {
+		t.Errorf("Suggest = %q, want the 404 reported", got)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want 1 (a 4xx isn't retried)", n)
+	}
+}
+
+func TestNamingBackends(t *testing.T) {
+	const folder = "/photos/2019/Beach Day"
+	samples := []string{folder + "/IMG_1.jpg", folder + "/IMG_2.jpg"}
+
+	t.Run("ollama", func(t *testing.T) {
+		stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+			var req ollamaRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			if req.Model != "llama3" || !strings.Contains(req.Prompt, "Beach Day") || !strings.Contains(req.Prompt, "IMG_1.jpg") {
+				t.Errorf("request = %+v", req)
+			}
+			writeOllamaReply(w, `"Album name: 2019-07 Beach Day"`)
+		})
+		namer := NewAlbumNamer(&Config{OllamaModel: "llama3"})
+		if _, ok := namer.(*OllamaNamer); !ok {
+			t.Fatalf("default backend is %T, want *OllamaNamer", namer)
+		}
+		if !namer.Available(t.Context()) {
+			t.Errorf("stub Ollama not available")
+		}
+		if got, err := namer.Suggest(t.Context(), folder, samples, nil); err != nil || got != "2019-07 Beach Day" {
+			t.Errorf("Suggest = %q, %v, want the cleaned reply", got, err)
+		}
+	})
+
+	t.Run("openai", func(t *testing.T) {
+		t.Setenv(openAIKeyEnv, "sk-test")
+		mux := http.NewServeMux()
+		authorized := func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer sk-test" }
+		mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+			if !authorized(r) {
+				http.Error(w, "bad key", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"data":[]}`)
+		})
+		mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+			var req openAIRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			if !authorized(r) || req.Model != "gpt-4o-mini" || len(req.Messages) != 1 || req.Messages[0].Role != "user" ||
+				!strings.Contains(req.Messages[0].Content, "Beach Day") {
+				t.Errorf("request = %+v (authorization %q)", req, r.Header.Get("Authorization"))
+			}
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"2019-07 Beach Day\n"}}]}`)
+		})
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+
+		config := &Config{NamingProvider: NamingOpenAI, OpenAIBaseURL: srv.URL + "/v1/", OpenAIModel: "gpt-4o-mini"}
+		namer := NewAlbumNamer(config)
+		if _, ok := namer.(*OpenAINamer); !ok {
+			t.Fatalf("openai backend is %T, want *OpenAINamer", namer)
+		}
+		if namer.Model() == config.OpenAIModel {
+			t.Errorf("model %q not kept apart from an Ollama model of the same name", namer.Model())
+		}
+		if !namer.Available(t.Context()) {
+			t.Errorf("stub API not available")
+		}
+		if got, err := namer.Suggest(t.Context(), folder, samples, nil); err != nil || got != "2019-07 Beach Day" {
+			t.Errorf("Suggest = %q, %v", got, err)
+		}
+
+		// A rejected key makes the backend unavailable
+		t.Setenv(openAIKeyEnv, "sk-wrong")
+		if namer.Available(t.Context()) {
+			t.Errorf("available with a rejected key")
+		}
+	})
+}
+
+func TestVisionNamingSendsThumbnails(t *testing.T) {
+	dir := t.TempDir()
+	var samples []string
+	for i := range 4 {
+		mf := writeTestJPEG(t, filepath.Join(dir, fmt.Sprintf("IMG_%d.jpg", i)), waveImage(1600, 1200), 90)
+		samples = append(samples, mf.Path)
+	}
+	raw := filepath.Join(dir, "IMG_9.cr2")
+	if err := os.WriteFile(raw, []byte("not decodable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var requests []ollamaRequest
+	stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		if req.Model == "missing-vision" {
+			http.Error(w, "model not found", http.StatusNotFound)
+			return
+		}
+		writeOllamaReply(w, "2019-07 Seaside")
+	})
+
+	suggest := func(visionModel string, samples []string) (string, []ollamaRequest) {
+		t.Helper()
+		mu.Lock()
+		requests = nil
+		mu.Unlock()
+		namer := NewAlbumNamer(&Config{OllamaModel: "llama3", VisionModel: visionModel})
+		got, err := namer.Suggest(t.Context(), dir, samples, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return got, requests
+	}
+
+	// Thumbnails of the first visionSampleImages photos go to the vision model
+	got, reqs := suggest("llava", samples)
+	if got != "2019-07 Seaside" || len(reqs) != 1 {
+		t.Fatalf("Suggest = %q after %d requests, want 1", got, len(reqs))
+	}
+	if reqs[0].Model != "llava" || len(reqs[0].Images) != visionSampleImages {
+		t.Fatalf("request to %q with %d images, want llava with %d", reqs[0].Model, len(reqs[0].Images), visionSampleImages)
+	}
+	for i, encoded := range reqs[0].Images {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatalf("image %d: %v", i, err)
+		}
+		img, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("image %d isn't a JPEG: %v", i, err)
+		}
+		if img.Width != visionImageSize || img.Height != visionImageSize*3/4 {
+			t.Errorf("image %d is %dx%d, want %dx%d", i, img.Width, img.Height, visionImageSize, visionImageSize*3/4)
+		}
+	}
+
+	// Without a decodable photo the text model is asked, without images
+	if _, reqs = suggest("llava", []string{raw}); len(reqs) != 1 || reqs[0].Model != "llama3" || len(reqs[0].Images) != 0 {
+		t.Errorf("undecodable samples: requests %+v, want one text request", reqs)
+	}
+
+	// A vision model that isn't there falls back to the text model
+	got, reqs = suggest("missing-vision", samples)
+	if got != "2019-07 Seaside" || len(reqs) != 2 || reqs[1].Model != "llama3" || len(reqs[1].Images) != 0 {
+		t.Errorf("missing vision model: %q after %d requests, want the text model's answer", got, len(reqs))
+	}
+}
+
+func TestNamingWorkersCacheEverySuggestion(t *testing.T) {
+	const folders, workers = 10, 3
+	var inFlight, maxInFlight, requests atomic.Int32
+	folderLine := regexp.MustCompile(`path: (.*)\n`)
+	stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		requests.Add(1)
+
+		var req ollamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		match := folderLine.FindStringSubmatch(req.Prompt)
+		if match == nil {
+			http.Error(w, "no folder in the prompt", http.StatusBadRequest)
+			return
+		}
+		parts := strings.Split(match[1], " / ")
+		time.Sleep(20 * time.Millisecond) // Long enough for requests to overlap
+		writeOllamaReply(w, "2019-07 Named "+parts[len(parts)-1])
+	})
+
+	root := t.TempDir()
+	var names []string
+	for i := range folders {
+		names = append(names, fmt.Sprintf("Folder %02d", i))
+	}
+	files := namingTestFiles(root, names...)
+	config := &Config{
+		LibraryBase:   filepath.Join(root, "library"),
+		NoDateFolder:  defaultNoDateFolder,
+		AlbumNaming:   AlbumNamingOllama,
+		OllamaModel:   "stub",
+		NamingWorkers: workers,
+	}
+	cache := openTestCache(t)
+	albumCache, err := OpenAlbumSuggestionCache(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, albumCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != folders {
+		t.Errorf("%d requests, want %d", n, folders)
+	}
+	if peak := maxInFlight.Load(); peak > workers || peak < 2 {
+		t.Errorf("up to %d requests at once, want 2 to %d", peak, workers)
+	}
+
+	// Each folder gets its own suggestion whatever order they arrive in, and it's cached
+	got := albumNames(albums)
+	cache.flush()
+	for _, name := range names {
+		if want := "2019-07 Named " + name; got[name] != want {
+			t.Errorf("%s named %q, want %q", name, got[name], want)
+		}
+		dir := filepath.Join(root, "scan", name)
+		var samples []string
+		for _, mf := range files {
+			if filepath.Dir(mf.Path) == dir {
+				samples = append(samples, mf.Path)
+			}
+		}
+		if cached, ok := albumCache.Get(dir, samples, config.OllamaModel); !ok || cached != got[name] {
+			t.Errorf("%s: cached %q, %v, want %q", name, cached, ok, got[name])
+		}
+	}
+
+	// The next run is answered from the cache alone
+	requests.Store(0)
+	if _, err := OrganizeIntoAlbums(t.Context(), files, config, nil, albumCache); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("second run sent %d requests, want 0", n)
+	}
+}
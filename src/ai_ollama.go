@@ -1,21 +1,24 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"path/filepath"
 	"strings"
 )
 
-const ollamaURL = "http://localhost:11434/api/generate"
+// Ollama endpoints (variables so tests can point them at a stub server)
+var (
+	ollamaURL     = "http://localhost:11434/api/generate"
+	ollamaTagsURL = "http://localhost:11434/api/tags"
+)
 
 type ollamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	Images []string `json:"images,omitempty"` // Base64 encoded, for multimodal models
+	Stream bool     `json:"stream"`
 }
 
 type ollamaResponse struct {
@@ -23,50 +26,30 @@ type ollamaResponse struct {
 	Done     bool   `json:"done"`
 }
 
-// SuggestAlbumName uses Ollama to suggest an album name
-func SuggestAlbumName(model, folderPath string, sampleFiles []string) (string, error) {
-	// Extract folder names from path
-	parts := strings.Split(folderPath, string(filepath.Separator))
-	var relevantParts []string
-	for _, part := range parts {
-		if part != "" && !strings.HasPrefix(part, ".") &&
-			part != "Volumes" && part != "TimeMachine" {
-			relevantParts = append(relevantParts, part)
-		}
-	}
-
-	// Take last 3 parts
-	if len(relevantParts) > 3 {
-		relevantParts = relevantParts[len(relevantParts)-3:]
-	}
-
-	// Get sample filenames
-	var sampleNames []string
-	for i, f := range sampleFiles {
-		if i >= 5 {
-			break
-		}
-		sampleNames = append(sampleNames, filepath.Base(f))
-	}
-
-	// Create prompt
-	prompt := fmt.Sprintf(`Given these folder names from a photo/video path: %s
-
-And these sample filenames: %s
+// OllamaNamer suggests album names with a local Ollama model (config.OllamaModel)
+type OllamaNamer struct {
+	config *Config
+}
 
-Suggest a good album name in format: YYYY-MM Description (e.g., "2005-06 Cyprus Vacation" or "2021-10 Yellowstone Trip")
+func (o *OllamaNamer) Model() string { return o.config.OllamaModel }
 
-If you can't determine a date, use just the description (e.g., "Family Photos").
+// Available checks if Ollama is running
+func (o *OllamaNamer) Available(ctx context.Context) bool {
+	return checkAvailable(ctx, ollamaTagsURL, nil)
+}
 
-Reply with ONLY the album name, nothing else.`,
-		strings.Join(relevantParts, " / "),
-		strings.Join(sampleNames, ", "))
+// Suggest streams a suggestion from Ollama, abandoned when ctx is cancelled or the naming timeout passes
+func (o *OllamaNamer) Suggest(ctx context.Context, folderPath string, sampleFiles, keywords []string) (string, error) {
+	return o.generate(ctx, o.config.OllamaModel, albumNamePrompt(folderPath, sampleFiles, keywords), nil)
+}
 
-	// Call Ollama
+// generate streams a reply to prompt (with optional base64 images) from an Ollama model
+func (o *OllamaNamer) generate(ctx context.Context, model, prompt string, images []string) (string, error) {
 	reqBody := ollamaRequest{
 		Model:  model,
 		Prompt: prompt,
-		Stream: false,
+		Images: images,
+		Stream: true,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -74,40 +57,33 @@ Reply with ONLY the album name, nothing else.`,
 		return "", err
 	}
 
-	resp, err := http.Post(ollamaURL, "application/json", bytes.NewBuffer(jsonData))
+	ctx, cancel := namingContext(ctx, o.config)
+	defer cancel()
+
+	resp, err := postJSON(ctx, o.config, ollamaURL, http.Header{}, jsonData, "ollama")
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var ollamaResp ollamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return "", err
-	}
-
-	// Clean up response
-	suggestion := strings.TrimSpace(ollamaResp.Response)
-	suggestion = strings.Trim(suggestion, `"'`)
-
-	// Remove common prefixes
-	for _, prefix := range []string{"Album name: ", "Suggested album name: ", "I suggest: "} {
-		suggestion = strings.TrimPrefix(suggestion, prefix)
+	// Streamed as one JSON object per chunk until done
+	var response strings.Builder
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaResponse
+		if err := dec.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err() // Reading was cut off by the timeout or cancellation
+			}
+			return "", err
+		}
+		response.WriteString(chunk.Response)
+		if chunk.Done {
+			break
+		}
 	}
 
-	return strings.TrimSpace(suggestion), nil
-}
-
-// CheckOllamaAvailable checks if Ollama is running
-func CheckOllamaAvailable() bool {
-	resp, err := http.Get("http://localhost:11434/api/tags")
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+	return cleanSuggestion(response.String()), nil
 }
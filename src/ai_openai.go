@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// defaultOpenAIBaseURL is used when openai_base_url is not set
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+	// openAIKeyEnv holds the API key (left out of the config file on purpose)
+	openAIKeyEnv = "OPENAI_API_KEY"
+)
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// OpenAINamer suggests album names through an OpenAI-compatible chat completions API
+// (config.OpenAIBaseURL and config.OpenAIModel, key from $OPENAI_API_KEY)
+type OpenAINamer struct {
+	config *Config
+}
+
+// Model is prefixed so cached suggestions don't mix with an Ollama model of the same name
+func (o *OpenAINamer) Model() string { return "openai:" + o.config.OpenAIModel }
+
+// Available checks that the API answers the model list with our key
+func (o *OpenAINamer) Available(ctx context.Context) bool {
+	return checkAvailable(ctx, o.url("models"), o.header())
+}
+
+// Suggest asks the chat completions endpoint for an album name
+func (o *OpenAINamer) Suggest(ctx context.Context, folderPath string, sampleFiles, keywords []string) (string, error) {
+	reqBody := openAIRequest{
+		Model:    o.config.OpenAIModel,
+		Messages: []openAIMessage{{Role: "user", Content: albumNamePrompt(folderPath, sampleFiles, keywords)}},
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := namingContext(ctx, o.config)
+	defer cancel()
+
+	resp, err := postJSON(ctx, o.config, o.url("chat/completions"), o.header(), jsonData, "openai")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var completion openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("openai returned no choices")
+	}
+
+	return cleanSuggestion(completion.Choices[0].Message.Content), nil
+}
+
+// url joins an API path to the base URL
+func (o *OpenAINamer) url(path string) string {
+	base := o.config.OpenAIBaseURL
+	if base == "" {
+		base = defaultOpenAIBaseURL
+	}
+	return strings.TrimRight(base, "/") + "/" + path
+}
+
+// header authenticates with the key from the environment, if any (local gateways may not need one)
+func (o *OpenAINamer) header() http.Header {
+	header := http.Header{}
+	if key := os.Getenv(openAIKeyEnv); key != "" {
+		header.Set("Authorization", "Bearer "+key)
+	}
+	return header
+}
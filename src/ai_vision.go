@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+)
+
+const (
+	// visionSampleImages is how many of a folder's sample photos are shown to the model
+	visionSampleImages = 3
+
+	// visionImageSize bounds the longer side of a thumbnail, plenty for a model to tell the scene
+	visionImageSize = 512
+)
+
+// VisionNamer shows a multimodal Ollama model (config.VisionModel) thumbnails of a folder's
+// sample photos, which helps with meaningless folder names like DCIM/100CANON. Folders
+// without decodable photos, and failed vision requests, fall back to the text model.
+type VisionNamer struct {
+	text *OllamaNamer
+}
+
+// Model keeps vision suggestions apart from text-only ones in the cache, where they are
+// also tied to the sample files they were made from
+func (v *VisionNamer) Model() string { return "vision:" + v.text.config.VisionModel }
+
+// Available checks if Ollama is running
+func (v *VisionNamer) Available(ctx context.Context) bool {
+	return v.text.Available(ctx)
+}
+
+// Suggest asks the vision model about thumbnails of the sample photos
+func (v *VisionNamer) Suggest(ctx context.Context, folderPath string, sampleFiles, keywords []string) (string, error) {
+	var images []string
+	for _, path := range sampleFiles {
+		if len(images) == visionSampleImages {
+			break
+		}
+		if thumb, err := thumbnailJPEG(path, visionImageSize); err == nil {
+			images = append(images, base64.StdEncoding.EncodeToString(thumb))
+		}
+	}
+	if len(images) == 0 {
+		return v.text.Suggest(ctx, folderPath, sampleFiles, keywords)
+	}
+
+	prompt := "The attached images are sample photos from one folder; name the album after what they show.\n\n" +
+		albumNamePrompt(folderPath, sampleFiles, keywords)
+	suggestion, err := v.text.generate(ctx, v.text.config.VisionModel, prompt, images)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		// E.g. the vision model isn't pulled, the text model may still do
+		debugLog.Printf("vision: %v, falling back to %s", err, v.text.config.OllamaModel)
+		return v.text.Suggest(ctx, folderPath, sampleFiles, keywords)
+	}
+	return suggestion, err
+}
+
+// thumbnailJPEG decodes an image and re-encodes it as a JPEG whose longer side is at most
+// size pixels (fails for formats without a decoder, e.g. RAW and HEIC)
+func thumbnailJPEG(path string, size int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscale(img, size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale shrinks img by nearest-neighbour sampling so it fits in size x size
+// (smaller images are returned as they are)
+func downscale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return img
+	}
+
+	w, h := size, b.Dy()*size/b.Dx()
+	if b.Dy() > b.Dx() {
+		w, h = b.Dx()*size/b.Dy(), size
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/w, sy))
+		}
+	}
+	return dst
+}
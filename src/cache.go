@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,25 +17,46 @@ type cacheWriteRequest struct {
 	mf      *MediaFile
 	modTime time.Time
 	oldPath string // For path updates (empty if new file)
+	deleted string // Path whose entry is removed (file gone, e.g. moved to the trash)
 
 	// For album suggestion cache writes
 	isAlbumSuggestion bool
 	folderPath        string
 	sampleFiles       []string
+	model             string
 	suggestion        string
+
+	flushed chan struct{} // Closed once all earlier writes are done (see flush)
 }
 
 type Cache struct {
-	db         *sql.DB
-	writeChan  chan cacheWriteRequest
-	writerDone sync.WaitGroup
+	db          *sql.DB
+	readDB      *sql.DB // Optional read-only connection pool (nil = reads use db)
+	dbPath      string
+	libraryBase string // Library the cache was opened for (exports store paths relative to it)
+	writeChan   chan cacheWriteRequest
+	writerDone  sync.WaitGroup
+	closeMu     sync.RWMutex // Held for reading while queueing, so Close can't close writeChan mid-send
+	closed      bool
 }
 
+const (
+	// A batch of queued writes is committed once it holds cacheWriteBatch writes or its
+	// first write has waited cacheWriteDelay, whichever comes first
+	cacheWriteBatch = 500
+	cacheWriteDelay = 200 * time.Millisecond
+
+	// cacheWriteTimeout bounds how long a write waits for room in the queue
+	// (the writer only stalls this long if the database is locked elsewhere)
+	cacheWriteTimeout = 30 * time.Second
+)
+
 type CachedFile struct {
 	Path        string
 	Size        int64
 	ModTime     int64
 	Hash        string
+	HashAlgo    string
 	DateTaken   *time.Time
 	CameraMake  string
 	CameraModel string
@@ -43,17 +65,32 @@ type CachedFile struct {
 	Title       string
 	Width       int
 	Height      int
+	Orientation int
+	GPS         *GPSCoord
+	Duration    time.Duration
+	ImageClass  string
+	PHash       string
+	ContentHash string
+	Keywords    []string
+	SidecarTime int64 // Unix seconds, 0 if the file had no sidecar
 	ProcessedAt int64
 }
 
-// OpenCache opens or creates the cache database
-func OpenCache(libraryBase string) (*Cache, error) {
-	cacheDir := filepath.Join(libraryBase, ".media-organizer-cache")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+// cacheDirPath returns the directory holding the cache and undo journals
+func cacheDirPath(libraryBase string) string {
+	return filepath.Join(libraryBase, ".media-organizer-cache")
+}
+
+// OpenCache opens or creates the cache database of a library, at dbPath if given
+// (default cache.db in the library's cacheDirPath)
+func OpenCache(libraryBase, dbPath string) (*Cache, error) {
+	if dbPath == "" {
+		dbPath = filepath.Join(cacheDirPath(libraryBase), "cache.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return nil, fmt.Errorf("create cache dir: %w", err)
 	}
 
-	dbPath := filepath.Join(cacheDir, "cache.db")
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("open cache db: %w", err)
@@ -97,10 +134,17 @@ func OpenCache(libraryBase string) (*Cache, error) {
 		return nil, fmt.Errorf("create schema: %w", err)
 	}
 
+	if err := migrateCache(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate cache: %w", err)
+	}
+
 	// Create cache with write queue
 	cache := &Cache{
-		db:        db,
-		writeChan: make(chan cacheWriteRequest, 10000), // Buffer for 10000 pending writes
+		db:          db,
+		dbPath:      dbPath,
+		libraryBase: libraryBase,
+		writeChan:   make(chan cacheWriteRequest, 10000), // Buffer for 10000 pending writes
 	}
 
 	// Start single writer goroutine to serialize all writes
@@ -110,29 +154,403 @@ func OpenCache(libraryBase string) (*Cache, error) {
 	return cache, nil
 }
 
-// writerLoop handles all database writes in a single thread
+// cacheMigrations bring the cache up to date one version at a time: step i upgrades a
+// database at version i (stored as PRAGMA user_version) to i+1. Append a step for every
+// schema or cached value format change; never edit a released one. Steps list the values
+// they act on (e.g. extensions) instead of reading ones that can change, like the
+// extension maps that extra_extensions adds to.
+var cacheMigrations = []func(tx *sql.Tx) error{
+	// 1: GPS, duration and image class columns (added before versioning, so they may
+	// exist already); hashes are hex strings (were raw MD5 bytes)
+	func(tx *sql.Tx) error {
+		for _, col := range []struct{ name, def string }{
+			{"latitude", "REAL"},
+			{"longitude", "REAL"},
+			{"duration_ms", "INTEGER"},
+			{"image_class", "TEXT"},
+		} {
+			if err := ensureColumn(tx, "files", col.name, col.def); err != nil {
+				return err
+			}
+		}
+		// Binary hashes are recomputed on the next run
+		_, err := tx.Exec("UPDATE files SET hash = '' WHERE hash IS NOT NULL")
+		return err
+	},
+
+	// 2: music tags are extracted (untagged music entries are re-read)
+	func(tx *sql.Tx) error {
+		exts := []string{".mp3", ".m4a", ".flac", ".wav", ".aac", ".ogg", ".wma", ".alac"}
+		query := fmt.Sprintf("DELETE FROM files WHERE COALESCE(artist, '') = '' AND COALESCE(album, '') = '' AND (%s)",
+			pathHasExtension(exts))
+		_, err := tx.Exec(query)
+		return err
+	},
+
+	// 3: hash_algorithm is recorded (older hashes are MD5); phash column (added at
+	// version 2 without a bump, so it may exist already)
+	func(tx *sql.Tx) error {
+		for _, col := range []string{"phash", "hash_algorithm"} {
+			if err := ensureColumn(tx, "files", col, "TEXT"); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("UPDATE files SET hash_algorithm = ? WHERE COALESCE(hash, '') != ''", HashMD5)
+		return err
+	},
+
+	// 4: HEIC/HEIF EXIF is read (entries dated by file time are re-read)
+	func(tx *sql.Tx) error {
+		exts := []string{".heic", ".heif"}
+		query := fmt.Sprintf("DELETE FROM files WHERE COALESCE(camera_make, '') = '' AND (%s)", pathHasExtension(exts))
+		_, err := tx.Exec(query)
+		return err
+	},
+
+	// 5: album suggestions live in the versioned schema, keyed by model (suggestions
+	// from a different model are cache misses)
+	func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS album_suggestions (
+				folder_path TEXT PRIMARY KEY,
+				sample_files TEXT NOT NULL,
+				suggestion TEXT NOT NULL,
+				created_at INTEGER NOT NULL
+			)
+		`); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "album_suggestions", "model", "TEXT NOT NULL DEFAULT ''")
+	},
+
+	// 6: CR2/NEF/ARW IFDs are walked directly (entries goexif couldn't read are re-read)
+	func(tx *sql.Tx) error {
+		exts := []string{".cr2", ".nef", ".arw"}
+		query := fmt.Sprintf("DELETE FROM files WHERE COALESCE(camera_make, '') = '' AND (%s)", pathHasExtension(exts))
+		_, err := tx.Exec(query)
+		return err
+	},
+
+	// 7: photo orientation is recorded and dimensions are as displayed (photos cached
+	// without it are re-oriented on their next scan, keeping their hashes)
+	func(tx *sql.Tx) error {
+		return ensureColumn(tx, "files", "orientation", "INTEGER")
+	},
+
+	// 8: folder listings of the last scan, for incremental scans
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS scan_dirs (
+				path TEXT PRIMARY KEY,
+				mod_time INTEGER NOT NULL,
+				scanned_at INTEGER NOT NULL,
+				entries TEXT NOT NULL
+			)
+		`)
+		return err
+	},
+
+	// 9: keywords from XMP sidecars, and the sidecar's mtime (a changed sidecar is read again)
+	func(tx *sql.Tx) error {
+		if err := ensureColumn(tx, "files", "keywords", "TEXT"); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "files", "sidecar_mod_time", "INTEGER")
+	},
+
+	// 10: content hash of JPEG/PNG image data, which metadata edits leave alone
+	func(tx *sql.Tx) error {
+		return ensureColumn(tx, "files", "content_hash", "TEXT")
+	},
+
+	// 11: album suggestions are keyed by folder and model, so each model's suggestion
+	// and the name typed during review are kept side by side
+	func(tx *sql.Tx) error {
+		for _, stmt := range []string{
+			`CREATE TABLE album_suggestions_new (
+				folder_path TEXT NOT NULL,
+				model TEXT NOT NULL DEFAULT '',
+				sample_files TEXT NOT NULL,
+				suggestion TEXT NOT NULL,
+				created_at INTEGER NOT NULL,
+				PRIMARY KEY (folder_path, model)
+			)`,
+			`INSERT INTO album_suggestions_new (folder_path, model, sample_files, suggestion, created_at)
+				SELECT folder_path, model, sample_files, suggestion, created_at FROM album_suggestions`,
+			`DROP TABLE album_suggestions`,
+			`ALTER TABLE album_suggestions_new RENAME TO album_suggestions`,
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	// 12: GPS is read for place naming (photos cached before without a location are
+	// re-read, as those cached without GPS extraction can't be told apart)
+	func(tx *sql.Tx) error {
+		exts := []string{".jpg", ".jpeg", ".jpe", ".tif", ".tiff", ".heic", ".heif", ".cr2", ".nef", ".arw"}
+		query := fmt.Sprintf("DELETE FROM files WHERE latitude IS NULL AND (%s)", pathHasExtension(exts))
+		_, err := tx.Exec(query)
+		return err
+	},
+
+	// 13: MP4/MOV mvhd is read (videos cached before have no duration and a file-time
+	// date, and are re-read; later entries record 0 when there is none)
+	func(tx *sql.Tx) error {
+		exts := []string{".mp4", ".mov", ".m4v", ".3gp"}
+		query := fmt.Sprintf("DELETE FROM files WHERE duration_ms IS NULL AND (%s)", pathHasExtension(exts))
+		_, err := tx.Exec(query)
+		return err
+	},
+}
+
+// cacheVersion is the schema version this build reads and writes
+var cacheVersion = len(cacheMigrations)
+
+// migrateCache applies the pending migrations, each in its own transaction together with
+// its version bump, so an interrupted upgrade resumes at the failed step. A cache written
+// by a newer build is refused rather than used with a schema we don't know.
+func migrateCache(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > cacheVersion {
+		return fmt.Errorf("cache schema version %d is newer than this build supports (%d); update media-organizer or delete the cache directory",
+			version, cacheVersion)
+	}
+
+	for ; version < cacheVersion; version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := cacheMigrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("to version %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pathHasExtension builds a WHERE clause matching files with any of the extensions
+func pathHasExtension(exts []string) string {
+	clauses := make([]string, len(exts))
+	for i, ext := range exts {
+		clauses[i] = fmt.Sprintf("path LIKE '%%%s'", ext)
+	}
+	return strings.Join(clauses, " OR ")
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func ensureColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// EnableReadPool opens a separate read-only connection pool so workers can read
+// concurrently without contending with the writer goroutine's connection
+func (c *Cache) EnableReadPool(conns int) error {
+	if conns < 1 {
+		conns = 1
+	}
+
+	dsn := fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(30000)", c.dbPath)
+	readDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("open read pool: %w", err)
+	}
+	readDB.SetMaxOpenConns(conns)
+	readDB.SetMaxIdleConns(conns)
+
+	if err := readDB.Ping(); err != nil {
+		readDB.Close()
+		return fmt.Errorf("open read pool: %w", err)
+	}
+
+	c.readDB = readDB
+	return nil
+}
+
+// reader returns the connection pool to use for reads
+func (c *Cache) reader() *sql.DB {
+	if c.readDB != nil {
+		return c.readDB
+	}
+	return c.db
+}
+
+// writerLoop handles all database writes in a single thread, in batches committed as
+// one transaction each (flush requests and Close commit a partial batch right away)
 func (c *Cache) writerLoop() {
 	defer c.writerDone.Done()
 
 	for req := range c.writeChan {
-		if req.isAlbumSuggestion {
-			// Handle album suggestion write
-			c.writeAlbumSuggestion(req.folderPath, req.sampleFiles, req.suggestion)
-		} else {
-			// Handle file metadata write
-			c.writeToDatabase(req.mf, req.modTime, req.oldPath)
+		batch := []cacheWriteRequest{req}
+		timer := time.NewTimer(cacheWriteDelay)
+	collect:
+		for len(batch) < cacheWriteBatch && batch[len(batch)-1].flushed == nil {
+			select {
+			case next, ok := <-c.writeChan:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		c.writeBatch(batch)
+	}
+}
+
+// writeBatch writes queued requests in one transaction; flush markers are released once
+// the writes before them are committed
+func (c *Cache) writeBatch(batch []cacheWriteRequest) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		warnLog.Printf("cache: transaction failed: %v", err)
+	} else {
+		for _, req := range batch {
+			if req.flushed != nil {
+				continue
+			} else if req.deleted != "" {
+				if _, err := tx.Exec("DELETE FROM files WHERE path = ?", req.deleted); err != nil {
+					warnLog.Printf("cache: delete failed for %s: %v", req.deleted, err)
+				}
+			} else if req.isAlbumSuggestion {
+				// Handle album suggestion write
+				writeAlbumSuggestion(tx, req.folderPath, req.sampleFiles, req.model, req.suggestion)
+			} else {
+				// Handle file metadata write
+				writeToDatabase(tx, req.mf, req.modTime, req.oldPath)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			warnLog.Printf("cache: commit failed for %d writes: %v", len(batch), err)
+		}
+	}
+
+	for _, req := range batch {
+		if req.flushed != nil {
+			close(req.flushed)
+		}
+	}
+}
+
+// enqueue hands a write to the writer goroutine, waiting up to cacheWriteTimeout for
+// room in the queue rather than dropping it
+func (c *Cache) enqueue(req cacheWriteRequest) error {
+	c.closeMu.RLock()
+	defer c.closeMu.RUnlock()
+	if c.closed {
+		return fmt.Errorf("cache closed")
+	}
+
+	select {
+	case c.writeChan <- req:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(cacheWriteTimeout)
+	defer timer.Stop()
+	select {
+	case c.writeChan <- req:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("cache write queue stalled")
+	}
+}
+
+// flush waits until the writer has committed all queued writes
+func (c *Cache) flush() {
+	done := make(chan struct{})
+	if c.enqueue(cacheWriteRequest{flushed: done}) == nil {
+		<-done
+	}
+}
+
+// Compact rebuilds the database to give the space of deleted rows back to the file
+// system (SQLite keeps it for reuse otherwise) and truncates the WAL. Queued writes
+// are finished first. Returns the number of bytes reclaimed.
+func (c *Cache) Compact() (int64, error) {
+	c.flush()
+	before := c.fileSize()
+
+	if _, err := c.db.Exec("VACUUM"); err != nil {
+		return 0, fmt.Errorf("vacuum: %w", err)
+	}
+	if _, err := c.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return 0, fmt.Errorf("checkpoint: %w", err)
+	}
+
+	return before - c.fileSize(), nil
+}
+
+// fileSize returns the size of the database file plus its WAL
+func (c *Cache) fileSize() int64 {
+	var size int64
+	for _, path := range []string{c.dbPath, c.dbPath + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
 		}
 	}
+	return size
 }
 
 // Close closes the cache database
 func (c *Cache) Close() error {
-	// Close write channel and wait for pending writes
-	if c.writeChan != nil {
+	// Close write channel (once no write is being queued) and wait for pending writes
+	c.closeMu.Lock()
+	wasClosed := c.closed
+	c.closed = true
+	if c.writeChan != nil && !wasClosed {
 		close(c.writeChan)
-		c.writerDone.Wait()
 	}
+	c.closeMu.Unlock()
+	c.writerDone.Wait()
 
+	if c.readDB != nil {
+		c.readDB.Close()
+	}
 	if c.db != nil {
 		return c.db.Close()
 	}
@@ -143,16 +561,21 @@ func (c *Cache) Close() error {
 func (c *Cache) Get(path string, size int64, modTime time.Time) (*CachedFile, bool) {
 	var cf CachedFile
 	var dateTakenUnix sql.NullInt64
+	var latitude, longitude sql.NullFloat64
+	var durationMs, orientation, sidecarTime sql.NullInt64
+	var imageClass, phash, hashAlgo, keywords, contentHash sql.NullString
 
-	err := c.db.QueryRow(`
+	err := c.reader().QueryRow(`
 		SELECT path, size, mod_time, hash, date_taken, camera_make, camera_model,
-		       artist, album, title, width, height, processed_at
+		       artist, album, title, width, height, orientation, latitude, longitude, duration_ms, image_class, phash, hash_algorithm,
+		       keywords, sidecar_mod_time, content_hash, processed_at
 		FROM files
 		WHERE path = ? AND size = ? AND mod_time = ?
 	`, path, size, modTime.Unix()).Scan(
 		&cf.Path, &cf.Size, &cf.ModTime, &cf.Hash, &dateTakenUnix,
 		&cf.CameraMake, &cf.CameraModel, &cf.Artist, &cf.Album, &cf.Title,
-		&cf.Width, &cf.Height, &cf.ProcessedAt,
+		&cf.Width, &cf.Height, &orientation, &latitude, &longitude, &durationMs, &imageClass, &phash, &hashAlgo,
+		&keywords, &sidecarTime, &contentHash, &cf.ProcessedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -168,104 +591,134 @@ func (c *Cache) Get(path string, size int64, modTime time.Time) (*CachedFile, bo
 		cf.DateTaken = &dt
 	}
 
+	if latitude.Valid && longitude.Valid {
+		cf.GPS = &GPSCoord{Lat: latitude.Float64, Lon: longitude.Float64}
+	}
+	if durationMs.Valid {
+		cf.Duration = time.Duration(durationMs.Int64) * time.Millisecond
+	}
+	cf.Orientation = int(orientation.Int64)
+	cf.ImageClass = imageClass.String
+	cf.PHash = phash.String
+	cf.HashAlgo = hashAlgo.String
+	if keywords.Valid {
+		json.Unmarshal([]byte(keywords.String), &cf.Keywords)
+	}
+	cf.SidecarTime = sidecarTime.Int64
+	cf.ContentHash = contentHash.String
+
 	return &cf, true
 }
 
-// Put queues file data for writing to cache (non-blocking)
+// Put queues file data for writing to cache (waits while the queue is full)
 func (c *Cache) Put(mf *MediaFile, modTime time.Time) error {
-	// Send to write queue (non-blocking if buffer full)
-	select {
-	case c.writeChan <- cacheWriteRequest{mf: mf, modTime: modTime}:
-		return nil
-	default:
-		// Channel full, skip this write (better than blocking)
-		return fmt.Errorf("cache write queue full")
-	}
+	// Send a snapshot to the write queue (callers keep mutating mf, e.g. Path after a move)
+	snapshot := *mf
+	return c.enqueue(cacheWriteRequest{mf: &snapshot, modTime: modTime})
 }
 
-// writeToDatabase performs the actual database write (called by writer goroutine)
-func (c *Cache) writeToDatabase(mf *MediaFile, modTime time.Time, oldPath string) {
+// writeToDatabase performs the actual database write in the writer's transaction
+func writeToDatabase(tx *sql.Tx, mf *MediaFile, modTime time.Time, oldPath string) {
 	var dateTakenUnix sql.NullInt64
 	if mf.DateTaken != nil {
 		dateTakenUnix.Valid = true
 		dateTakenUnix.Int64 = mf.DateTaken.Unix()
 	}
 
-	// Use a transaction for atomic delete+insert (only when updating path)
-	if oldPath != "" && oldPath != mf.Path {
-		tx, err := c.db.Begin()
-		if err != nil {
-			fmt.Printf("Warning: cache transaction failed for %s: %v\n", mf.Path, err)
-			return
-		}
-		defer tx.Rollback()
+	var latitude, longitude sql.NullFloat64
+	if mf.GPS != nil {
+		latitude = sql.NullFloat64{Float64: mf.GPS.Lat, Valid: true}
+		longitude = sql.NullFloat64{Float64: mf.GPS.Lon, Valid: true}
+	}
 
-		// Delete old path
-		_, err = tx.Exec("DELETE FROM files WHERE path = ?", oldPath)
-		if err != nil {
-			fmt.Printf("Warning: cache delete failed for %s: %v\n", oldPath, err)
-			return
-		}
+	hash, hashAlgo := mf.Hash, sql.NullString{String: mf.HashAlgo, Valid: mf.HashAlgo != ""}
+	if hash == uniqueHash {
+		hash, hashAlgo = "", sql.NullString{} // Only a marker, the file may get a duplicate later
+	}
 
-		// Insert new path
-		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO files
-			(path, size, mod_time, hash, date_taken, camera_make, camera_model,
-			 artist, album, title, width, height, processed_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, mf.Path, mf.Size, modTime.Unix(), mf.Hash, dateTakenUnix,
-			mf.CameraMake, mf.CameraModel, mf.Artist, mf.Album, mf.Title,
-			mf.Width, mf.Height, time.Now().Unix())
+	var orientation sql.NullInt64
+	if mf.Orientation != 0 {
+		orientation = sql.NullInt64{Int64: int64(mf.Orientation), Valid: true}
+	}
 
-		if err != nil {
-			fmt.Printf("Warning: cache write failed for %s: %v\n", mf.Path, err)
+	var imageClass, phash, contentHash sql.NullString
+	if mf.ImageClass != "" {
+		imageClass = sql.NullString{String: mf.ImageClass, Valid: true}
+	}
+	if mf.PHash != "" {
+		phash = sql.NullString{String: mf.PHash, Valid: true}
+	}
+	if mf.ContentHash != "" {
+		contentHash = sql.NullString{String: mf.ContentHash, Valid: true}
+	}
+
+	var keywords sql.NullString
+	if len(mf.Keywords) > 0 {
+		encoded, _ := json.Marshal(mf.Keywords)
+		keywords = sql.NullString{String: string(encoded), Valid: true}
+	}
+	var sidecarTime sql.NullInt64
+	if mf.Sidecar != "" {
+		sidecarTime = sql.NullInt64{Int64: mf.SidecarTime.Unix(), Valid: true}
+	}
+
+	// Moved file: drop the old path in the same transaction
+	if oldPath != "" && oldPath != mf.Path {
+		if _, err := tx.Exec("DELETE FROM files WHERE path = ?", oldPath); err != nil {
+			warnLog.Printf("cache: delete failed for %s: %v", oldPath, err)
 			return
 		}
+	}
 
-		if err := tx.Commit(); err != nil {
-			fmt.Printf("Warning: cache commit failed for %s: %v\n", mf.Path, err)
-		}
-	} else {
-		// Simple insert/update (no path change)
-		_, err := c.db.Exec(`
-			INSERT OR REPLACE INTO files
-			(path, size, mod_time, hash, date_taken, camera_make, camera_model,
-			 artist, album, title, width, height, processed_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, mf.Path, mf.Size, modTime.Unix(), mf.Hash, dateTakenUnix,
-			mf.CameraMake, mf.CameraModel, mf.Artist, mf.Album, mf.Title,
-			mf.Width, mf.Height, time.Now().Unix())
+	_, err := tx.Exec(`
+		INSERT OR REPLACE INTO files
+		(path, size, mod_time, hash, date_taken, camera_make, camera_model,
+		 artist, album, title, width, height, orientation, latitude, longitude, duration_ms, image_class, phash, hash_algorithm,
+		 keywords, sidecar_mod_time, content_hash, processed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, mf.Path, mf.Size, modTime.Unix(), hash, dateTakenUnix,
+		mf.CameraMake, mf.CameraModel, mf.Artist, mf.Album, mf.Title,
+		mf.Width, mf.Height, orientation, latitude, longitude, mf.Duration.Milliseconds(), imageClass, phash, hashAlgo,
+		keywords, sidecarTime, contentHash, time.Now().Unix())
 
-		if err != nil {
-			fmt.Printf("Warning: cache write failed for %s: %v\n", mf.Path, err)
-		}
+	if err != nil {
+		warnLog.Printf("cache: write failed for %s: %v", mf.Path, err)
 	}
 }
 
 // UpdatePath updates cache entry when a file is moved (for duplicate detection)
 func (c *Cache) UpdatePath(oldPath string, mf *MediaFile, modTime time.Time) {
 	// Queue both delete and insert (async, single writer will handle atomically)
-	select {
-	case c.writeChan <- cacheWriteRequest{mf: mf, modTime: modTime, oldPath: oldPath}:
-		// Queued successfully
-	default:
-		// Channel full, skip this update
-	}
+	snapshot := *mf
+	c.enqueue(cacheWriteRequest{mf: &snapshot, modTime: modTime, oldPath: oldPath})
+}
+
+// Delete queues the removal of a file's entry, for files that left the scanned folders
+// (e.g. duplicates moved to the trash, which scans skip)
+func (c *Cache) Delete(path string) {
+	c.enqueue(cacheWriteRequest{deleted: path})
 }
 
-// writeAlbumSuggestion performs album suggestion database write (called by writer goroutine)
-func (c *Cache) writeAlbumSuggestion(folderPath string, sampleFiles []string, suggestion string) {
+// RenamePath moves a cache entry to a new path right away (used by undo, when no
+// queued writes are pending)
+func (c *Cache) RenamePath(oldPath, newPath string) error {
+	_, err := c.db.Exec("UPDATE OR REPLACE files SET path = ? WHERE path = ?", newPath, oldPath)
+	return err
+}
+
+// writeAlbumSuggestion performs album suggestion database write in the writer's transaction
+func writeAlbumSuggestion(tx *sql.Tx, folderPath string, sampleFiles []string, model, suggestion string) {
 	samplesJSON, _ := json.Marshal(sampleFiles)
 
-	_, err := c.db.Exec(`
+	_, err := tx.Exec(`
 		INSERT OR REPLACE INTO album_suggestions
-		(folder_path, sample_files, suggestion, created_at)
-		VALUES (?, ?, ?, ?)
-	`, folderPath, string(samplesJSON), suggestion, time.Now().Unix())
+		(folder_path, sample_files, model, suggestion, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, folderPath, string(samplesJSON), model, suggestion, time.Now().Unix())
 
 	if err != nil {
 		// Log error but don't crash - cache is best-effort
-		fmt.Printf("Warning: album suggestion cache write failed for %s: %v\n", folderPath, err)
+		warnLog.Printf("cache: album suggestion write failed for %s: %v", folderPath, err)
 	}
 }
 
@@ -273,12 +726,51 @@ func (c *Cache) writeAlbumSuggestion(folderPath string, sampleFiles []string, su
 func (c *Cache) GetStats() (total, withHash, withMetadata int64) {
 	c.db.QueryRow("SELECT COUNT(*) FROM files").Scan(&total)
 	c.db.QueryRow("SELECT COUNT(*) FROM files WHERE hash IS NOT NULL AND hash != ''").Scan(&withHash)
-	c.db.QueryRow("SELECT COUNT(*) FROM files WHERE camera_make IS NOT NULL AND camera_make != ''").Scan(&withMetadata)
+	// Any extracted metadata counts (EXIF camera info, tags, dimensions, location, duration)
+	c.db.QueryRow(`
+		SELECT COUNT(*) FROM files
+		WHERE (camera_make IS NOT NULL AND camera_make != '')
+		   OR (artist IS NOT NULL AND artist != '')
+		   OR (album IS NOT NULL AND album != '')
+		   OR (title IS NOT NULL AND title != '')
+		   OR width > 0
+		   OR latitude IS NOT NULL
+		   OR duration_ms > 0
+	`).Scan(&withMetadata)
 	return
 }
 
-// PruneDeleted removes entries for files that no longer exist
-func (c *Cache) PruneDeleted(validPaths map[string]bool) (int64, error) {
+// FilesUnder returns the cached files below roots whose size is in sizes or whose content
+// hash is in contentHashes (path, size, mtime and hashes only), after queued writes are
+// committed
+func (c *Cache) FilesUnder(roots []string, sizes map[int64]bool, contentHashes map[string]bool) ([]*CachedFile, error) {
+	c.flush()
+	rows, err := c.reader().Query("SELECT path, size, mod_time, hash, hash_algorithm, content_hash FROM files")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []*CachedFile
+	for rows.Next() {
+		var cf CachedFile
+		var hash, hashAlgo, contentHash sql.NullString
+		if err := rows.Scan(&cf.Path, &cf.Size, &cf.ModTime, &hash, &hashAlgo, &contentHash); err != nil {
+			return nil, err
+		}
+		if (!sizes[cf.Size] && !contentHashes[contentHash.String]) || scanRootOf(cf.Path, roots) == "" {
+			continue
+		}
+		cf.Hash, cf.HashAlgo, cf.ContentHash = hash.String, hashAlgo.String, contentHash.String
+		files = append(files, &cf)
+	}
+	return files, rows.Err()
+}
+
+// PruneDeleted removes entries for files that no longer exist, limited to entries
+// under roots if given (a shared cache also holds other libraries' files). Entries below
+// keep are left alone: folders the scan didn't read.
+func (c *Cache) PruneDeleted(validPaths map[string]bool, roots []string, keep []string) (int64, error) {
 	// Get all paths from cache
 	rows, err := c.db.Query("SELECT path FROM files")
 	if err != nil {
@@ -292,7 +784,7 @@ func (c *Cache) PruneDeleted(validPaths map[string]bool) (int64, error) {
 		if err := rows.Scan(&path); err != nil {
 			continue
 		}
-		if !validPaths[path] {
+		if !validPaths[path] && (roots == nil || scanRootOf(path, roots) != "") && scanRootOf(path, keep) == "" {
 			toDelete = append(toDelete, path)
 		}
 	}
@@ -329,39 +821,25 @@ func (c *Cache) PruneDeleted(validPaths map[string]bool) (int64, error) {
 
 // AlbumSuggestionCache stores Ollama suggestions
 type AlbumSuggestionCache struct {
-	db    *sql.DB
-	cache *Cache // Reference to main cache for write queue access
+	cache *Cache // Reference to main cache for connections and write queue access
 }
 
-// OpenAlbumSuggestionCache opens the album suggestion cache
+// OpenAlbumSuggestionCache opens the album suggestion cache (its table is created by the
+// cache migrations)
 func OpenAlbumSuggestionCache(cache *Cache) (*AlbumSuggestionCache, error) {
-	// Create table for album suggestions
-	schema := `
-	CREATE TABLE IF NOT EXISTS album_suggestions (
-		folder_path TEXT PRIMARY KEY,
-		sample_files TEXT NOT NULL,
-		suggestion TEXT NOT NULL,
-		created_at INTEGER NOT NULL
-	);
-	`
-
-	if _, err := cache.db.Exec(schema); err != nil {
-		return nil, fmt.Errorf("create album suggestion schema: %w", err)
-	}
-
-	return &AlbumSuggestionCache{db: cache.db, cache: cache}, nil
+	return &AlbumSuggestionCache{cache: cache}, nil
 }
 
-// Get retrieves cached album suggestion
-func (a *AlbumSuggestionCache) Get(folderPath string, sampleFiles []string) (string, bool) {
+// Get retrieves cached album suggestion made by the given model
+func (a *AlbumSuggestionCache) Get(folderPath string, sampleFiles []string, model string) (string, bool) {
 	var suggestion string
 	var cachedSamples string
 
-	err := a.db.QueryRow(`
+	err := a.cache.reader().QueryRow(`
 		SELECT sample_files, suggestion
 		FROM album_suggestions
-		WHERE folder_path = ?
-	`, folderPath).Scan(&cachedSamples, &suggestion)
+		WHERE folder_path = ? AND model = ?
+	`, folderPath, model).Scan(&cachedSamples, &suggestion)
 
 	if err == sql.ErrNoRows {
 		return "", false
@@ -380,18 +858,36 @@ func (a *AlbumSuggestionCache) Get(folderPath string, sampleFiles []string) (str
 }
 
 // Put stores album suggestion (queued through write channel)
-func (a *AlbumSuggestionCache) Put(folderPath string, sampleFiles []string, suggestion string) error {
+func (a *AlbumSuggestionCache) Put(folderPath string, sampleFiles []string, model, suggestion string) error {
 	// Queue write through main cache's write channel for serialized access
-	select {
-	case a.cache.writeChan <- cacheWriteRequest{
+	return a.cache.enqueue(cacheWriteRequest{
 		isAlbumSuggestion: true,
 		folderPath:        folderPath,
 		sampleFiles:       sampleFiles,
+		model:             model,
 		suggestion:        suggestion,
-	}:
-		return nil
-	default:
-		// Channel full, skip this write (better than blocking)
-		return fmt.Errorf("cache write queue full")
+	})
+}
+
+// editedSuggestionModel marks album names typed by the user during review (they win over
+// any model's suggestion for the folder)
+const editedSuggestionModel = "user"
+
+// GetEdited returns the album name the user chose for a folder during an earlier review
+func (a *AlbumSuggestionCache) GetEdited(folderPath string) (string, bool) {
+	return a.Get(folderPath, nil, editedSuggestionModel)
+}
+
+// PutEdited remembers the album name the user chose for a folder
+func (a *AlbumSuggestionCache) PutEdited(folderPath, name string) error {
+	return a.Put(folderPath, nil, editedSuggestionModel, name)
+}
+
+// Clear removes all cached album suggestions
+func (a *AlbumSuggestionCache) Clear() (int64, error) {
+	result, err := a.cache.db.Exec("DELETE FROM album_suggestions")
+	if err != nil {
+		return 0, err
 	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// scanDirRacyWindow is how much older than its listing a folder's mtime must be to be
+// trusted: a file created in the same (possibly 2s, on FAT) timestamp tick as the
+// listing would leave the mtime unchanged
+const scanDirRacyWindow = 2 * time.Second
+
+// scanDir is one folder as the last scan listed it. While the folder's mtime stays the
+// same, no entry was added, removed or renamed, so the next scan takes the listing from
+// here instead of reading the folder again. Its files are still stat'ed: editing one in
+// place leaves the folder's mtime alone.
+type scanDir struct {
+	ModTime   int64 // UnixNano
+	ScannedAt int64 // UnixNano, when the folder was read
+	Entries   []scanDirEntry
+}
+
+// scanDirEntry is a file or subfolder of a scanDir
+type scanDirEntry struct {
+	Name string      `json:"n"`
+	Mode fs.FileMode `json:"m"`
+}
+
+// trusted reports whether the listing still holds for a folder whose mtime is now modTime
+func (d *scanDir) trusted(modTime time.Time) bool {
+	return d.ModTime == modTime.UnixNano() && d.ModTime < d.ScannedAt-int64(scanDirRacyWindow)
+}
+
+func newScanDirEntry(info os.FileInfo) scanDirEntry {
+	return scanDirEntry{Name: info.Name(), Mode: info.Mode()}
+}
+
+// LoadScanDirs returns the folder listings recorded by earlier scans, by path
+func (c *Cache) LoadScanDirs() (map[string]*scanDir, error) {
+	rows, err := c.reader().Query("SELECT path, mod_time, scanned_at, entries FROM scan_dirs")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dirs := make(map[string]*scanDir)
+	for rows.Next() {
+		var path, entries string
+		dir := &scanDir{}
+		if err := rows.Scan(&path, &dir.ModTime, &dir.ScannedAt, &entries); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(entries), &dir.Entries); err != nil {
+			continue // Read again by the next scan
+		}
+		dirs[path] = dir
+	}
+	return dirs, rows.Err()
+}
+
+// SaveScanDirs records the folder listings of a scan, replacing earlier ones
+func (c *Cache) SaveScanDirs(dirs map[string]*scanDir) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO scan_dirs (path, mod_time, scanned_at, entries) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for path, dir := range dirs {
+		entries, err := json.Marshal(dir.Entries)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(path, dir.ModTime, dir.ScannedAt, string(entries)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
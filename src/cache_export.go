@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// cacheExportRow is one files row in an exported cache. Paths inside the library are
+// stored relative to it, so the cache can be imported under a different library base.
+type cacheExportRow struct {
+	Path        string   `json:"path"`
+	Size        int64    `json:"size"`
+	ModTime     int64    `json:"mod_time"`
+	Hash        string   `json:"hash,omitempty"`
+	HashAlgo    string   `json:"hash_algorithm,omitempty"`
+	DateTaken   *int64   `json:"date_taken,omitempty"`
+	CameraMake  string   `json:"camera_make,omitempty"`
+	CameraModel string   `json:"camera_model,omitempty"`
+	Artist      string   `json:"artist,omitempty"`
+	Album       string   `json:"album,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Width       int64    `json:"width,omitempty"`
+	Height      int64    `json:"height,omitempty"`
+	Orientation int64    `json:"orientation,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+	DurationMs  int64    `json:"duration_ms,omitempty"`
+	ImageClass  string   `json:"image_class,omitempty"`
+	PHash       string   `json:"phash,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	SidecarTime *int64   `json:"sidecar_mod_time,omitempty"`
+	ContentHash string   `json:"content_hash,omitempty"`
+	ProcessedAt int64    `json:"processed_at"`
+}
+
+// ExportJSON streams the file metadata cache as JSON, one row object per line, after
+// queued writes are committed. Returns the number of rows written.
+func (c *Cache) ExportJSON(w io.Writer) (int, error) {
+	c.flush()
+
+	rows, err := c.reader().Query(`
+		SELECT path, size, mod_time, hash, hash_algorithm, date_taken, camera_make, camera_model,
+		       artist, album, title, width, height, orientation, latitude, longitude, duration_ms, image_class, phash,
+		       keywords, sidecar_mod_time, content_hash, processed_at
+		FROM files
+		ORDER BY path
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	base := c.libraryBase
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	count := 0
+	for rows.Next() {
+		var row cacheExportRow
+		var hash, hashAlgo, cameraMake, cameraModel, artist, album, title, imageClass, phash, keywords, contentHash sql.NullString
+		var dateTaken, width, height, orientation, durationMs, sidecarTime sql.NullInt64
+		var latitude, longitude sql.NullFloat64
+		if err := rows.Scan(&row.Path, &row.Size, &row.ModTime, &hash, &hashAlgo, &dateTaken, &cameraMake, &cameraModel,
+			&artist, &album, &title, &width, &height, &orientation, &latitude, &longitude, &durationMs, &imageClass, &phash,
+			&keywords, &sidecarTime, &contentHash, &row.ProcessedAt); err != nil {
+			return count, err
+		}
+
+		if rel, err := filepath.Rel(base, row.Path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			row.Path = filepath.ToSlash(rel)
+		}
+		row.Hash, row.HashAlgo = hash.String, hashAlgo.String
+		row.CameraMake, row.CameraModel = cameraMake.String, cameraModel.String
+		row.Artist, row.Album, row.Title = artist.String, album.String, title.String
+		row.Width, row.Height, row.Orientation, row.DurationMs = width.Int64, height.Int64, orientation.Int64, durationMs.Int64
+		row.ImageClass, row.PHash, row.ContentHash = imageClass.String, phash.String, contentHash.String
+		if dateTaken.Valid {
+			row.DateTaken = &dateTaken.Int64
+		}
+		if keywords.Valid {
+			json.Unmarshal([]byte(keywords.String), &row.Keywords)
+		}
+		if sidecarTime.Valid {
+			row.SidecarTime = &sidecarTime.Int64
+		}
+		if latitude.Valid && longitude.Valid {
+			row.Latitude, row.Longitude = &latitude.Float64, &longitude.Float64
+		}
+
+		if err := enc.Encode(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// ImportJSON upserts rows written by ExportJSON in one transaction. Relative paths are
+// placed under the library the cache was opened for. Returns the number of rows imported.
+func (c *Cache) ImportJSON(r io.Reader) (int, error) {
+	c.flush()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO files
+		(path, size, mod_time, hash, hash_algorithm, date_taken, camera_make, camera_model,
+		 artist, album, title, width, height, orientation, latitude, longitude, duration_ms, image_class, phash,
+		 keywords, sidecar_mod_time, content_hash, processed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	base := c.libraryBase
+	dec := json.NewDecoder(r)
+	count := 0
+	for {
+		var row cacheExportRow
+		if err := dec.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("row %d: %w", count+1, err)
+		}
+		if row.Path == "" {
+			return 0, fmt.Errorf("row %d: missing path", count+1)
+		}
+
+		path := filepath.FromSlash(row.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		hashAlgo := sql.NullString{String: row.HashAlgo, Valid: row.HashAlgo != ""}
+		imageClass := sql.NullString{String: row.ImageClass, Valid: row.ImageClass != ""}
+		phash := sql.NullString{String: row.PHash, Valid: row.PHash != ""}
+		contentHash := sql.NullString{String: row.ContentHash, Valid: row.ContentHash != ""}
+		orientation := sql.NullInt64{Int64: row.Orientation, Valid: row.Orientation != 0}
+		var keywords sql.NullString
+		if len(row.Keywords) > 0 {
+			encoded, _ := json.Marshal(row.Keywords)
+			keywords = sql.NullString{String: string(encoded), Valid: true}
+		}
+
+		if _, err := stmt.Exec(path, row.Size, row.ModTime, row.Hash, hashAlgo, row.DateTaken, row.CameraMake, row.CameraModel,
+			row.Artist, row.Album, row.Title, row.Width, row.Height, orientation, row.Latitude, row.Longitude, row.DurationMs,
+			imageClass, phash, keywords, row.SidecarTime, contentHash, row.ProcessedAt); err != nil {
+			return 0, fmt.Errorf("row %d: %w", count+1, err)
+		}
+		count++
+	}
+
+	return count, tx.Commit()
+}
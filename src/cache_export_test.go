@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheExportImportRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	library := filepath.Join(root, "library")
+	cache, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	modTime := time.Unix(1700000000, 0)
+	taken := time.Unix(1562241600, 0)
+	files := []*MediaFile{
+		{Path: filepath.Join(library, "Trip", "a.jpg"), Size: 10, Type: TypePhoto, Hash: "aa", HashAlgo: HashXXHash,
+			DateTaken: &taken, CameraMake: "Canon", CameraModel: "EOS R5", Width: 4000, Height: 3000, Orientation: 6,
+			GPS: &GPSCoord{Lat: 35.3, Lon: 25.1}, ImageClass: ImageClassPhoto, PHash: "ff00", ContentHash: "cc",
+			Keywords: []string{"beach", "crete"}, SidecarTime: modTime},
+		{Path: filepath.Join(library, "Music", "song.mp3"), Size: 20, Type: TypeMusic, Artist: "Pixies", Album: "Doolittle",
+			Title: "Debaser", Duration: 172 * time.Second},
+		{Path: filepath.Join(root, "scan", "b.png"), Size: 30, Type: TypePhoto}, // Outside the library
+	}
+	for _, mf := range files {
+		cache.Put(mf, modTime)
+	}
+	cache.flush()
+	wantTotal, wantHash, wantMetadata := cache.GetStats()
+	want := map[string]*CachedFile{}
+	for _, mf := range files {
+		cf, ok := cache.Get(mf.Path, mf.Size, modTime)
+		if !ok {
+			t.Fatalf("%s not cached", mf.Path)
+		}
+		want[mf.Path] = cf
+	}
+
+	var exported bytes.Buffer
+	if n, err := cache.ExportJSON(&exported); err != nil || n != len(files) {
+		t.Fatalf("exported %d rows, %v, want %d", n, err, len(files))
+	}
+
+	// Into the cleared cache
+	if _, err := cache.PruneDeleted(map[string]bool{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if total, _, _ := cache.GetStats(); total != 0 {
+		t.Fatalf("%d entries after clearing", total)
+	}
+	if n, err := cache.ImportJSON(bytes.NewReader(exported.Bytes())); err != nil || n != len(files) {
+		t.Fatalf("imported %d rows, %v, want %d", n, err, len(files))
+	}
+	if total, withHash, withMetadata := cache.GetStats(); total != wantTotal || withHash != wantHash || withMetadata != wantMetadata {
+		t.Errorf("stats after import %d/%d/%d, want %d/%d/%d", total, withHash, withMetadata, wantTotal, wantHash, wantMetadata)
+	}
+	for _, mf := range files {
+		if cf, ok := cache.Get(mf.Path, mf.Size, modTime); !ok || !reflect.DeepEqual(cf, want[mf.Path]) {
+			t.Errorf("%s after import: %+v, want %+v", mf.Path, cf, want[mf.Path])
+		}
+	}
+
+	// Into the cache of the library moved elsewhere: its paths follow, others stay
+	moved := filepath.Join(root, "moved")
+	other, err := OpenCache(moved, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.ImportJSON(bytes.NewReader(exported.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		size int64
+	}{
+		{filepath.Join(moved, "Trip", "a.jpg"), 10},
+		{filepath.Join(moved, "Music", "song.mp3"), 20},
+		{filepath.Join(root, "scan", "b.png"), 30},
+	}
+	for _, tt := range tests {
+		if _, ok := other.Get(tt.path, tt.size, modTime); !ok {
+			t.Errorf("%s not in the rebased cache", tt.path)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// maxStatsCameras is how many cameras CacheStats lists
+const maxStatsCameras = 5
+
+// CacheStats summarizes what the cache knows about a library, without rescanning it
+type CacheStats struct {
+	Files           int64
+	ByType          map[MediaType]int64 // Told by file extension (TypeUnknown for sniffed files)
+	TotalSize       int64
+	Earliest        *time.Time // Range of DateTaken, nil if no file has one
+	Latest          *time.Time
+	Cameras         []CameraCount // Most common cameras first, at most maxStatsCameras
+	DuplicateGroups int64         // Hashes shared by several cached files
+	DuplicateFiles  int64         // Files in those groups
+	WithHash        int64
+	WithMetadata    int64
+}
+
+// CameraCount is how many cached files a camera took
+type CameraCount struct {
+	Camera string
+	Files  int64
+}
+
+// Stats aggregates the cached files, after queued writes are committed
+func (c *Cache) Stats() (*CacheStats, error) {
+	c.flush()
+	db := c.reader()
+	stats := &CacheStats{ByType: make(map[MediaType]int64)}
+
+	rows, err := db.Query("SELECT path, size FROM files")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var path string
+		var size int64
+		if err := rows.Scan(&path, &size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Files++
+		stats.TotalSize += size
+		stats.ByType[detectMediaType(path)]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var earliest, latest sql.NullInt64
+	if err := db.QueryRow("SELECT MIN(date_taken), MAX(date_taken) FROM files").Scan(&earliest, &latest); err != nil {
+		return nil, err
+	}
+	if earliest.Valid && latest.Valid {
+		first, last := time.Unix(earliest.Int64, 0), time.Unix(latest.Int64, 0)
+		stats.Earliest, stats.Latest = &first, &last
+	}
+
+	rows, err = db.Query(`
+		SELECT COALESCE(camera_make, ''), COALESCE(camera_model, ''), COUNT(*) AS n
+		FROM files
+		WHERE COALESCE(camera_make, '') != '' OR COALESCE(camera_model, '') != ''
+		GROUP BY 1, 2
+		ORDER BY n DESC, 1, 2
+		LIMIT ?
+	`, maxStatsCameras)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var make, model string
+		var count CameraCount
+		if err := rows.Scan(&make, &model, &count.Files); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		count.Camera = cameraName(make, model)
+		stats.Cameras = append(stats.Cameras, count)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Same hash from the same algorithm; empty hashes were never computed
+	if err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(n), 0) FROM (
+			SELECT COUNT(*) AS n FROM files
+			WHERE COALESCE(hash, '') != ''
+			GROUP BY hash_algorithm, hash
+			HAVING n > 1
+		)
+	`).Scan(&stats.DuplicateGroups, &stats.DuplicateFiles); err != nil {
+		return nil, err
+	}
+
+	_, stats.WithHash, stats.WithMetadata = c.GetStats()
+	return stats, nil
+}
+
+// cameraName joins make and model, which often repeats the make ("Canon", "Canon EOS R5")
+func cameraName(make, model string) string {
+	make, model = strings.TrimSpace(make), strings.TrimSpace(model)
+	if make == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(make)) {
+		return model
+	}
+	if model == "" {
+		return make
+	}
+	return make + " " + model
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheStats(t *testing.T) {
+	cache := openTestCache(t)
+	modTime := time.Unix(1700000000, 0)
+	first := time.Date(2015, 3, 1, 10, 0, 0, 0, time.Local)
+	last := time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local)
+	for _, mf := range []*MediaFile{
+		{Path: "/photos/a.jpg", Size: 100, Type: TypePhoto, Hash: "h1", HashAlgo: HashXXHash, DateTaken: &first, CameraMake: "Canon", CameraModel: "Canon EOS R5"},
+		{Path: "/photos/b.jpg", Size: 200, Type: TypePhoto, Hash: "h1", HashAlgo: HashXXHash, DateTaken: &last, CameraMake: "Canon", CameraModel: "Canon EOS R5"},
+		{Path: "/photos/c.jpg", Size: 300, Type: TypePhoto, Hash: "h2", HashAlgo: HashXXHash, CameraMake: "Apple", CameraModel: "iPhone 13"},
+		{Path: "/photos/d.jpg", Size: 300, Type: TypePhoto, Hash: "h2", HashAlgo: HashMD5}, // Same text, other algorithm
+		{Path: "/videos/clip.mp4", Size: 1000, Type: TypeVideo, Duration: 3 * time.Second},
+		{Path: "/music/song.mp3", Size: 50, Type: TypeMusic, Artist: "Pixies"},
+		{Path: "/misc/renamed.dat", Size: 7, Type: TypePhoto}, // Found by sniffing
+	} {
+		cache.Put(mf, modTime)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 7 || stats.TotalSize != 1957 {
+		t.Errorf("%d files of %d bytes, want 7 of 1957", stats.Files, stats.TotalSize)
+	}
+	wantTypes := map[MediaType]int64{TypePhoto: 4, TypeVideo: 1, TypeMusic: 1, TypeUnknown: 1}
+	if !maps.Equal(stats.ByType, wantTypes) {
+		t.Errorf("by type %v, want %v", stats.ByType, wantTypes)
+	}
+	if stats.Earliest == nil || !stats.Earliest.Equal(first) || stats.Latest == nil || !stats.Latest.Equal(last) {
+		t.Errorf("dates %v to %v, want %v to %v", stats.Earliest, stats.Latest, first, last)
+	}
+	wantCameras := []CameraCount{{"Canon EOS R5", 2}, {"Apple iPhone 13", 1}}
+	if !slices.Equal(stats.Cameras, wantCameras) {
+		t.Errorf("cameras %v, want %v", stats.Cameras, wantCameras)
+	}
+	if stats.DuplicateGroups != 1 || stats.DuplicateFiles != 2 {
+		t.Errorf("%d duplicate groups of %d files, want 1 of 2", stats.DuplicateGroups, stats.DuplicateFiles)
+	}
+	if stats.WithHash != 4 || stats.WithMetadata != 5 {
+		t.Errorf("%d with a hash, %d with metadata, want 4, 5", stats.WithHash, stats.WithMetadata)
+	}
+}
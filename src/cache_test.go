@@ -0,0 +1,457 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestCache opens a cache in a temporary library, closed when the test ends
+func openTestCache(t testing.TB) *Cache {
+	t.Helper()
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "library"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestAlbumSuggestionsKeyedByModel(t *testing.T) {
+	cache := openTestCache(t)
+	suggestions, _ := OpenAlbumSuggestionCache(cache)
+	samples := []string{"/photos/trip/a.jpg"}
+
+	suggestions.Put("/photos/trip", samples, "gemma2:2b", "Beach Trip")
+	suggestions.Put("/photos/trip", samples, "llava", "Summer Vacation")
+	suggestions.PutEdited("/photos/trip", "Crete 2019")
+	cache.flush()
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"gemma2:2b", "Beach Trip"},
+		{"llava", "Summer Vacation"},
+	}
+	for _, tt := range tests {
+		if got, ok := suggestions.Get("/photos/trip", samples, tt.model); !ok || got != tt.want {
+			t.Errorf("Get(%s) = %q, %v, want %q", tt.model, got, ok, tt.want)
+		}
+	}
+	if got, ok := suggestions.GetEdited("/photos/trip"); !ok || got != "Crete 2019" {
+		t.Errorf("GetEdited = %q, %v, want %q", got, ok, "Crete 2019")
+	}
+}
+
+func TestCachePutUnderLoad(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	cache, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Far more writes than the queue holds, from several goroutines: none is dropped
+	const writers = 8
+	perWriter := 3 * cap(cache.writeChan) / writers
+	modTime := time.Unix(1700000000, 0)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				mf := &MediaFile{Path: fmt.Sprintf("/photos/%d/%d.jpg", w, i), Size: 1, Type: TypePhoto}
+				if err := cache.Put(mf, modTime); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		t.Fatalf("%d puts failed, first: %v", len(errs), errs[0])
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put(&MediaFile{Path: "/photos/late.jpg"}, modTime); err == nil {
+		t.Errorf("Put after Close succeeded")
+	}
+
+	reopened, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if total, _, _ := reopened.GetStats(); total != int64(writers*perWriter) {
+		t.Errorf("%d rows persisted, want %d", total, writers*perWriter)
+	}
+}
+
+// setCacheVersion sets a closed cache's schema version, so its next open migrates from there
+func setCacheVersion(t *testing.T, library string, version int) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(cacheDirPath(library), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrationRereadsPhotosWithoutGPS(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	cache, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Unix(1700000000, 0)
+	files := []*MediaFile{
+		{Path: "/photos/geotagged.jpg", Type: TypePhoto, GPS: &GPSCoord{Lat: 41.4, Lon: 2.17}},
+		{Path: "/photos/no-gps.jpg", Type: TypePhoto},
+		{Path: "/photos/no-gps.cr2", Type: TypePhoto},
+		{Path: "/photos/graphic.png", Type: TypePhoto}, // No EXIF to read GPS from
+	}
+	for _, mf := range files {
+		cache.Put(mf, modTime)
+	}
+	cache.Close()
+	setCacheVersion(t, library, 11)
+
+	cache, err = OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/photos/geotagged.jpg", true},
+		{"/photos/no-gps.jpg", false},
+		{"/photos/no-gps.cr2", false},
+		{"/photos/graphic.png", true},
+	}
+	for _, tt := range tests {
+		if _, ok := cache.Get(tt.path, 0, modTime); ok != tt.cached {
+			t.Errorf("%s cached = %v, want %v", tt.path, ok, tt.cached)
+		}
+	}
+}
+
+func TestMigrationRereadsVideosWithoutDuration(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	cache, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Unix(1700000000, 0)
+	for _, path := range []string{"/videos/old.mov", "/videos/new.mp4", "/videos/old.avi"} {
+		cache.Put(&MediaFile{Path: path, Type: TypeVideo}, modTime)
+	}
+	cache.Close()
+
+	// As cached before durations were read (the column was added without a value)
+	db, err := sql.Open("sqlite", filepath.Join(cacheDirPath(library), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE files SET duration_ms = NULL WHERE path != '/videos/new.mp4'"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	setCacheVersion(t, library, 12)
+
+	cache, err = OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/videos/old.mov", false},
+		{"/videos/new.mp4", true}, // Recorded without a duration: 0, not NULL
+		{"/videos/old.avi", true}, // No mvhd to read
+	}
+	for _, tt := range tests {
+		if _, ok := cache.Get(tt.path, 0, modTime); ok != tt.cached {
+			t.Errorf("%s cached = %v, want %v", tt.path, ok, tt.cached)
+		}
+	}
+}
+
+func TestMigrateOldSchemaCache(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	dbPath := filepath.Join(cacheDirPath(library), "cache.db")
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cache written before versioning: no GPS, duration, phash or algorithm columns,
+	// MD5 hashes stored as raw bytes, and suggestions keyed by folder alone
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE files (
+			path TEXT PRIMARY KEY, size INTEGER NOT NULL, mod_time INTEGER NOT NULL, hash TEXT,
+			date_taken INTEGER, camera_make TEXT, camera_model TEXT, artist TEXT, album TEXT,
+			title TEXT, width INTEGER, height INTEGER, processed_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE album_suggestions (
+			folder_path TEXT PRIMARY KEY, sample_files TEXT NOT NULL, suggestion TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		)`,
+		`INSERT INTO files VALUES
+			('/photos/graphic.png', 5, 1700000000, X'5d41402abc4b2a76', NULL, '', '', '', '', '', 16, 16, 1700000000),
+			('/photos/old.jpg', 5, 1700000000, '', NULL, '', '', '', '', '', 16, 16, 1700000000),
+			('/music/song.mp3', 5, 1700000000, '', NULL, '', '', 'Pixies', 'Doolittle', 'Debaser', 0, 0, 1700000000),
+			('/music/untagged.mp3', 5, 1700000000, '', NULL, '', '', '', '', '', 0, 0, 1700000000)`,
+		`INSERT INTO album_suggestions VALUES ('/photos/trip', '[]', 'Beach Trip', 1700000000)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	cache, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatalf("open old-schema cache: %v", err)
+	}
+	var version int
+	if err := cache.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != cacheVersion {
+		t.Errorf("migrated to version %d, want %d", version, cacheVersion)
+	}
+
+	modTime := time.Unix(1700000000, 0)
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/photos/graphic.png", true},
+		{"/photos/old.jpg", false}, // Read again for GPS
+		{"/music/song.mp3", true},
+		{"/music/untagged.mp3", false}, // Read again for tags
+	}
+	for _, tt := range tests {
+		if _, ok := cache.Get(tt.path, 5, modTime); ok != tt.cached {
+			t.Errorf("%s cached = %v, want %v", tt.path, ok, tt.cached)
+		}
+	}
+	if cf, ok := cache.Get("/photos/graphic.png", 5, modTime); ok && cf.Hash != "" {
+		t.Errorf("binary hash kept as %q, want it cleared", cf.Hash)
+	}
+	var suggestion string
+	if err := cache.db.QueryRow("SELECT suggestion FROM album_suggestions WHERE folder_path = ? AND model = ''",
+		"/photos/trip").Scan(&suggestion); err != nil || suggestion != "Beach Trip" {
+		t.Errorf("suggestion = %q, %v, want Beach Trip", suggestion, err)
+	}
+
+	// The new columns are written and read back
+	mf := &MediaFile{Path: "/photos/new.jpg", Size: 5, Type: TypePhoto, Hash: "h", HashAlgo: HashXXHash,
+		GPS: &GPSCoord{Lat: 41.4, Lon: 2.17}, Orientation: 6}
+	cache.Put(mf, modTime)
+	cache.flush()
+	if cf, ok := cache.Get(mf.Path, 5, modTime); !ok || cf.GPS == nil || cf.HashAlgo != HashXXHash || cf.Orientation != 6 {
+		t.Errorf("entry written after migration read back as %+v, %v", cf, ok)
+	}
+	cache.Close()
+
+	// A cache from a newer build is refused, not downgraded
+	setCacheVersion(t, library, cacheVersion+1)
+	if cache, err := OpenCache(library, ""); err == nil {
+		cache.Close()
+		t.Error("opened a cache with a newer schema version")
+	}
+}
+
+func TestCompactShrinksPrunedCache(t *testing.T) {
+	cache := openTestCache(t)
+	modTime := time.Unix(1700000000, 0)
+	const entries = 5000
+	valid := map[string]bool{}
+	for i := range entries {
+		mf := &MediaFile{Path: fmt.Sprintf("/photos/%05d.jpg", i), Size: int64(i), Type: TypePhoto,
+			Hash: fmt.Sprintf("%064x", i), HashAlgo: HashSHA256, CameraMake: "Canon", CameraModel: "EOS R5"}
+		cache.Put(mf, modTime)
+		if i%100 == 0 {
+			valid[mf.Path] = true
+		}
+	}
+	cache.flush()
+
+	pruned, err := cache.PruneDeleted(valid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != entries-int64(len(valid)) {
+		t.Errorf("pruned %d entries, want %d", pruned, entries-len(valid))
+	}
+	before := cache.fileSize()
+
+	reclaimed, err := cache.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := cache.fileSize()
+	if after >= before {
+		t.Errorf("cache is %d bytes after compacting, was %d", after, before)
+	}
+	if reclaimed != before-after {
+		t.Errorf("reported %d bytes reclaimed, file shrank by %d", reclaimed, before-after)
+	}
+	if total, _, _ := cache.GetStats(); total != int64(len(valid)) {
+		t.Errorf("%d entries after compacting, want %d", total, len(valid))
+	}
+	if _, ok := cache.Get("/photos/00100.jpg", 100, modTime); !ok {
+		t.Error("kept entry missing after compacting")
+	}
+}
+
+func TestCacheCloseFlushesPartialBatch(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	cache, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fewer writes than a batch, closed before the batch delay runs out
+	const entries = cacheWriteBatch / 10
+	modTime := time.Unix(1700000000, 0)
+	for i := range entries {
+		cache.Put(&MediaFile{Path: fmt.Sprintf("/photos/%03d.jpg", i), Size: 1, Type: TypePhoto}, modTime)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenCache(library, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if total, _, _ := reopened.GetStats(); total != entries {
+		t.Errorf("%d entries after Close, want %d", total, entries)
+	}
+}
+
+// BenchmarkCachePut compares queued writes, committed in batches, with committing
+// every write on its own
+func BenchmarkCachePut(b *testing.B) {
+	modTime := time.Unix(1700000000, 0)
+	entry := func(i int) *MediaFile {
+		return &MediaFile{Path: fmt.Sprintf("/photos/%08d.jpg", i), Size: int64(i), Type: TypePhoto,
+			Hash: fmt.Sprintf("%016x", i), HashAlgo: HashXXHash}
+	}
+
+	b.Run("batched", func(b *testing.B) {
+		cache := openTestCache(b)
+		for i := range b.N {
+			cache.Put(entry(i), modTime)
+		}
+		cache.flush()
+	})
+	b.Run("one per transaction", func(b *testing.B) {
+		cache := openTestCache(b)
+		for i := range b.N {
+			cache.writeBatch([]cacheWriteRequest{{mf: entry(i), modTime: modTime}})
+		}
+	})
+}
+
+func TestCacheAtCustomPath(t *testing.T) {
+	root := t.TempDir()
+	dbPath := filepath.Join(root, "shared", "media.db")
+	modTime := time.Unix(1700000000, 0)
+	samples := []string{"/photos/trip/a.jpg"}
+
+	cache, err := OpenCache(filepath.Join(root, "photos-library"), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(&MediaFile{Path: "/photos/trip/a.jpg", Size: 5, Type: TypePhoto, Hash: "aa", HashAlgo: HashXXHash}, modTime)
+	suggestions, _ := OpenAlbumSuggestionCache(cache)
+	suggestions.Put("/photos/trip", samples, "gemma2:2b", "Beach Trip")
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("no cache at the custom path: %v", err)
+	}
+	if _, err := os.Stat(cacheDirPath(filepath.Join(root, "photos-library"))); !os.IsNotExist(err) {
+		t.Errorf("cache directory created in the library (%v)", err)
+	}
+
+	// Another library sharing the file reads what the first one wrote
+	shared, err := OpenCache(filepath.Join(root, "music-library"), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close()
+	if cf, ok := shared.Get("/photos/trip/a.jpg", 5, modTime); !ok || cf.Hash != "aa" {
+		t.Errorf("shared cache entry = %+v, %v", cf, ok)
+	}
+	suggestions, _ = OpenAlbumSuggestionCache(shared)
+	if got, ok := suggestions.Get("/photos/trip", samples, "gemma2:2b"); !ok || got != "Beach Trip" {
+		t.Errorf("shared suggestion = %q, %v, want Beach Trip", got, ok)
+	}
+}
+
+// BenchmarkCacheGetParallel compares concurrent lookups through the writer's single
+// connection with lookups through the read-only pool
+func BenchmarkCacheGetParallel(b *testing.B) {
+	const entries = 1000
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, mode := range []struct {
+		name  string
+		conns int // 0: no read pool
+	}{{"single db", 0}, {"read pool", 8}} {
+		b.Run(mode.name, func(b *testing.B) {
+			cache := openTestCache(b)
+			for i := range entries {
+				mf := &MediaFile{Path: fmt.Sprintf("/photos/%04d.jpg", i), Size: int64(i), Hash: "h"}
+				if err := cache.Put(mf, modTime); err != nil {
+					b.Fatal(err)
+				}
+			}
+			cache.flush()
+			if mode.conns > 0 {
+				if err := cache.EnableReadPool(mode.conns); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					n := i % entries
+					if _, ok := cache.Get(fmt.Sprintf("/photos/%04d.jpg", n), int64(n), modTime); !ok {
+						b.Errorf("entry %d missing", n)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
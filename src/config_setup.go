@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -14,13 +15,71 @@ import (
 
 // ConfigFile represents the YAML configuration
 type ConfigFile struct {
-	ScanPath        string `yaml:"scan_path"`
-	LibraryBase     string `yaml:"library_base"`
-	DuplicatesTrash string `yaml:"duplicates_trash"`
-	OllamaModel     string `yaml:"ollama_model"`
-	Workers         int    `yaml:"workers"`
+	ScanPath        string   `yaml:"scan_path"`
+	ScanPaths       []string `yaml:"scan_paths,omitempty"` // Several roots, instead of scan_path
+	LibraryBase     string   `yaml:"library_base"`
+	PhotosRoot      string   `yaml:"photos_root,omitempty"`
+	VideosRoot      string   `yaml:"videos_root,omitempty"`
+	MusicRoot       string   `yaml:"music_root,omitempty"`
+	DuplicatesTrash string   `yaml:"duplicates_trash"`
+	CachePath       string   `yaml:"cache_path,omitempty"`
+	OllamaModel     string   `yaml:"ollama_model"`
+	NamingProvider  string   `yaml:"naming_provider,omitempty"`
+	OpenAIBaseURL   string   `yaml:"openai_base_url,omitempty"`
+	OpenAIModel     string   `yaml:"openai_model,omitempty"`
+	VisionModel     string   `yaml:"ollama_vision_model,omitempty"`
+	NamingTimeout   string   `yaml:"naming_timeout,omitempty"` // e.g. "30s"
+	NamingRetries   *int     `yaml:"naming_retries,omitempty"` // nil = defaultNamingRetries
+	NamingWorkers   int      `yaml:"naming_workers,omitempty"`
+	Workers         int      `yaml:"workers"`
+	MoveWorkers     int      `yaml:"move_workers,omitempty"`
+	OrganizeMode    string   `yaml:"organize_mode,omitempty"`
+	VerifyCopies    bool     `yaml:"verify_copies,omitempty"`
+	MetadataCommand string   `yaml:"metadata_command,omitempty"`
+	Layout          string   `yaml:"layout,omitempty"`
+	FolderTemplate  string   `yaml:"folder_template,omitempty"`
+	MixedDirs       string   `yaml:"mixed_dirs,omitempty"`
+	AlbumNaming     string   `yaml:"album_naming,omitempty"`
+	GroupBy         string   `yaml:"group_by,omitempty"`
+	EventGap        string   `yaml:"event_gap,omitempty"` // e.g. "6h"
+	PlacesFile      string   `yaml:"places_file,omitempty"`
+	ExcludePatterns []string `yaml:"exclude_patterns,omitempty"` // Replaces the defaults when set
+	ExcludeExts     []string `yaml:"exclude_extensions,omitempty"`
+	AlbumSort       string   `yaml:"album_sort,omitempty"`
+	NonPhotos       string   `yaml:"non_photos,omitempty"`
+	MinAlbumFiles   int      `yaml:"min_album_files,omitempty"`
+	NoDateFolder    string   `yaml:"no_date_folder,omitempty"`
+	MaxAlbumFiles   int      `yaml:"max_album_files,omitempty"`
+	MergeDepth      int      `yaml:"merge_depth,omitempty"`
+	KeepSubfolders  bool     `yaml:"keep_subfolders,omitempty"`
+	MaxMoves        int      `yaml:"max_moves,omitempty"`
+	SniffContent    bool     `yaml:"sniff_content,omitempty"`
+	SidecarDates    bool     `yaml:"prefer_sidecar_dates,omitempty"`
+	FilenameDates   []string `yaml:"filename_date_patterns,omitempty"`
+	DedupThreshold  string   `yaml:"dedup_threshold,omitempty"` // e.g. "100KB"
+	HashAlgorithm   string   `yaml:"hash_algorithm,omitempty"`
+	NearDupBits     int      `yaml:"near_dup_threshold,omitempty"`
+	DedupLibrary    bool     `yaml:"dedup_library,omitempty"`
+	ContentHash     bool     `yaml:"content_hash,omitempty"`
+	DestExists      string   `yaml:"dest_exists_policy,omitempty"`
+	OnConflict      string   `yaml:"on_conflict,omitempty"`
+	PostCommand     string   `yaml:"post_command,omitempty"`
+	PostTimeout     string   `yaml:"post_command_timeout,omitempty"` // e.g. "10m"
+
+	// Extensions recognized on top of the built-in ones, by type (photo, video, music)
+	ExtraExtensions map[string][]string `yaml:"extra_extensions,omitempty"`
+
+	// Which file of a duplicate group is kept
+	DuplicateRules []DuplicateRule `yaml:"duplicate_rules,omitempty"` // Replace the default rules when set
+	PreferLarger   *bool           `yaml:"prefer_larger,omitempty"`   // nil = true
+	PreferMetadata *bool           `yaml:"prefer_metadata,omitempty"` // nil = true
+	PreferOldest   bool            `yaml:"prefer_oldest,omitempty"`
+	DuplicateKeep  string          `yaml:"duplicate_keep,omitempty"` // best-score (default), oldest, newest, largest, shortest-path
 }
 
+// defaultOllamaModel is the naming model the setup proposes
+const defaultOllamaModel = "gemma2:2b"
+
 // getConfigPath returns the path to the config file
 func getConfigPath() string {
 	home, err := os.UserHomeDir()
@@ -79,12 +138,27 @@ func runSetupWizard() (*ConfigFile, error) {
 
 	// Scan Path
 	fmt.Println("1. Where are your media files located?")
-	fmt.Println("   (This is the root directory containing photos, videos, music)")
-	fmt.Print("   Path [/Volumes/TimeMachine]: ")
-	scanPath, _ := reader.ReadString('\n')
-	scanPath = strings.TrimSpace(scanPath)
-	if scanPath == "" {
-		scanPath = "/Volumes/TimeMachine"
+	fmt.Println("   (This is the root directory containing photos, videos, music;")
+	fmt.Println("   list several under scan_paths in the config file later if needed)")
+	var scanPath string
+	for {
+		fmt.Print("   Path [/Volumes/TimeMachine]: ")
+		line, readErr := reader.ReadString('\n')
+		scanPath = strings.TrimSpace(line)
+		if scanPath == "" {
+			scanPath = "/Volumes/TimeMachine"
+		}
+		var err error
+		if scanPath, err = expandPath(scanPath); err == nil {
+			err = checkScanPath(scanPath)
+		}
+		if err == nil {
+			break
+		}
+		if readErr != nil {
+			return nil, err // Input ended, asking again would loop forever
+		}
+		fmt.Printf("   %v\n", err)
 	}
 	cfg.ScanPath = scanPath
 
@@ -99,6 +173,10 @@ func runSetupWizard() (*ConfigFile, error) {
 	if libraryBase == "" {
 		libraryBase = defaultLibrary
 	}
+	libraryBase, err := expandPath(libraryBase)
+	if err != nil {
+		return nil, err
+	}
 	cfg.LibraryBase = libraryBase
 
 	// Duplicates Trash
@@ -112,17 +190,23 @@ func runSetupWizard() (*ConfigFile, error) {
 	if trash == "" {
 		trash = defaultTrash
 	}
+	if trash, err = expandPath(trash); err != nil {
+		return nil, err
+	}
 	cfg.DuplicatesTrash = trash
+	for _, warning := range setupPathWarnings(cfg.ScanPath, cfg.LibraryBase, cfg.DuplicatesTrash) {
+		fmt.Println("   Warning:", warning)
+	}
 
 	// Ollama Model
 	fmt.Println()
 	fmt.Println("4. Which Ollama model for smart album naming?")
 	fmt.Println("   (Requires Ollama running locally, or leave default)")
-	fmt.Print("   Model [gemma2:2b]: ")
+	fmt.Printf("   Model [%s]: ", defaultOllamaModel)
 	model, _ := reader.ReadString('\n')
 	model = strings.TrimSpace(model)
 	if model == "" {
-		model = "gemma2:2b"
+		model = defaultOllamaModel
 	}
 	cfg.OllamaModel = model
 
@@ -145,6 +229,47 @@ func runSetupWizard() (*ConfigFile, error) {
 		}
 	}
 
+	// Organize mode
+	fmt.Println()
+	fmt.Println("6. How should files be placed in the library?")
+	fmt.Println("   move:     move the originals (default)")
+	fmt.Println("   copy:     copy, leaving the originals untouched")
+	fmt.Println("   hardlink: hardlink to the originals (no extra space, same filesystem)")
+	fmt.Println("   symlink:  symlink to the originals")
+	fmt.Printf("   Mode [%s]: ", OrganizeMove)
+	mode, _ := reader.ReadString('\n')
+	mode = strings.TrimSpace(strings.ToLower(mode))
+	switch mode {
+	case OrganizeCopy, OrganizeHardlink, OrganizeSymlink:
+		cfg.OrganizeMode = mode
+	default:
+		cfg.OrganizeMode = OrganizeMove
+	}
+
+	// Exclusions
+	fmt.Println()
+	fmt.Println("7. Any other folders to skip?")
+	fmt.Printf("   (Always skipped: %s)\n", strings.Join(defaultExcludePatterns, ", "))
+	fmt.Println("   Comma-separated glob patterns: a name skips matching folders at any depth")
+	fmt.Println("   (e.g. *.photoslibrary), a path is relative to the scan path (e.g. Backups/old)")
+	fmt.Print("   Patterns [none]: ")
+	excludes, _ := reader.ReadString('\n')
+	var custom []string
+	for _, pattern := range strings.Split(excludes, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if err := validateExcludePatterns([]string{pattern}); err != nil {
+			fmt.Printf("   Ignoring invalid pattern %v\n", err)
+			continue
+		}
+		custom = append(custom, pattern)
+	}
+	if len(custom) > 0 {
+		cfg.ExcludePatterns = append(append([]string{}, defaultExcludePatterns...), custom...)
+	}
+
 	// Summary
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════════")
@@ -155,6 +280,10 @@ func runSetupWizard() (*ConfigFile, error) {
 	fmt.Printf("  Duplicates Trash: %s\n", cfg.DuplicatesTrash)
 	fmt.Printf("  Ollama Model:     %s\n", cfg.OllamaModel)
 	fmt.Printf("  Workers:          %d\n", cfg.Workers)
+	fmt.Printf("  Organize Mode:    %s\n", cfg.OrganizeMode)
+	if len(custom) > 0 {
+		fmt.Printf("  Also Skipping:    %s\n", strings.Join(custom, ", "))
+	}
 	fmt.Println()
 
 	// Confirm
@@ -180,6 +309,117 @@ func runSetupWizard() (*ConfigFile, error) {
 	return cfg, nil
 }
 
+// initConfigFile writes the config without prompting (--init, or a first run given
+// --path, --library or --yes), taking the wizard's defaults for whatever the flags
+// leave out. The scan path has no sensible default, so it must be given.
+func initConfigFile(scanPaths []string, libraryBase string, workers int, mode string) (*ConfigFile, error) {
+	if len(scanPaths) == 0 {
+		return nil, fmt.Errorf("--path is required to write %s without the setup wizard", getConfigPath())
+	}
+	paths := make([]string, len(scanPaths))
+	for i, path := range scanPaths {
+		abs, err := expandPath(path)
+		if err == nil {
+			err = checkScanPath(abs)
+		}
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = abs
+	}
+
+	cfg := &ConfigFile{
+		ScanPath:        paths[0],
+		LibraryBase:     filepath.Join(paths[0], "MediaLibrary"),
+		DuplicatesTrash: filepath.Join(paths[0], ".duplicates-trash"),
+		OllamaModel:     defaultOllamaModel,
+		Workers:         getDefaultWorkers(),
+		OrganizeMode:    OrganizeMove,
+	}
+	if len(paths) > 1 {
+		cfg.ScanPath = ""
+		cfg.ScanPaths = paths
+	}
+	if libraryBase != "" {
+		abs, err := expandPath(libraryBase)
+		if err != nil {
+			return nil, err
+		}
+		cfg.LibraryBase = abs
+	}
+	if workers > 0 {
+		cfg.Workers = workers
+	}
+	switch mode {
+	case "":
+	case OrganizeMove, OrganizeCopy, OrganizeHardlink, OrganizeSymlink:
+		cfg.OrganizeMode = mode
+	default:
+		return nil, fmt.Errorf("invalid mode %q (use %s, %s, %s or %s)", mode, OrganizeMove, OrganizeCopy, OrganizeHardlink, OrganizeSymlink)
+	}
+
+	for _, path := range paths {
+		for _, warning := range setupPathWarnings(path, cfg.LibraryBase, cfg.DuplicatesTrash) {
+			fmt.Fprintln(os.Stderr, "Warning:", warning)
+		}
+	}
+
+	if err := saveConfig(cfg); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+	// stderr, so --output json keeps stdout to its events
+	fmt.Fprintln(os.Stderr, "✓ Configuration saved to:", getConfigPath())
+	return cfg, nil
+}
+
+// expandPath resolves a path typed during setup: a leading ~ is the home directory
+// (the shell doesn't expand it in answers), and relative paths become absolute
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("expand %s: %w", path, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// checkScanPath reports why path can't be scanned: missing, not a folder, or unreadable
+func checkScanPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("scan path %s does not exist", path)
+		}
+		return fmt.Errorf("scan path %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("scan path %s is not a folder", path)
+	}
+	dir, err := os.Open(path)
+	if err == nil {
+		_, err = dir.Readdirnames(1)
+		dir.Close()
+	}
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("scan path %s is not readable: %w", path, err)
+	}
+	return nil
+}
+
+// setupPathWarnings lists the setup's folder choices that work but are likely mistakes
+func setupPathWarnings(scanPath, libraryBase, trash string) []string {
+	var warnings []string
+	if isWithinDir(libraryBase, scanPath) {
+		warnings = append(warnings, fmt.Sprintf("library %s is inside scan path %s; scans skip it, so media already in that folder stays as it is", libraryBase, scanPath))
+	}
+	if isWithinDir(trash, libraryBase) {
+		warnings = append(warnings, fmt.Sprintf("duplicates trash %s is inside library %s, so trashed duplicates stay in the library", trash, libraryBase))
+	}
+	return warnings
+}
+
 // getDefaultWorkers returns recommended worker count
 func getDefaultWorkers() int {
 	cpus := runtime.NumCPU()
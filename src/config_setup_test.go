@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInitConfigFileFromFlags(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	scan := filepath.Join(home, "Pictures")
+	if err := os.Mkdir(scan, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := initConfigFile(nil, "", 0, ""); err == nil || !strings.Contains(err.Error(), "--path is required") {
+		t.Errorf("no scan path: error %v, want --path is required", err)
+	}
+	if _, err := initConfigFile([]string{filepath.Join(home, "missing")}, "", 0, ""); err == nil {
+		t.Error("missing scan path accepted")
+	}
+	if _, err := initConfigFile([]string{scan}, "", 0, "teleport"); err == nil {
+		t.Error("invalid mode accepted")
+	}
+	if configExists() {
+		t.Fatal("failed init wrote the config")
+	}
+
+	returned, err := initConfigFile([]string{"~/Pictures"}, "~/Library", 2, OrganizeCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".media-organizer.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written ConfigFile
+	if err := yaml.Unmarshal(data, &written); err != nil {
+		t.Fatalf("config is not YAML: %v\n%s", err, data)
+	}
+	want := ConfigFile{
+		ScanPath:        scan,
+		LibraryBase:     filepath.Join(home, "Library"),
+		DuplicatesTrash: filepath.Join(scan, ".duplicates-trash"),
+		OllamaModel:     defaultOllamaModel,
+		Workers:         2,
+		OrganizeMode:    OrganizeCopy,
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("wrote %+v, want %+v", written, want)
+	}
+	if !reflect.DeepEqual(*returned, want) {
+		t.Errorf("returned %+v, want %+v", *returned, want)
+	}
+
+	// Defaults fill in what the flags leave out; several paths are written as a list
+	other := filepath.Join(home, "Phone")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := initConfigFile([]string{scan, other}, "", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = ConfigFile{
+		ScanPaths:       []string{scan, other},
+		LibraryBase:     filepath.Join(scan, "MediaLibrary"),
+		DuplicatesTrash: filepath.Join(scan, ".duplicates-trash"),
+		OllamaModel:     defaultOllamaModel,
+		Workers:         getDefaultWorkers(),
+		OrganizeMode:    OrganizeMove,
+	}
+	if !reflect.DeepEqual(*loaded, want) {
+		t.Errorf("wrote %+v, want %+v", *loaded, want)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", home},
+		{"~/Photos", filepath.Join(home, "Photos")},
+		{"~/Photos/../Videos/", filepath.Join(home, "Videos")},
+		{"Photos", filepath.Join(wd, "Photos")},
+		{"/srv/media", "/srv/media"},
+		{"~bob/Photos", filepath.Join(wd, "~bob", "Photos")}, // Other users' homes aren't expanded
+	}
+	for _, tt := range tests {
+		got, err := expandPath(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+		} else if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSetupPathWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		library  string
+		trash    string
+		warnings []string // Substrings, one per expected warning
+	}{
+		{"separate", "/media/library", "/media/trash", nil},
+		{"library in scan path", "/home/me/Pictures/Library", "/media/trash", []string{"inside scan path"}},
+		{"scan path itself", "/home/me/Pictures", "/media/trash", []string{"inside scan path"}},
+		{"name prefix only", "/home/me/Pictures-library", "/media/trash", nil},
+		{"trash in library", "/media/library", "/media/library/.trash", []string{"inside library"}},
+		{"both", "/home/me/Pictures/Library", "/home/me/Pictures/Library/trash", []string{"inside scan path", "inside library"}},
+	}
+	for _, tt := range tests {
+		got := setupPathWarnings("/home/me/Pictures", tt.library, tt.trash)
+		if len(got) != len(tt.warnings) {
+			t.Errorf("%s: got warnings %q, want %d", tt.name, got, len(tt.warnings))
+			continue
+		}
+		for i, want := range tt.warnings {
+			if !strings.Contains(got[i], want) {
+				t.Errorf("%s: warning %q, want one about %q", tt.name, got[i], want)
+			}
+		}
+	}
+}
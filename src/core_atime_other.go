@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime falls back to the modification time on this platform
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// screenshotPrefixes are file name prefixes used by OS and phone screenshot tools
+var screenshotPrefixes = []string{
+	"screenshot", "screen shot", "screen_shot", "screen-shot", "screencapture",
+	"bildschirmfoto", "capture d", "schermafbeelding", "captura de pantalla",
+}
+
+// screenSizes are common display resolutions (portrait or landscape)
+var screenSizes = map[[2]int]bool{
+	{1280, 720}: true, {1280, 800}: true, {1366, 768}: true, {1440, 900}: true,
+	{1536, 864}: true, {1680, 1050}: true, {1920, 1080}: true, {1920, 1200}: true,
+	{2560, 1440}: true, {2560, 1600}: true, {2880, 1800}: true, {3840, 2160}: true,
+	{1334, 750}: true, {1792, 828}: true, {2208, 1242}: true, {2436, 1125}: true,
+	{2532, 1170}: true, {2556, 1179}: true, {2688, 1242}: true, {2778, 1284}: true,
+	{2796, 1290}: true, {2340, 1080}: true, {2400, 1080}: true, {3200, 1440}: true,
+}
+
+// classifyImage sets mf.ImageClass for photos: animated GIFs, screenshots and other
+// graphics are told apart from camera photographs using the name, format and metadata
+func classifyImage(mf *MediaFile) {
+	if mf.Type != TypePhoto {
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(mf.Path))
+	if ext == ".gif" {
+		mf.ImageClass = ImageClassGraphic
+		if frames, err := countGIFFrames(mf.Path); err == nil && frames > 1 {
+			mf.ImageClass = ImageClassAnimation
+		}
+		return
+	}
+
+	if isScreenshot(mf, ext) {
+		mf.ImageClass = ImageClassScreenshot
+		return
+	}
+	mf.ImageClass = ImageClassPhoto
+}
+
+// isScreenshot matches screenshot tool file names, or camera-less PNGs at a screen resolution
+func isScreenshot(mf *MediaFile, ext string) bool {
+	name := strings.ToLower(filepath.Base(mf.Path))
+	for _, prefix := range screenshotPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	if ext != ".png" || mf.CameraMake != "" {
+		return false
+	}
+	w, h := mf.Width, mf.Height
+	if w < h {
+		w, h = h, w
+	}
+	return screenSizes[[2]int{w, h}]
+}
+
+// countGIFFrames walks the GIF block structure and counts image descriptors
+// (stops at 2, which is all we need to know the image is animated)
+func countGIFFrames(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	// Header (6) + logical screen descriptor (7)
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	if string(header[:3]) != "GIF" {
+		return 0, errors.New("not a GIF")
+	}
+	if header[10]&0x80 != 0 {
+		if err := skipBytes(r, 3<<(header[10]&0x07+1)); err != nil {
+			return 0, err
+		}
+	}
+
+	frames := 0
+	for frames < 2 {
+		block, err := r.ReadByte()
+		if err != nil {
+			return frames, nil // Truncated file, count what we saw
+		}
+
+		switch block {
+		case 0x21: // Extension: label, then data sub-blocks
+			if _, err := r.ReadByte(); err != nil {
+				return frames, nil
+			}
+		case 0x2C: // Image descriptor
+			frames++
+			var desc [9]byte
+			if _, err := io.ReadFull(r, desc[:]); err != nil {
+				return frames, nil
+			}
+			if desc[8]&0x80 != 0 {
+				if err := skipBytes(r, 3<<(desc[8]&0x07+1)); err != nil {
+					return frames, nil
+				}
+			}
+			if _, err := r.ReadByte(); err != nil { // LZW minimum code size
+				return frames, nil
+			}
+		case 0x3B: // Trailer
+			return frames, nil
+		default:
+			return frames, errors.New("corrupt GIF")
+		}
+
+		if err := skipSubBlocks(r); err != nil {
+			return frames, nil
+		}
+	}
+	return frames, nil
+}
+
+// skipSubBlocks skips a sequence of GIF data sub-blocks up to the zero-length terminator
+func skipSubBlocks(r *bufio.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if err := skipBytes(r, int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+func skipBytes(r *bufio.Reader, n int) error {
+	_, err := r.Discard(n)
+	return err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// errNoImageData is returned for files that are neither JPEG nor PNG (no content hash)
+var errNoImageData = errors.New("not a JPEG or PNG")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// CalculateContentHashes computes content hashes for the JPEG and PNG photos among files
+// in parallel (Config.ContentHash), taking current ones from the cache. Every other such
+// photo is read in full once, unlike CalculateHashes, since a metadata edit changes the
+// size too. Returns the number of cache hits.
+func CalculateContentHashes(ctx context.Context, files []*MediaFile, workers int, progressChan chan<- ScanProgress, cache *Cache) int {
+	var candidates []*MediaFile
+	for _, mf := range files {
+		if mf.Type == TypePhoto {
+			candidates = append(candidates, mf)
+		}
+	}
+
+	// Only loaded here, so runs without Config.ContentHash never group by cached ones
+	var mu sync.Mutex
+	cacheHits := 0
+	if cache != nil {
+		runParallel(workers, len(candidates), func(i int) {
+			mf := candidates[i]
+			info, err := os.Stat(mf.Path)
+			if err != nil {
+				return
+			}
+			if cf, ok := cache.Get(mf.Path, mf.Size, info.ModTime()); ok && cf.ContentHash != "" {
+				mf.ContentHash = cf.ContentHash
+				mu.Lock()
+				cacheHits++
+				mu.Unlock()
+			}
+		})
+	}
+	var photos []*MediaFile
+	for _, mf := range candidates {
+		if mf.ContentHash == "" {
+			photos = append(photos, mf)
+		}
+	}
+
+	processed := 0
+	runParallel(workers, len(photos), func(i int) {
+		mf := photos[i]
+		waitIfPaused(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if hash, err := calculateContentHash(mf.Path); err == nil {
+			mf.ContentHash = hash
+			putHash(mf, cache)
+		} else if !errors.Is(err, errNoImageData) {
+			debugLog.Printf("content hash %s: %v", mf.Path, err)
+		}
+
+		mu.Lock()
+		processed++
+		if progressChan != nil {
+			select {
+			case progressChan <- ScanProgress{
+				ProcessedFiles: processed,
+				TotalFiles:     len(photos),
+				CurrentFile:    mf.Path,
+			}:
+			default:
+			}
+		}
+		mu.Unlock()
+	})
+	return cacheHits
+}
+
+// calculateContentHash hashes (xxhash, hex) the image data of a JPEG or PNG, leaving out
+// the parts that hold metadata, so re-tagging a photo doesn't change its content hash
+func calculateContentHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64*1024)
+	magic, _ := r.Peek(len(pngSignature))
+	h := newXXHash64()
+	switch {
+	case bytes.HasPrefix(magic, []byte{0xFF, 0xD8}):
+		err = hashJPEGImageData(h, r)
+	case bytes.Equal(magic, pngSignature):
+		err = hashPNGImageData(h, r)
+	default:
+		return "", errNoImageData
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashJPEGImageData writes a JPEG's segments to w, except APPn (EXIF, XMP, ICC, maker
+// notes) and comments, followed by everything from the start of scan on
+func hashJPEGImageData(w io.Writer, r *bufio.Reader) error {
+	if _, err := r.Discard(2); err != nil { // SOI
+		return err
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != 0xFF {
+			return fmt.Errorf("expected a JPEG marker, found 0x%02x", b)
+		}
+		marker := byte(0xFF)
+		for marker == 0xFF { // Fill bytes
+			if marker, err = r.ReadByte(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case marker == 0xD9: // EOI before any image data
+			return nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // No length
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return err
+		}
+		n := int64(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return fmt.Errorf("bad JPEG segment length")
+		}
+		if (marker >= 0xE0 && marker <= 0xEF) || marker == 0xFE {
+			if _, err := r.Discard(int(n)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		w.Write([]byte{0xFF, marker})
+		w.Write(length[:])
+		if _, err := io.CopyN(w, r, n); err != nil {
+			return err
+		}
+		if marker == 0xDA { // SOS: the compressed image follows
+			_, err := io.Copy(w, r)
+			return err
+		}
+	}
+}
+
+// hashPNGImageData writes a PNG's critical chunks (IHDR, PLTE, IDAT, IEND) to w, leaving
+// out ancillary ones (text, EXIF, time, ...)
+func hashPNGImageData(w io.Writer, r *bufio.Reader) error {
+	if _, err := r.Discard(len(pngSignature)); err != nil {
+		return err
+	}
+	for {
+		var header [8]byte // Length, type
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		n := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:])
+
+		// Critical chunks have an uppercase first letter, ancillary ones a lowercase one
+		if chunkType[0]&0x20 == 0 {
+			w.Write(header[:])
+			if _, err := io.CopyN(w, r, n); err != nil {
+				return err
+			}
+		} else if _, err := r.Discard(int(n)); err != nil {
+			return err
+		}
+		if _, err := r.Discard(4); err != nil { // CRC
+			return err
+		}
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// withJPEGSegment returns a JPEG with a metadata segment (APP1, COM, ...) inserted after SOI
+func withJPEGSegment(jpg []byte, marker byte, payload string) []byte {
+	n := len(payload) + 2
+	segment := append([]byte{0xFF, marker, byte(n >> 8), byte(n)}, payload...)
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+// withPNGChunk returns a PNG with an ancillary chunk inserted after IHDR (CRC not checked)
+func withPNGChunk(pngData []byte, chunkType, payload string) []byte {
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	n := len(payload)
+	chunk := append([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, chunkType...)
+	chunk = append(chunk, payload...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	out := append([]byte{}, pngData[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, pngData[ihdrEnd:]...)
+}
+
+func testImage(shade uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{shade, uint8(x * 16), uint8(y * 16), 255})
+		}
+	}
+	return img
+}
+
+func TestContentHashIgnoresMetadata(t *testing.T) {
+	var jpgBuf, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpgBuf, testImage(0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngBuf, testImage(0)); err != nil {
+		t.Fatal(err)
+	}
+	var otherBuf bytes.Buffer
+	if err := jpeg.Encode(&otherBuf, testImage(200), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpg, pngData, other := jpgBuf.Bytes(), pngBuf.Bytes(), otherBuf.Bytes()
+
+	tests := []struct {
+		name     string
+		original []byte
+		retagged []byte
+		same     bool // Content hashes expected to match
+	}{
+		{"jpeg exif added", jpg, withJPEGSegment(jpg, 0xE1, "Exif\x00\x00artist=A"), true},
+		{"jpeg exif rewritten", withJPEGSegment(jpg, 0xE1, "Exif\x00\x00artist=A"), withJPEGSegment(jpg, 0xE1, "Exif\x00\x00artist=B, keywords=beach"), true},
+		{"jpeg comment", jpg, withJPEGSegment(jpg, 0xFE, "edited"), true},
+		{"png text chunk", pngData, withPNGChunk(pngData, "tEXt", "Comment\x00hello"), true},
+		{"different image", jpg, other, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+			if err := os.WriteFile(a, tt.original, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(b, tt.retagged, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			contentA, err := calculateContentHash(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contentB, err := calculateContentHash(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (contentA == contentB) != tt.same {
+				t.Errorf("content hashes equal = %v, want %v", contentA == contentB, tt.same)
+			}
+
+			md5A, _ := calculateFileHash(a, "md5")
+			md5B, _ := calculateFileHash(b, "md5")
+			if md5A == md5B {
+				t.Errorf("MD5 unchanged, the test files are identical")
+			}
+		})
+	}
+}
+
+func TestContentHashNotAnImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.jpg")
+	if err := os.WriteFile(path, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := calculateContentHash(path); err != errNoImageData {
+		t.Errorf("err = %v, want errNoImageData", err)
+	}
+}
+
+func TestFindDuplicatesContentHashIsSecondaryKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []*MediaFile
+		groups [][]string // Paths per group, in input order
+	}{
+		{
+			name: "identical bytes, one without content hash",
+			files: []*MediaFile{
+				{Path: "a", Size: 10, Hash: "x", ContentHash: "c"},
+				{Path: "b", Size: 10, Hash: "x"},
+			},
+			groups: [][]string{{"a", "b"}},
+		},
+		{
+			name: "re-tagged copy, same content hash",
+			files: []*MediaFile{
+				{Path: "a", Size: 10, Hash: "x", ContentHash: "c"},
+				{Path: "b", Size: 12, Hash: uniqueHash, ContentHash: "c"},
+			},
+			groups: [][]string{{"a", "b"}},
+		},
+		{
+			name: "content hash links two hash groups",
+			files: []*MediaFile{
+				{Path: "a", Size: 10, Hash: "x"},
+				{Path: "b", Size: 10, Hash: "x", ContentHash: "c"},
+				{Path: "c", Size: 12, Hash: "y", ContentHash: "c"},
+				{Path: "d", Size: 12, Hash: "y"},
+			},
+			groups: [][]string{{"a", "b", "c", "d"}},
+		},
+		{
+			name: "different content",
+			files: []*MediaFile{
+				{Path: "a", Size: 10, Hash: "x", ContentHash: "c"},
+				{Path: "b", Size: 10, Hash: "y", ContentHash: "d"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := FindDuplicates(tt.files, 0, &DuplicatePolicy{})
+			if len(groups) != len(tt.groups) {
+				t.Fatalf("got %d groups, want %d", len(groups), len(tt.groups))
+			}
+			for i, group := range groups {
+				var paths []string
+				for _, mf := range group.Files {
+					paths = append(paths, mf.Path)
+				}
+				if !slices.Equal(paths, tt.groups[i]) {
+					t.Errorf("group %d = %v, want %v", i, paths, tt.groups[i])
+				}
+			}
+		})
+	}
+}
@@ -1,118 +1,316 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
 	"io"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
-// CalculateHashes calculates MD5 hashes for all files in parallel
-func CalculateHashes(files []*MediaFile, workers int, progressChan chan<- ScanProgress, cache *Cache) int {
-	var wg sync.WaitGroup
-	fileChan := make(chan *MediaFile, len(files))
+// uniqueHash marks a file that cannot have a duplicate (no other file shares its size,
+// or its head and tail): it is not a real hash, never cached and ignored by FindDuplicates
+const uniqueHash = "unique"
+
+// partialHashSize is how much of the head and of the tail is read for the cheap pre-check
+const partialHashSize = 4 * 1024
+
+// hashBufferPool holds read buffers for streaming files through the hash
+var hashBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 256*1024)
+		return &buf
+	},
+}
+
+// CalculateHashes calculates content hashes for all files in parallel. Only files that could
+// have a duplicate are read in full: files with a unique size are marked uniqueHash without
+// reading them, and same-size files are first compared by a head+tail hash. Cancelling ctx
+// stops hashing between files, leaving the rest without a hash, and a Pauser in ctx holds
+// it between files.
+func CalculateHashes(ctx context.Context, files []*MediaFile, workers int, algorithm string, progressChan chan<- ScanProgress, cache *Cache) int {
 	processed := 0
 	cacheHits := 0
+	var processedBytes, totalBytes int64
+	for _, mf := range files {
+		totalBytes += mf.Size
+	}
 	var mu sync.Mutex
 
-	// Start worker pool
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for mf := range fileChan {
-				// Try cache first
-				cached := false
-				if cache != nil {
-					info, err := os.Stat(mf.Path)
-					if err == nil {
-						if cf, ok := cache.Get(mf.Path, mf.Size, info.ModTime()); ok && cf.Hash != "" {
-							mf.Hash = cf.Hash
-							cached = true
-							mu.Lock()
-							cacheHits++
-							mu.Unlock()
-						}
-					}
-				}
-
-				// Calculate if not cached
-				if !cached {
-					hash, err := calculateFileHash(mf.Path)
-					if err == nil {
-						mf.Hash = hash
-
-						// Store in cache (queued asynchronously)
-						if cache != nil {
-							if info, err := os.Stat(mf.Path); err == nil {
-								cache.Put(mf, info.ModTime())
-							}
-						}
-					}
-				}
+	// finish counts a file done, hashed or not (its size too: a large file dominates the pass)
+	finish := func(mf *MediaFile) {
+		mu.Lock()
+		defer mu.Unlock()
+		processed++
+		processedBytes += mf.Size
+		if progressChan != nil {
+			select {
+			case progressChan <- ScanProgress{
+				ProcessedFiles: processed,
+				TotalFiles:     len(files),
+				ProcessedBytes: processedBytes,
+				TotalBytes:     totalBytes,
+				CurrentFile:    mf.Path,
+			}:
+			default:
+			}
+		}
+	}
 
+	// Try cache first
+	cached := make([]bool, len(files))
+	if cache != nil {
+		runParallel(workers, len(files), func(i int) {
+			mf := files[i]
+			info, err := os.Stat(mf.Path)
+			if err != nil {
+				return
+			}
+			// Hashes from another algorithm can't be compared, they are recalculated
+			if cf, ok := cache.Get(mf.Path, mf.Size, info.ModTime()); ok && cf.Hash != "" && cf.HashAlgo == algorithm {
+				mf.Hash = cf.Hash
+				mf.HashAlgo = cf.HashAlgo
+				cached[i] = true
 				mu.Lock()
-				processed++
-				if progressChan != nil {
-					select {
-					case progressChan <- ScanProgress{
-						ProcessedFiles: processed,
-						TotalFiles:     len(files),
-						CurrentFile:    mf.Path,
-					}:
-					default:
-					}
-				}
+				cacheHits++
 				mu.Unlock()
 			}
-		}()
+		})
 	}
 
-	// Send files to workers
-	for _, mf := range files {
-		fileChan <- mf
+	// Only files sharing their size with another file can be duplicates
+	bySize := make(map[int64][]int)
+	for i, mf := range files {
+		bySize[mf.Size] = append(bySize[mf.Size], i)
+	}
+
+	var needPartial []int
+	for _, group := range bySize {
+		uncached := 0
+		for _, i := range group {
+			if !cached[i] {
+				uncached++
+			}
+		}
+		switch {
+		case uncached == 0:
+			for _, i := range group {
+				finish(files[i])
+			}
+		case len(group) == 1:
+			files[group[0]].Hash = uniqueHash
+			finish(files[group[0]])
+		default:
+			// Cached members are needed too, to compare the uncached ones against
+			needPartial = append(needPartial, group...)
+		}
+	}
+
+	// Cheap head+tail hash for same-size files
+	partial := make([]string, len(files))
+	runParallel(workers, len(needPartial), func(n int) {
+		i := needPartial[n]
+		waitIfPaused(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if hash, err := calculatePartialHash(files[i].Path, files[i].Size, algorithm); err == nil {
+			partial[i] = hash
+		}
+	})
+
+	type partialKey struct {
+		size int64
+		hash string
+	}
+	byPartial := make(map[partialKey][]int)
+	for _, i := range needPartial {
+		if partial[i] == "" && !cached[i] {
+			finish(files[i]) // Unreadable, left without hash
+			continue
+		}
+		key := partialKey{files[i].Size, partial[i]}
+		byPartial[key] = append(byPartial[key], i)
+	}
+
+	var needFull []int
+	for key, group := range byPartial {
+		for _, i := range group {
+			mf := files[i]
+			switch {
+			case cached[i]:
+				finish(mf)
+			case len(group) == 1:
+				mf.Hash = uniqueHash
+				finish(mf)
+			case key.size <= 2*partialHashSize:
+				// The partial hash covered the whole file
+				mf.Hash = key.hash
+				mf.HashAlgo = algorithm
+				putHash(mf, cache)
+				finish(mf)
+			default:
+				needFull = append(needFull, i)
+			}
+		}
 	}
-	close(fileChan)
 
-	wg.Wait()
+	// Full hash for the remaining candidates
+	runParallel(workers, len(needFull), func(n int) {
+		mf := files[needFull[n]]
+		waitIfPaused(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if hash, err := calculateFileHash(mf.Path, algorithm); err == nil {
+			mf.Hash = hash
+			mf.HashAlgo = algorithm
+			putHash(mf, cache)
+		}
+		finish(mf)
+	})
+
 	return cacheHits
 }
 
-// calculateFileHash calculates MD5 hash of a file
-func calculateFileHash(path string) (string, error) {
+// putHash stores a freshly calculated hash in the cache (queued asynchronously)
+func putHash(mf *MediaFile, cache *Cache) {
+	if cache == nil {
+		return
+	}
+	if info, err := os.Stat(mf.Path); err == nil {
+		cache.Put(mf, info.ModTime())
+	}
+}
+
+// calculatePartialHash hashes the first and last partialHashSize bytes of a file
+// (the whole file when it is smaller than both together, matching calculateFileHash)
+func calculatePartialHash(path string, size int64, algorithm string) (string, error) {
+	if size <= 2*partialHashSize {
+		return calculateFileHash(path, algorithm)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 
-	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
+	buf := make([]byte, 2*partialHashSize)
+	if _, err := io.ReadFull(f, buf[:partialHashSize]); err != nil {
+		return "", err
+	}
+	if _, err := f.ReadAt(buf[partialHashSize:], size-partialHashSize); err != nil {
 		return "", err
 	}
 
-	return string(h.Sum(nil)), nil
+	h := newHasher(algorithm)
+	h.Write(buf)
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// FindDuplicates groups files by hash and identifies duplicates
-func FindDuplicates(files []*MediaFile) []*DuplicateGroup {
-	byHash := make(map[string][]*MediaFile)
+// hashWithCache returns a file's hash, from cache when it is still valid
+func hashWithCache(path, algorithm string, cache *Cache) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if cache != nil {
+		if cf, ok := cache.Get(path, info.Size(), info.ModTime()); ok && cf.Hash != "" && cf.HashAlgo == algorithm {
+			return cf.Hash, nil
+		}
+	}
+	return calculateFileHash(path, algorithm)
+}
+
+// newHasher returns a digest for a hash algorithm (HashXXHash for unknown names)
+func newHasher(algorithm string) hash.Hash {
+	switch algorithm {
+	case HashMD5:
+		return md5.New()
+	case HashSHA256:
+		return sha256.New()
+	default:
+		return newXXHash64()
+	}
+}
 
+// calculateFileHash streams a file through a hash algorithm (lowercase hex)
+func calculateFileHash(path, algorithm string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := hashBufferPool.Get().(*[]byte)
+	defer hashBufferPool.Put(buf)
+
+	h := newHasher(algorithm)
+	if _, err := io.CopyBuffer(h, f, *buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FindDuplicates groups files by hash and identifies duplicates, ignoring files smaller
+// than minSize bytes (not worth trashing); policy picks the file kept in each group.
+// Groups sharing a content hash (Config.ContentHash) are then merged, which also catches
+// copies that only differ in metadata.
+func FindDuplicates(files []*MediaFile, minSize int64, policy *DuplicatePolicy) []*DuplicateGroup {
+	// Union-find over hashes ("h:") and content hashes ("c:"): a file links its two keys
+	parent := make(map[string]string)
+	find := func(key string) string {
+		if _, ok := parent[key]; !ok {
+			parent[key] = key
+		}
+		for parent[key] != key {
+			parent[key] = parent[parent[key]]
+			key = parent[key]
+		}
+		return key
+	}
+	keyOf := make(map[*MediaFile]string)
 	for _, mf := range files {
-		if mf.Hash == "" {
+		if mf.Size < minSize {
 			continue
 		}
-		byHash[mf.Hash] = append(byHash[mf.Hash], mf)
+		hashed := mf.Hash != "" && mf.Hash != uniqueHash
+		switch {
+		case hashed && mf.ContentHash != "":
+			keyOf[mf] = "h:" + mf.Hash
+			if a, b := find("h:"+mf.Hash), find("c:"+mf.ContentHash); a != b {
+				parent[b] = a
+			}
+		case hashed:
+			keyOf[mf] = "h:" + mf.Hash
+		case mf.ContentHash != "":
+			keyOf[mf] = "c:" + mf.ContentHash
+		}
+	}
+
+	byHash := make(map[string][]*MediaFile)
+	for _, mf := range files {
+		if key, ok := keyOf[mf]; ok {
+			root := find(key)
+			byHash[root] = append(byHash[root], mf)
+		}
 	}
 
 	var duplicates []*DuplicateGroup
-	for hash, group := range byHash {
+	for root, group := range byHash {
 		if len(group) > 1 {
-			best := chooseBestDuplicate(group)
+			best := chooseBestDuplicate(group, policy)
 			duplicates = append(duplicates, &DuplicateGroup{
-				Hash:  hash,
+				Hash:  root[2:],
 				Files: group,
 				Best:  best,
 			})
@@ -122,54 +320,321 @@ func FindDuplicates(files []*MediaFile) []*DuplicateGroup {
 	return duplicates
 }
 
-// chooseBestDuplicate selects the best version from duplicates
-func chooseBestDuplicate(files []*MediaFile) *MediaFile {
-	scored := make(map[*MediaFile]int)
+// AddLibraryDuplicates also flags scanned files identical to a file already in the library
+// (DedupLibrary): the library file becomes the kept file of their group, so they are
+// trashed instead of imported again. Library files are looked up in the cache; only those
+// sharing a size with a scanned file are checked, and hashed when the cache has no
+// current hash for them (it only keeps hashes of files that had a same-size twin). With
+// content hashes, library files whose cached content hash matches count as well.
+func AddLibraryDuplicates(ctx context.Context, duplicates []*DuplicateGroup, files []*MediaFile, config *Config, cache *Cache) []*DuplicateGroup {
+	if cache == nil {
+		return duplicates
+	}
 
+	scanned := make(map[string]bool, len(files))
+	sizes := make(map[int64]bool)
+	contentHashes := make(map[string]bool)
 	for _, mf := range files {
-		score := 0
+		scanned[mf.Path] = true
+		if mf.Size >= config.DedupThreshold && !config.inLibrary(mf.Path) {
+			sizes[mf.Size] = true
+			if mf.ContentHash != "" {
+				contentHashes[mf.ContentHash] = true
+			}
+		}
+	}
+	cached, err := cache.FilesUnder(config.libraryRoots(), sizes, contentHashes)
+	if err != nil {
+		warnLog.Printf("library duplicates: reading the cache: %v", err)
+		return duplicates
+	}
 
-		// Prefer larger files (better quality)
-		score += int(mf.Size / 1024) // KB
+	// Library files still there (a scanned library already took part in FindDuplicates)
+	var library []*MediaFile
+	var unhashed []*MediaFile
+	librarySizes := make(map[int64]bool)
+	for _, cf := range cached {
+		if scanned[cf.Path] {
+			continue
+		}
+		info, err := os.Stat(cf.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		mf := &MediaFile{Path: cf.Path, Size: info.Size(), ModTime: info.ModTime(), Type: detectMediaType(cf.Path)}
+		current := cf.Size == info.Size() && cf.ModTime == info.ModTime().Unix()
+		if current && config.ContentHash {
+			mf.ContentHash = cf.ContentHash
+		}
+		if !sizes[mf.Size] {
+			// Found by its content hash only
+			if mf.ContentHash != "" {
+				library = append(library, mf)
+			}
+			continue
+		}
+		if current && cf.Hash != "" && cf.HashAlgo == config.HashAlgorithm {
+			mf.Hash, mf.HashAlgo = cf.Hash, cf.HashAlgo
+		} else {
+			unhashed = append(unhashed, mf)
+		}
+		library = append(library, mf)
+		librarySizes[mf.Size] = true
+	}
 
-		// Prefer non-Recovered paths (+1000000)
-		if !strings.Contains(mf.Path, "/Recovered/") {
-			score += 1000000
+	// Scanned files of those sizes that CalculateHashes skipped (no same-size twin in the scan)
+	for _, mf := range files {
+		if librarySizes[mf.Size] && (mf.Hash == "" || mf.Hash == uniqueHash) && !config.inLibrary(mf.Path) {
+			unhashed = append(unhashed, mf)
+		}
+	}
+	runParallel(config.Workers, len(unhashed), func(i int) {
+		mf := unhashed[i]
+		waitIfPaused(ctx)
+		if ctx.Err() != nil {
+			return
 		}
+		if hash, err := calculateFileHash(mf.Path, config.HashAlgorithm); err == nil {
+			mf.Hash = hash
+			mf.HashAlgo = config.HashAlgorithm
+			putHash(mf, cache)
+		}
+	})
 
-		// Prefer organized paths
-		for _, pattern := range []string{"/Photo/", "/Pictures/", "/Video/", "/Music/"} {
-			if strings.Contains(mf.Path, pattern) {
-				score += 500000
+	byHash := make(map[string]*MediaFile)
+	byContent := make(map[string]*MediaFile)
+	for _, mf := range library {
+		if mf.Hash != "" && mf.Hash != uniqueHash {
+			byHash[mf.Hash] = mf
+		}
+		if mf.ContentHash != "" {
+			byContent[mf.ContentHash] = mf
+		}
+	}
+	libraryCopy := func(mf *MediaFile) *MediaFile {
+		if kept, ok := byHash[mf.Hash]; ok {
+			return kept
+		}
+		if mf.ContentHash != "" {
+			return byContent[mf.ContentHash]
+		}
+		return nil
+	}
+
+	// Groups found in the scan keep the library copy instead
+	groupOf := make(map[*MediaFile]*DuplicateGroup)
+	for _, group := range duplicates {
+		for _, mf := range group.Files {
+			groupOf[mf] = group
+		}
+		if config.inLibrary(group.Best.Path) {
+			continue
+		}
+		for _, mf := range group.Files {
+			if kept := libraryCopy(mf); kept != nil {
+				group.Files = append(group.Files, kept)
+				group.Best = kept
+				groupOf[kept] = group
 				break
 			}
 		}
+	}
+	for _, mf := range files {
+		if mf.Size < config.DedupThreshold || config.inLibrary(mf.Path) {
+			continue
+		}
+		kept := libraryCopy(mf)
+		if kept == nil {
+			continue
+		}
+		if group, ok := groupOf[kept]; ok {
+			if !slices.Contains(group.Files, mf) {
+				group.Files = append(group.Files, mf)
+				groupOf[mf] = group
+			}
+			continue
+		}
+		if _, ok := groupOf[mf]; ok {
+			continue // Its group kept another library file
+		}
+		debugLog.Printf("duplicate %s: already in the library as %s", mf.Path, kept.Path)
+		group := &DuplicateGroup{Hash: mf.Hash, Files: []*MediaFile{kept, mf}, Best: kept}
+		if kept.Hash != mf.Hash {
+			group.Hash = mf.ContentHash
+		}
+		groupOf[kept], groupOf[mf] = group, group
+		duplicates = append(duplicates, group)
+	}
+	return duplicates
+}
 
-		// Penalize UNNAMED
-		if strings.Contains(mf.Path, "/UNNAMED_") {
-			score -= 500000
+// withoutLibraryDuplicates leaves out the files AddLibraryDuplicates found in the library
+// already, so they get no album
+func withoutLibraryDuplicates(files []*MediaFile, duplicates []*DuplicateGroup, config *Config) []*MediaFile {
+	known := make(map[*MediaFile]bool)
+	for _, group := range duplicates {
+		if !config.inLibrary(group.Best.Path) {
+			continue
+		}
+		for _, mf := range group.Files {
+			if mf != group.Best && !config.inLibrary(mf.Path) {
+				known[mf] = true
+			}
 		}
+	}
+	if len(known) == 0 {
+		return files
+	}
+
+	kept := make([]*MediaFile, 0, len(files)-len(known))
+	for _, mf := range files {
+		if !known[mf] {
+			kept = append(kept, mf)
+		}
+	}
+	return kept
+}
 
-		// Prefer files with more metadata
+// ReclaimableBytes returns bytes that would be freed by removing all but the best file of each group
+func ReclaimableBytes(duplicates []*DuplicateGroup) int64 {
+	var total int64
+	for _, group := range duplicates {
+		for _, mf := range group.Files {
+			if mf != group.Best {
+				total += mf.Size
+			}
+		}
+	}
+	return total
+}
+
+// DuplicateRule adds Score to duplicates whose path contains any of the substrings
+// (counted once per rule; negative scores penalize)
+type DuplicateRule struct {
+	Contains []string `yaml:"contains"`
+	Score    int      `yaml:"score"`
+}
+
+// DuplicatePolicy decides which file of a duplicate group is kept: Keep picks the file
+// outright unless it is best-score; otherwise, and on ties, the highest score from rules,
+// size and metadata wins, then (optionally) the oldest, then the first path
+type DuplicatePolicy struct {
+	Keep           string // DuplicateKeepBestScore, DuplicateKeepOldest, ...
+	Rules          []DuplicateRule
+	PreferLarger   bool // +1 per KB (matters for near-duplicates, exact ones are equal)
+	PreferMetadata bool // +10000 each for camera and album tags
+	PreferOldest   bool // Oldest modification time breaks score ties
+}
+
+// defaultDuplicateRules keep originals over recovered copies and unnamed recovery dumps,
+// and prefer files already in an organized folder
+var defaultDuplicateRules = []DuplicateRule{
+	{Contains: []string{"/Recovered/"}, Score: -1000000},
+	{Contains: []string{"/Photo/", "/Pictures/", "/Video/", "/Music/"}, Score: 500000},
+	{Contains: []string{"/UNNAMED_"}, Score: -500000},
+}
+
+// defaultDuplicatePolicy is used when nothing is configured
+var defaultDuplicatePolicy = DuplicatePolicy{
+	Keep:           DuplicateKeepBestScore,
+	Rules:          defaultDuplicateRules,
+	PreferLarger:   true,
+	PreferMetadata: true,
+}
+
+// score rates one file of a duplicate group
+func (p *DuplicatePolicy) score(mf *MediaFile) int {
+	score := 0
+
+	if p.PreferLarger {
+		score += int(mf.Size / 1024) // KB
+	}
+
+	for _, rule := range p.Rules {
+		for _, pattern := range rule.Contains {
+			if strings.Contains(mf.Path, pattern) {
+				score += rule.Score
+				break
+			}
+		}
+	}
+
+	// Prefer files with more metadata
+	if p.PreferMetadata {
 		if mf.CameraMake != "" {
 			score += 10000
 		}
 		if mf.Album != "" {
 			score += 10000
 		}
+	}
+
+	return score
+}
+
+// chooseBestDuplicate selects the best version from duplicates
+func chooseBestDuplicate(files []*MediaFile, policy *DuplicatePolicy) *MediaFile {
+	scored := make(map[*MediaFile]int)
+	modTimes := make(map[*MediaFile]time.Time)
 
-		scored[mf] = score
+	byAge := policy.Keep == DuplicateKeepOldest || policy.Keep == DuplicateKeepNewest
+	for _, mf := range files {
+		scored[mf] = policy.score(mf)
+		if byAge || policy.PreferOldest {
+			if info, err := os.Stat(mf.Path); err == nil {
+				modTimes[mf] = info.ModTime()
+			}
+		}
 	}
 
-	// Sort by score
 	sort.Slice(files, func(i, j int) bool {
-		si, sj := scored[files[i]], scored[files[j]]
-		if si != sj {
+		a, b := files[i], files[j]
+		switch policy.Keep {
+		case DuplicateKeepOldest, DuplicateKeepNewest:
+			if ta, tb := ageTimes(a, b, modTimes); !ta.Equal(tb) {
+				if ta.IsZero() || tb.IsZero() {
+					return tb.IsZero() // Unknown times last
+				}
+				return ta.Before(tb) == (policy.Keep == DuplicateKeepOldest)
+			}
+		case DuplicateKeepLargest:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case DuplicateKeepShortestPath:
+			if len(a.Path) != len(b.Path) {
+				return len(a.Path) < len(b.Path)
+			}
+		}
+
+		// Sort by score
+		if si, sj := scored[a], scored[b]; si != sj {
 			return si > sj
 		}
+		if policy.PreferOldest {
+			if ti, tj := modTimes[a], modTimes[b]; !ti.Equal(tj) {
+				return tj.IsZero() || (!ti.IsZero() && ti.Before(tj)) // Unknown times last
+			}
+		}
 		// Tiebreaker: alphabetical
-		return files[i].Path < files[j].Path
+		return a.Path < b.Path
 	})
 
 	return files[0]
 }
+
+// ageTimes returns the times two files are compared by when keeping the oldest or
+// newest: their dates taken, or their modification times if those don't differ
+func ageTimes(a, b *MediaFile, modTimes map[*MediaFile]time.Time) (time.Time, time.Time) {
+	var ta, tb time.Time
+	if a.DateTaken != nil {
+		ta = *a.DateTaken
+	}
+	if b.DateTaken != nil {
+		tb = *b.DateTaken
+	}
+	if ta.Equal(tb) {
+		return modTimes[a], modTimes[b]
+	}
+	return ta, tb
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCalculateFileHashHex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.jpg")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		algorithm string
+		want      string
+	}{
+		{HashMD5, "5d41402abc4b2a76b9719d911017c592"},
+		{HashSHA256, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{HashXXHash, "26c7827d889f6da3"},
+	}
+	for _, tt := range tests {
+		got, err := calculateFileHash(path, tt.algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: hash = %s, want %s", tt.algorithm, got, tt.want)
+		}
+	}
+}
+
+func TestHashCacheRoundTrip(t *testing.T) {
+	cache := openTestCache(t)
+	path := filepath.Join(t.TempDir(), "a.jpg")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := calculateFileHash(path, HashMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf := &MediaFile{Path: path, Size: info.Size(), Type: TypePhoto, Hash: hash, HashAlgo: HashMD5}
+	cache.Put(mf, info.ModTime())
+	cache.flush()
+
+	// Stored as text, read back unchanged
+	cf, ok := cache.Get(path, info.Size(), info.ModTime())
+	if !ok || cf.Hash != hash || cf.HashAlgo != HashMD5 {
+		t.Errorf("cached hash = %q (%s), %v, want %q", cf.Hash, cf.HashAlgo, ok, hash)
+	}
+}
+
+func TestDuplicatesGroupedByHash(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string]string{
+		"a.jpg":      "beach",
+		"copy_a.jpg": "beach",
+		"b.jpg":      "beach!", // Different size
+		"c.jpg":      "beech",  // Same size, different content
+		"copy_c.jpg": "beech",
+	}
+	var files []*MediaFile
+	for name, content := range contents {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: int64(len(content)), Type: TypePhoto})
+	}
+
+	for _, algorithm := range []string{HashXXHash, HashMD5, HashSHA256} {
+		for _, mf := range files {
+			mf.Hash = ""
+		}
+		CalculateHashes(t.Context(), files, 2, algorithm, nil, nil)
+		groups := FindDuplicates(files, 0, &DuplicatePolicy{})
+		if len(groups) != 2 {
+			t.Fatalf("%s: %d groups, want 2", algorithm, len(groups))
+		}
+		for _, group := range groups {
+			if len(group.Files) != 2 {
+				t.Errorf("%s: group %s has %d files, want 2", algorithm, group.Hash, len(group.Files))
+				continue
+			}
+			a, b := filepath.Base(group.Files[0].Path), filepath.Base(group.Files[1].Path)
+			if contents[a] != contents[b] {
+				t.Errorf("%s: %s and %s grouped, their contents differ", algorithm, a, b)
+			}
+		}
+	}
+}
+
+func TestCalculateHashesMissesNoDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	big := func(fill byte, middle byte) []byte {
+		data := bytes.Repeat([]byte{fill}, 5*partialHashSize)
+		data[len(data)/2] = middle
+		return data
+	}
+	contents := map[string][]byte{
+		"big.jpg":           big('a', 'x'),
+		"big_copy.jpg":      big('a', 'x'),
+		"big_middle.jpg":    big('a', 'y'), // Same size, head and tail: only the full hash tells
+		"big_head.jpg":      append([]byte{'b'}, big('a', 'x')[1:]...),
+		"small.jpg":         []byte("small file"),
+		"small_copy.jpg":    []byte("small file"),
+		"small_other.jpg":   []byte("other file"),
+		"one_of_a_kind.jpg": []byte("no other file has this size"),
+		"cached.jpg":        big('c', 'z'),
+		"cached_copy.jpg":   big('c', 'z'), // Duplicate of a file hashed in an earlier run
+	}
+
+	cache := openTestCache(t)
+	var files []*MediaFile
+	for name, data := range contents {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: int64(len(data)), Type: TypePhoto})
+	}
+	cachedPath := filepath.Join(dir, "cached.jpg")
+	info, err := os.Stat(cachedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := calculateFileHash(cachedPath, HashXXHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(&MediaFile{Path: cachedPath, Size: info.Size(), Type: TypePhoto, Hash: hash, HashAlgo: HashXXHash}, info.ModTime())
+	cache.flush()
+
+	if hits := CalculateHashes(t.Context(), files, 4, HashXXHash, nil, cache); hits != 1 {
+		t.Errorf("%d cache hits, want 1", hits)
+	}
+
+	// Every pair with identical content must share a real hash, and no other pair may
+	for i, a := range files {
+		if a.Hash == "" {
+			t.Errorf("%s: no hash", a.Path)
+		}
+		for _, b := range files[i+1:] {
+			same := bytes.Equal(contents[filepath.Base(a.Path)], contents[filepath.Base(b.Path)])
+			grouped := a.Hash == b.Hash && a.Hash != uniqueHash
+			if same != grouped {
+				t.Errorf("%s (%s) and %s (%s): identical %v, same hash %v",
+					filepath.Base(a.Path), a.Hash, filepath.Base(b.Path), b.Hash, same, grouped)
+			}
+		}
+	}
+	if got := files[slices.IndexFunc(files, func(mf *MediaFile) bool {
+		return filepath.Base(mf.Path) == "one_of_a_kind.jpg"
+	})].Hash; got != uniqueHash {
+		t.Errorf("file with a unique size hashed %q, want %q", got, uniqueHash)
+	}
+
+	groups := FindDuplicates(files, 0, &DuplicatePolicy{})
+	if len(groups) != 3 {
+		t.Errorf("%d duplicate groups, want 3", len(groups))
+	}
+}
+
+func TestCalculateHashesByteProgress(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string][]byte{
+		"video.mp4":  bytes.Repeat([]byte{'v'}, 3*partialHashSize), // Hashed in full
+		"video2.mp4": bytes.Repeat([]byte{'v'}, 3*partialHashSize),
+		"other.mp4":  bytes.Repeat([]byte{'o'}, 3*partialHashSize), // Told apart by head and tail
+		"photo.jpg":  []byte("unique size, never read"),
+		"empty.jpg":  {},
+	}
+	var files []*MediaFile
+	var want int64
+	for name, data := range contents {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: info.Size(), Type: TypeVideo})
+		want += info.Size()
+	}
+
+	cache := openTestCache(t)
+	for _, pass := range []string{"hashing", "from the cache"} {
+		progress := make(chan ScanProgress, len(files)) // Room for every update, none dropped
+		CalculateHashes(t.Context(), files, 2, HashXXHash, progress, cache)
+		close(progress)
+		cache.flush()
+
+		var last ScanProgress
+		for prog := range progress {
+			if prog.TotalBytes != want {
+				t.Errorf("%s: total %d bytes, want %d", pass, prog.TotalBytes, want)
+			}
+			if prog.ProcessedBytes < last.ProcessedBytes || prog.ProcessedFiles != last.ProcessedFiles+1 {
+				t.Errorf("%s: update %+v after %+v", pass, prog, last)
+			}
+			last = prog
+		}
+		if last.ProcessedFiles != len(files) || last.ProcessedBytes != want {
+			t.Errorf("%s: processed %d files, %d bytes, want %d, %d", pass, last.ProcessedFiles, last.ProcessedBytes, len(files), want)
+		}
+		if last.Fraction() != 1 {
+			t.Errorf("%s: finished at %.2f", pass, last.Fraction())
+		}
+	}
+}
+
+// benchmarkHashFiles writes count files of size bytes, all the same size when sameSize is set
+// (each file differs in its last byte, so nothing is a duplicate either way)
+func benchmarkHashFiles(b *testing.B, count, size int, sameSize bool) []*MediaFile {
+	dir := b.TempDir()
+	var files []*MediaFile
+	for i := range count {
+		n := size
+		if !sameSize {
+			n += i
+		}
+		data := bytes.Repeat([]byte{0x5A}, n)
+		data[n-1] = byte(i)
+		path := filepath.Join(dir, fmt.Sprintf("%03d.jpg", i))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: int64(n), Type: TypePhoto})
+	}
+	return files
+}
+
+// BenchmarkCalculateHashes shows what the size and head+tail pre-checks save over
+// hashing every file in full
+func BenchmarkCalculateHashes(b *testing.B) {
+	const count, size = 32, 1 << 20
+	for _, bm := range []struct {
+		name     string
+		sameSize bool
+	}{{"unique sizes", false}, {"same size", true}} {
+		b.Run(bm.name, func(b *testing.B) {
+			files := benchmarkHashFiles(b, count, size, bm.sameSize)
+			b.SetBytes(count * size)
+			b.ResetTimer()
+			for range b.N {
+				for _, mf := range files {
+					mf.Hash = ""
+				}
+				CalculateHashes(b.Context(), files, 4, HashXXHash, nil, nil)
+			}
+		})
+	}
+	b.Run("full hash only", func(b *testing.B) {
+		files := benchmarkHashFiles(b, count, size, true)
+		b.SetBytes(count * size)
+		b.ResetTimer()
+		for range b.N {
+			runParallel(4, len(files), func(i int) {
+				calculateFileHash(files[i].Path, HashXXHash)
+			})
+		}
+	})
+}
+
+func TestCachedHashFromAnotherAlgorithmIgnored(t *testing.T) {
+	cache := openTestCache(t)
+	dir := t.TempDir()
+	var files []*MediaFile
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("same bytes"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mf := &MediaFile{Path: path, Size: info.Size(), Type: TypePhoto}
+		files = append(files, mf)
+
+		// Hashed with MD5 in an earlier run
+		cache.Put(&MediaFile{Path: path, Size: info.Size(), Type: TypePhoto, Hash: "5d41402abc4b2a76b9719d911017c592", HashAlgo: HashMD5}, info.ModTime())
+	}
+	cache.flush()
+
+	want, err := calculateFileHash(files[0].Path, HashXXHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits := CalculateHashes(t.Context(), files, 2, HashXXHash, nil, cache); hits != 0 {
+		t.Errorf("%d cache hits for MD5 entries in an xxHash run, want 0", hits)
+	}
+	for _, mf := range files {
+		if mf.Hash != want || mf.HashAlgo != HashXXHash {
+			t.Errorf("%s: hash %q (%s), want %q (%s)", mf.Path, mf.Hash, mf.HashAlgo, want, HashXXHash)
+		}
+	}
+	if got, err := hashWithCache(files[0].Path, HashXXHash, cache); err != nil || got != want {
+		t.Errorf("hashWithCache = %q, %v, want %q", got, err, want)
+	}
+
+	// The recalculated hashes replace the stale ones and are used by the next run
+	cache.flush()
+	for _, mf := range files {
+		mf.Hash, mf.HashAlgo = "", ""
+	}
+	if hits := CalculateHashes(t.Context(), files, 2, HashXXHash, nil, cache); hits != 2 {
+		t.Errorf("%d cache hits on the second run, want 2", hits)
+	}
+}
+
+// BenchmarkCalculateFileHash compares the throughput of the hash algorithms
+func BenchmarkCalculateFileHash(b *testing.B) {
+	const size = 16 << 20
+	path := filepath.Join(b.TempDir(), "big.jpg")
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, algorithm := range []string{HashXXHash, HashMD5, HashSHA256} {
+		b.Run(algorithm, func(b *testing.B) {
+			b.SetBytes(size)
+			for range b.N {
+				if _, err := calculateFileHash(path, algorithm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// dupFile describes one file of a duplicate group written by writeDuplicates
+type dupFile struct {
+	path   string // Slash-relative
+	size   int64
+	camera string
+	age    int // Days since last modified
+}
+
+// writeDuplicates writes a duplicate group below a temp dir, with the given sizes (as
+// recorded by the scan, not written) and modification times
+func writeDuplicates(t *testing.T, specs ...dupFile) []*MediaFile {
+	t.Helper()
+	root := t.TempDir()
+	now := time.Now()
+	var files []*MediaFile
+	for _, spec := range specs {
+		path := filepath.Join(root, filepath.FromSlash(spec.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.AddDate(0, 0, -spec.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: spec.size, Type: TypePhoto, CameraMake: spec.camera})
+	}
+	return files
+}
+
+func TestChooseBestDuplicateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DuplicatePolicy
+		files  []dupFile
+		want   string
+	}{
+		{
+			name:   "default: recovered copy loses",
+			policy: defaultDuplicatePolicy,
+			files:  []dupFile{{path: "Recovered/a.jpg", size: 4096}, {path: "dump/a.jpg", size: 1024}},
+			want:   "dump/a.jpg",
+		},
+		{
+			name:   "default: organized folder wins",
+			policy: defaultDuplicatePolicy,
+			files:  []dupFile{{path: "dump/a.jpg"}, {path: "Pictures/a.jpg"}, {path: "UNNAMED_1/a.jpg"}},
+			want:   "Pictures/a.jpg",
+		},
+		{
+			name:   "default: larger, then more metadata",
+			policy: defaultDuplicatePolicy,
+			files:  []dupFile{{path: "a/a.jpg", size: 2048}, {path: "b/a.jpg", size: 8192}, {path: "c/a.jpg", size: 2048, camera: "Canon"}},
+			want:   "c/a.jpg",
+		},
+		{
+			name:   "custom rules replace the defaults",
+			policy: DuplicatePolicy{Rules: []DuplicateRule{{Contains: []string{"/backup/", "/old/"}, Score: -10}, {Contains: []string{"/master/"}, Score: 5}}},
+			files:  []dupFile{{path: "Pictures/a.jpg"}, {path: "master/a.jpg"}, {path: "backup/master/a.jpg"}},
+			want:   "master/a.jpg",
+		},
+		{
+			name:   "size ignored",
+			policy: DuplicatePolicy{PreferMetadata: true},
+			files:  []dupFile{{path: "a/a.jpg", size: 8192}, {path: "b/a.jpg", size: 1024, camera: "Canon"}},
+			want:   "b/a.jpg",
+		},
+		{
+			name:   "metadata ignored",
+			policy: DuplicatePolicy{PreferLarger: true},
+			files:  []dupFile{{path: "a/a.jpg", size: 1024, camera: "Canon"}, {path: "b/a.jpg", size: 8192}},
+			want:   "b/a.jpg",
+		},
+		{
+			name:   "oldest breaks ties",
+			policy: DuplicatePolicy{PreferOldest: true},
+			files:  []dupFile{{path: "a/a.jpg", age: 1}, {path: "b/a.jpg", age: 30}, {path: "c/a.jpg", age: 7}},
+			want:   "b/a.jpg",
+		},
+		{
+			name:   "score before age",
+			policy: DuplicatePolicy{PreferOldest: true, Rules: []DuplicateRule{{Contains: []string{"/keep/"}, Score: 1}}},
+			files:  []dupFile{{path: "a/a.jpg", age: 30}, {path: "keep/a.jpg", age: 1}},
+			want:   "keep/a.jpg",
+		},
+		{
+			name:   "no preferences: first path",
+			policy: DuplicatePolicy{},
+			files:  []dupFile{{path: "b/a.jpg", size: 8192, age: 30}, {path: "a/a.jpg", size: 1024}},
+			want:   "a/a.jpg",
+		},
+	}
+	for _, tt := range tests {
+		files := writeDuplicates(t, tt.files...)
+		root := filepath.Dir(filepath.Dir(files[0].Path))
+		best := chooseBestDuplicate(files, &tt.policy)
+		if got, _ := filepath.Rel(root, best.Path); filepath.ToSlash(got) != tt.want {
+			t.Errorf("%s: kept %s, want %s", tt.name, filepath.ToSlash(got), tt.want)
+		}
+	}
+}
+
+func TestChooseBestDuplicateKeepModes(t *testing.T) {
+	taken := func(year int) *time.Time {
+		d := time.Date(year, 6, 1, 12, 0, 0, 0, time.UTC)
+		return &d
+	}
+	tests := []struct {
+		keep string
+		want string
+	}{
+		{DuplicateKeepBestScore, "Pictures/a.jpg"},
+		{DuplicateKeepOldest, "x/a.jpg"}, // Taken the same day as scans/2001/a.jpg, modified earlier
+		{DuplicateKeepNewest, "Pictures/a.jpg"},
+		{DuplicateKeepLargest, "scans/2001/a.jpg"},
+		{DuplicateKeepShortestPath, "x/a.jpg"},
+	}
+	for _, tt := range tests {
+		files := writeDuplicates(t,
+			dupFile{path: "Pictures/a.jpg", size: 1024, age: 10},
+			dupFile{path: "scans/2001/a.jpg", size: 8192, age: 1},
+			dupFile{path: "x/a.jpg", size: 2048, age: 30})
+		files[0].DateTaken, files[1].DateTaken, files[2].DateTaken = taken(2019), taken(2015), taken(2015)
+		root := filepath.Dir(filepath.Dir(files[0].Path))
+
+		policy := defaultDuplicatePolicy
+		policy.Keep = tt.keep
+		best := chooseBestDuplicate(files, &policy)
+		if got, _ := filepath.Rel(root, best.Path); filepath.ToSlash(got) != tt.want {
+			t.Errorf("%s: kept %s, want %s", tt.keep, filepath.ToSlash(got), tt.want)
+		}
+	}
+
+	// Without dates taken, modification times decide
+	for keep, want := range map[string]string{DuplicateKeepOldest: "x/a.jpg", DuplicateKeepNewest: "scans/2001/a.jpg"} {
+		files := writeDuplicates(t,
+			dupFile{path: "Pictures/a.jpg", age: 10},
+			dupFile{path: "scans/2001/a.jpg", age: 1},
+			dupFile{path: "x/a.jpg", age: 30})
+		root := filepath.Dir(filepath.Dir(files[0].Path))
+		best := chooseBestDuplicate(files, &DuplicatePolicy{Keep: keep})
+		if got, _ := filepath.Rel(root, best.Path); filepath.ToSlash(got) != want {
+			t.Errorf("%s by modification time: kept %s, want %s", keep, filepath.ToSlash(got), want)
+		}
+	}
+}
+
+func TestLibraryDuplicateTrashed(t *testing.T) {
+	album, config := newTestAlbum(t, "IMG_1.jpg", "IMG_2.jpg", "IMG_3.jpg")
+	config.DedupLibrary = true
+	cache, err := OpenCache(config.LibraryBase, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	// IMG_1.jpg was imported by an earlier run, under another name
+	imported := filepath.Join(config.LibraryBase, "Photos", "2019", "2019-07 Trip", "beach.jpg")
+	if err := os.MkdirAll(filepath.Dir(imported), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(imported, []byte("IMG_1.jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := calculateFileHash(imported, config.HashAlgorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(&MediaFile{Path: imported, Size: info.Size(), Type: TypePhoto, Hash: hash, HashAlgo: config.HashAlgorithm}, info.ModTime())
+	cache.flush()
+
+	files := album.Files
+	CalculateHashes(t.Context(), files, 1, config.HashAlgorithm, nil, cache)
+	duplicates := FindDuplicates(files, 0, &config.DuplicatePolicy)
+	if len(duplicates) != 0 {
+		t.Fatalf("%d duplicate groups within the scan, want none", len(duplicates))
+	}
+	duplicates = AddLibraryDuplicates(t.Context(), duplicates, files, config, cache)
+	if len(duplicates) != 1 || duplicates[0].Best.Path != imported || len(duplicates[0].Files) != 2 {
+		t.Fatalf("duplicate groups %v, want IMG_1.jpg with the library copy kept", duplicates)
+	}
+	trashed := duplicates[0].Files[1]
+	if filepath.Base(trashed.Path) != "IMG_1.jpg" {
+		t.Fatalf("%s grouped with the library copy, want IMG_1.jpg", trashed.Path)
+	}
+	album.Files = withoutLibraryDuplicates(files, duplicates, config)
+	if len(album.Files) != 2 || slices.Contains(album.Files, trashed) {
+		t.Fatalf("album keeps %d files, want IMG_2.jpg and IMG_3.jpg", len(album.Files))
+	}
+
+	result, err := ExecuteOrganization(t.Context(), []*Album{album}, duplicates, config, nil, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 3 || result.Failed != 0 {
+		t.Errorf("moved %d, failed %d, want 3 moved (one to the trash)", result.Moved, result.Failed)
+	}
+	if _, err := os.Stat(filepath.Join(config.LibraryBase, "trip", "IMG_1.jpg")); !os.IsNotExist(err) {
+		t.Error("IMG_1.jpg imported again")
+	}
+	if _, err := os.Stat(trashPath(&MediaFile{Path: filepath.Join(config.ScanPaths[0], "trip", "IMG_1.jpg")}, config)); err != nil {
+		t.Errorf("IMG_1.jpg not in the trash: %v", err)
+	}
+	if _, err := os.Stat(imported); err != nil {
+		t.Errorf("library copy gone: %v", err)
+	}
+}
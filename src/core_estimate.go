@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Rough costs behind PlanEstimate: copies are bound by disk throughput (a USB or
+// network disk; local SSDs are faster), renames and links by per-file metadata updates
+const (
+	estimateCopyRate   = 60 << 20 // Bytes per second
+	estimateRenameCost = 2 * time.Millisecond
+)
+
+// PlanEstimate predicts how much work executing a plan is: files that can be renamed
+// or linked in place are quick, files crossing filesystems (or copied) are slow
+type PlanEstimate struct {
+	Renames     int   // Moves and hardlinks within a filesystem, symlinks
+	Copies      int   // Copies, and moves or hardlinks across filesystems
+	CopyBytes   int64 // Bytes written by the copies
+	crossDevice map[*Album]int64
+	devices     map[string]deviceID
+}
+
+// deviceID is a filesystem's device number (ok false where it can't be told)
+type deviceID struct {
+	dev uint64
+	ok  bool
+}
+
+// errDeviceUnknown is returned where the platform doesn't expose device numbers
+var errDeviceUnknown = errors.New("device number not available on this platform")
+
+// sameDevice reports whether two paths are on the same filesystem. A path that doesn't
+// exist yet counts as its nearest existing parent.
+func sameDevice(a, b string) (bool, error) {
+	devA, err := deviceOf(a)
+	if err != nil {
+		return false, err
+	}
+	devB, err := deviceOf(b)
+	if err != nil {
+		return false, err
+	}
+	return devA == devB, nil
+}
+
+// deviceOf returns the device number of path or its nearest existing parent
+func deviceOf(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if parent := filepath.Dir(path); parent != path {
+			return deviceOf(parent)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, ok := fileIdentityOf(info)
+	if !ok {
+		return 0, errDeviceUnknown
+	}
+	return id.dev, nil
+}
+
+// EstimatePlan sorts the album files and trashed duplicates into renames and copies by
+// comparing the device IDs of their source and destination folders
+func EstimatePlan(albums []*Album, duplicates []*DuplicateGroup, config *Config) *PlanEstimate {
+	est := &PlanEstimate{crossDevice: make(map[*Album]int64), devices: make(map[string]deviceID)}
+
+	for _, album := range albums {
+		for _, file := range album.Files {
+			dst := album.DestPath(file)
+			if file.Path == dst {
+				continue // Already in place
+			}
+			if est.add(file, dst, config.OrganizeMode) && config.OrganizeMode != OrganizeCopy {
+				est.crossDevice[album] += file.Size
+			}
+		}
+	}
+
+	// Duplicates are only trashed (moved) in move mode
+	if config.OrganizeMode == OrganizeMove {
+		for _, group := range duplicates {
+			for _, file := range group.Files {
+				if file != group.Best {
+					est.add(file, trashPath(file, config), OrganizeMove)
+				}
+			}
+		}
+	}
+
+	return est
+}
+
+// add counts one file placed at dst in the given organize mode, reporting whether it's copied
+func (e *PlanEstimate) add(file *MediaFile, dst, mode string) bool {
+	copied := false
+	switch mode {
+	case OrganizeCopy:
+		copied = true
+	case OrganizeMove, OrganizeHardlink:
+		copied = !e.sameDevice(filepath.Dir(file.Path), filepath.Dir(dst))
+	}
+
+	if copied {
+		e.Copies++
+		e.CopyBytes += file.Size
+	} else {
+		e.Renames++
+	}
+	return copied
+}
+
+// sameDevice compares the devices of two folders, looked up once per folder. Unknown
+// devices are assumed the same (the executor tries a rename first anyway).
+func (e *PlanEstimate) sameDevice(a, b string) bool {
+	devA, devB := e.device(a), e.device(b)
+	if !devA.ok || !devB.ok {
+		return true
+	}
+	return devA.dev == devB.dev
+}
+
+// device returns the remembered device of dir
+func (e *PlanEstimate) device(dir string) deviceID {
+	id, ok := e.devices[dir]
+	if !ok {
+		dev, err := deviceOf(dir)
+		id = deviceID{dev: dev, ok: err == nil}
+		e.devices[dir] = id
+	}
+	return id
+}
+
+// CrossDeviceBytes is how much of an album is moved or hardlinked across filesystems,
+// and so copied (0 for albums staying on one filesystem, and in copy mode)
+func (e *PlanEstimate) CrossDeviceBytes(album *Album) int64 {
+	return e.crossDevice[album]
+}
+
+// Duration is the rough time executing the plan takes
+func (e *PlanEstimate) Duration() time.Duration {
+	return time.Duration(e.Renames)*estimateRenameCost +
+		time.Duration(float64(e.CopyBytes)/estimateCopyRate*float64(time.Second))
+}
+
+// String summarizes the estimate, e.g. "1200 renames, 35 copies (8.2 GB to write), about 3m"
+func (e *PlanEstimate) String() string {
+	return fmt.Sprintf("%d renames, %d copies (%s to write), %s",
+		e.Renames, e.Copies, formatBytes(e.CopyBytes), formatEstimate(e.Duration()))
+}
+
+// formatEstimate rounds a duration to what an estimate can promise
+func formatEstimate(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "under a minute"
+	case d < time.Hour:
+		return fmt.Sprintf("about %dm", int(d.Round(time.Minute).Minutes()))
+	default:
+		d = d.Round(10 * time.Minute)
+		return fmt.Sprintf("about %dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// otherFilesystem returns a temporary directory on another filesystem than the temp dir,
+// skipping the test where the system has none
+func otherFilesystem(t *testing.T) string {
+	t.Helper()
+	other, err := os.MkdirTemp("/dev/shm", "library")
+	if err != nil {
+		t.Skip("no /dev/shm")
+	}
+	t.Cleanup(func() { os.RemoveAll(other) })
+	if same, err := sameDevice(other, t.TempDir()); err != nil || same {
+		t.Skip("/dev/shm is on the temp dir's filesystem")
+	}
+	return other
+}
+
+func TestSameDevice(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	if _, err := sameDevice(a, b); errors.Is(err, errDeviceUnknown) {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"two temp dirs", a, b},
+		{"not created yet", a, filepath.Join(b, "library", "2019")}, // Counts as b
+	}
+	for _, tt := range tests {
+		if same, err := sameDevice(tt.a, tt.b); err != nil || !same {
+			t.Errorf("%s: sameDevice = %v, %v, want true", tt.name, same, err)
+		}
+	}
+
+	t.Run("other filesystem", func(t *testing.T) {
+		other := otherFilesystem(t)
+		if same, err := sameDevice(a, filepath.Join(other, "library")); err != nil || same {
+			t.Errorf("sameDevice = %v, %v, want false", same, err)
+		}
+	})
+}
+
+func TestEstimatePlanCrossDeviceAlbums(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg")
+	if same, err := sameDevice(album.Files[0].Path, album.Files[1].Path); errors.Is(err, errDeviceUnknown) {
+		t.Skip(err)
+	} else if err != nil || !same {
+		t.Fatalf("files in one folder: sameDevice = %v, %v, want true", same, err)
+	}
+
+	est := EstimatePlan([]*Album{album}, nil, config)
+	if est.Renames != 2 || est.Copies != 0 || est.CrossDeviceBytes(album) != 0 {
+		t.Errorf("same filesystem: %d renames, %d copies, %d bytes across devices, want 2, 0, 0",
+			est.Renames, est.Copies, est.CrossDeviceBytes(album))
+	}
+
+	t.Run("other filesystem", func(t *testing.T) {
+		album.Destination = filepath.Join(otherFilesystem(t), "trip")
+		est := EstimatePlan([]*Album{album}, nil, config)
+		if est.Copies != 2 || est.CopyBytes != 10 || est.CrossDeviceBytes(album) != 10 {
+			t.Errorf("%d copies of %d bytes, %d bytes across devices, want 2, 10, 10",
+				est.Copies, est.CopyBytes, est.CrossDeviceBytes(album))
+		}
+	})
+}
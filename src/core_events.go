@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultEventGap is the pause between shots that starts a new event (GroupByEvent)
+const defaultEventGap = 6 * time.Hour
+
+// splitEvents sorts files by DateTaken and cuts them wherever consecutive shots are more
+// than gap apart, e.g. a DCIM folder holding a wedding and, weeks later, a hike. Files
+// without a date go with the first event.
+func splitEvents(files []*MediaFile, gap time.Duration) [][]*MediaFile {
+	sorted := make([]*MediaFile, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].DateTaken, sorted[j].DateTaken
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+
+	var events [][]*MediaFile
+	var last *time.Time
+	for _, mf := range sorted {
+		if len(events) == 0 || (last != nil && mf.DateTaken.Sub(*last) > gap) {
+			events = append(events, nil)
+		}
+		events[len(events)-1] = append(events[len(events)-1], mf)
+		if mf.DateTaken != nil {
+			last = mf.DateTaken
+		}
+	}
+	return events
+}
+
+// eventName names an event by its date span: "2023-07-14", or "2023-07-14 to 2023-07-16"
+// for an event crossing midnight or lasting days
+func eventName(files []*MediaFile) string {
+	var first, last *time.Time
+	for _, mf := range files {
+		if mf.DateTaken == nil {
+			continue
+		}
+		if first == nil || mf.DateTaken.Before(*first) {
+			first = mf.DateTaken
+		}
+		if last == nil || mf.DateTaken.After(*last) {
+			last = mf.DateTaken
+		}
+	}
+	if first == nil {
+		return "Unknown Date"
+	}
+	from, to := first.Format("2006-01-02"), last.Format("2006-01-02")
+	if from == to {
+		return from
+	}
+	return from + " to " + to
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGroupByEventSplitsAtGap(t *testing.T) {
+	root := t.TempDir()
+	dcim := filepath.Join(root, "scan", "DCIM")
+	// A wedding afternoon running past midnight, then a hike three weeks later
+	shots := []string{
+		"2023-07-14 15:00", "2023-07-14 18:30", "2023-07-14 23:50", "2023-07-15 01:10",
+		"2023-08-05 09:00", "2023-08-05 11:00", "2023-08-05 14:45",
+	}
+	var files []*MediaFile
+	for i, shot := range shots {
+		date, err := time.ParseInLocation("2006-01-02 15:04", shot, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: filepath.Join(dcim, fmt.Sprintf("IMG_%d.jpg", i)), Type: TypePhoto, DateTaken: &date})
+	}
+	// Listed out of order: events follow the dates, not the file names
+	files[0], files[5] = files[5], files[0]
+
+	events := splitEvents(files, defaultEventGap)
+	if len(events) != 2 || len(events[0]) != 4 || len(events[1]) != 3 {
+		t.Fatalf("split into %d events, want 2 of 4 and 3 files", len(events))
+	}
+	if got := eventName(events[0]); got != "2023-07-14 to 2023-07-15" {
+		t.Errorf("first event named %q", got)
+	}
+	if got := eventName(events[1]); got != "2023-08-05" {
+		t.Errorf("second event named %q", got)
+	}
+	if n := len(splitEvents(files, 30*24*time.Hour)); n != 1 {
+		t.Errorf("a 30 day gap split the shots into %d events, want 1", n)
+	}
+
+	config := &Config{
+		LibraryBase:  filepath.Join(root, "library"),
+		NoDateFolder: defaultNoDateFolder,
+		GroupBy:      GroupByEvent,
+		EventGap:     defaultEventGap,
+	}
+	albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, album := range albums {
+		got[album.Name] = len(album.Files)
+	}
+	want := map[string]int{"2023-07-14 to 2023-07-15": 4, "2023-08-05": 3}
+	if !maps.Equal(got, want) {
+		t.Errorf("albums %v, want %v", got, want)
+	}
+}
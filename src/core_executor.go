@@ -1,132 +1,550 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
-// ExecuteOrganization moves files to their organized destinations
-func ExecuteOrganization(albums []*Album, duplicates []*DuplicateGroup, config *Config, progressChan chan<- ScanProgress, cache *Cache) error {
-	var moved, failed int
+// ExecuteOrganization moves files to their organized destinations. Files that fail are
+// counted and their errors collected in the result without stopping the run; the error is
+// only set when execution could not start. Cancelling ctx stops the run between files (the
+// file being moved is finished), leaving the remaining files where they are; a Pauser in
+// ctx holds it between files.
+func ExecuteOrganization(ctx context.Context, albums []*Album, duplicates []*DuplicateGroup, config *Config, progressChan chan<- ScanProgress, cache *Cache) (*ExecutionResult, error) {
+	var (
+		moved, failed, skipped, linkFailed int
+		verifyFailed, resumed, processed   int
+		mu                                 sync.Mutex
+	)
 	totalFiles := 0
+	var totalBytes, processedBytes int64
 
 	// Count total files
 	for _, album := range albums {
 		totalFiles += len(album.Files)
+		for _, file := range album.Files {
+			totalBytes += file.Size
+		}
+	}
+	// Duplicates are only trashed when moving (see below)
+	if config.OrganizeMode == OrganizeMove {
+		for _, group := range duplicates {
+			totalFiles += len(group.Files) - 1 // Exclude best duplicate
+			for _, file := range group.Files {
+				if file != group.Best {
+					totalBytes += file.Size
+				}
+			}
+		}
+	}
+
+	var errs []error
+	var changed []string
+	verify := config.verifyAlgorithm()
+
+	// count increments a result counter (shared by move workers)
+	count := func(counter *int) {
+		mu.Lock()
+		*counter++
+		mu.Unlock()
+	}
+
+	// fail records a failure for counter (failed or linkFailed); copies that didn't match
+	// their source are also counted as verification failures
+	fail := func(counter *int, err error) {
+		errorLog.Print(err)
+		mu.Lock()
+		*counter++
+		if errors.Is(err, errVerifyMismatch) {
+			verifyFailed++
+		}
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	// skipChanged leaves a file modified since the scan in place (its hash and album may
+	// no longer fit), reporting whether it did
+	skipChanged := func(file *MediaFile) bool {
+		if !changedSinceScan(file) {
+			return false
+		}
+		warnLog.Printf("skip %s: changed since the scan", file.Path)
+		mu.Lock()
+		changed = append(changed, file.Path)
+		mu.Unlock()
+		return true
 	}
-	for _, group := range duplicates {
-		totalFiles += len(group.Files) - 1 // Exclude best duplicate
+
+	// fileDone marks a file processed and sends a progress update
+	fileDone := func(file *MediaFile) {
+		mu.Lock()
+		defer mu.Unlock()
+		processed++
+		processedBytes += file.Size
+		if progressChan != nil {
+			select {
+			case progressChan <- ScanProgress{
+				ProcessedFiles: processed,
+				TotalFiles:     totalFiles,
+				ProcessedBytes: processedBytes,
+				TotalBytes:     totalBytes,
+				CurrentFile:    file.Path,
+			}:
+			default:
+			}
+		}
 	}
 
-	processed := 0
+	// Journal every operation so the run can be undone (--undo), or continued (--resume)
+	var journal *Journal
+	var done map[string]string // Source path -> destination, placed by the run being resumed
+	if !config.Simulate {
+		var err error
+		if config.Resume {
+			journal, done, err = ResumeJournal(config.LibraryBase)
+		} else {
+			journal, err = OpenJournal(config.LibraryBase)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("open undo journal: %w", err)
+		}
+		defer journal.Close()
+	}
 
-	// Move album files
+	// alreadyDone reports whether the interrupted run placed path and the result is still there
+	// (sources it moved are gone, which is not an error)
+	alreadyDone := func(path string) bool {
+		dst, ok := done[path]
+		if !ok {
+			return false
+		}
+		_, err := os.Lstat(dst)
+		return err == nil
+	}
+
+	// toTrash moves a file to this run's trash (duplicates, and what the conflict policy
+	// displaces or skips)
+	toTrash := func(file *MediaFile) error {
+		trashPath := trashPath(file, config)
+		if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+			return fmt.Errorf("create trash dir: %w", err)
+		}
+		if err := transferFile(file.Path, trashPath, OrganizeMove, config.Simulate, verify); err != nil {
+			return err
+		}
+		journal.Record(JournalTrash, file.Path, trashPath)
+		// Scans skip the trash: drop the entry now rather than at the next prune
+		if cache != nil && !config.Simulate {
+			cache.Delete(file.Path)
+		}
+		return nil
+	}
+
+	// Create destination directories up front, before any worker moves into them
+	destErrs := make(map[string]error)
 	for _, album := range albums {
-		// Create destination directory
-		if err := os.MkdirAll(album.Destination, 0755); err != nil {
-			return fmt.Errorf("create album dir %s: %w", album.Destination, err)
+		if _, ok := destErrs[album.Destination]; ok {
+			continue
 		}
+		destErrs[album.Destination] = os.MkdirAll(album.Destination, 0755)
+	}
 
+	// Move files, one file per worker; names are claimed so that workers moving
+	// same-named files into one album never pick the same destination
+	type albumFile struct {
+		album *Album
+		file  *MediaFile
+	}
+	var jobs []albumFile
+	for _, album := range albums {
 		for _, file := range album.Files {
-			destPath := filepath.Join(album.Destination, filepath.Base(file.Path))
+			jobs = append(jobs, albumFile{album, file})
+		}
+	}
+	names := newDestNames(!config.Simulate)
+
+	runParallel(config.MoveWorkers, len(jobs), func(i int) {
+		album, file := jobs[i].album, jobs[i].file
+		waitIfPaused(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Album directory couldn't be created: all its files fail
+		if err := destErrs[album.Destination]; err != nil {
+			fail(&failed, fmt.Errorf("move %s: create album dir: %w", file.Path, err))
+			fileDone(file)
+			return
+		}
+
+		destPath := album.DestPath(file)
+
+		// Skip if already at destination (no need to move)
+		if file.Path == destPath {
+			fileDone(file)
+			return
+		}
+		if alreadyDone(file.Path) {
+			debugLog.Printf("skip %s: placed by the interrupted run", file.Path)
+			count(&resumed)
+			fileDone(file)
+			return
+		}
+		if skipChanged(file) {
+			fileDone(file)
+			return
+		}
 
-			// Skip if already at destination (no need to move)
-			if file.Path == destPath {
-				processed++
-				continue
+		// Subfolder kept inside the album (KeepSubfolders)
+		if dir := filepath.Dir(destPath); dir != album.Destination {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				fail(&failed, fmt.Errorf("move %s: create album subfolder: %w", file.Path, err))
+				fileDone(file)
+				return
 			}
+		}
 
+		// Same content already at destination: apply the dest-exists policy
+		claimed := false
+		if config.DestExists != DestExistsKeepBoth && sameContent(file, destPath, config.HashAlgorithm, cache) {
+			if config.DestExists == DestExistsSkip {
+				debugLog.Printf("skip %s: identical file at %s", file.Path, destPath)
+				count(&skipped)
+				// The source is a duplicate of the library copy: trash it when moving
+				if config.OnConflict == ConflictSkipIdentical && config.OrganizeMode == OrganizeMove {
+					if err := toTrash(file); err != nil {
+						fail(&failed, fmt.Errorf("trash %s: %w", file.Path, err))
+					}
+				}
+				fileDone(file)
+				return
+			}
+			// DestExistsReplace: move over the existing copy
+			names.claimExact(destPath)
+		} else if config.OnConflict == ConflictOverwrite && names.claimExisting(destPath) {
+			// A different file is in the way: it goes to the trash (restored by --undo)
+			if err := toTrash(&MediaFile{Path: destPath}); err != nil {
+				fail(&failed, fmt.Errorf("move %s: displace %s: %w", file.Path, destPath, err))
+				fileDone(file)
+				return
+			}
+		} else {
 			// Handle filename conflicts
-			destPath = ensureUniqueFilename(destPath)
+			unique, err := names.claim(destPath)
+			if err != nil {
+				fail(&failed, fmt.Errorf("move %s: claim %s: %w", file.Path, destPath, err))
+				fileDone(file)
+				return
+			}
+			destPath = unique
+			claimed = true
+		}
 
-			// Move file
-			if err := moveFile(file.Path, destPath); err != nil {
-				fmt.Printf("  ✗ Failed to move %s: %v\n", file.Path, err)
-				failed++
-			} else {
-				moved++
+		// Link-back needs source and library on one filesystem; across filesystems the
+		// move would delete the source before the link fails, so the file stays put
+		if config.LinkBack && !config.Simulate {
+			if same, err := sameDevice(filepath.Dir(file.Path), filepath.Dir(destPath)); err != nil || !same {
+				if err == nil {
+					err = errors.New("library is on another filesystem")
+				}
+				fail(&linkFailed, fmt.Errorf("hardlink back %s: %w", file.Path, err))
+				if claimed {
+					names.release(destPath)
+				}
+				fileDone(file)
+				return
+			}
+		}
 
-				// Update cache with new path (so duplicate detection works on next run)
-				if cache != nil {
+		// Move (or copy/link) file
+		if err := transferFile(file.Path, destPath, config.OrganizeMode, config.Simulate, verify); err != nil {
+			fail(&failed, fmt.Errorf("move %s: %w", file.Path, err))
+			if claimed {
+				names.release(destPath)
+			}
+		} else {
+			debugLog.Printf("%s %s → %s", config.OrganizeMode, file.Path, destPath)
+			count(&moved)
+			sourcePath := file.Path
+			journal.Record(config.OrganizeMode, sourcePath, destPath)
+
+			// Update cache with new path (so duplicate detection works on next run)
+			if cache != nil && !config.Simulate {
+				// A file with no same-size twin in the scan was never hashed: hash it now,
+				// so the next run's library dedup finds it without reading it again
+				if config.DedupLibrary && config.OrganizeMode != OrganizeSymlink && (file.Hash == "" || file.Hash == uniqueHash) {
+					if hash, err := calculateFileHash(destPath, config.HashAlgorithm); err == nil {
+						file.Hash, file.HashAlgo = hash, config.HashAlgorithm
+					}
+				}
+				switch config.OrganizeMode {
+				case OrganizeMove:
 					// Update the file's path for cache update
 					oldPath := file.Path
 					file.Path = destPath
 					if info, err := os.Stat(destPath); err == nil {
 						cache.UpdatePath(oldPath, file, info.ModTime())
 					}
+				case OrganizeCopy, OrganizeHardlink:
+					// Original stays where it is, the library copy gets its own entry
+					placed := *file
+					placed.Path = destPath
+					if info, err := os.Stat(destPath); err == nil {
+						cache.Put(&placed, info.ModTime())
+					}
 				}
+				// Symlinks resolve to the original when scanned, nothing to cache
 			}
 
-			processed++
-			if progressChan != nil {
-				select {
-				case progressChan <- ScanProgress{
-					ProcessedFiles: processed,
-					TotalFiles:     totalFiles,
-					CurrentFile:    file.Path,
-				}:
-				default:
+			// Leave a hardlink at the source so existing references keep working
+			// (the library path stays canonical in the cache)
+			if config.LinkBack && !config.Simulate {
+				if err := os.Link(destPath, sourcePath); err != nil {
+					fail(&linkFailed, fmt.Errorf("hardlink back %s: %w", sourcePath, err))
+				} else {
+					journal.Record(JournalLinkBack, destPath, sourcePath)
 				}
 			}
 		}
-	}
 
-	// Move duplicates to trash
-	if len(duplicates) > 0 {
-		trashDir := config.DuplicatesTrash
-		if err := os.MkdirAll(trashDir, 0755); err != nil {
-			return fmt.Errorf("create trash dir: %w", err)
-		}
+		fileDone(file)
+	})
 
-		for _, group := range duplicates {
+	// Move duplicates to trash (only when moving, other modes leave originals alone)
+	if len(duplicates) > 0 && config.OrganizeMode == OrganizeMove && ctx.Err() == nil {
+		runParallel(config.MoveWorkers, len(duplicates), func(i int) {
+			group := duplicates[i]
 			for _, file := range group.Files {
+				waitIfPaused(ctx)
+				if ctx.Err() != nil {
+					return
+				}
 				// Skip the best duplicate
 				if file == group.Best {
 					continue
 				}
-
-				// Preserve directory structure in trash
-				relPath, _ := filepath.Rel(config.ScanPath, file.Path)
-				trashPath := filepath.Join(trashDir, relPath)
-
-				// Create parent directories
-				if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
-					fmt.Printf("  ✗ Failed to create trash dir for %s: %v\n", file.Path, err)
-					failed++
+				if alreadyDone(file.Path) {
+					count(&resumed)
+					fileDone(file)
+					continue
+				}
+				if skipChanged(file) {
+					fileDone(file)
 					continue
 				}
 
-				// Move to trash
-				if err := moveFile(file.Path, trashPath); err != nil {
-					fmt.Printf("  ✗ Failed to trash %s: %v\n", file.Path, err)
-					failed++
+				if err := toTrash(file); err != nil {
+					fail(&failed, fmt.Errorf("trash %s: %w", file.Path, err))
 				} else {
-					moved++
+					count(&moved)
 				}
 
-				processed++
-				if progressChan != nil {
-					select {
-					case progressChan <- ScanProgress{
-						ProcessedFiles: processed,
-						TotalFiles:     totalFiles,
-						CurrentFile:    file.Path,
-					}:
-					default:
-					}
-				}
+				fileDone(file)
+			}
+		})
+	}
+
+	cancelled := ctx.Err() != nil && processed < totalFiles
+	if !cancelled {
+		journal.Finish()
+	}
+
+	return &ExecutionResult{
+		Moved:        moved,
+		Failed:       failed,
+		VerifyFailed: verifyFailed,
+		Skipped:      skipped,
+		Resumed:      resumed,
+		LinkFailed:   linkFailed,
+		Changed:      changed,
+		Errors:       errs,
+		Cancelled:    cancelled,
+	}, nil
+}
+
+// changedSinceScan reports whether a file's size or modification time differs from what
+// the scan recorded. Files not found by a scan, or gone, count as unchanged (a missing
+// source fails the move anyway).
+func changedSinceScan(file *MediaFile) bool {
+	if file.ModTime.IsZero() {
+		return false
+	}
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return false
+	}
+	return info.Size() != file.Size || !info.ModTime().Equal(file.ModTime)
+}
+
+// trashPath returns where a duplicate goes in the trash: inside this run's quarantine
+// folder, preserving its directory structure below its scan path (under a folder named
+// after the scan path when there are several)
+func trashPath(file *MediaFile, config *Config) string {
+	trash := filepath.Join(config.DuplicatesTrash, config.TrashRun)
+	root := scanRootOf(file.Path, config.ScanPaths)
+	if root == "" {
+		// Outside the scan paths (e.g. from --files-from): keep the full path
+		return filepath.Join(trash, file.Path)
+	}
+
+	relPath, _ := filepath.Rel(root, file.Path)
+	if len(config.ScanPaths) > 1 {
+		relPath = filepath.Join(trashRootName(root, config.ScanPaths), relPath)
+	}
+	return filepath.Join(trash, relPath)
+}
+
+// trashRootName names a scan path's folder in the trash: its base name, numbered when
+// an earlier scan path has the same one (/Volumes/A/Photos and /Volumes/B/Photos)
+func trashRootName(root string, roots []string) string {
+	name := filepath.Base(root)
+	taken := false
+	for i, other := range roots {
+		if other == root {
+			if taken {
+				return fmt.Sprintf("%s-%d", name, i+1)
+			}
+			break
+		}
+		taken = taken || filepath.Base(other) == name
+	}
+	return name
+}
+
+// runParallel calls fn for each index in [0, n) using a pool of workers
+func runParallel(workers, n int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexChan := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indexChan <- i
+	}
+	close(indexChan)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				fn(i)
 			}
+		}()
+	}
+	wg.Wait()
+}
+
+// sameContent checks if dst exists and has the same content as file
+func sameContent(file *MediaFile, dst, algorithm string, cache *Cache) bool {
+	info, err := os.Stat(dst)
+	if err != nil || info.Size() != file.Size {
+		return false
+	}
+
+	srcHash := file.Hash
+	if srcHash == "" || srcHash == uniqueHash || file.HashAlgo != algorithm {
+		if srcHash, err = hashWithCache(file.Path, algorithm, cache); err != nil {
+			return false
 		}
 	}
+	dstHash, err := hashWithCache(dst, algorithm, cache)
+	return err == nil && dstHash == srcHash
+}
+
+// transferFile places src at dst according to the organize mode,
+// or only creates an empty placeholder at dst in simulate mode.
+// Copies are verified with the verify hash algorithm ("" = not verified).
+func transferFile(src, dst, mode string, simulate bool, verify string) error {
+	if simulate {
+		return createPlaceholder(src, dst)
+	}
+
+	switch mode {
+	case OrganizeCopy:
+		return copyVerified(src, dst, verify)
+	case OrganizeHardlink:
+		return linkFile(src, dst, verify)
+	case OrganizeSymlink:
+		return symlinkFile(src, dst)
+	default:
+		return moveFile(src, dst, verify)
+	}
+}
+
+// placedVerb describes what happens to files in an organize mode ("moved", "copied", ...)
+func placedVerb(mode string) string {
+	switch mode {
+	case OrganizeCopy:
+		return "copied"
+	case OrganizeHardlink:
+		return "hardlinked"
+	case OrganizeSymlink:
+		return "symlinked"
+	default:
+		return "moved"
+	}
+}
 
-	fmt.Printf("\nExecution complete: %d files moved, %d failed\n", moved, failed)
+// linkFile hardlinks dst to src, falling back to a copy (e.g. across filesystems)
+func linkFile(src, dst, verify string) error {
+	// Replace policy: os.Link won't overwrite
+	if err := removeExisting(dst); err != nil {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyVerified(src, dst, verify)
+}
+
+// symlinkFile creates dst as a symlink to src's absolute path
+func symlinkFile(src, dst string) error {
+	target, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	if err := removeExisting(dst); err != nil {
+		return err
+	}
+	return os.Symlink(target, dst)
+}
+
+// removeExisting removes dst if it exists (only reached when replacing an identical file)
+func removeExisting(dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
-// moveFile moves a file, with fallback to copy+delete if cross-device
-func moveFile(src, dst string) error {
+// createPlaceholder creates a zero-byte file at dst carrying src's modification time
+func createPlaceholder(src, dst string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, srcInfo.ModTime(), srcInfo.ModTime())
+}
+
+// moveFile moves a file, with fallback to copy+delete if cross-device (the source is
+// only deleted once the copy passed verification, when verify names a hash algorithm)
+func moveFile(src, dst, verify string) error {
 	// Try rename first (fast, atomic)
 	err := os.Rename(src, dst)
 	if err == nil {
@@ -134,7 +552,7 @@ func moveFile(src, dst string) error {
 	}
 
 	// If rename fails (probably cross-device), copy then delete
-	if err := copyFile(src, dst); err != nil {
+	if err := copyVerified(src, dst, verify); err != nil {
 		return fmt.Errorf("copy: %w", err)
 	}
 
@@ -158,32 +576,103 @@ func copyFile(src, dst string) error {
 		return err
 	}
 
-	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, srcInfo.Mode())
-	if err != nil {
+	// Don't write through an existing link at dst (it may point back to src)
+	if err := removeExisting(dst); err != nil {
 		return err
 	}
-	defer dstFile.Close()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	// Exclusive, so a file created there since is never truncated
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, srcInfo.Mode().Perm())
+	if err != nil {
 		return err
 	}
 
-	if err := dstFile.Sync(); err != nil {
+	if err := writeCopy(dstFile, srcFile, srcInfo); err != nil {
+		dstFile.Close()
+		os.Remove(dst) // Don't leave a partial copy behind
+		return err
+	}
+	if err := dstFile.Close(); err != nil {
+		os.Remove(dst)
 		return err
 	}
 
-	// Preserve modification time (critical for cache lookups!)
-	if err := os.Chtimes(dst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+	// Preserve access and modification times (mtime is critical for cache lookups and
+	// for dating files without metadata), after closing so nothing touches them again
+	if err := os.Chtimes(dst, accessTime(srcInfo), srcInfo.ModTime()); err != nil {
 		return fmt.Errorf("preserve timestamps: %w", err)
 	}
 
 	return nil
 }
 
-// ensureUniqueFilename adds a counter if file exists
-func ensureUniqueFilename(path string) string {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return path
+// copyContent makes the copies that copyVerified checks (a variable so tests can inject
+// a faulty copy)
+var copyContent = copyFile
+
+// errVerifyMismatch is returned for a copy whose content differs from its source
+var errVerifyMismatch = errors.New("copy does not match the source")
+
+// copyVerified copies src to dst; with a verify hash algorithm, dst is then re-read and
+// compared with src, and a copy that doesn't match (or can't be read) is removed
+func copyVerified(src, dst, verify string) error {
+	if err := copyContent(src, dst); err != nil {
+		return err
+	}
+	if verify == "" {
+		return nil
+	}
+
+	srcHash, err := calculateFileHash(src, verify)
+	if err == nil {
+		var dstHash string
+		dstHash, err = calculateFileHash(dst, verify)
+		if err == nil && dstHash != srcHash {
+			err = errVerifyMismatch
+		}
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("verify: %w", err)
+	}
+	return nil
+}
+
+// writeCopy copies src's content into dst and applies src's permissions, which the
+// umask may have narrowed when dst was created
+func writeCopy(dst, src *os.File, srcInfo os.FileInfo) error {
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+	if err := dst.Chmod(srcInfo.Mode().Perm()); err != nil {
+		return fmt.Errorf("preserve permissions: %w", err)
+	}
+	return dst.Sync()
+}
+
+// ensureUniqueFilename adds a counter if file exists or is taken. With claim, the name
+// is reserved by creating an empty file exclusively (O_EXCL), so that nothing else
+// (another run, another program) can pick it before the file is moved over it.
+func ensureUniqueFilename(path string, taken func(string) bool, claim bool) (string, error) {
+	free := func(path string) (bool, error) {
+		if taken(path) {
+			return false, nil
+		}
+		if !claim {
+			_, err := os.Lstat(path)
+			return os.IsNotExist(err), nil
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, f.Close()
+	}
+	if ok, err := free(path); ok || err != nil {
+		return path, err
 	}
 
 	dir := filepath.Dir(path)
@@ -193,8 +682,66 @@ func ensureUniqueFilename(path string) string {
 
 	for i := 1; ; i++ {
 		newPath := filepath.Join(dir, fmt.Sprintf("%s_%d%s", name, i, ext))
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
-			return newPath
+		if ok, err := free(newPath); ok || err != nil {
+			return newPath, err
 		}
 	}
 }
+
+// destNames hands out destination paths to concurrent move workers. A path handed out
+// is taken for the others even before its file exists; onDisk also reserves it on disk
+// with an empty file (not when simulating, placeholders are created exclusively).
+type destNames struct {
+	mu     sync.Mutex
+	taken  map[string]bool
+	onDisk bool
+}
+
+func newDestNames(onDisk bool) *destNames {
+	return &destNames{taken: make(map[string]bool), onDisk: onDisk}
+}
+
+// claim returns path, or path with a counter if it exists or another worker claimed it
+func (d *destNames) claim(path string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	path, err := ensureUniqueFilename(path, func(p string) bool { return d.taken[p] }, d.onDisk)
+	if err != nil {
+		return "", err
+	}
+	d.taken[path] = true
+	return path, nil
+}
+
+// release removes the reservation of a claimed path whose transfer failed, unless
+// something was written there after all
+func (d *destNames) release(path string) {
+	if !d.onDisk {
+		return
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		os.Remove(path)
+	}
+}
+
+// claimExact claims path as is, to move over an existing file
+func (d *destNames) claimExact(path string) {
+	d.mu.Lock()
+	d.taken[path] = true
+	d.mu.Unlock()
+}
+
+// claimExisting claims path as is if a file from before the run is there, to overwrite
+// it (ConflictOverwrite); files placed by this run are never overwritten
+func (d *destNames) claimExisting(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.taken[path] {
+		return false
+	}
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	d.taken[path] = true
+	return true
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestAlbum writes files into a scan folder and returns them as one album bound
+// for the library, with a config that moves them there
+func newTestAlbum(t *testing.T, names ...string) (*Album, *Config) {
+	t.Helper()
+	root := t.TempDir()
+	source := filepath.Join(root, "scan", "trip")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		ScanPaths:       []string{filepath.Join(root, "scan")},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		TrashRun:        newTrashRun(),
+		OrganizeMode:    OrganizeMove,
+		DestExists:      DestExistsSkip,
+		HashAlgorithm:   HashXXHash,
+		Workers:         1,
+		MoveWorkers:     1,
+	}
+	album := &Album{Name: "trip", Destination: filepath.Join(config.LibraryBase, "trip"), SourceDirs: []string{source}}
+	for _, name := range names {
+		path := filepath.Join(source, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		album.Files = append(album.Files, &MediaFile{Path: path, Size: int64(len(name)), Type: TypePhoto})
+	}
+	return album, config
+}
+
+func TestPhasesStopWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("hashes", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			ctx    context.Context
+			hashed bool
+		}{
+			{"running", context.Background(), true},
+			{"cancelled", ctx, false},
+		}
+		for _, tt := range tests {
+			// Same size and content, so both are hashed in full
+			album, config := newTestAlbum(t, "a.jpg", "b.jpg")
+			for _, mf := range album.Files {
+				if err := os.WriteFile(mf.Path, []byte("same"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				mf.Size = 4
+			}
+			CalculateHashes(tt.ctx, album.Files, config.Workers, "", nil, nil)
+			for _, mf := range album.Files {
+				if hashed := mf.Hash != ""; hashed != tt.hashed {
+					t.Errorf("%s: %s hashed = %v, want %v", tt.name, filepath.Base(mf.Path), hashed, tt.hashed)
+				}
+			}
+		}
+	})
+
+	t.Run("execute", func(t *testing.T) {
+		album, config := newTestAlbum(t, "a.jpg", "b.jpg")
+		result, err := ExecuteOrganization(ctx, []*Album{album}, nil, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Cancelled || result.Moved != 0 {
+			t.Errorf("Cancelled = %v, Moved = %d, want true, 0", result.Cancelled, result.Moved)
+		}
+		for _, mf := range album.Files {
+			if _, err := os.Stat(mf.Path); err != nil {
+				t.Errorf("%s not left in place: %v", mf.Path, err)
+			}
+		}
+	})
+}
+
+func TestOrganizeModes(t *testing.T) {
+	tests := []struct {
+		mode       string
+		sourceKept bool
+	}{
+		{OrganizeMove, false},
+		{OrganizeCopy, true},
+		{OrganizeHardlink, true},
+		{OrganizeSymlink, true},
+	}
+	for _, tt := range tests {
+		album, config := newTestAlbum(t, "a.jpg")
+		config.OrganizeMode = tt.mode
+		source := album.Files[0].Path
+		dest := album.DestPath(album.Files[0])
+		result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Moved != 1 {
+			t.Errorf("%s: Moved = %d, want 1", tt.mode, result.Moved)
+		}
+		if content, err := os.ReadFile(dest); err != nil || string(content) != "a.jpg" {
+			t.Errorf("%s: library file = %q, %v", tt.mode, content, err)
+		}
+		sourceInfo, err := os.Stat(source)
+		if kept := err == nil; kept != tt.sourceKept {
+			t.Errorf("%s: source kept = %v, want %v", tt.mode, kept, tt.sourceKept)
+		}
+		if !tt.sourceKept {
+			continue
+		}
+
+		destInfo, err := os.Lstat(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tt.mode {
+		case OrganizeCopy:
+			if os.SameFile(sourceInfo, destInfo) {
+				t.Errorf("copy shares the source's inode")
+			}
+		case OrganizeHardlink:
+			if !os.SameFile(sourceInfo, destInfo) {
+				t.Errorf("hardlink doesn't share the source's inode")
+			}
+		case OrganizeSymlink:
+			if target, err := os.Readlink(dest); err != nil || target != source {
+				t.Errorf("symlink points to %q (%v), want %s", target, err, source)
+			}
+		}
+	}
+}
+
+func TestExecuteReportsFailures(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg")
+	good, _ := newTestAlbum(t, "c.jpg")
+	// The album folder can't be created below a file
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	album.Destination = filepath.Join(blocker, "trip")
+	good.Destination = filepath.Join(config.LibraryBase, "good")
+
+	result, err := ExecuteOrganization(t.Context(), []*Album{album, good}, nil, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 1 || result.Failed != 2 || len(result.Errors) != 2 {
+		t.Errorf("Moved = %d, Failed = %d, %d errors, want 1, 2, 2", result.Moved, result.Failed, len(result.Errors))
+	}
+	for _, mf := range album.Files {
+		if _, err := os.Stat(mf.Path); err != nil {
+			t.Errorf("%s not left in place: %v", mf.Path, err)
+		}
+	}
+}
+
+func TestExecuteSkipsChangedFiles(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg", "c.jpg")
+	// Size and time as recorded by the scan
+	for _, mf := range album.Files {
+		info, err := os.Stat(mf.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mf.ModTime = info.ModTime()
+	}
+	grown, touched := album.Files[0], album.Files[1]
+	if err := os.WriteFile(grown.Path, []byte("edited since the scan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := touched.ModTime.Add(time.Minute)
+	if err := os.Chtimes(touched.Path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(result.Changed)
+	if want := []string{grown.Path, touched.Path}; !slices.Equal(result.Changed, want) {
+		t.Errorf("Changed = %v, want %v", result.Changed, want)
+	}
+	if result.Moved != 1 {
+		t.Errorf("Moved = %d, want 1", result.Moved)
+	}
+	for _, mf := range []*MediaFile{grown, touched} {
+		if _, err := os.Stat(mf.Path); err != nil {
+			t.Errorf("%s not left in place: %v", mf.Path, err)
+		}
+	}
+}
+
+func TestConcurrentMovesSameName(t *testing.T) {
+	album, config := newTestAlbum(t)
+	config.MoveWorkers = 8
+	// Every camera folder has an IMG_0001.jpg, all going into one album
+	const n = 50
+	for i := range n {
+		dir := filepath.Join(config.ScanPaths[0], fmt.Sprintf("card%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "IMG_0001.jpg")
+		content := fmt.Sprintf("photo %d", i)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		album.Files = append(album.Files, &MediaFile{Path: path, Size: int64(len(content)), Type: TypePhoto})
+	}
+
+	result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != n || result.Failed != 0 {
+		t.Errorf("Moved = %d, Failed = %d, want %d, 0", result.Moved, result.Failed, n)
+	}
+	// No file overwrote another: every content arrived, under its own name
+	seen := make(map[string]bool)
+	entries, err := os.ReadDir(album.Destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(album.Destination, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[string(content)] = true
+	}
+	if len(entries) != n || len(seen) != n {
+		t.Errorf("%d files with %d distinct contents in the album, want %d", len(entries), len(seen), n)
+	}
+}
+
+func TestEnsureUniqueFilenameConcurrentClaims(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG_0001.jpg")
+	if err := os.WriteFile(path, []byte("already there"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claimants that don't share a taken set, like two processes: only O_EXCL keeps them apart
+	const claimants = 20
+	claimed := make([]string, claimants)
+	var wg sync.WaitGroup
+	for i := range claimants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, err := ensureUniqueFilename(path, func(string) bool { return false }, true)
+			if err != nil {
+				t.Error(err)
+			}
+			claimed[i] = name
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(claimed)
+	if unique := slices.Compact(slices.Clone(claimed)); len(unique) != claimants {
+		t.Errorf("%d claimants got %d distinct names", claimants, len(unique))
+	}
+	if slices.Contains(claimed, path) {
+		t.Errorf("existing %s handed out", path)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "already there" {
+		t.Errorf("existing file overwritten: %q", content)
+	}
+}
+
+func TestConflictPolicies(t *testing.T) {
+	tests := []struct {
+		policy     string
+		destExists string // As set from the policy at startup
+		identical  bool
+		library    map[string]string // Album folder afterwards
+		trashed    []string          // Contents moved to the trash
+	}{
+		{ConflictRename, DestExistsKeepBoth, true, map[string]string{"a.jpg": "a.jpg", "a_1.jpg": "a.jpg"}, nil},
+		{ConflictRename, DestExistsKeepBoth, false, map[string]string{"a.jpg": "other", "a_1.jpg": "a.jpg"}, nil},
+		{ConflictSkipIdentical, DestExistsSkip, true, map[string]string{"a.jpg": "a.jpg"}, []string{"a.jpg"}},
+		{ConflictSkipIdentical, DestExistsSkip, false, map[string]string{"a.jpg": "other", "a_1.jpg": "a.jpg"}, nil},
+		{ConflictOverwrite, DestExistsReplace, true, map[string]string{"a.jpg": "a.jpg"}, nil},
+		{ConflictOverwrite, DestExistsReplace, false, map[string]string{"a.jpg": "a.jpg"}, []string{"other"}},
+	}
+	for _, tt := range tests {
+		album, config := newTestAlbum(t, "a.jpg")
+		config.OnConflict, config.DestExists = tt.policy, tt.destExists
+		existing := "other"
+		if tt.identical {
+			existing = "a.jpg"
+		}
+		if err := os.MkdirAll(album.Destination, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(album.Destination, "a.jpg"), []byte(existing), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("%s, identical %v", tt.policy, tt.identical)
+		if got := treeOf(t, album.Destination); !maps.Equal(got, tt.library) {
+			t.Errorf("%s: album = %v, want %v", name, got, tt.library)
+		}
+		var trashed []string
+		for _, content := range treeOf(t, config.DuplicatesTrash) {
+			trashed = append(trashed, content)
+		}
+		if !slices.Equal(trashed, tt.trashed) {
+			t.Errorf("%s: trashed %v, want %v", name, trashed, tt.trashed)
+		}
+		if _, err := os.Stat(album.Files[0].Path); err == nil {
+			t.Errorf("%s: source left in place", name)
+		}
+	}
+}
+
+func TestLinkBack(t *testing.T) {
+	t.Run("same filesystem", func(t *testing.T) {
+		album, config := newTestAlbum(t, "a.jpg")
+		config.LinkBack = true
+		source := album.Files[0].Path
+		result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Moved != 1 || result.LinkFailed != 0 {
+			t.Errorf("Moved = %d, LinkFailed = %d, want 1, 0", result.Moved, result.LinkFailed)
+		}
+		sourceInfo, err := os.Stat(source)
+		if err != nil {
+			t.Fatalf("no link at the source: %v", err)
+		}
+		destInfo, err := os.Stat(filepath.Join(album.Destination, "a.jpg"))
+		if err != nil || !os.SameFile(sourceInfo, destInfo) {
+			t.Errorf("source isn't a hardlink of the library file (%v)", err)
+		}
+	})
+
+	t.Run("other filesystem", func(t *testing.T) {
+		album, config := newTestAlbum(t, "a.jpg")
+		config.LinkBack = true
+		// A library on another filesystem than the temp dir, when the system has one
+		other, err := os.MkdirTemp("/dev/shm", "library")
+		if err != nil {
+			t.Skip("no /dev/shm")
+		}
+		t.Cleanup(func() { os.RemoveAll(other) })
+		if same, err := sameDevice(other, config.ScanPaths[0]); err != nil || same {
+			t.Skip("/dev/shm is on the temp dir's filesystem")
+		}
+		album.Destination = filepath.Join(other, "trip")
+
+		source := album.Files[0].Path
+		result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Moved != 0 || result.LinkFailed != 1 {
+			t.Errorf("Moved = %d, LinkFailed = %d, want 0, 1", result.Moved, result.LinkFailed)
+		}
+		if content, err := os.ReadFile(source); err != nil || string(content) != "a.jpg" {
+			t.Errorf("source not left in place: %q, %v", content, err)
+		}
+		if _, err := os.Stat(filepath.Join(album.Destination, "a.jpg")); !os.IsNotExist(err) {
+			t.Errorf("file placed in the library anyway (%v)", err)
+		}
+	})
+}
+
+func TestCopyPreservesFileTimes(t *testing.T) {
+	// A simulated boundary (copyFile is what a cross-device move falls back to), and a real
+	// one when /dev/shm is another filesystem
+	targets := map[string]string{"copy": t.TempDir()}
+	if other, err := os.MkdirTemp("/dev/shm", "library"); err == nil {
+		t.Cleanup(func() { os.RemoveAll(other) })
+		if same, err := sameDevice(other, t.TempDir()); err == nil && !same {
+			targets["move across filesystems"] = other
+		}
+	}
+
+	mtime := time.Date(2011, 4, 5, 6, 7, 8, 0, time.Local)
+	atime := time.Date(2012, 1, 1, 0, 0, 0, 0, time.Local)
+	for name, target := range targets {
+		src := filepath.Join(t.TempDir(), "a.jpg")
+		if err := os.WriteFile(src, []byte("photo"), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(src, atime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		dst := filepath.Join(target, "a.jpg")
+		var err error
+		if name == "copy" {
+			err = copyFile(src, dst)
+		} else {
+			err = moveFile(src, dst, "")
+			if _, statErr := os.Stat(src); !os.IsNotExist(statErr) {
+				t.Errorf("%s: source still there (%v)", name, statErr)
+			}
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		info, err := os.Stat(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := info.ModTime().Sub(mtime).Abs(); d > time.Second {
+			t.Errorf("%s: mtime %v, want within 1s of %v", name, info.ModTime(), mtime)
+		}
+		if d := accessTime(info).Sub(atime).Abs(); d > time.Second && (runtime.GOOS == "linux" || runtime.GOOS == "darwin") {
+			t.Errorf("%s: atime %v, want within 1s of %v", name, accessTime(info), atime)
+		}
+		if info.Mode().Perm() != 0o640 {
+			t.Errorf("%s: mode %v, want -rw-r-----", name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestVerifyKeepsSourceOfShortCopy(t *testing.T) {
+	// A copy that silently loses its last byte
+	copyContent = func(src, dst string) error {
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		info, err := os.Stat(dst)
+		if err != nil {
+			return err
+		}
+		return os.Truncate(dst, info.Size()-1)
+	}
+	t.Cleanup(func() { copyContent = copyFile })
+
+	tests := []struct {
+		name  string
+		setup func(album *Album, config *Config)
+	}{
+		{"copy", func(album *Album, config *Config) { config.OrganizeMode = OrganizeCopy }},
+		{"move across filesystems", func(album *Album, config *Config) {
+			album.Destination = filepath.Join(otherFilesystem(t), "trip")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			album, config := newTestAlbum(t, "a.jpg", "b.jpg")
+			config.Verify = true
+			tt.setup(album, config)
+
+			result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.VerifyFailed != 2 || result.Moved != 0 {
+				t.Errorf("VerifyFailed = %d, Moved = %d, want 2, 0", result.VerifyFailed, result.Moved)
+			}
+			for _, mf := range album.Files {
+				if content, err := os.ReadFile(mf.Path); err != nil || string(content) != filepath.Base(mf.Path) {
+					t.Errorf("source %s: %q, %v, want it intact", mf.Path, content, err)
+				}
+				if _, err := os.Stat(album.DestPath(mf)); !os.IsNotExist(err) {
+					t.Errorf("short copy of %s left at the destination (%v)", filepath.Base(mf.Path), err)
+				}
+			}
+		})
+	}
+}
+
+func TestOrganizeTwiceFindsImportedFiles(t *testing.T) {
+	// Different sizes: the first run never hashes them (no same-size twin)
+	album, config := newTestAlbum(t, "IMG_1.jpg", "IMG_22.jpg", "IMG_333.jpg")
+	config.DedupLibrary = true
+	config.NoDateFolder = defaultNoDateFolder
+	config.MinAlbumFiles = defaultMinAlbumFiles
+	source := album.SourceDirs[0]
+	cache, err := OpenCache(config.LibraryBase, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	// organize runs scan, plan and execution as the CLI does
+	organize := func() ([]*Album, []*DuplicateGroup) {
+		t.Helper()
+		files, err := ScanMediaFiles(config, nil, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		albums, duplicates, err := PlanBatch(t.Context(), files, config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := ExecuteOrganization(t.Context(), albums, duplicates, config, nil, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Failed != 0 {
+			t.Fatalf("%d files failed: %v", result.Failed, result.Errors)
+		}
+		cache.flush()
+		return albums, duplicates
+	}
+
+	albums, _ := organize()
+	if len(albums) != 1 || len(albums[0].Files) != 3 {
+		t.Fatalf("first run: albums %v, want the 3 files imported", albumNamesOf(albums))
+	}
+	imported := make(map[string]bool)
+	for _, mf := range albums[0].Files {
+		imported[mf.Path] = true
+	}
+
+	// The card is imported again
+	writeFiles(t, source, "IMG_1.jpg", "IMG_22.jpg", "IMG_333.jpg")
+	albums, duplicates := organize()
+	if len(albums) != 0 {
+		t.Errorf("second run imported %v again", albumNamesOf(albums))
+	}
+	if len(duplicates) != 3 {
+		t.Fatalf("second run: %d duplicate groups, want one per imported file", len(duplicates))
+	}
+	for _, group := range duplicates {
+		if !imported[group.Best.Path] || len(group.Files) != 2 {
+			t.Errorf("%s kept for group %v, want the imported file", group.Best.Path, group.Files)
+		}
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files left in the scan folder, want the copies trashed", len(entries))
+	}
+	for path := range imported {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("imported file gone: %v", err)
+		}
+	}
+}
+
+func TestExecuteIntoTypeRoots(t *testing.T) {
+	album, config := newTestAlbum(t)
+	root := filepath.Dir(config.LibraryBase)
+	config.VideosRoot = filepath.Join(root, "big", "Videos") // A separate drive
+	config.NoDateFolder = defaultNoDateFolder
+	config.MinAlbumFiles = defaultMinAlbumFiles
+	writeFiles(t, album.SourceDirs[0], "a.jpg", "b.jpg", "c.jpg", "a.mp4", "b.mp4", "c.mp4")
+	info, err := os.Stat(filepath.Join(album.SourceDirs[0], "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	year := info.ModTime().Format("2006") // Undated files go by their file time
+
+	files, err := ScanMediaFiles(config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	albums, duplicates, err := PlanBatch(t.Context(), files, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ExecuteOrganization(t.Context(), albums, duplicates, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 6 || result.Failed != 0 {
+		t.Fatalf("moved %d, failed %d (%v), want 6 moved", result.Moved, result.Failed, result.Errors)
+	}
+
+	placed := make(map[string]string) // File name -> type root it landed under
+	for _, base := range []string{filepath.Join(config.LibraryBase, "Photos"), filepath.Join(config.LibraryBase, "Videos"), config.VideosRoot} {
+		for path := range treeOf(t, base) {
+			if !strings.HasPrefix(path, year+string(filepath.Separator)) {
+				t.Errorf("%s under %s, want it in the %s folder", path, base, year)
+			}
+			rel, _ := filepath.Rel(root, base)
+			placed[filepath.Base(path)] = filepath.ToSlash(rel)
+		}
+	}
+	want := map[string]string{
+		"a.jpg": "library/Photos", "b.jpg": "library/Photos", "c.jpg": "library/Photos",
+		"a.mp4": "big/Videos", "b.mp4": "big/Videos", "c.mp4": "big/Videos",
+	}
+	if !maps.Equal(placed, want) {
+		t.Errorf("files placed under %v, want %v", placed, want)
+	}
+}
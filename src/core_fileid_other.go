@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileIdentityOf is unavailable on this platform (paths are the only identity)
+func fileIdentityOf(info os.FileInfo) (fileIdentity, bool) {
+	return fileIdentity{}, false
+}
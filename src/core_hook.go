@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPostTimeout bounds the post command when no timeout is configured
+const defaultPostTimeout = 5 * time.Minute
+
+// postCommandSummary is the JSON written to the post command's stdin
+type postCommandSummary struct {
+	Mode       string `json:"mode"` // "execute" or "simulate"
+	Library    string `json:"library"`
+	Trash      string `json:"trash"`
+	Albums     int    `json:"albums"`
+	Moved      int    `json:"moved"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	LinkFailed int    `json:"link_failed"`
+}
+
+// RunPostCommand runs the configured post command after an execution, passing the
+// summary as MEDIAORG_* environment variables and as JSON on stdin.
+// Command output goes to output; a non-zero exit or timeout is returned as an error.
+func RunPostCommand(config *Config, albums int, result *ExecutionResult, output io.Writer) error {
+	args := strings.Fields(config.PostCommand)
+	if len(args) == 0 {
+		return nil
+	}
+
+	timeout := config.PostTimeout
+	if timeout <= 0 {
+		timeout = defaultPostTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	summary := postCommandSummary{
+		Mode:       "execute",
+		Library:    config.LibraryBase,
+		Trash:      config.DuplicatesTrash,
+		Albums:     albums,
+		Moved:      result.Moved,
+		Failed:     result.Failed,
+		Skipped:    result.Skipped,
+		LinkFailed: result.LinkFailed,
+	}
+	if config.Simulate {
+		summary.Mode = "simulate"
+	}
+	input, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 5 * time.Second // Don't hang on children that keep output open
+	cmd.Env = append(os.Environ(),
+		"MEDIAORG_MODE="+summary.Mode,
+		"MEDIAORG_LIBRARY="+summary.Library,
+		"MEDIAORG_TRASH="+summary.Trash,
+		"MEDIAORG_ALBUMS="+strconv.Itoa(summary.Albums),
+		"MEDIAORG_MOVED="+strconv.Itoa(summary.Moved),
+		"MEDIAORG_FAILED="+strconv.Itoa(summary.Failed),
+		"MEDIAORG_SKIPPED="+strconv.Itoa(summary.Skipped),
+		"MEDIAORG_LINK_FAILED="+strconv.Itoa(summary.LinkFailed),
+	)
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("exited with status %d", exitErr.ExitCode())
+	}
+	return err
+}
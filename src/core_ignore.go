@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName holds gitignore-style patterns for the folder it is in and everything below
+const ignoreFileName = ".mediaignore"
+
+// ignoreRule is one line of a .mediaignore file
+type ignoreRule struct {
+	base     string   // Folder holding the .mediaignore, patterns are relative to it
+	segments []string // Pattern split on "/", "**" matches any number of folders
+	negate   bool     // "!pattern" re-includes what an earlier rule excluded
+	dirOnly  bool     // "pattern/" only matches folders
+	anchored bool     // Pattern contains a slash, so it's matched from base rather than by name
+}
+
+// ignoreRules holds the .mediaignore rules in effect in each scanned folder
+// (its own rules after those inherited from its parents)
+type ignoreRules map[string][]ignoreRule
+
+// load reads dir's .mediaignore, if any, on top of the rules inherited from its parent
+func (r ignoreRules) load(dir string) {
+	rules := r[filepath.Dir(dir)]
+	if own := readIgnoreFile(dir); len(own) > 0 {
+		rules = append(append([]ignoreRule{}, rules...), own...)
+	}
+	r[dir] = rules
+}
+
+// ignored reports whether a file or folder is excluded by the rules of its parent folder;
+// the last matching rule wins, so deeper files and later lines override earlier ones
+func (r ignoreRules) ignored(p string, isDir bool) bool {
+	rules := r[filepath.Dir(p)]
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(p, isDir) {
+			return !rules[i].negate
+		}
+	}
+	return false
+}
+
+// matches checks a path below the rule's folder against the pattern
+func (rule ignoreRule) matches(p string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	if !rule.anchored {
+		ok, _ := path.Match(rule.segments[0], filepath.Base(p))
+		return ok
+	}
+	rel, err := filepath.Rel(rule.base, p)
+	if err != nil {
+		return false
+	}
+	return matchSegments(rule.segments, strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**" spans any number
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// readIgnoreFile parses dir's .mediaignore (nil if there is none). Blank lines and "#" comments
+// are skipped, "\#" and "\!" escape a leading character, invalid patterns are ignored.
+func readIgnoreFile(dir string) []ignoreRule {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		rule.segments = strings.Split(line, "/")
+		valid := true
+		for _, segment := range rule.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				valid = false
+			}
+		}
+		if valid {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
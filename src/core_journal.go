@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Journal operations besides the organize modes (OrganizeMove, OrganizeCopy, ...)
+const (
+	JournalTrash    = "trash"     // Duplicate moved to trash
+	JournalLinkBack = "link-back" // Hardlink left at the source after a move
+	JournalDone     = "done"      // Last entry of an execution that processed every file
+)
+
+// undoneSuffix marks a journal that has been undone
+const undoneSuffix = ".undone"
+
+// JournalEntry records one successful file operation
+type JournalEntry struct {
+	Operation string    `json:"operation"`
+	OldPath   string    `json:"old_path"`
+	NewPath   string    `json:"new_path"`
+	Time      time.Time `json:"time"`
+}
+
+// Journal appends the operations of one execution to a JSON Lines file, so the run can be undone
+type Journal struct {
+	mu       sync.Mutex
+	file     *os.File
+	enc      *json.Encoder
+	recorded int // Operations in the file, including those of the run being resumed
+}
+
+// journalDir returns where journals are stored (next to the cache)
+func journalDir(libraryBase string) string {
+	return filepath.Join(cacheDirPath(libraryBase), "journal")
+}
+
+// OpenJournal creates a new journal for this run
+func OpenJournal(libraryBase string) (*Journal, error) {
+	dir := journalDir(libraryBase)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := time.Now().Format("20060102-150405.000000000") + ".jsonl"
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// ResumeJournal reopens the latest journal for appending when its execution was interrupted,
+// returning the destinations of the operations it finished by source path. The journal
+// then covers the whole run, so --undo reverses the resumed part too.
+func ResumeJournal(libraryBase string) (*Journal, map[string]string, error) {
+	path, entries, err := ResumableJournal(libraryBase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		return nil, nil, fmt.Errorf("no interrupted execution to resume")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Operation != JournalLinkBack { // Its old path is the library file
+			done[entry.OldPath] = entry.NewPath
+		}
+	}
+	return &Journal{file: f, enc: json.NewEncoder(f), recorded: len(entries)}, done, nil
+}
+
+// ResumableJournal returns the latest journal and its entries when its execution stopped
+// before processing every file ("" when there is none, or it finished)
+func ResumableJournal(libraryBase string) (string, []JournalEntry, error) {
+	path, err := LatestJournal(libraryBase)
+	if err != nil || path == "" {
+		return "", nil, err
+	}
+	entries, finished, err := readJournal(path)
+	if err != nil || finished {
+		return "", nil, err
+	}
+	return path, entries, nil
+}
+
+// Record appends an operation (safe for concurrent use, each entry is written immediately)
+func (j *Journal) Record(operation, oldPath, newPath string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry := JournalEntry{Operation: operation, OldPath: oldPath, NewPath: newPath, Time: time.Now()}
+	if err := j.enc.Encode(entry); err != nil {
+		warnLog.Printf("undo journal: could not record %s: %v", oldPath, err)
+		return
+	}
+	j.recorded++
+}
+
+// Finish marks the execution as having processed every file, so it isn't offered to --resume
+func (j *Journal) Finish() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.recorded > 0 {
+		j.enc.Encode(JournalEntry{Operation: JournalDone, Time: time.Now()})
+	}
+}
+
+// Close closes the journal, removing it if nothing was recorded
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	if j.recorded == 0 {
+		return os.Remove(j.file.Name())
+	}
+	return nil
+}
+
+// LatestJournal returns the most recent journal that hasn't been undone ("" if none)
+func LatestJournal(libraryBase string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(journalDir(libraryBase), "*.jsonl"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	sort.Strings(matches) // Names are timestamps
+	return matches[len(matches)-1], nil
+}
+
+// ReadJournal loads all operations of a journal
+func ReadJournal(path string) ([]JournalEntry, error) {
+	entries, _, err := readJournal(path)
+	return entries, err
+}
+
+// readJournal loads a journal's operations and whether its execution finished
+func readJournal(path string) ([]JournalEntry, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	finished := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, false, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if entry.Operation == JournalDone {
+			finished = true
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, finished, scanner.Err()
+}
+
+// UndoResult summarizes an undo
+type UndoResult struct {
+	Undone  int
+	Skipped int // Destination gone, or original path taken again
+	Failed  int
+}
+
+// UndoJournal reverses a journal's operations newest first and marks it undone.
+// Moved files go back to their original path (recreating deleted directories), copies
+// and links are removed; directories left empty are cleaned up.
+func UndoJournal(path string, config *Config, cache *Cache) (*UndoResult, error) {
+	entries, err := ReadJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UndoResult{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if _, err := os.Lstat(entry.NewPath); err != nil {
+			warnLog.Printf("undo: skipping %s: no longer exists", entry.NewPath)
+			result.Skipped++
+			continue
+		}
+
+		switch entry.Operation {
+		case OrganizeCopy, OrganizeHardlink, OrganizeSymlink, JournalLinkBack:
+			// The original is still in place (for link-back, the library copy is)
+			err = os.Remove(entry.NewPath)
+		default: // OrganizeMove, JournalTrash
+			if _, statErr := os.Lstat(entry.OldPath); statErr == nil {
+				warnLog.Printf("undo: skipping %s: %s exists again", entry.NewPath, entry.OldPath)
+				result.Skipped++
+				continue
+			}
+			if err = os.MkdirAll(filepath.Dir(entry.OldPath), 0755); err == nil {
+				err = moveFile(entry.NewPath, entry.OldPath, config.verifyAlgorithm())
+			}
+			if err == nil && cache != nil {
+				cache.RenamePath(entry.NewPath, entry.OldPath)
+			}
+		}
+
+		if err != nil {
+			errorLog.Printf("undo %s: %v", entry.NewPath, err)
+			result.Failed++
+			continue
+		}
+		result.Undone++
+		removeEmptyParents(filepath.Dir(entry.NewPath), append(config.libraryRoots(), config.DuplicatesTrash)...)
+	}
+
+	if err := os.Rename(path, path+undoneSuffix); err != nil {
+		return result, fmt.Errorf("mark journal undone: %w", err)
+	}
+	return result, nil
+}
+
+// removeEmptyParents removes dir and its parents while they are empty and below one of roots
+func removeEmptyParents(dir string, roots ...string) {
+	for {
+		inside := false
+		for _, root := range roots {
+			if isWithinDir(dir, root) && filepath.Clean(dir) != filepath.Clean(root) {
+				inside = true
+			}
+		}
+		if !inside || os.Remove(dir) != nil {
+			return // Outside the library, or not empty
+		}
+		dir = filepath.Dir(dir)
+	}
+}
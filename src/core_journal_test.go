@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// treeOf lists the files below root with their content, relative to root (none when
+// root doesn't exist)
+func treeOf(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return tree
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		rel, _ := filepath.Rel(root, path)
+		tree[rel] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestUndoRestoresTree(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg", "c.jpg")
+	scanPath := config.ScanPaths[0]
+	// A duplicate of a.jpg elsewhere, trashed by the run
+	dupDir := filepath.Join(scanPath, "copies")
+	if err := os.MkdirAll(dupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dup := &MediaFile{Path: filepath.Join(dupDir, "a.jpg"), Size: album.Files[0].Size, Type: TypePhoto}
+	if err := os.WriteFile(dup.Path, []byte("a.jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	duplicates := []*DuplicateGroup{{Files: []*MediaFile{album.Files[0], dup}, Best: album.Files[0]}}
+	before := treeOf(t, scanPath)
+
+	result, err := ExecuteOrganization(t.Context(), []*Album{album}, duplicates, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 4 {
+		t.Fatalf("Moved = %d, want 4", result.Moved)
+	}
+	// The emptied folders are gone: undo recreates them
+	for _, dir := range []string{album.SourceDirs[0], dupDir} {
+		if err := os.Remove(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	journal, err := LatestJournal(config.LibraryBase)
+	if err != nil || journal == "" {
+		t.Fatalf("LatestJournal = %q, %v", journal, err)
+	}
+	undo, err := UndoJournal(journal, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undo.Undone != 4 || undo.Skipped != 0 || undo.Failed != 0 {
+		t.Errorf("undo = %+v, want 4 undone", undo)
+	}
+
+	after := treeOf(t, scanPath)
+	if len(after) != len(before) {
+		t.Errorf("after undo: %v, want %v", after, before)
+	}
+	for path, content := range before {
+		if after[path] != content {
+			t.Errorf("%s after undo = %q, want %q", path, after[path], content)
+		}
+	}
+	if entries, _ := os.ReadDir(album.Destination); len(entries) != 0 {
+		t.Errorf("album folder still has %d files", len(entries))
+	}
+	if latest, _ := LatestJournal(config.LibraryBase); latest == journal {
+		t.Errorf("journal not marked undone")
+	}
+}
+
+func TestUndoSkipsMissingDestinations(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg")
+	if _, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Deleted from the library since
+	if err := os.Remove(filepath.Join(album.Destination, "a.jpg")); err != nil {
+		t.Fatal(err)
+	}
+
+	journal, _ := LatestJournal(config.LibraryBase)
+	undo, err := UndoJournal(journal, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undo.Undone != 1 || undo.Skipped != 1 {
+		t.Errorf("undo = %+v, want 1 undone, 1 skipped", undo)
+	}
+	var names []string
+	for path := range treeOf(t, config.ScanPaths[0]) {
+		names = append(names, filepath.Base(path))
+	}
+	if !slices.Equal(names, []string{"b.jpg"}) {
+		t.Errorf("restored %v, want [b.jpg]", names)
+	}
+}
+
+func TestResumeInterruptedExecution(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg", "c.jpg", "d.jpg")
+	if err := os.MkdirAll(album.Destination, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// An execution interrupted after half the moves: journaled, but not finished
+	journal, err := OpenJournal(config.LibraryBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range album.Files[:2] {
+		dest := album.DestPath(mf)
+		if err := moveFile(mf.Path, dest, ""); err != nil {
+			t.Fatal(err)
+		}
+		journal.Record(OrganizeMove, mf.Path, dest)
+	}
+	journal.Close()
+	if path, _, _ := ResumableJournal(config.LibraryBase); path == "" {
+		t.Fatal("interrupted execution not resumable")
+	}
+
+	// Re-planned from the same scan: the moved sources are gone, which isn't an error
+	config.Resume = true
+	result, err := ExecuteOrganization(t.Context(), []*Album{album}, nil, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Resumed != 2 || result.Moved != 2 || result.Failed != 0 {
+		t.Errorf("Resumed = %d, Moved = %d, Failed = %d, want 2, 2, 0", result.Resumed, result.Moved, result.Failed)
+	}
+	for _, mf := range album.Files {
+		name := filepath.Base(mf.Path)
+		if content, err := os.ReadFile(filepath.Join(album.Destination, name)); err != nil || string(content) != name {
+			t.Errorf("%s in library = %q, %v", name, content, err)
+		}
+	}
+	if entries, _ := os.ReadDir(album.SourceDirs[0]); len(entries) != 0 {
+		t.Errorf("%d files left in the source folder", len(entries))
+	}
+	if path, _, _ := ResumableJournal(config.LibraryBase); path != "" {
+		t.Errorf("finished execution still resumable")
+	}
+
+	// The journal covers the whole run
+	entries, err := ReadJournal(journal.file.Name())
+	if err != nil || len(entries) != 4 {
+		t.Errorf("journal has %d entries (%v), want 4", len(entries), err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+// Log levels (--log-level), each one including the more severe ones before it
+const (
+	LogLevelError = "error" // Failures that leave work undone
+	LogLevelWarn  = "warn"  // Recoverable problems: a file that can't be read, a failed naming request
+	LogLevelInfo  = "info"  // Decisions for a whole run or folder
+	LogLevelDebug = "debug" // Per-file decisions (cache hits, date fallbacks, skip reasons) and retries
+)
+
+// Leveled loggers, all discarded until setLogLevel enables them
+var (
+	errorLog = log.New(io.Discard, "error: ", log.Ltime)
+	warnLog  = log.New(io.Discard, "warn: ", log.Ltime)
+	infoLog  = log.New(io.Discard, "info: ", log.Ltime)
+	debugLog = log.New(io.Discard, "debug: ", log.Ltime)
+)
+
+// setLogLevel sends the messages of level and the more severe levels to w
+func setLogLevel(level string, w io.Writer) error {
+	loggers := []*log.Logger{errorLog, warnLog, infoLog, debugLog}
+	enabled := 0
+	for i, name := range []string{LogLevelError, LogLevelWarn, LogLevelInfo, LogLevelDebug} {
+		if name == level {
+			enabled = i + 1
+		}
+	}
+	if enabled == 0 {
+		return fmt.Errorf("unknown log level %q (use error, warn, info or debug)", level)
+	}
+
+	for i, logger := range loggers {
+		if i < enabled {
+			logger.SetOutput(w)
+		} else {
+			logger.SetOutput(io.Discard)
+		}
+	}
+	return nil
+}
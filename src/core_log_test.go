@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetadataFallbackLogsAtDebug(t *testing.T) {
+	t.Cleanup(func() { setLogLevel(LogLevelError, io.Discard) })
+	path := filepath.Join(t.TempDir(), "IMG_0001.jpg")
+	if err := os.WriteFile(path, []byte("no EXIF here"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		level  string
+		logged bool
+	}{
+		{LogLevelInfo, false},
+		{LogLevelDebug, true},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := setLogLevel(tt.level, &out); err != nil {
+			t.Fatal(err)
+		}
+		mf := &MediaFile{Path: path, Type: TypePhoto}
+		extractMetadata(mf)
+		if mf.DateTaken == nil {
+			t.Fatalf("%s: no date, want the file time", tt.level)
+		}
+
+		logged := strings.Contains(out.String(), "debug: ") &&
+			strings.Contains(out.String(), "metadata "+path+": no date taken, using the file time")
+		if logged != tt.logged {
+			t.Errorf("%s: logged %v, want %v:\n%s", tt.level, logged, tt.logged, out.String())
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"image"
+	_ "image/gif" // Register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/rwcarlsen/goexif/exif"
@@ -11,18 +17,43 @@ import (
 func extractMetadata(mf *MediaFile) {
 	switch mf.Type {
 	case TypePhoto:
-		extractPhotoMetadata(mf)
-	case TypeVideo, TypeMusic:
-		// TODO: Add video/music metadata extraction
-		fallbackToFileTime(mf)
+		switch ext := strings.ToLower(filepath.Ext(mf.Path)); {
+		case heifExtensions[ext]:
+			extractHEIFMetadata(mf)
+		case rawExtensions[ext]:
+			extractRAWMetadata(mf)
+		case ext != ".gif": // GIFs carry no EXIF
+			extractPhotoMetadata(mf)
+		}
+		// Edited/exported files often lack EXIF dimensions
+		if mf.Width == 0 || mf.Height == 0 {
+			extractImageDimensions(mf)
+		}
+	case TypeVideo:
+		extractContainerMetadata(mf)
+	case TypeMusic:
+		extractContainerMetadata(mf)
+		extractMusicMetadata(mf)
 	default:
 		fallbackToFileTime(mf)
 	}
 
-	// Fallback to file modification time if no date found
+	applySidecar(mf, preferSidecarDates)
+
+	// External providers fill in what they know over the built-in extraction (their
+	// dimensions are stored ones too, so orienting comes after)
+	applyMetadataProviders(mf)
+	if mf.Type == TypePhoto {
+		orientDimensions(mf)
+	}
+
+	// Fallback to the date in the file name, then the modification time, if no date found
 	if mf.DateTaken == nil {
+		applyFilenameDate(mf)
 		fallbackToFileTime(mf)
 	}
+
+	classifyImage(mf)
 }
 
 // extractPhotoMetadata extracts EXIF data from photos
@@ -36,12 +67,17 @@ func extractPhotoMetadata(mf *MediaFile) {
 	x, err := exif.Decode(f)
 	if err != nil {
 		// No EXIF data or decode failed - will use file time fallback
+		debugLog.Printf("metadata %s: no EXIF: %v", mf.Path, err)
 		return
 	}
+	applyExif(mf, x)
+}
 
-	// Extract date - try DateTime first (works for most cameras)
-	if tm, err := x.DateTime(); err == nil {
-		mf.DateTaken = &tm
+// applyExif copies date, camera, location, dimensions and orientation from decoded EXIF
+func applyExif(mf *MediaFile, x *exif.Exif) {
+	// Extract date
+	if tm := exifCaptureTime(x); tm != nil {
+		mf.DateTaken = tm
 	}
 
 	// Extract camera make
@@ -58,6 +94,11 @@ func extractPhotoMetadata(mf *MediaFile) {
 		}
 	}
 
+	// Extract location
+	if lat, lon, err := x.LatLong(); err == nil && (lat != 0 || lon != 0) {
+		mf.GPS = &GPSCoord{Lat: lat, Lon: lon}
+	}
+
 	// Extract dimensions
 	if width, err := x.Get(exif.PixelXDimension); err == nil {
 		if w, err := width.Int(0); err == nil {
@@ -70,6 +111,101 @@ func extractPhotoMetadata(mf *MediaFile) {
 			mf.Height = h
 		}
 	}
+
+	if orientation, err := x.Get(exif.Orientation); err == nil {
+		if o, err := orientation.Int(0); err == nil {
+			mf.Orientation = o
+		}
+	}
+}
+
+// orientDimensions turns the stored Width and Height of a photo into its displayed size:
+// EXIF orientations 5-8 rotate the image by 90° or 270°. Photos without a (valid)
+// orientation count as upright.
+func orientDimensions(mf *MediaFile) {
+	if mf.Orientation < 1 || mf.Orientation > 8 {
+		mf.Orientation = 1
+	}
+	if mf.Orientation >= 5 {
+		mf.Width, mf.Height = mf.Height, mf.Width
+	}
+}
+
+// readOrientation re-reads the orientation and displayed dimensions of a photo cached
+// before orientation was recorded
+func readOrientation(mf *MediaFile) {
+	fresh := MediaFile{Path: mf.Path, Size: mf.Size, Type: mf.Type}
+	extractMetadata(&fresh)
+	mf.Orientation, mf.Width, mf.Height = fresh.Orientation, fresh.Width, fresh.Height
+}
+
+// exifCaptureTime returns when a photo was taken: the earliest sensible DateTimeOriginal or
+// DateTimeDigitized, falling back to DateTime (often rewritten by editing tools) only when
+// neither is usable. Returns nil if no tag holds a sensible date.
+func exifCaptureTime(x *exif.Exif) *time.Time {
+	loc := time.Local
+	if tz, _ := x.TimeZone(); tz != nil {
+		loc = tz
+	}
+
+	var capture *time.Time
+	for _, field := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTimeDigitized} {
+		if tm := exifTime(x, field, loc); tm != nil && (capture == nil || tm.Before(*capture)) {
+			capture = tm
+		}
+	}
+	if capture == nil {
+		capture = exifTime(x, exif.DateTime, loc)
+	}
+	return capture
+}
+
+// exifTime parses an EXIF date tag ("2006:01:02 15:04:05"), or returns nil when it is
+// missing, unparsable or not sensible (zeroed by the camera, before 1900 or in the future)
+func exifTime(x *exif.Exif, field exif.FieldName, loc *time.Location) *time.Time {
+	tag, err := x.Get(field)
+	if err != nil {
+		return nil
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return nil
+	}
+	return parseExifTime(value, loc)
+}
+
+// parseExifTime parses an EXIF date value, nil unless it is a sensible date
+func parseExifTime(value string, loc *time.Location) *time.Time {
+	tm, err := time.ParseInLocation("2006:01:02 15:04:05", strings.TrimSpace(strings.TrimRight(value, "\x00")), loc)
+	if err != nil {
+		return nil
+	}
+	return sensibleDate(tm)
+}
+
+// sensibleDate returns tm unless it is before 1900 or in the future
+func sensibleDate(tm time.Time) *time.Time {
+	if tm.Year() < 1900 || tm.After(time.Now().Add(24*time.Hour)) {
+		return nil
+	}
+	return &tm
+}
+
+// extractImageDimensions reads dimensions from the image header (JPEG SOF / PNG IHDR)
+// without decoding pixel data
+func extractImageDimensions(mf *MediaFile) {
+	f, err := os.Open(mf.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return // Unsupported format (RAW, HEIC, ...)
+	}
+	mf.Width = cfg.Width
+	mf.Height = cfg.Height
 }
 
 // fallbackToFileTime uses file modification time as fallback
@@ -82,9 +218,9 @@ func fallbackToFileTime(mf *MediaFile) {
 	if err == nil {
 		modTime := info.ModTime()
 		mf.DateTaken = &modTime
+		debugLog.Printf("metadata %s: no date taken, using the file time %s", mf.Path, modTime.Format(time.DateTime))
 	} else {
-		// Ultimate fallback to current time
-		now := time.Now()
-		mf.DateTaken = &now
+		// No date at all: left unset, the organizer files it under config.NoDateFolder
+		warnLog.Printf("metadata %s: no date taken and no file time (%v), leaving it undated", mf.Path, err)
 	}
 }
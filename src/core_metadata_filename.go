@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultFilenameDatePatterns find the dates cameras, phones and screenshot tools write
+// into file names: IMG_20190704_153000.jpg, PXL_20210101_123456789.jpg, Screenshot
+// 2021-10-02 at 15.30.00.png, IMG-20190704-WA0001.jpg, Screenshot 2021-10-02.png. Only
+// year-first dates are read: 04-07-2019 could be April or July.
+var defaultFilenameDatePatterns = []string{
+	`(?:^|\D)(?P<year>(?:19|20)\d{2})[-_.]?(?P<month>\d{2})[-_.]?(?P<day>\d{2})(?:[ _T-]|\s+at\s+)?(?P<hour>\d{2})[-_.:]?(?P<minute>\d{2})[-_.:]?(?P<second>\d{2})`,
+	`(?:^|\D)(?P<year>(?:19|20)\d{2})[-_.]?(?P<month>\d{2})[-_.]?(?P<day>\d{2})(?:\D|$)`,
+}
+
+// filenameDatePatterns date files without embedded or sidecar dates by their name, tried
+// in order (set from Config.FilenameDates at startup, the defaults otherwise)
+var filenameDatePatterns = mustCompileFilenameDatePatterns(defaultFilenameDatePatterns)
+
+// compileFilenameDatePatterns compiles filename date patterns, which name their parts
+// with the groups year, month and day, and optionally hour, minute and second
+func compileFilenameDatePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("filename date pattern %q: %w", pattern, err)
+		}
+		for _, group := range []string{"year", "month", "day"} {
+			if re.SubexpIndex(group) < 0 {
+				return nil, fmt.Errorf("filename date pattern %q: no (?P<%s>...) group", pattern, group)
+			}
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func mustCompileFilenameDatePatterns(patterns []string) []*regexp.Regexp {
+	compiled, err := compileFilenameDatePatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+// applyFilenameDate dates a file that has no date yet by its name, before the file time
+// is used (copying files often resets that)
+func applyFilenameDate(mf *MediaFile) {
+	if mf.DateTaken != nil {
+		return
+	}
+	if tm := filenameDate(filepath.Base(mf.Path), filenameDatePatterns); tm != nil {
+		mf.DateTaken = tm
+		debugLog.Printf("metadata %s: no date taken, using the date in its name %s", mf.Path, tm.Format(time.DateTime))
+	}
+}
+
+// filenameDate returns the local time the first matching pattern reads from name, nil if
+// none matches with a real date and time (IMG_20191399.jpg is no date)
+func filenameDate(name string, patterns []*regexp.Regexp) *time.Time {
+	for _, re := range patterns {
+		match := re.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		part := func(group string) int {
+			i := re.SubexpIndex(group)
+			if i < 0 || match[i] == "" {
+				return 0
+			}
+			n, err := strconv.Atoi(match[i])
+			if err != nil {
+				return -1
+			}
+			return n
+		}
+		year, month, day := part("year"), part("month"), part("day")
+		hour, minute, second := part("hour"), part("minute"), part("second")
+		if year < 1900 || year > time.Now().Year()+1 || hour < 0 || hour > 23 || minute < 0 || minute > 59 || second < 0 || second > 59 {
+			continue
+		}
+		tm := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.Local)
+		if tm.Month() != time.Month(month) || tm.Day() != day { // Month 13, February 30, ...
+			continue
+		}
+		return &tm
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilenameDate(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // time.DateTime, "" = no date
+	}{
+		{"IMG_20190704_153000.jpg", "2019-07-04 15:30:00"},
+		{"PXL_20210101_123456789.jpg", "2021-01-01 12:34:56"},
+		{"VID_20200229_080910.mp4", "2020-02-29 08:09:10"},
+		{"Screenshot 2021-10-02 at 15.30.00.png", "2021-10-02 15:30:00"},
+		{"Screenshot 2021-10-02.png", "2021-10-02 00:00:00"},
+		{"IMG-20190704-WA0001.jpg", "2019-07-04 00:00:00"},
+		{"2018.12.24 Christmas.jpg", "2018-12-24 00:00:00"},
+		{"04-07-2019.jpg", ""}, // Day or month first: ambiguous
+		{"IMG_20191399.jpg", ""},
+		{"2019-02-30.jpg", ""},
+		{"DSC_0001.jpg", ""},
+		{"IMG_123420190704.jpg", ""}, // Digits run into the date
+	}
+	for _, tt := range tests {
+		tm := filenameDate(tt.name, filenameDatePatterns)
+		got := ""
+		if tm != nil {
+			got = tm.Format(time.DateTime)
+		}
+		if got != tt.want {
+			t.Errorf("filenameDate(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilenameDatePatternsConfigured(t *testing.T) {
+	// Day-first names of one known camera
+	patterns, err := compileFilenameDatePatterns([]string{`^CAM(?P<day>\d{2})(?P<month>\d{2})(?P<year>\d{4})`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := filenameDate("CAM04072019.jpg", patterns)
+	if tm == nil || tm.Format(time.DateOnly) != "2019-07-04" {
+		t.Errorf("filenameDate = %v, want 2019-07-04", tm)
+	}
+
+	if _, err := compileFilenameDatePatterns([]string{`(?P<year>\d{4})(?P<month>\d{2})`}); err == nil {
+		t.Errorf("pattern without a day accepted")
+	}
+}
+
+func TestApplyFilenameDateKeepsEmbeddedDate(t *testing.T) {
+	exif := time.Date(2015, 5, 5, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		dateTaken *time.Time
+		want      string
+	}{
+		{&exif, "2015-05-05"},
+		{nil, "2019-07-04"},
+	}
+	for _, tt := range tests {
+		mf := &MediaFile{Path: "/photos/IMG_20190704_153000.jpg", DateTaken: tt.dateTaken}
+		applyFilenameDate(mf)
+		if mf.DateTaken == nil || mf.DateTaken.Format(time.DateOnly) != tt.want {
+			t.Errorf("date = %v, want %s", mf.DateTaken, tt.want)
+		}
+	}
+}
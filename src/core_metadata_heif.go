@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// heifExtensions are HEIF containers (iPhone photos), which store EXIF as a metadata item
+// that goexif can't find on its own
+var heifExtensions = map[string]bool{
+	".heic": true, ".heif": true,
+}
+
+// maxHEIFExifSize caps how much is read for the EXIF item
+const maxHEIFExifSize = 4 * 1024 * 1024
+
+// extractHEIFMetadata reads EXIF and dimensions from a HEIF file's meta box
+// (files it can't parse keep the file time fallback)
+func extractHEIFMetadata(mf *MediaFile) {
+	f, err := os.Open(mf.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	metaStart, metaEnd, err := findMP4Box(f, 0, info.Size(), "meta")
+	if err != nil {
+		return
+	}
+	metaStart += 4 // meta is a full box (version and flags)
+
+	if tiff, err := readHEIFExif(f, metaStart, metaEnd); err == nil {
+		if x, err := exif.Decode(bytes.NewReader(tiff)); err == nil {
+			applyExif(mf, x)
+		}
+	}
+
+	// The image's own size is authoritative over EXIF
+	if width, height, err := readHEIFSize(f, metaStart, metaEnd); err == nil {
+		mf.Width = width
+		mf.Height = height
+	}
+}
+
+// readHEIFExif returns the TIFF data of the Exif item (located via iinf and iloc)
+func readHEIFExif(r io.ReadSeeker, metaStart, metaEnd int64) ([]byte, error) {
+	itemID, err := findHEIFItem(r, metaStart, metaEnd, "Exif")
+	if err != nil {
+		return nil, err
+	}
+	data, err := readHEIFItem(r, metaStart, metaEnd, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The item starts with the offset of the TIFF header (past an "Exif\0\0" prefix)
+	if len(data) < 4 {
+		return nil, errors.New("invalid Exif item")
+	}
+	skip := int64(binary.BigEndian.Uint32(data[:4]))
+	if skip > int64(len(data)-4) {
+		return nil, errors.New("invalid Exif item")
+	}
+	return data[4+skip:], nil
+}
+
+// findHEIFItem returns the ID of the first item of the given type in iinf
+func findHEIFItem(r io.ReadSeeker, metaStart, metaEnd int64, itemType string) (uint32, error) {
+	start, end, err := findMP4Box(r, metaStart, metaEnd, "iinf")
+	if err != nil {
+		return 0, err
+	}
+	version, err := readFullBoxVersion(r, start)
+	if err != nil {
+		return 0, err
+	}
+	start += 4
+	if version == 0 {
+		start += 2 // entry_count (16 bits)
+	} else {
+		start += 4
+	}
+
+	for offset := start; offset+8 <= end; {
+		size, name, headerLen, err := readMP4BoxHeader(r, offset)
+		if err != nil {
+			return 0, err
+		}
+		if size < headerLen || offset+size > end {
+			return 0, errors.New("invalid box size")
+		}
+
+		if name == "infe" {
+			// Versions 2 and 3: item_ID, item_protection_index (16 bits), item_type
+			infeVersion, err := readFullBoxVersion(r, offset+headerLen)
+			if err != nil {
+				return 0, err
+			}
+			if infeVersion >= 2 {
+				idSize := 2
+				if infeVersion == 3 {
+					idSize = 4
+				}
+				buf := make([]byte, idSize+2+4)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return 0, err
+				}
+				if string(buf[idSize+2:]) == itemType {
+					return uint32(readUintN(buf[:idSize])), nil
+				}
+			}
+		}
+		offset += size
+	}
+	return 0, errors.New(itemType + " item not found")
+}
+
+// readHEIFItem reads an item's data from the extents listed in iloc (file offsets only)
+func readHEIFItem(r io.ReadSeeker, metaStart, metaEnd int64, itemID uint32) ([]byte, error) {
+	start, end, err := findMP4Box(r, metaStart, metaEnd, "iloc")
+	if err != nil {
+		return nil, err
+	}
+	if end-start > 1024*1024 {
+		return nil, errors.New("iloc too large")
+	}
+	iloc := make([]byte, end-start)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, iloc); err != nil {
+		return nil, err
+	}
+
+	p := &byteParser{data: iloc}
+	version := p.uint(1)
+	p.uint(3) // flags
+	sizes := p.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0xf)
+	sizes = p.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0xf)
+	if version == 0 {
+		indexSize = 0 // Reserved
+	}
+	var itemCount uint64
+	if version < 2 {
+		itemCount = p.uint(2)
+	} else {
+		itemCount = p.uint(4)
+	}
+
+	for i := uint64(0); i < itemCount && p.err == nil; i++ {
+		var id uint64
+		if version < 2 {
+			id = p.uint(2)
+		} else {
+			id = p.uint(4)
+		}
+		constructionMethod := uint64(0)
+		if version >= 1 {
+			constructionMethod = p.uint(2) & 0xf
+		}
+		p.uint(2) // data_reference_index
+		baseOffset := p.uint(baseOffsetSize)
+		extentCount := p.uint(2)
+
+		var data []byte
+		for e := uint64(0); e < extentCount && p.err == nil; e++ {
+			p.uint(indexSize)
+			extentOffset := p.uint(offsetSize)
+			extentLength := p.uint(lengthSize)
+			if uint32(id) != itemID {
+				continue
+			}
+			if constructionMethod != 0 {
+				return nil, errors.New("unsupported item construction method")
+			}
+			if extentLength == 0 || uint64(len(data))+extentLength > maxHEIFExifSize {
+				return nil, errors.New("invalid item length")
+			}
+
+			extent := make([]byte, extentLength)
+			if _, err := r.Seek(int64(baseOffset+extentOffset), io.SeekStart); err != nil {
+				return nil, err
+			}
+			if _, err := io.ReadFull(r, extent); err != nil {
+				return nil, err
+			}
+			data = append(data, extent...)
+		}
+		if uint32(id) == itemID && p.err == nil {
+			return data, nil
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return nil, errors.New("item location not found")
+}
+
+// readHEIFSize returns the largest image spatial extent (ispe) in iprp/ipco, which is
+// the full image rather than its tiles or thumbnail
+func readHEIFSize(r io.ReadSeeker, metaStart, metaEnd int64) (int, int, error) {
+	iprpStart, iprpEnd, err := findMP4Box(r, metaStart, metaEnd, "iprp")
+	if err != nil {
+		return 0, 0, err
+	}
+	start, end, err := findMP4Box(r, iprpStart, iprpEnd, "ipco")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var width, height uint32
+	for offset := start; offset+8 <= end; {
+		size, name, headerLen, err := readMP4BoxHeader(r, offset)
+		if err != nil {
+			return 0, 0, err
+		}
+		if size < headerLen || offset+size > end {
+			return 0, 0, errors.New("invalid box size")
+		}
+		if name == "ispe" && size >= headerLen+12 {
+			var buf [12]byte // version/flags, width, height
+			if _, err := io.ReadFull(r, buf[:]); err != nil {
+				return 0, 0, err
+			}
+			w, h := binary.BigEndian.Uint32(buf[4:8]), binary.BigEndian.Uint32(buf[8:12])
+			if uint64(w)*uint64(h) > uint64(width)*uint64(height) {
+				width, height = w, h
+			}
+		}
+		offset += size
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, errors.New("ispe not found")
+	}
+	return int(width), int(height), nil
+}
+
+// readFullBoxVersion reads the version byte of a full box payload starting at offset
+// (the reader is left after the version and flags)
+func readFullBoxVersion(r io.ReadSeeker, offset int64) (byte, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var versionFlags [4]byte
+	if _, err := io.ReadFull(r, versionFlags[:]); err != nil {
+		return 0, err
+	}
+	return versionFlags[0], nil
+}
+
+// byteParser reads big-endian unsigned integers of varying width, remembering the first error
+type byteParser struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// uint reads an n-byte big-endian integer (n = 0 reads nothing and returns 0)
+func (p *byteParser) uint(n int) uint64 {
+	if p.err != nil {
+		return 0
+	}
+	if n > 8 || p.pos+n > len(p.data) {
+		p.err = errors.New("truncated box")
+		return 0
+	}
+	v := readUintN(p.data[p.pos : p.pos+n])
+	p.pos += n
+	return v
+}
+
+// readUintN decodes a big-endian integer of up to 8 bytes
+func readUintN(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
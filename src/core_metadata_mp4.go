@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mp4Extensions are ISO base media containers that carry a movie header (mvhd) atom
+var mp4Extensions = map[string]bool{
+	".mp4": true, ".mov": true, ".m4v": true, ".3gp": true, ".m4a": true,
+}
+
+// mp4Epoch is the reference time for QuickTime/MP4 timestamps
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// mvhdInfo holds the fields we use from the movie header atom
+type mvhdInfo struct {
+	Created  time.Time // Zero if unset
+	Duration time.Duration
+}
+
+// extractContainerMetadata reads creation time and duration from MP4/MOV containers
+func extractContainerMetadata(mf *MediaFile) {
+	if !mp4Extensions[strings.ToLower(filepath.Ext(mf.Path))] {
+		return
+	}
+
+	f, err := os.Open(mf.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := readMvhd(f)
+	if err != nil {
+		return
+	}
+
+	mf.Duration = info.Duration
+	// Audio encoders stamp the encoding date, only trust it for video
+	if mf.Type == TypeVideo && !info.Created.IsZero() && mf.DateTaken == nil {
+		created := info.Created.Local()
+		mf.DateTaken = &created
+	}
+}
+
+// readMvhd finds moov/mvhd by walking box headers (payloads other than moov are skipped)
+func readMvhd(r io.ReadSeeker) (*mvhdInfo, error) {
+	var header [8]byte
+	inMoov := false
+	var moovEnd int64 = -1
+
+	for {
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if moovEnd >= 0 && offset >= moovEnd {
+			return nil, errors.New("mvhd not found")
+		}
+
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, errors.New("mvhd not found")
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+
+		if size == 1 {
+			// 64-bit extended size
+			var ext [8]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(ext[:]))
+			headerLen = 16
+		}
+		if size != 0 && size < headerLen {
+			return nil, errors.New("invalid box size")
+		}
+
+		switch {
+		case boxType == "moov" && !inMoov:
+			inMoov = true
+			if size != 0 {
+				moovEnd = offset + size
+			}
+			continue // Descend into children
+		case boxType == "mvhd" && inMoov:
+			return parseMvhd(r)
+		}
+
+		if size == 0 {
+			return nil, errors.New("mvhd not found") // Box extends to end of file
+		}
+		if _, err := r.Seek(offset+size, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseMvhd decodes the mvhd payload (reader positioned after the box header)
+func parseMvhd(r io.Reader) (*mvhdInfo, error) {
+	var versionFlags [4]byte
+	if _, err := io.ReadFull(r, versionFlags[:]); err != nil {
+		return nil, err
+	}
+
+	var created, duration uint64
+	var timescale uint32
+	if versionFlags[0] == 1 {
+		var buf [28]byte // created(8) modified(8) timescale(4) duration(8)
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		created = binary.BigEndian.Uint64(buf[0:8])
+		timescale = binary.BigEndian.Uint32(buf[16:20])
+		duration = binary.BigEndian.Uint64(buf[20:28])
+	} else {
+		var buf [16]byte // created(4) modified(4) timescale(4) duration(4)
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		created = uint64(binary.BigEndian.Uint32(buf[0:4]))
+		timescale = binary.BigEndian.Uint32(buf[8:12])
+		duration = uint64(binary.BigEndian.Uint32(buf[12:16]))
+	}
+
+	info := &mvhdInfo{}
+	if created > 0 {
+		info.Created = mp4Epoch.Add(time.Duration(created) * time.Second)
+	}
+	if timescale > 0 {
+		info.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	}
+	return info, nil
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// rawExtensions are camera RAW formats built on TIFF, whose maker-specific IFDs and huge
+// image strips often make goexif give up on the whole file
+var rawExtensions = map[string]bool{
+	".cr2": true, ".nef": true, ".arw": true,
+}
+
+// TIFF tags read from RAW files
+const (
+	tagNewSubfileType    = 0x00fe
+	tagImageWidth        = 0x0100
+	tagImageLength       = 0x0101
+	tagCompression       = 0x0103
+	tagMake              = 0x010f
+	tagModel             = 0x0110
+	tagStripOffsets      = 0x0111
+	tagOrientation       = 0x0112
+	tagStripByteCounts   = 0x0117
+	tagDateTime          = 0x0132
+	tagSubIFDs           = 0x014a
+	tagJPEGOffset        = 0x0201
+	tagJPEGLength        = 0x0202
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+	tagPixelXDimension   = 0xa002
+	tagPixelYDimension   = 0xa003
+	tagGPSLatitudeRef    = 0x0001
+	tagGPSLatitude       = 0x0002
+	tagGPSLongitudeRef   = 0x0003
+	tagGPSLongitude      = 0x0004
+)
+
+const (
+	// maxRAWIFDs bounds how many IFDs are followed (RAW files have a handful)
+	maxRAWIFDs = 32
+
+	// maxTIFFValueSize bounds a single tag value read from the file
+	maxTIFFValueSize = 64 * 1024
+)
+
+// rawInfo is what the TIFF structure of a RAW file tells
+type rawInfo struct {
+	make, model                       string
+	dateOriginal, dateDigitized, date string
+	exifWidth, exifHeight             int // PixelX/YDimension from the Exif IFD
+	width, height                     int // Largest full-resolution image IFD
+	orientation                       int // From IFD0
+	gps                               *GPSCoord
+	previewOffset, previewLength      int64 // Largest embedded JPEG
+}
+
+// extractRAWMetadata reads date, camera, location and dimensions by walking the TIFF IFDs
+// of a RAW file. Without a date there, the EXIF of the embedded preview JPEG is used;
+// files that aren't TIFF-based fall back to goexif.
+func extractRAWMetadata(mf *MediaFile) {
+	f, err := os.Open(mf.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	raw, err := readRAWInfo(f, info.Size())
+	if err != nil {
+		extractPhotoMetadata(mf)
+		return
+	}
+
+	var capture *time.Time
+	for _, value := range []string{raw.dateOriginal, raw.dateDigitized} {
+		if tm := parseExifTime(value, time.Local); tm != nil && (capture == nil || tm.Before(*capture)) {
+			capture = tm
+		}
+	}
+	if capture == nil {
+		capture = parseExifTime(raw.date, time.Local)
+	}
+	mf.DateTaken = capture
+	mf.CameraMake = raw.make
+	mf.CameraModel = raw.model
+	mf.GPS = raw.gps
+	mf.Orientation = raw.orientation
+	mf.Width, mf.Height = raw.exifWidth, raw.exifHeight
+	if mf.Width == 0 || mf.Height == 0 {
+		mf.Width, mf.Height = raw.width, raw.height
+	}
+
+	// The preview is a JPEG with its own EXIF, usually a copy of the RAW's
+	if mf.DateTaken == nil && raw.previewLength > 0 {
+		if x, err := exif.Decode(io.NewSectionReader(f, raw.previewOffset, raw.previewLength)); err == nil {
+			mf.DateTaken = exifCaptureTime(x)
+			var preview MediaFile
+			applyExif(&preview, x)
+			if mf.CameraMake == "" {
+				mf.CameraMake, mf.CameraModel = preview.CameraMake, preview.CameraModel
+			}
+			if mf.GPS == nil {
+				mf.GPS = preview.GPS
+			}
+		}
+	}
+}
+
+// tiffReader reads IFDs and tag values from TIFF data
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+	size  int64
+}
+
+// tiffEntry is one 12-byte IFD entry
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    [4]byte // The value itself when it fits, else its offset
+}
+
+// tiffTypeSizes are the byte sizes of the TIFF field types (BYTE, ASCII, SHORT, LONG,
+// RATIONAL, SBYTE, UNDEFINED, SSHORT, SLONG, SRATIONAL, FLOAT, DOUBLE, IFD)
+var tiffTypeSizes = map[uint16]int64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+// readRAWInfo walks IFD0 and the IFDs chained to it, SubIFDs, and the Exif and GPS IFDs
+func readRAWInfo(r io.ReaderAt, size int64) (*rawInfo, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	t := &tiffReader{r: r, size: size}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errors.New("not a TIFF file")
+	}
+	if t.order.Uint16(header[2:4]) != 42 {
+		return nil, errors.New("not a TIFF file")
+	}
+
+	type ifdRef struct {
+		offset int64
+		kind   int
+	}
+	const (
+		imageIFD = iota
+		exifIFD
+		gpsIFD
+	)
+
+	info := &rawInfo{}
+	ifd0 := int64(t.order.Uint32(header[4:8]))
+	queue := []ifdRef{{ifd0, imageIFD}}
+	visited := make(map[int64]bool)
+	for len(queue) > 0 && len(visited) < maxRAWIFDs {
+		ref := queue[0]
+		queue = queue[1:]
+		if ref.offset == 0 || visited[ref.offset] {
+			continue
+		}
+		visited[ref.offset] = true
+
+		entries, next, err := t.ifd(ref.offset)
+		if err != nil {
+			if ref.offset == ifd0 {
+				return nil, err // IFD0 is required, the rest is best effort
+			}
+			continue
+		}
+
+		switch ref.kind {
+		case exifIFD:
+			for _, e := range entries {
+				switch e.tag {
+				case tagDateTimeOriginal:
+					info.dateOriginal = t.str(e)
+				case tagDateTimeDigitized:
+					info.dateDigitized = t.str(e)
+				case tagPixelXDimension:
+					info.exifWidth = int(t.uint(e, 0))
+				case tagPixelYDimension:
+					info.exifHeight = int(t.uint(e, 0))
+				}
+			}
+
+		case gpsIFD:
+			info.gps = t.gps(entries)
+
+		default:
+			queue = append(queue, ifdRef{next, imageIFD})
+			var subfileType, width, height, compression uint32
+			var stripOffset, stripLength, jpegOffset, jpegLength int64
+			strips := 0
+			for _, e := range entries {
+				switch e.tag {
+				case tagNewSubfileType:
+					subfileType = t.uint(e, 0)
+				case tagImageWidth:
+					width = t.uint(e, 0)
+				case tagImageLength:
+					height = t.uint(e, 0)
+				case tagCompression:
+					compression = t.uint(e, 0)
+				case tagMake:
+					if info.make == "" {
+						info.make = t.str(e)
+					}
+				case tagModel:
+					if info.model == "" {
+						info.model = t.str(e)
+					}
+				case tagDateTime:
+					if info.date == "" {
+						info.date = t.str(e)
+					}
+				case tagOrientation:
+					if info.orientation == 0 {
+						info.orientation = int(t.uint(e, 0))
+					}
+				case tagStripOffsets:
+					stripOffset, strips = int64(t.uint(e, 0)), int(e.count)
+				case tagStripByteCounts:
+					stripLength = int64(t.uint(e, 0))
+				case tagJPEGOffset:
+					jpegOffset = int64(t.uint(e, 0))
+				case tagJPEGLength:
+					jpegLength = int64(t.uint(e, 0))
+				case tagSubIFDs:
+					for i := uint32(0); i < e.count && i < maxRAWIFDs; i++ {
+						queue = append(queue, ifdRef{int64(t.uint(e, int(i))), imageIFD})
+					}
+				case tagExifIFD:
+					queue = append(queue, ifdRef{int64(t.uint(e, 0)), exifIFD})
+				case tagGPSIFD:
+					queue = append(queue, ifdRef{int64(t.uint(e, 0)), gpsIFD})
+				}
+			}
+
+			if subfileType == 0 && int(width)*int(height) > info.width*info.height {
+				info.width, info.height = int(width), int(height)
+			}
+			// Previews: a JPEG referenced directly, or a single old-style JPEG strip (CR2 IFD0)
+			if compression == 6 && strips == 1 && jpegLength == 0 {
+				jpegOffset, jpegLength = stripOffset, stripLength
+			}
+			if jpegLength > info.previewLength && jpegOffset > 0 && jpegOffset+jpegLength <= t.size {
+				info.previewOffset, info.previewLength = jpegOffset, jpegLength
+			}
+		}
+	}
+	return info, nil
+}
+
+// ifd reads the entries of the IFD at offset and the offset of the next one
+func (t *tiffReader) ifd(offset int64) ([]tiffEntry, int64, error) {
+	var countBuf [2]byte
+	if _, err := t.r.ReadAt(countBuf[:], offset); err != nil {
+		return nil, 0, err
+	}
+	count := int64(t.order.Uint16(countBuf[:]))
+	if offset+2+count*12+4 > t.size {
+		return nil, 0, errors.New("IFD beyond end of file")
+	}
+
+	buf := make([]byte, count*12+4)
+	if _, err := t.r.ReadAt(buf, offset+2); err != nil {
+		return nil, 0, err
+	}
+	entries := make([]tiffEntry, count)
+	for i := range entries {
+		b := buf[i*12:]
+		entries[i] = tiffEntry{tag: t.order.Uint16(b[0:2]), typ: t.order.Uint16(b[2:4]), count: t.order.Uint32(b[4:8])}
+		copy(entries[i].value[:], b[8:12])
+	}
+	return entries, int64(t.order.Uint32(buf[count*12:])), nil
+}
+
+// data returns the raw bytes of a tag value
+func (t *tiffReader) data(e tiffEntry) []byte {
+	size := tiffTypeSizes[e.typ] * int64(e.count)
+	if size == 0 || size > maxTIFFValueSize {
+		return nil
+	}
+	if size <= 4 {
+		return e.value[:size]
+	}
+	offset := int64(t.order.Uint32(e.value[:]))
+	if offset+size > t.size {
+		return nil
+	}
+	buf := make([]byte, size)
+	if _, err := t.r.ReadAt(buf, offset); err != nil {
+		return nil
+	}
+	return buf
+}
+
+// uint returns the i-th value of a BYTE, SHORT, LONG or IFD tag (0 if there is none)
+func (t *tiffReader) uint(e tiffEntry, i int) uint32 {
+	data := t.data(e)
+	switch size := int(tiffTypeSizes[e.typ]); {
+	case e.typ == 1 && i < len(data):
+		return uint32(data[i])
+	case e.typ == 3 && (i+1)*size <= len(data):
+		return uint32(t.order.Uint16(data[i*size:]))
+	case (e.typ == 4 || e.typ == 13) && (i+1)*size <= len(data):
+		return t.order.Uint32(data[i*size:])
+	}
+	return 0
+}
+
+// str returns an ASCII tag value without padding
+func (t *tiffReader) str(e tiffEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.data(e)), "\x00"))
+}
+
+// rational returns the i-th value of a RATIONAL tag
+func (t *tiffReader) rational(e tiffEntry, i int) (float64, bool) {
+	data := t.data(e)
+	if e.typ != 5 || (i+1)*8 > len(data) {
+		return 0, false
+	}
+	num, den := t.order.Uint32(data[i*8:]), t.order.Uint32(data[i*8+4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// gps returns the location in a GPS IFD (nil if incomplete or 0,0)
+func (t *tiffReader) gps(entries []tiffEntry) *GPSCoord {
+	var latRef, lonRef string
+	var lat, lon []float64
+	degrees := func(e tiffEntry) []float64 {
+		var dms []float64
+		for i := 0; i < 3; i++ {
+			v, ok := t.rational(e, i)
+			if !ok {
+				return nil
+			}
+			dms = append(dms, v)
+		}
+		return dms
+	}
+	for _, e := range entries {
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = t.str(e)
+		case tagGPSLatitude:
+			lat = degrees(e)
+		case tagGPSLongitudeRef:
+			lonRef = t.str(e)
+		case tagGPSLongitude:
+			lon = degrees(e)
+		}
+	}
+	if lat == nil || lon == nil {
+		return nil
+	}
+
+	coord := &GPSCoord{Lat: lat[0] + lat[1]/60 + lat[2]/3600, Lon: lon[0] + lon[1]/60 + lon[2]/3600}
+	if latRef == "S" {
+		coord.Lat = -coord.Lat
+	}
+	if lonRef == "W" {
+		coord.Lon = -coord.Lon
+	}
+	if coord.Lat == 0 && coord.Lon == 0 {
+		return nil
+	}
+	return coord
+}
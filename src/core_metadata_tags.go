@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// maxID3Size bounds the ID3v2 tag read into memory (embedded cover art makes tags large)
+const maxID3Size = 64 << 20
+
+// audioTags are the tag fields used to organize music
+type audioTags struct {
+	Artist      string
+	AlbumArtist string
+	Album       string
+	Title       string
+	Date        string // Year or full date as written in the tag
+}
+
+// extractMusicMetadata fills Artist, Album, Title and the recording date from
+// ID3 (MP3), Vorbis comments (FLAC) or iTunes atoms (M4A/ALAC)
+func extractMusicMetadata(mf *MediaFile) {
+	f, err := os.Open(mf.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var tags *audioTags
+	switch strings.ToLower(filepath.Ext(mf.Path)) {
+	case ".mp3":
+		tags, err = readID3(f)
+	case ".flac":
+		tags, err = readFLACTags(f)
+	case ".m4a", ".alac", ".aac":
+		tags, err = readMP4Tags(f)
+	default:
+		return
+	}
+	if err != nil || tags == nil {
+		return
+	}
+
+	// Album artist keeps compilations together
+	if tags.AlbumArtist != "" {
+		mf.Artist = tags.AlbumArtist
+	} else if tags.Artist != "" {
+		mf.Artist = tags.Artist
+	}
+	if tags.Album != "" {
+		mf.Album = tags.Album
+	}
+	if tags.Title != "" {
+		mf.Title = tags.Title
+	}
+	if date, ok := parseTagDate(tags.Date); ok {
+		mf.DateTaken = &date
+	}
+}
+
+// parseTagDate accepts "2004", "2004-05" or "2004-05-12" (anything after the date is ignored)
+func parseTagDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if len(s) >= len(layout) {
+			if tm, err := time.ParseInLocation(layout, s[:len(layout)], time.Local); err == nil && tm.Year() > 1000 {
+				return tm, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// readID3 reads an ID3v2 tag at the start of the file, falling back to ID3v1 at the end
+func readID3(r io.ReadSeeker) (*audioTags, error) {
+	tags, err := readID3v2(r)
+	if err == nil {
+		return tags, nil
+	}
+	return readID3v1(r)
+}
+
+// readID3v2 parses ID3v2.2, v2.3 and v2.4 text frames
+func readID3v2(r io.ReadSeeker) (*audioTags, error) {
+	var header [10]byte
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:3]) != "ID3" {
+		return nil, errors.New("no ID3v2 tag")
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if version < 2 || version > 4 {
+		return nil, errors.New("unsupported ID3v2 version")
+	}
+	if size > maxID3Size {
+		return nil, errors.New("ID3v2 tag too large")
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	// Whole-tag unsynchronisation (v2.4 marks it per frame, which we don't support)
+	if flags&0x80 != 0 && version < 4 {
+		data = bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	// Skip extended header
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(data[:4]))
+		if version == 4 {
+			extSize = syncsafe(data[:4])
+		} else {
+			extSize += 4 // v2.3 size excludes itself
+		}
+		if extSize > len(data) {
+			return nil, errors.New("invalid extended header")
+		}
+		data = data[extSize:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	tags := &audioTags{}
+	for len(data) >= headerLen && data[0] != 0 {
+		id := string(data[:idLen])
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[4:8]))
+		default:
+			frameSize = syncsafe(data[4:8])
+		}
+		if frameSize <= 0 || headerLen+frameSize > len(data) {
+			break
+		}
+		body := data[headerLen : headerLen+frameSize]
+		data = data[headerLen+frameSize:]
+
+		var field *string
+		switch id {
+		case "TPE1", "TP1":
+			field = &tags.Artist
+		case "TPE2", "TP2":
+			field = &tags.AlbumArtist
+		case "TALB", "TAL":
+			field = &tags.Album
+		case "TIT2", "TT2":
+			field = &tags.Title
+		case "TDRC", "TYER", "TYE": // Recording time (v2.4), year (v2.3, v2.2)
+			field = &tags.Date
+		default:
+			continue
+		}
+		*field = decodeID3Text(body)
+	}
+
+	return tags, nil
+}
+
+// syncsafe decodes a 28-bit integer stored in 7 bits per byte
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// decodeID3Text decodes a text frame body (encoding byte + text), returning the first value
+func decodeID3Text(body []byte) string {
+	if len(body) < 2 {
+		return ""
+	}
+	encoding, text := body[0], body[1:]
+
+	var s string
+	switch encoding {
+	case 0: // ISO-8859-1
+		runes := make([]rune, len(text))
+		for i, c := range text {
+			runes[i] = rune(c)
+		}
+		s = string(runes)
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		s = decodeUTF16(text, encoding == 2)
+	default: // UTF-8
+		s = string(text)
+	}
+
+	// v2.4 separates multiple values with NUL
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// decodeUTF16 decodes UTF-16 text, using the BOM if present
+func decodeUTF16(b []byte, bigEndian bool) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xFF && b[1] == 0xFE:
+			bigEndian, b = false, b[2:]
+		case b[0] == 0xFE && b[1] == 0xFF:
+			bigEndian, b = true, b[2:]
+		}
+	}
+
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if bigEndian {
+			units = append(units, binary.BigEndian.Uint16(b[i:]))
+		} else {
+			units = append(units, binary.LittleEndian.Uint16(b[i:]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// readID3v1 parses the fixed 128-byte tag at the end of the file
+func readID3v1(r io.ReadSeeker) (*audioTags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	var tag [128]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil {
+		return nil, err
+	}
+	if string(tag[:3]) != "TAG" {
+		return nil, errors.New("no ID3 tag")
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+	}
+	return &audioTags{
+		Title:  field(tag[3:33]),
+		Artist: field(tag[33:63]),
+		Album:  field(tag[63:93]),
+		Date:   field(tag[93:97]),
+	}, nil
+}
+
+// readFLACTags reads the Vorbis comment block of a FLAC file
+func readFLACTags(r io.Reader) (*audioTags, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		if blockType == 4 { // VORBIS_COMMENT
+			block := make([]byte, size)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return nil, err
+			}
+			return parseVorbisComments(block)
+		}
+		if last {
+			return nil, errors.New("no Vorbis comments")
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseVorbisComments decodes a Vorbis comment block (little-endian lengths, KEY=value entries)
+func parseVorbisComments(block []byte) (*audioTags, error) {
+	next := func() ([]byte, bool) {
+		if len(block) < 4 {
+			return nil, false
+		}
+		n := int(binary.LittleEndian.Uint32(block))
+		if n > len(block)-4 {
+			return nil, false
+		}
+		value := block[4 : 4+n]
+		block = block[4+n:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok { // Vendor string
+		return nil, errors.New("invalid Vorbis comments")
+	}
+	if len(block) < 4 {
+		return nil, errors.New("invalid Vorbis comments")
+	}
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+
+	tags := &audioTags{}
+	for i := 0; i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+		key, value, found := strings.Cut(string(comment), "=")
+		if !found {
+			continue
+		}
+		var field *string
+		switch strings.ToUpper(key) {
+		case "ARTIST":
+			field = &tags.Artist
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			field = &tags.AlbumArtist
+		case "ALBUM":
+			field = &tags.Album
+		case "TITLE":
+			field = &tags.Title
+		case "DATE", "YEAR":
+			field = &tags.Date
+		default:
+			continue
+		}
+		if *field == "" { // First value wins
+			*field = strings.TrimSpace(value)
+		}
+	}
+	return tags, nil
+}
+
+// readMP4Tags reads iTunes-style metadata from moov/udta/meta/ilst
+func readMP4Tags(r io.ReadSeeker) (*audioTags, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int64(0)
+	for _, path := range []string{"moov", "udta", "meta", "ilst"} {
+		boxStart, boxEnd, err := findMP4Box(r, start, end, path)
+		if err != nil {
+			return nil, err
+		}
+		start, end = boxStart, boxEnd
+		if path == "meta" {
+			start += 4 // Full box: version and flags precede the children
+		}
+	}
+
+	tags := &audioTags{}
+	for offset := start; offset+8 <= end; {
+		size, name, headerLen, err := readMP4BoxHeader(r, offset)
+		if err != nil || size < headerLen || offset+size > end {
+			break
+		}
+
+		var field *string
+		switch name {
+		case "\xa9ART":
+			field = &tags.Artist
+		case "aART":
+			field = &tags.AlbumArtist
+		case "\xa9alb":
+			field = &tags.Album
+		case "\xa9nam":
+			field = &tags.Title
+		case "\xa9day":
+			field = &tags.Date
+		}
+		if field != nil {
+			if value, err := readMP4DataText(r, offset+headerLen, offset+size); err == nil {
+				*field = value
+			}
+		}
+		offset += size
+	}
+	return tags, nil
+}
+
+// findMP4Box returns the payload range of the first box named name within [start, end)
+func findMP4Box(r io.ReadSeeker, start, end int64, name string) (int64, int64, error) {
+	for offset := start; offset+8 <= end; {
+		size, boxName, headerLen, err := readMP4BoxHeader(r, offset)
+		if err != nil {
+			return 0, 0, err
+		}
+		if size == 0 {
+			size = end - offset // Extends to the end of the parent
+		}
+		if size < headerLen || offset+size > end {
+			return 0, 0, errors.New("invalid box size")
+		}
+		if boxName == name {
+			return offset + headerLen, offset + size, nil
+		}
+		offset += size
+	}
+	return 0, 0, errors.New(name + " not found")
+}
+
+// readMP4BoxHeader reads the size, type and header length of the box at offset
+func readMP4BoxHeader(r io.ReadSeeker, offset int64) (int64, string, int64, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, "", 0, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	if size == 1 {
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, "", 0, err
+		}
+		return int64(binary.BigEndian.Uint64(ext[:])), string(header[4:8]), 16, nil
+	}
+	return size, string(header[4:8]), 8, nil
+}
+
+// readMP4DataText reads the UTF-8 value of the "data" box inside an ilst item
+func readMP4DataText(r io.ReadSeeker, start, end int64) (string, error) {
+	dataStart, dataEnd, err := findMP4Box(r, start, end, "data")
+	if err != nil {
+		return "", err
+	}
+	// Type indicator (4) and locale (4) precede the value
+	if dataEnd-dataStart < 8 || dataEnd-dataStart > 64*1024 {
+		return "", errors.New("invalid data box")
+	}
+	value := make([]byte, dataEnd-dataStart-8)
+	if _, err := r.Seek(dataStart+8, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(r, value); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// id3Frame is a text frame written by buildID3v2
+type id3Frame struct {
+	id       string
+	encoding byte
+	text     []byte
+}
+
+// buildID3v2 builds an ID3v2 tag of the given major version (2, 3 or 4) followed by padding
+// and a few bytes standing in for audio
+func buildID3v2(version byte, frames ...id3Frame) []byte {
+	var body bytes.Buffer
+	for _, f := range frames {
+		size := len(f.text) + 1
+		body.WriteString(f.id)
+		switch version {
+		case 2:
+			body.Write([]byte{byte(size >> 16), byte(size >> 8), byte(size)})
+		case 3:
+			body.Write(binary.BigEndian.AppendUint32(nil, uint32(size)))
+			body.Write([]byte{0, 0})
+		default:
+			body.Write(syncsafeBytes(size))
+			body.Write([]byte{0, 0})
+		}
+		body.WriteByte(f.encoding)
+		body.Write(f.text)
+	}
+	body.Write(make([]byte, 16)) // Padding
+
+	out := []byte{'I', 'D', '3', version, 0, 0}
+	out = append(out, syncsafeBytes(body.Len())...)
+	out = append(out, body.Bytes()...)
+	return append(out, 0xFF, 0xFB, 0x90, 0x00) // MPEG frame header
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// buildID3v1 builds audio bytes ending in a 128-byte ID3v1 tag
+func buildID3v1(title, artist, album, year string) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	copy(tag[33:63], artist)
+	copy(tag[63:93], album)
+	copy(tag[93:97], year)
+	return append([]byte{0xFF, 0xFB, 0x90, 0x00}, tag...)
+}
+
+// mp4Box builds an MP4 box from its name and children
+func mp4Box(name string, children ...[]byte) []byte {
+	payload := bytes.Join(children, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	out = append(out, name...)
+	return append(out, payload...)
+}
+
+// mp4Item builds an ilst item holding a UTF-8 data box
+func mp4Item(name, value string) []byte {
+	data := []byte{0, 0, 0, 1, 0, 0, 0, 0} // Type UTF-8, default locale
+	return mp4Box(name, mp4Box("data", data, []byte(value)))
+}
+
+// buildM4A builds an M4A whose moov/udta/meta/ilst holds the given items
+func buildM4A(items ...[]byte) []byte {
+	meta := mp4Box("meta",
+		[]byte{0, 0, 0, 0}, // Version and flags
+		mp4Box("hdlr", make([]byte, 25)),
+		mp4Box("ilst", items...))
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("M4A "), make([]byte, 4), []byte("M4A isom")),
+		mp4Box("moov", mp4Box("mvhd", make([]byte, 100)), mp4Box("udta", meta)),
+		mp4Box("mdat", make([]byte, 32)),
+	}, nil)
+}
+
+// buildFLAC builds a FLAC file with STREAMINFO and a Vorbis comment block
+func buildFLAC(comments ...string) []byte {
+	var block bytes.Buffer
+	vendor := "reference libFLAC 1.4.3"
+	block.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(vendor))))
+	block.WriteString(vendor)
+	block.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(comments))))
+	for _, c := range comments {
+		block.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(c))))
+		block.WriteString(c)
+	}
+
+	out := []byte("fLaC")
+	out = append(out, 0, 0, 0, 34) // STREAMINFO
+	out = append(out, make([]byte, 34)...)
+	n := block.Len()
+	out = append(out, 0x80|4, byte(n>>16), byte(n>>8), byte(n)) // Last block: VORBIS_COMMENT
+	return append(out, block.Bytes()...)
+}
+
+// utf16LE encodes s as UTF-16 with a little-endian BOM
+func utf16LE(s string) []byte {
+	out := []byte{0xFF, 0xFE}
+	for _, r := range s {
+		out = binary.LittleEndian.AppendUint16(out, uint16(r))
+	}
+	return out
+}
+
+func TestExtractMusicMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		data   []byte
+		artist string
+		album  string
+		title  string
+		date   string // YYYY-MM-DD, empty for no date
+	}{
+		{
+			name: "ID3v2.3", file: "song.mp3",
+			data: buildID3v2(3,
+				id3Frame{"TPE1", 0, []byte("Sigur R\xf3s")}, // ISO-8859-1
+				id3Frame{"TALB", 1, utf16LE("Ágætis byrjun")},
+				id3Frame{"TIT2", 0, []byte("Svefn-g-englar")},
+				id3Frame{"TYER", 0, []byte("1999")}),
+			artist: "Sigur Rós", album: "Ágætis byrjun", title: "Svefn-g-englar", date: "1999-01-01",
+		},
+		{
+			name: "ID3v2.4 album artist", file: "track.mp3",
+			data: buildID3v2(4,
+				id3Frame{"TPE1", 3, []byte("Guest\x00Other")},
+				id3Frame{"TPE2", 3, []byte("Various Artists")},
+				id3Frame{"TALB", 3, []byte("Now 42")},
+				id3Frame{"TDRC", 3, []byte("2004-05-12T10:00")}),
+			artist: "Various Artists", album: "Now 42", date: "2004-05-12",
+		},
+		{
+			name: "ID3v2.2", file: "old.mp3",
+			data: buildID3v2(2,
+				id3Frame{"TP1", 0, []byte("Pixies")},
+				id3Frame{"TAL", 0, []byte("Doolittle")},
+				id3Frame{"TYE", 0, []byte("1989")}),
+			artist: "Pixies", album: "Doolittle", date: "1989-01-01",
+		},
+		{
+			name: "ID3v1", file: "v1.mp3",
+			data:   buildID3v1("Debaser", "Pixies", "Doolittle", "1989"),
+			artist: "Pixies", album: "Doolittle", title: "Debaser", date: "1989-01-01",
+		},
+		{
+			name: "M4A", file: "song.m4a",
+			data: buildM4A(
+				mp4Item("\xa9nam", "Paranoid Android"),
+				mp4Item("\xa9ART", "Radiohead"),
+				mp4Item("\xa9alb", "OK Computer"),
+				mp4Item("\xa9day", "1997-05-21T07:00:00Z"),
+				mp4Item("trkn", "\x00\x00\x00\x02")),
+			artist: "Radiohead", album: "OK Computer", title: "Paranoid Android", date: "1997-05-21",
+		},
+		{
+			name: "M4A album artist", file: "comp.m4a",
+			data:   buildM4A(mp4Item("\xa9ART", "Someone"), mp4Item("aART", "Various Artists"), mp4Item("\xa9alb", "Hits")),
+			artist: "Various Artists", album: "Hits",
+		},
+		{
+			name: "FLAC", file: "song.flac",
+			data: buildFLAC("title=Teardrop", "ARTIST=Massive Attack", "Album=Mezzanine", "DATE=1998-04-20",
+				"ARTIST=Elizabeth Fraser"), // First value wins
+			artist: "Massive Attack", album: "Mezzanine", title: "Teardrop", date: "1998-04-20",
+		},
+		{
+			name: "FLAC album artist", file: "comp.flac",
+			data:   buildFLAC("ARTIST=Someone", "ALBUMARTIST=Various Artists", "ALBUM=Hits", "YEAR=2001"),
+			artist: "Various Artists", album: "Hits", date: "2001-01-01",
+		},
+		{
+			name: "untagged", file: "plain.mp3",
+			data: []byte{0xFF, 0xFB, 0x90, 0x00},
+		},
+		{
+			name: "not FLAC", file: "fake.flac",
+			data: []byte("RIFF0000WAVE"),
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mf := &MediaFile{Path: path, Type: TypeMusic}
+		extractMusicMetadata(mf)
+
+		if mf.Artist != tt.artist || mf.Album != tt.album || mf.Title != tt.title {
+			t.Errorf("%s: got artist %q album %q title %q, want %q %q %q",
+				tt.name, mf.Artist, mf.Album, mf.Title, tt.artist, tt.album, tt.title)
+		}
+		var date string
+		if mf.DateTaken != nil {
+			date = mf.DateTaken.Format(time.DateOnly)
+		}
+		if date != tt.date {
+			t.Errorf("%s: date %q, want %q", tt.name, date, tt.date)
+		}
+	}
+}
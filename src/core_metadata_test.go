@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testTag is a TIFF tag for test fixtures: a string is stored as ASCII, a uint16 as
+// SHORT and a uint32 as LONG
+type testTag struct {
+	tag   uint16
+	value any
+}
+
+// tiffIFD encodes a little-endian IFD placed at offset, followed by its values that
+// don't fit in an entry
+func tiffIFD(tags []testTag, offset uint32) []byte {
+	sort.Slice(tags, func(i, j int) bool { return tags[i].tag < tags[j].tag })
+	le := binary.LittleEndian
+	dataOffset := offset + 2 + 12*uint32(len(tags)) + 4
+	var ifd, data []byte
+	ifd = le.AppendUint16(ifd, uint16(len(tags)))
+	for _, tag := range tags {
+		ifd = le.AppendUint16(ifd, tag.tag)
+		switch v := tag.value.(type) {
+		case string:
+			b := append([]byte(v), 0)
+			ifd = le.AppendUint16(ifd, 2)
+			ifd = le.AppendUint32(ifd, uint32(len(b)))
+			if len(b) <= 4 {
+				ifd = append(ifd, append(b, make([]byte, 4-len(b))...)...)
+				continue
+			}
+			ifd = le.AppendUint32(ifd, dataOffset+uint32(len(data)))
+			data = append(data, b...)
+			if len(data)%2 == 1 {
+				data = append(data, 0)
+			}
+		case uint16:
+			ifd = le.AppendUint16(ifd, 3)
+			ifd = le.AppendUint32(ifd, 1)
+			ifd = le.AppendUint16(ifd, v)
+			ifd = le.AppendUint16(ifd, 0)
+		case uint32:
+			ifd = le.AppendUint16(ifd, 4)
+			ifd = le.AppendUint32(ifd, 1)
+			ifd = le.AppendUint32(ifd, v)
+		}
+	}
+	ifd = le.AppendUint32(ifd, 0) // No next IFD
+	return append(ifd, data...)
+}
+
+// buildTIFF encodes a TIFF header with IFD0 and, if given, an Exif IFD it points to
+func buildTIFF(ifd0, exifIFD []testTag) []byte {
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	if exifIFD == nil {
+		return append(tiff, tiffIFD(ifd0, 8)...)
+	}
+	ifd0 = append(ifd0, testTag{0x8769, uint32(0)})
+	size := len(tiffIFD(ifd0, 8))
+	ifd0[len(ifd0)-1].value = uint32(8 + size)
+	tiff = append(tiff, tiffIFD(ifd0, 8)...)
+	return append(tiff, tiffIFD(exifIFD, uint32(8+size))...)
+}
+
+// writeEXIFJPEG writes a 16x16 JPEG carrying the EXIF tags to dir/name
+func writeEXIFJPEG(t *testing.T, dir, name string, ifd0, exifIFD []testTag) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(0), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpg := withJPEGSegment(buf.Bytes(), 0xE1, "Exif\x00\x00"+string(buildTIFF(ifd0, exifIFD)))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, jpg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// staticProvider answers every file with the same metadata
+type staticProvider struct{ meta MediaFile }
+
+func (p staticProvider) Name() string { return "static" }
+
+func (p staticProvider) Provide(mf *MediaFile) (bool, error) {
+	if p.meta.Title != "" {
+		mf.Title = p.meta.Title
+	}
+	if p.meta.DateTaken != nil {
+		mf.DateTaken = p.meta.DateTaken
+	}
+	return true, nil
+}
+
+func TestMetadataProviderOverlaysEXIF(t *testing.T) {
+	path := writeEXIFJPEG(t, t.TempDir(), "a.jpg",
+		[]testTag{{0x010f, "Canon"}, {0x0110, "EOS 5D"}},
+		[]testTag{{0x9003, "2019:07:04 15:30:00"}})
+	provided := time.Date(1998, 7, 14, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		provider staticProvider
+		date     string
+		title    string
+	}{
+		{"title only", staticProvider{MediaFile{Title: "Birthday"}}, "2019-07-04 15:30:00", "Birthday"},
+		{"date", staticProvider{MediaFile{DateTaken: &provided}}, "1998-07-14 00:00:00", ""},
+	}
+	saved := metadataProviders
+	t.Cleanup(func() { metadataProviders = saved })
+	for _, tt := range tests {
+		metadataProviders = []MetadataProvider{tt.provider}
+		mf := &MediaFile{Path: path, Type: TypePhoto}
+		extractMetadata(mf)
+		if mf.DateTaken == nil || mf.DateTaken.Format(time.DateTime) != tt.date {
+			t.Errorf("%s: date = %v, want %s", tt.name, mf.DateTaken, tt.date)
+		}
+		if mf.Title != tt.title || mf.CameraMake != "Canon" || mf.CameraModel != "EOS 5D" {
+			t.Errorf("%s: title %q, camera %q %q, want %q, Canon EOS 5D", tt.name, mf.Title, mf.CameraMake, mf.CameraModel, tt.title)
+		}
+	}
+}
+
+func TestEXIFCaptureDatePreference(t *testing.T) {
+	const edited = "2022:03:01 09:00:00" // DateTime rewritten by an editor
+	tests := []struct {
+		name    string
+		ifd0    []testTag
+		exifIFD []testTag
+		want    string
+	}{
+		{"original wins over DateTime",
+			[]testTag{{0x0132, edited}}, []testTag{{0x9003, "2015:08:20 18:45:10"}}, "2015-08-20 18:45:10"},
+		{"digitized when no original",
+			[]testTag{{0x0132, edited}}, []testTag{{0x9004, "2016:01:02 03:04:05"}}, "2016-01-02 03:04:05"},
+		{"earliest of original and digitized",
+			[]testTag{{0x0132, edited}}, []testTag{{0x9003, "2017:05:05 10:00:00"}, {0x9004, "2017:05:04 22:00:00"}}, "2017-05-04 22:00:00"},
+		{"zeroed original ignored",
+			[]testTag{{0x0132, edited}}, []testTag{{0x9003, "0000:00:00 00:00:00"}}, "2022-03-01 09:00:00"},
+		{"DateTime alone", []testTag{{0x0132, edited}}, nil, "2022-03-01 09:00:00"},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		path := writeEXIFJPEG(t, dir, fmt.Sprintf("%d.jpg", i), tt.ifd0, tt.exifIFD)
+		mf := &MediaFile{Path: path, Type: TypePhoto}
+		extractPhotoMetadata(mf)
+		if mf.DateTaken == nil || mf.DateTaken.Format(time.DateTime) != tt.want {
+			t.Errorf("%s: date = %v, want %s", tt.name, mf.DateTaken, tt.want)
+		}
+	}
+}
+
+// buildHEIC builds a HEIF file whose meta box lists an image and an Exif item stored
+// in mdat, with the image size in ispe (a full size image and a thumbnail)
+func buildHEIC(tiff []byte, width, height uint32) []byte {
+	fullBox := func(name string, version byte, children ...[]byte) []byte {
+		return mp4Box(name, append([][]byte{{version, 0, 0, 0}}, children...)...)
+	}
+	be := binary.BigEndian
+	infe := func(id uint16, itemType string) []byte {
+		return fullBox("infe", 2, be.AppendUint16(nil, id), []byte{0, 0}, []byte(itemType), []byte{0})
+	}
+	ispe := func(w, h uint32) []byte {
+		return fullBox("ispe", 0, be.AppendUint32(be.AppendUint32(nil, w), h))
+	}
+	exifItem := append([]byte{0, 0, 0, 6}, "Exif\x00\x00"...)
+	exifItem = append(exifItem, tiff...)
+
+	// iloc v0 with 4-byte offsets and lengths; the offset is patched in once the layout is known
+	iloc := func(offset uint32) []byte {
+		body := []byte{0x44, 0x00}
+		body = be.AppendUint16(body, 1) // item_count
+		body = be.AppendUint16(body, 2) // item_ID
+		body = be.AppendUint16(body, 0) // data_reference_index
+		body = be.AppendUint16(body, 1) // extent_count
+		body = be.AppendUint32(body, offset)
+		body = be.AppendUint32(body, uint32(len(exifItem)))
+		return fullBox("iloc", 0, body)
+	}
+	layout := func(offset uint32) []byte {
+		meta := fullBox("meta", 0,
+			fullBox("hdlr", 0, make([]byte, 4), []byte("pict"), make([]byte, 13)),
+			fullBox("iinf", 0, be.AppendUint16(nil, 2), infe(1, "hvc1"), infe(2, "Exif")),
+			iloc(offset),
+			mp4Box("iprp", mp4Box("ipco", ispe(512, 384), ispe(width, height))))
+		return bytes.Join([][]byte{
+			mp4Box("ftyp", []byte("heic"), make([]byte, 4), []byte("mif1heic")),
+			meta,
+			mp4Box("mdat", exifItem),
+		}, nil)
+	}
+	file := layout(0)
+	return layout(uint32(len(file) - len(exifItem)))
+}
+
+func TestHEICMetadata(t *testing.T) {
+	tiff := buildTIFF(
+		[]testTag{{0x010f, "Apple"}, {0x0110, "iPhone 13"}},
+		[]testTag{{0x9003, "2022:12:24 19:05:33"}})
+	path := filepath.Join(t.TempDir(), "IMG_0001.HEIC")
+	if err := os.WriteFile(path, buildHEIC(tiff, 4032, 3024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mf := &MediaFile{Path: path, Type: TypePhoto}
+	extractMetadata(mf)
+	if mf.DateTaken == nil || mf.DateTaken.Format(time.DateTime) != "2022-12-24 19:05:33" {
+		t.Errorf("date = %v, want 2022-12-24 19:05:33", mf.DateTaken)
+	}
+	if mf.CameraMake != "Apple" || mf.CameraModel != "iPhone 13" {
+		t.Errorf("camera = %q %q, want Apple iPhone 13", mf.CameraMake, mf.CameraModel)
+	}
+	if mf.Width != 4032 || mf.Height != 3024 {
+		t.Errorf("size = %dx%d, want 4032x3024 (not the thumbnail)", mf.Width, mf.Height)
+	}
+
+	// A HEIC without an Exif item falls back to the file time
+	bare := filepath.Join(t.TempDir(), "bare.heic")
+	if err := os.WriteFile(bare, mp4Box("ftyp", []byte("heic")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.Local)
+	if err := os.Chtimes(bare, fileTime, fileTime); err != nil {
+		t.Fatal(err)
+	}
+	mf = &MediaFile{Path: bare, Type: TypePhoto}
+	extractMetadata(mf)
+	if mf.DateTaken == nil || !mf.DateTaken.Equal(fileTime) {
+		t.Errorf("bare HEIC: date %v, want the file time %v", mf.DateTaken, fileTime)
+	}
+}
+
+func TestRAWMetadata(t *testing.T) {
+	dir := t.TempDir()
+
+	// A CR2-like file: camera in IFD0, which also holds the full size image, capture date
+	// and dimensions in the Exif IFD
+	cr2 := filepath.Join(dir, "IMG_0001.CR2")
+	tiff := buildTIFF(
+		[]testTag{{0x00fe, uint32(0)}, {0x0100, uint32(5472)}, {0x0101, uint32(3648)}, {0x010f, "Canon"},
+			{0x0110, "Canon EOS 6D"}, {0x0112, uint16(1)}, {0x0132, "2021:01:01 00:00:00"}},
+		[]testTag{{0x9003, "2019:07:04 16:20:05"}, {0xa002, uint32(5472)}, {0xa003, uint32(3648)}})
+	if err := os.WriteFile(cr2, tiff, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mf := &MediaFile{Path: cr2, Type: TypePhoto}
+	extractMetadata(mf)
+	if mf.DateTaken == nil || mf.DateTaken.Format(time.DateTime) != "2019-07-04 16:20:05" {
+		t.Errorf("CR2 date = %v, want 2019-07-04 16:20:05", mf.DateTaken)
+	}
+	if mf.CameraMake != "Canon" || mf.CameraModel != "Canon EOS 6D" || mf.Width != 5472 || mf.Height != 3648 {
+		t.Errorf("CR2 = %q %q %dx%d, want Canon EOS 6D 5472x3648", mf.CameraMake, mf.CameraModel, mf.Width, mf.Height)
+	}
+
+	// A NEF-like file dated only by the EXIF of its embedded preview JPEG
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, testImage(0), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpg := withJPEGSegment(preview.Bytes(), 0xE1, "Exif\x00\x00"+string(buildTIFF(
+		[]testTag{{0x010f, "NIKON CORPORATION"}, {0x0110, "NIKON D750"}},
+		[]testTag{{0x9003, "2018:12:31 23:59:58"}})))
+	ifd0 := func(offset uint32) []testTag {
+		return []testTag{{0x0100, uint32(6016)}, {0x0101, uint32(4016)}, {0x0201, offset}, {0x0202, uint32(len(jpg))}}
+	}
+	header := buildTIFF(ifd0(0), nil)
+	nef := filepath.Join(dir, "DSC_0001.NEF")
+	if err := os.WriteFile(nef, append(buildTIFF(ifd0(uint32(len(header))), nil), jpg...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mf = &MediaFile{Path: nef, Type: TypePhoto}
+	extractMetadata(mf)
+	if mf.DateTaken == nil || mf.DateTaken.Format(time.DateTime) != "2018-12-31 23:59:58" {
+		t.Errorf("NEF date = %v, want 2018-12-31 23:59:58 from the preview", mf.DateTaken)
+	}
+	if mf.CameraMake != "NIKON CORPORATION" || mf.Width != 6016 || mf.Height != 4016 {
+		t.Errorf("NEF = %q %dx%d, want NIKON CORPORATION 6016x4016", mf.CameraMake, mf.Width, mf.Height)
+	}
+}
+
+func TestOrientationSwapsDimensions(t *testing.T) {
+	tests := []struct {
+		orientation   uint16
+		width, height int
+	}{
+		{1, 4000, 3000},
+		{3, 4000, 3000}, // Upside down
+		{6, 3000, 4000}, // Rotated 90° clockwise for display
+		{8, 3000, 4000}, // Rotated 270°
+		{5, 3000, 4000}, // Transposed
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := writeEXIFJPEG(t, dir, fmt.Sprintf("orientation-%d.jpg", tt.orientation),
+			[]testTag{{0x0112, tt.orientation}},
+			[]testTag{{0xa002, uint32(4000)}, {0xa003, uint32(3000)}})
+		mf := &MediaFile{Path: path, Type: TypePhoto}
+		extractMetadata(mf)
+		if mf.Width != tt.width || mf.Height != tt.height || mf.Orientation != int(tt.orientation) {
+			t.Errorf("orientation %d: %dx%d (orientation %d), want %dx%d",
+				tt.orientation, mf.Width, mf.Height, mf.Orientation, tt.width, tt.height)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// XMP namespaces of the properties read from sidecars
+const (
+	xmpNSExif       = "http://ns.adobe.com/exif/1.0/"
+	xmpNSPhotoshop  = "http://ns.adobe.com/photoshop/1.0/"
+	xmpNSDublinCore = "http://purl.org/dc/elements/1.1/"
+	xmpNSRDF        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// maxAlbumKeywords is how many keywords of an album's files are passed on for naming
+const maxAlbumKeywords = 5
+
+// preferSidecarDates makes the sidecar date win over the embedded one (set from
+// Config.PreferSidecar at startup). Without it a sidecar only dates files that have no date.
+var preferSidecarDates bool
+
+// xmpSidecar is what media-organizer uses of an XMP sidecar
+type xmpSidecar struct {
+	DateTaken *time.Time
+	Keywords  []string
+}
+
+// sidecarFile is an XMP sidecar found by a scan
+type sidecarFile struct {
+	path    string
+	modTime time.Time
+}
+
+// sidecarKey is how a sidecar is looked up: its path with a lowercase extension
+// (IMG_1.XMP and IMG_1.xmp both belong to IMG_1.jpg)
+func sidecarKey(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"
+}
+
+// isSidecar reports whether a path is an XMP sidecar
+func isSidecar(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".xmp")
+}
+
+// sidecarCandidates are the sidecar keys of a media file, in order of preference:
+// IMG_1.xmp (Lightroom, Capture One) and IMG_1.jpg.xmp (darktable, digiKam)
+func sidecarCandidates(path string) []string {
+	return []string{sidecarKey(path), path + ".xmp"}
+}
+
+// findSidecar stats the sidecar candidates of a media file (for files not found by a
+// walk, which collects sidecars as it lists folders)
+func findSidecar(path string) (string, time.Time) {
+	for _, candidate := range sidecarCandidates(path) {
+		for _, name := range []string{candidate, strings.TrimSuffix(candidate, ".xmp") + ".XMP"} {
+			if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+				return name, info.ModTime()
+			}
+		}
+	}
+	return "", time.Time{}
+}
+
+// applySidecar reads the file's XMP sidecar, if it has one: its keywords, and its date
+// when the file has none or preferDate is set
+func applySidecar(mf *MediaFile, preferDate bool) {
+	if mf.Sidecar == "" {
+		return
+	}
+	f, err := os.Open(mf.Sidecar)
+	if err != nil {
+		warnLog.Printf("metadata %s: sidecar: %v", mf.Path, err)
+		return
+	}
+	defer f.Close()
+
+	sidecar, err := parseXMP(f)
+	if err != nil {
+		warnLog.Printf("metadata %s: sidecar %s: %v", mf.Path, mf.Sidecar, err)
+		return
+	}
+	mf.Keywords = sidecar.Keywords
+	if sidecar.DateTaken != nil && (mf.DateTaken == nil || preferDate) {
+		mf.DateTaken = sidecar.DateTaken
+		debugLog.Printf("metadata %s: date %s from the sidecar", mf.Path, sidecar.DateTaken.Format(time.DateTime))
+	}
+}
+
+// parseXMP reads the capture date (exif:DateTimeOriginal, else photoshop:DateCreated)
+// and the keywords (dc:subject) of an XMP packet. Properties may be written as
+// attributes of rdf:Description or as elements, tools use both.
+func parseXMP(r io.Reader) (*xmpSidecar, error) {
+	dec := xml.NewDecoder(r)
+	var original, created *time.Time
+	var keywords []string
+	inSubject := false
+	var parent xml.Name // Property element whose text is being read
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				switch attr.Name {
+				case xml.Name{Space: xmpNSExif, Local: "DateTimeOriginal"}:
+					original = parseXMPDate(attr.Value)
+				case xml.Name{Space: xmpNSPhotoshop, Local: "DateCreated"}:
+					created = parseXMPDate(attr.Value)
+				}
+			}
+			if t.Name == (xml.Name{Space: xmpNSDublinCore, Local: "subject"}) {
+				inSubject = true
+			}
+			parent = t.Name
+		case xml.EndElement:
+			if t.Name == (xml.Name{Space: xmpNSDublinCore, Local: "subject"}) {
+				inSubject = false
+			}
+			parent = xml.Name{}
+		case xml.CharData:
+			value := strings.TrimSpace(string(t))
+			if value == "" {
+				continue
+			}
+			switch {
+			case parent == xml.Name{Space: xmpNSExif, Local: "DateTimeOriginal"}:
+				original = parseXMPDate(value)
+			case parent == xml.Name{Space: xmpNSPhotoshop, Local: "DateCreated"}:
+				created = parseXMPDate(value)
+			case inSubject && parent == xml.Name{Space: xmpNSRDF, Local: "li"}:
+				keywords = append(keywords, value)
+			}
+		}
+	}
+
+	if original == nil && created == nil && len(keywords) == 0 {
+		return nil, errors.New("no date or keywords")
+	}
+	sidecar := &xmpSidecar{DateTaken: original, Keywords: keywords}
+	if sidecar.DateTaken == nil {
+		sidecar.DateTaken = created
+	}
+	return sidecar, nil
+}
+
+// parseXMPDate parses an XMP date: ISO 8601, possibly without seconds, time or day.
+// Dates without a time zone are local. Returns nil unless it is a sensible date.
+func parseXMPDate(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02T15:04:05Z07:00", "2006-01-02T15:04Z07:00"} {
+		if tm, err := time.Parse(layout, value); err == nil {
+			return sensibleDate(tm.Local())
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02", "2006-01", "2006"} {
+		if tm, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return sensibleDate(tm)
+		}
+	}
+	return nil
+}
+
+// topKeywords returns the keywords most files share, most common first, at most n
+func topKeywords(files []*MediaFile, n int) []string {
+	counts := make(map[string]int)
+	for _, file := range files {
+		for _, keyword := range file.Keywords {
+			counts[keyword]++
+		}
+	}
+	keywords := make([]string, 0, len(counts))
+	for keyword := range counts {
+		keywords = append(keywords, keyword)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if counts[keywords[i]] != counts[keywords[j]] {
+			return counts[keywords[i]] > counts[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+	if len(keywords) > n {
+		keywords = keywords[:n]
+	}
+	return keywords
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Sidecars as Lightroom writes them (properties as attributes) and as darktable does
+// (properties as elements)
+const (
+	attributeXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+   exif:DateTimeOriginal="2021-05-01T10:30:00">
+   <dc:subject><rdf:Bag><rdf:li>beach</rdf:li><rdf:li>family</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+	elementXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">
+   <photoshop:DateCreated>2020-12-24T18:00</photoshop:DateCreated>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+)
+
+func TestSidecarDates(t *testing.T) {
+	t.Cleanup(func() { preferSidecarDates = false })
+	dir := t.TempDir()
+	// IMG_1 has an EXIF date, IMG_2 and IMG_3 none
+	writeEXIFJPEG(t, dir, "IMG_1.jpg", nil, []testTag{{0x9003, "2019:07:04 12:00:00"}})
+	writeEXIFJPEG(t, dir, "IMG_2.jpg", nil, nil)
+	writeEXIFJPEG(t, dir, "IMG_3.jpg", nil, nil)
+	sidecars := map[string]string{
+		"IMG_1.XMP":     attributeXMP,
+		"IMG_2.jpg.xmp": elementXMP,
+		"IMG_3.xmp":     "<x:xmpmeta", // Broken: the file falls back to its own dates
+	}
+	for name, content := range sidecars {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		prefer   bool
+		want     string // DateTime, "" for the file time
+		keywords []string
+	}{
+		{"IMG_1.jpg", false, "2019-07-04 12:00:00", []string{"beach", "family"}},
+		{"IMG_1.jpg", true, "2021-05-01 10:30:00", []string{"beach", "family"}},
+		{"IMG_2.jpg", false, "2020-12-24 18:00:00", nil},
+		{"IMG_3.jpg", true, "", nil},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mf := &MediaFile{Path: path, Type: TypePhoto}
+		mf.Sidecar, mf.SidecarTime = findSidecar(path)
+		if mf.Sidecar == "" {
+			t.Fatalf("%s: sidecar not found", tt.name)
+		}
+		preferSidecarDates = tt.prefer
+		extractMetadata(mf)
+
+		want := tt.want
+		if want == "" {
+			want = info.ModTime().Format(time.DateTime)
+		}
+		if mf.DateTaken == nil || mf.DateTaken.Format(time.DateTime) != want {
+			t.Errorf("%s (prefer sidecar %v): date %v, want %s", tt.name, tt.prefer, mf.DateTaken, want)
+		}
+		if !slices.Equal(mf.Keywords, tt.keywords) {
+			t.Errorf("%s: keywords %q, want %q", tt.name, mf.Keywords, tt.keywords)
+		}
+	}
+}
@@ -1,17 +1,64 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-// OrganizeIntoAlbums groups media files into albums
-func OrganizeIntoAlbums(files []*MediaFile, config *Config, progressChan chan<- string, albumCache *AlbumSuggestionCache) ([]*Album, error) {
-	// Group by source directory and type
+// keepMarkerFile marks a curated folder that must be kept as a single album
+const keepMarkerFile = ".mediaorg-keep"
+
+// defaultMinAlbumFiles is the smallest folder that becomes an album of its own
+const defaultMinAlbumFiles = 3
+
+// defaultNoDateFolder is where files without any date go, relative to the library
+const defaultNoDateFolder = "Unsorted/NoDate"
+
+// hasKeepMarker checks if a directory contains the keep marker file
+func hasKeepMarker(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, keepMarkerFile))
+	return err == nil
+}
+
+// OrganizeIntoAlbums groups media files into albums. Cancelling ctx stops naming
+// requests, remaining folders get fallback names. progressChan (optional) receives one
+// update per folder or location album whose name is settled: ProcessedFiles and
+// TotalFiles count them, CurrentFile is the folder (or album name).
+func OrganizeIntoAlbums(ctx context.Context, files []*MediaFile, config *Config, progressChan chan<- ScanProgress, albumCache *AlbumSuggestionCache) ([]*Album, error) {
+	// Group by source directory (or the ancestor it is merged into) and type
 	byDirectory := make(map[string][]*MediaFile)
+	keepDirs := make(map[string]bool)
+	mergedDirs := make(map[string]string)
+	var nonPhotos []*MediaFile
+	var geotagged []*MediaFile
+	var undated []*MediaFile
+
+	// albumDir returns the folder whose album a folder's files join
+	albumDir := func(sourceDir string) string {
+		dir, ok := mergedDirs[sourceDir]
+		if !ok {
+			dir = mergedDir(sourceDir, config)
+			mergedDirs[sourceDir] = dir
+		}
+		return dir
+	}
+
+	// Places for GPS-based naming and location grouping
+	var places *Places
+	if (config.AlbumNaming == AlbumNamingGPS || config.GroupBy == GroupByLocation) && config.PlacesFile != "" {
+		var err error
+		places, err = LoadPlaces(config.PlacesFile)
+		if err != nil {
+			return nil, fmt.Errorf("load places: %w", err)
+		}
+	}
 
 	for _, mf := range files {
 		if mf.Type == TypeMusic {
@@ -19,126 +66,495 @@ func OrganizeIntoAlbums(files []*MediaFile, config *Config, progressChan chan<-
 		}
 
 		sourceDir := filepath.Dir(mf.Path)
+		keep, ok := keepDirs[sourceDir]
+		if !ok {
+			keep = hasKeepMarker(sourceDir)
+			keepDirs[sourceDir] = keep
+		}
+
+		// Files whose date is unknown aren't placed in any year (curated folders keep them)
+		if mf.DateTaken == nil && !keep {
+			undated = append(undated, mf)
+			continue
+		}
+
+		// Screenshots, animations and graphics get their own folders (curated folders keep theirs)
+		if config.NonPhotos == NonPhotosSeparate && isNonPhoto(mf) && !keep {
+			nonPhotos = append(nonPhotos, mf)
+			continue
+		}
+
+		if config.GroupBy == GroupByLocation && mf.GPS != nil && !keep {
+			geotagged = append(geotagged, mf)
+			continue
+		}
+
+		if !keep {
+			sourceDir = albumDir(sourceDir)
+		}
 		byDirectory[sourceDir] = append(byDirectory[sourceDir], mf)
 	}
 
+	// Geotagged files are grouped by place and month; small groups fall back to their folder
+	byLocation := groupByLocation(geotagged, places)
+	for name, locFiles := range byLocation {
+		if len(locFiles) < 3 {
+			for _, mf := range locFiles {
+				sourceDir := albumDir(filepath.Dir(mf.Path))
+				byDirectory[sourceDir] = append(byDirectory[sourceDir], mf)
+			}
+			delete(byLocation, name)
+		}
+	}
+
+	// Folders whose files fall into several events (shooting sessions more than
+	// config.EventGap apart) become one album per event, named by its dates; curated
+	// folders and folders already in the library stay whole
+	byEvent := make(map[string][]*MediaFile)
+	if config.GroupBy == GroupByEvent {
+		for sourceDir, dirFiles := range byDirectory {
+			if keepDirs[sourceDir] || config.inLibrary(sourceDir) {
+				continue
+			}
+			events := splitEvents(dirFiles, config.EventGap)
+			if len(events) < 2 {
+				continue
+			}
+			debugLog.Printf("album %s: %d events", sourceDir, len(events))
+			delete(byDirectory, sourceDir)
+			for _, event := range events {
+				name := eventName(event)
+				byEvent[name] = append(byEvent[name], event...)
+			}
+		}
+	}
+
 	var albums []*Album
 	albumsByName := make(map[string]*Album)
 
-	ollamaAvailable := CheckOllamaAvailable()
-	if !ollamaAvailable && progressChan != nil {
-		progressChan <- "Ollama not available, using folder names"
+	// addAlbums creates the photo/video albums for a group of files, merging into
+	// existing albums with the same name and type (curated folders are never merged)
+	addAlbums := func(groupFiles []*MediaFile, albumName string, sourceDirs []string, medianDate *time.Time, dates []time.Time, keep, location, event bool) {
+		dateDir := "Unknown"
+		if medianDate != nil {
+			dateDir = dateFolder(config.Layout, *medianDate, dates)
+		}
+
+		// Photos and videos go to their own trees (or roots), sharing the album name;
+		// curated folders are never split, they go whole to their majority type's tree
+		mixedDirs := config.MixedDirs
+		if keep {
+			mixedDirs = MixedDirsMajority
+		}
+		for _, group := range groupByType(groupFiles, mixedDirs) {
+			destDir := filepath.Join(config.typeRoot(group.Type), dateDir, albumName)
+
+			mergeKey := fmt.Sprintf("%s|%s", group.Type, albumName)
+			if existing, ok := albumsByName[mergeKey]; ok && !keep {
+				existing.Files = append(existing.Files, group.Files...)
+				existing.SourceDirs = append(existing.SourceDirs, sourceDirs...)
+			} else {
+				album := &Album{
+					Name:        albumName,
+					Destination: destDir,
+					Files:       group.Files,
+					SourceDirs:  sourceDirs,
+					Date:        medianDate,
+					Type:        group.Type,
+					Keep:        keep,
+					Location:    location,
+					Event:       event,
+					Subfolders:  config.KeepSubfolders && !location,
+				}
+				if config.FolderTemplate != "" {
+					album.Destination = config.templateDestination(group.Files[0], album)
+				}
+				albums = append(albums, album)
+				if !keep {
+					albumsByName[mergeKey] = album
+				}
+			}
+		}
 	}
 
-	// Process each directory group
+	// Location albums are named "<month> <place>" by groupByLocation
+	locationNames := make([]string, 0, len(byLocation))
+	for name := range byLocation {
+		locationNames = append(locationNames, name)
+	}
+	sort.Strings(locationNames)
+
+	eventNames := make([]string, 0, len(byEvent))
+	for name := range byEvent {
+		eventNames = append(eventNames, name)
+	}
+	sort.Strings(eventNames)
+
+	// Directories in a fixed order, so same-name albums merge the same way every run
+	sourceDirs := make([]string, 0, len(byDirectory))
+	var misc []*MediaFile
 	for sourceDir, dirFiles := range byDirectory {
-		if len(dirFiles) < 3 {
-			continue // Skip directories with very few files
+		// Directories with too few files for an album of their own go to Misc (unless
+		// they are curated folders); those already in the library are left alone
+		switch {
+		case len(dirFiles) >= config.MinAlbumFiles || keepDirs[sourceDir]:
+			sourceDirs = append(sourceDirs, sourceDir)
+		case !config.inLibrary(sourceDir):
+			debugLog.Printf("album %s: %d files, fewer than %d, going to Misc", sourceDir, len(dirFiles), config.MinAlbumFiles)
+			misc = append(misc, dirFiles...)
 		}
-
-		if progressChan != nil {
-			progressChan <- fmt.Sprintf("Processing: %s (%d files)", sourceDir, len(dirFiles))
+	}
+	sort.Strings(sourceDirs)
+
+	// settled reports a folder or location album whose name is known (naming workers
+	// report concurrently)
+	var progressMu sync.Mutex
+	settledCount, total := 0, len(locationNames)+len(eventNames)+len(sourceDirs)
+	settled := func(current string) {
+		if progressChan == nil {
+			return
 		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		settledCount++
+		progressChan <- ScanProgress{ProcessedFiles: settledCount, TotalFiles: total, CurrentFile: current}
+	}
 
-		// Extract dates from files
-		var dates []time.Time
-		for _, mf := range dirFiles {
-			if mf.DateTaken != nil {
-				dates = append(dates, *mf.DateTaken)
-			}
+	for _, albumName := range locationNames {
+		locFiles := byLocation[albumName]
+		medianDate, dates := medianDateOf(locFiles)
+		addAlbums(locFiles, albumName, sourceDirsOf(locFiles), medianDate, dates, false, true, false)
+		settled(albumName)
+	}
+
+	// Events too small for an album of their own go to Misc, like small folders
+	for _, albumName := range eventNames {
+		eventFiles := byEvent[albumName]
+		if len(eventFiles) >= config.MinAlbumFiles {
+			medianDate, dates := medianDateOf(eventFiles)
+			addAlbums(eventFiles, albumName, sourceDirsOf(eventFiles), medianDate, dates, false, false, true)
+		} else {
+			misc = append(misc, eventFiles...)
 		}
+		settled(albumName)
+	}
 
-		var medianDate *time.Time
-		var yearMonth string
+	namer := NewAlbumNamer(config)
+	namerAvailable := namer.Available(ctx)
+	if !namerAvailable {
+		infoLog.Printf("%s not available, using folder names", config.NamingProvider)
+	}
 
-		if len(dates) > 0 {
-			sort.Slice(dates, func(i, j int) bool {
-				return dates[i].Before(dates[j])
-			})
-			median := dates[len(dates)/2]
-			medianDate = &median
-			yearMonth = median.Format("2006-01")
-		} else {
-			yearMonth = "Unknown Date"
+	// Settle each directory's album name without the naming backend where possible
+	// (curated folder, earlier review, GPS place, cached suggestion)
+	albumNames := make(map[string]string)
+	var pending []namingRequest
+	for _, sourceDir := range sourceDirs {
+		dirFiles := byDirectory[sourceDir]
+		keep := keepDirs[sourceDir]
+
+		place := ""
+		if config.AlbumNaming == AlbumNamingGPS && places.Len() > 0 && !keep {
+			place = dominantPlace(dirFiles, places)
+		}
+
+		edited, hasEdit := "", false
+		if albumCache != nil && !keep {
+			edited, hasEdit = albumCache.GetEdited(sourceDir)
 		}
 
-		// Suggest album name
-		var albumName string
-		if ollamaAvailable {
+		if keep {
+			albumNames[sourceDir] = filepath.Base(sourceDir)
+		} else if hasEdit {
+			albumNames[sourceDir] = edited // Chosen in an earlier review
+		} else if place != "" {
+			if _, dates := medianDateOf(dirFiles); len(dates) > 0 {
+				albumNames[sourceDir] = fmt.Sprintf("%s %s", dominantMonth(dates).Format("2006-01"), place)
+			} else {
+				albumNames[sourceDir] = place
+			}
+		} else if namerAvailable {
 			samplePaths := make([]string, 0, 5)
 			for i := 0; i < len(dirFiles) && i < 5; i++ {
 				samplePaths = append(samplePaths, dirFiles[i].Path)
 			}
 
-			// Try cache first
-			cached := false
 			if albumCache != nil {
-				if suggestion, ok := albumCache.Get(sourceDir, samplePaths); ok {
-					albumName = suggestion
-					cached = true
+				if suggestion, ok := albumCache.Get(sourceDir, samplePaths, namer.Model()); ok {
+					debugLog.Printf("naming %s: %q from the suggestion cache", sourceDir, suggestion)
+					albumNames[sourceDir] = suggestion
+					settled(sourceDir)
+					continue
 				}
 			}
+			pending = append(pending, namingRequest{
+				sourceDir:   sourceDir,
+				samplePaths: samplePaths,
+				keywords:    topKeywords(dirFiles, maxAlbumKeywords),
+			})
+			continue
+		}
+		settled(sourceDir)
+	}
 
-			// Call Ollama if not cached
-			if !cached {
-				suggested, err := SuggestAlbumName(config.OllamaModel, sourceDir, samplePaths)
-				if err == nil && suggested != "" {
-					albumName = suggested
-					// Cache the suggestion
-					if albumCache != nil {
-						albumCache.Put(sourceDir, samplePaths, albumName)
-					}
-				} else {
-					albumName = fallbackAlbumName(sourceDir, yearMonth)
-				}
+	// Ask the naming backend for the rest, config.NamingWorkers folders at a time
+	for sourceDir, suggestion := range suggestAlbumNames(ctx, namer, pending, config, settled, albumCache) {
+		albumNames[sourceDir] = suggestion
+	}
+
+	for _, sourceDir := range sourceDirs {
+		dirFiles := byDirectory[sourceDir]
+
+		medianDate, dates := medianDateOf(dirFiles)
+		albumName, ok := albumNames[sourceDir]
+		if !ok {
+			yearMonth := "Unknown Date"
+			if medianDate != nil {
+				yearMonth = medianDate.Format("2006-01")
 			}
-		} else {
 			albumName = fallbackAlbumName(sourceDir, yearMonth)
 		}
 
-		if progressChan != nil {
-			progressChan <- fmt.Sprintf("  → Album: %s", albumName)
+		addAlbums(dirFiles, albumName, []string{sourceDir}, medianDate, dates, keepDirs[sourceDir], false, false)
+	}
+
+	// Handle music files
+	musicAlbums := organizeMusicFiles(files, config)
+	albums = append(albums, musicAlbums...)
+
+	albums = append(albums, organizeNonPhotos(nonPhotos, config)...)
+	albums = append(albums, organizeMisc(misc, config)...)
+	albums = append(albums, organizeUndated(undated, config)...)
+
+	// Split oversized albums before filtering, so parts stay the same whichever files are new
+	albums = splitLargeAlbums(albums, config.MaxAlbumFiles)
+
+	// Filter albums to only include those with new files
+	albums = filterAlbumsWithNewFiles(albums)
+
+	// Deterministic order inside albums (previews and "first file" logic depend on it)
+	for _, album := range albums {
+		sortAlbumFiles(album.Files, config.AlbumSort)
+	}
+
+	return albums, nil
+}
+
+// mergedDir returns the folder whose album the files of dir join: with config.MergeDepth,
+// its ancestor that many levels up, stopping below the scan path dir was found in and at
+// curated folders. Folders in the library, or outside every scan path, are not merged.
+func mergedDir(dir string, config *Config) string {
+	if config.MergeDepth < 1 || config.inLibrary(dir) {
+		return dir
+	}
+	root := scanRootOf(dir, config.ScanPaths)
+	if root == "" {
+		return dir
+	}
+
+	merged := dir
+	for i := 0; i < config.MergeDepth && dirDepth(root, merged) > 1; i++ {
+		parent := filepath.Dir(merged)
+		if hasKeepMarker(parent) {
+			break
 		}
+		merged = parent
+	}
+	return merged
+}
 
-		// Determine destination
-		year := "Unknown"
-		if medianDate != nil {
-			year = fmt.Sprintf("%d", medianDate.Year())
+// DestPath is where file goes in the album: the album folder, or with Subfolders, the
+// file's path below the source folder it was merged into the album from
+func (a *Album) DestPath(file *MediaFile) string {
+	if a.Subfolders {
+		for _, dir := range a.SourceDirs {
+			if isWithinDir(file.Path, dir) {
+				if rel, err := filepath.Rel(dir, file.Path); err == nil {
+					return filepath.Join(a.Destination, rel)
+				}
+			}
 		}
+	}
+	return filepath.Join(a.Destination, filepath.Base(file.Path))
+}
 
-		var destDir string
-		if dirFiles[0].Type == TypePhoto {
-			destDir = filepath.Join(config.LibraryBase, "Photos", year, albumName)
-		} else {
-			destDir = filepath.Join(config.LibraryBase, "Videos", year, albumName)
+// CanRename reports whether an album's name may be edited during review (curated
+// folders keep theirs, music and non-photo folders are named after their contents)
+func (a *Album) CanRename() bool {
+	various := len(a.SourceDirs) == 1 && a.SourceDirs[0] == "various"
+	return !a.Keep && a.Type != TypeMusic && !various
+}
+
+// RenameAlbum gives an album the name chosen during review and recomputes its destination
+func RenameAlbum(album *Album, name string, config *Config) error {
+	if !album.CanRename() {
+		return fmt.Errorf("%s can't be renamed", album.Name)
+	}
+	name = sanitizeFolderName(name)
+	if name == "" {
+		return fmt.Errorf("album name can't be empty")
+	}
+
+	album.Name = name
+	if config.FolderTemplate != "" {
+		album.Destination = config.templateDestination(album.Files[0], album)
+	} else {
+		album.Destination = filepath.Join(filepath.Dir(album.Destination), name) // Name is the last level
+	}
+	return nil
+}
+
+// medianDateOf returns the median date taken of files (nil if none has one) and all dates, sorted
+func medianDateOf(files []*MediaFile) (*time.Time, []time.Time) {
+	var dates []time.Time
+	for _, mf := range files {
+		if mf.DateTaken != nil {
+			dates = append(dates, *mf.DateTaken)
+		}
+	}
+	if len(dates) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+	median := dates[len(dates)/2]
+	return &median, dates
+}
+
+// sourceDirsOf returns the distinct directories files come from, sorted
+func sourceDirsOf(files []*MediaFile) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, mf := range files {
+		dir := filepath.Dir(mf.Path)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
 		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
 
-		// Merge into existing album if same name
-		if existing, ok := albumsByName[albumName]; ok {
-			existing.Files = append(existing.Files, dirFiles...)
-			existing.SourceDirs = append(existing.SourceDirs, sourceDir)
+// typeGroup is a set of files from one directory destined for one media tree
+type typeGroup struct {
+	Type  MediaType
+	Files []*MediaFile
+}
+
+// groupByType splits a directory's files by media type, or keeps them together
+// under the majority type when mixedDirs is MixedDirsMajority
+func groupByType(files []*MediaFile, mixedDirs string) []typeGroup {
+	var photos, videos []*MediaFile
+	for _, mf := range files {
+		if mf.Type == TypePhoto {
+			photos = append(photos, mf)
 		} else {
-			album := &Album{
-				Name:        albumName,
-				Destination: destDir,
-				Files:       dirFiles,
-				SourceDirs:  []string{sourceDir},
-				Date:        medianDate,
-				Type:        dirFiles[0].Type,
-			}
-			albums = append(albums, album)
-			albumsByName[albumName] = album
+			videos = append(videos, mf)
 		}
 	}
 
-	// Handle music files
-	musicAlbums := organizeMusicFiles(files, config)
-	albums = append(albums, musicAlbums...)
+	if mixedDirs == MixedDirsMajority {
+		if len(videos) > len(photos) {
+			return []typeGroup{{Type: TypeVideo, Files: files}}
+		}
+		return []typeGroup{{Type: TypePhoto, Files: files}}
+	}
 
-	// Filter albums to only include those with new files
-	albums = filterAlbumsWithNewFiles(albums)
+	var groups []typeGroup
+	if len(photos) > 0 {
+		groups = append(groups, typeGroup{Type: TypePhoto, Files: photos})
+	}
+	if len(videos) > 0 {
+		groups = append(groups, typeGroup{Type: TypeVideo, Files: videos})
+	}
+	return groups
+}
 
-	return albums, nil
+// dateFolder returns the date part of an album destination for the configured layout
+func dateFolder(layout string, medianDate time.Time, dates []time.Time) string {
+	switch layout {
+	case LayoutYearMonth:
+		month := dominantMonth(dates)
+		return filepath.Join(month.Format("2006"), month.Format("01"))
+	case LayoutYearDashMonth:
+		return dominantMonth(dates).Format("2006-01")
+	default:
+		return fmt.Sprintf("%d", medianDate.Year())
+	}
+}
+
+// dominantMonth returns the month containing the most dates (earliest wins ties)
+func dominantMonth(dates []time.Time) time.Time {
+	counts := make(map[time.Time]int)
+	var best time.Time
+	for _, d := range dates {
+		month := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, d.Location())
+		counts[month]++
+		if best.IsZero() || counts[month] > counts[best] ||
+			(counts[month] == counts[best] && month.Before(best)) {
+			best = month
+		}
+	}
+	return best
+}
+
+// sortAlbumFiles orders files chronologically (or by name for AlbumSortName), ties broken by name
+func sortAlbumFiles(files []*MediaFile, order string) {
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if order != AlbumSortName {
+			switch {
+			case a.DateTaken != nil && b.DateTaken != nil && !a.DateTaken.Equal(*b.DateTaken):
+				return a.DateTaken.Before(*b.DateTaken)
+			case a.DateTaken != nil && b.DateTaken == nil:
+				return true
+			case a.DateTaken == nil && b.DateTaken != nil:
+				return false
+			}
+		}
+		nameA, nameB := filepath.Base(a.Path), filepath.Base(b.Path)
+		if nameA != nameB {
+			return nameA < nameB
+		}
+		return a.Path < b.Path
+	})
+}
+
+// splitLargeAlbums replaces albums with more than maxFiles files by parts of maxFiles
+// files each, in date order: "Name (Part 1)", "Name (Part 2)", ... next to where the
+// album would have gone. Curated folders and music albums are never split.
+func splitLargeAlbums(albums []*Album, maxFiles int) []*Album {
+	if maxFiles < 1 {
+		return albums
+	}
+
+	var split []*Album
+	for _, album := range albums {
+		if len(album.Files) <= maxFiles || album.Keep || album.Type == TypeMusic {
+			split = append(split, album)
+			continue
+		}
+
+		files := make([]*MediaFile, len(album.Files))
+		copy(files, album.Files)
+		sortAlbumFiles(files, AlbumSortDate)
+		for start := 0; start < len(files); start += maxFiles {
+			end := min(start+maxFiles, len(files))
+			part := *album
+			part.Part = start/maxFiles + 1
+			suffix := fmt.Sprintf(" (Part %d)", part.Part)
+			part.Name = album.Name + suffix
+			part.Destination = album.Destination + suffix
+			part.Files = files[start:end]
+			part.Date, _ = medianDateOf(part.Files)
+			split = append(split, &part)
+		}
+		debugLog.Printf("album %s: %d files, split into %d parts", album.Name, len(files), (len(files)+maxFiles-1)/maxFiles)
+	}
+	return split
 }
 
 // filterAlbumsWithNewFiles returns only albums that contain new files
@@ -149,7 +565,7 @@ func filterAlbumsWithNewFiles(albums []*Album) []*Album {
 		var newFiles []*MediaFile
 		for _, file := range album.Files {
 			// Check if file is new OR if it needs to be moved (not already at destination)
-			destPath := filepath.Join(album.Destination, filepath.Base(file.Path))
+			destPath := album.DestPath(file)
 			if file.IsNew || file.Path != destPath {
 				hasNewFiles = true
 				newFiles = append(newFiles, file)
@@ -157,20 +573,66 @@ func filterAlbumsWithNewFiles(albums []*Album) []*Album {
 		}
 		if hasNewFiles {
 			// Create a copy of the album with only new files
-			filteredAlbum := &Album{
-				Name:        album.Name,
-				Destination: album.Destination,
-				Files:       newFiles,
-				SourceDirs:  album.SourceDirs,
-				Date:        album.Date,
-				Type:        album.Type,
-			}
-			filtered = append(filtered, filteredAlbum)
+			filteredAlbum := *album
+			filteredAlbum.Files = newFiles
+			filtered = append(filtered, &filteredAlbum)
 		}
 	}
 	return filtered
 }
 
+// namingRequest is a folder waiting for a suggestion from the naming backend
+type namingRequest struct {
+	sourceDir   string
+	samplePaths []string
+	keywords    []string // Most common keywords of the folder's files
+}
+
+// suggestAlbumNames asks the naming backend about the pending folders, config.NamingWorkers
+// at a time, caching each suggestion as it arrives and calling settled for every folder
+// done with (suggested or not). Folders that got no suggestion are left out of the result;
+// after a timeout or cancellation no further requests are sent.
+func suggestAlbumNames(ctx context.Context, namer AlbumNamer, pending []namingRequest, config *Config, settled func(sourceDir string), albumCache *AlbumSuggestionCache) map[string]string {
+	suggestions := make(map[string]string)
+	var mu sync.Mutex
+	stopped := false
+
+	runParallel(config.NamingWorkers, len(pending), func(i int) {
+		req := pending[i]
+		defer settled(req.sourceDir)
+		mu.Lock()
+		skip := stopped
+		mu.Unlock()
+		if skip {
+			return
+		}
+
+		suggested, err := namer.Suggest(ctx, req.sourceDir, req.samplePaths, req.keywords)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			// Don't wait on a stuck or cancelled backend for every remaining folder
+			if !stopped && ctx.Err() == nil {
+				warnLog.Printf("%s timed out, using folder names", config.NamingProvider)
+			}
+			stopped = true
+			return
+		}
+		if err != nil {
+			warnLog.Printf("naming %s: %v, using the folder name", req.sourceDir, err)
+		}
+		if err == nil && suggested != "" {
+			debugLog.Printf("naming %s: %q from %s", req.sourceDir, suggested, config.NamingProvider)
+			suggestions[req.sourceDir] = suggested
+			if albumCache != nil {
+				albumCache.Put(req.sourceDir, req.samplePaths, namer.Model(), suggested)
+			}
+		}
+	})
+	return suggestions
+}
+
 // fallbackAlbumName creates a fallback album name from directory
 func fallbackAlbumName(sourceDir, yearMonth string) string {
 	dirName := filepath.Base(sourceDir)
@@ -186,44 +648,178 @@ func fallbackAlbumName(sourceDir, yearMonth string) string {
 	return fmt.Sprintf("%s %s", yearMonth, dirName)
 }
 
+// nonPhotoFolders maps image classes to their top-level library folder
+var nonPhotoFolders = map[string]string{
+	ImageClassScreenshot: "Screenshots",
+	ImageClassAnimation:  "Animations",
+	ImageClassGraphic:    "Graphics",
+}
+
+// isNonPhoto checks if a file is an image that isn't a photograph
+func isNonPhoto(mf *MediaFile) bool {
+	return mf.Type == TypePhoto && nonPhotoFolders[mf.ImageClass] != ""
+}
+
+// organizeNonPhotos groups non-photographic images by kind and year (e.g. Screenshots/2021)
+func organizeNonPhotos(files []*MediaFile, config *Config) []*Album {
+	var albums []*Album
+	for base, baseFiles := range byTypeBase(files, config) {
+		byFolder := make(map[string][]*MediaFile)
+		for _, mf := range baseFiles {
+			folder := filepath.Join(nonPhotoFolders[mf.ImageClass], yearFolder(mf))
+			byFolder[folder] = append(byFolder[folder], mf)
+		}
+
+		for folder, files := range byFolder {
+			albums = append(albums, &Album{
+				Name:        fmt.Sprintf("%s %s", filepath.Dir(folder), filepath.Base(folder)),
+				Destination: filepath.Join(base, folder),
+				Files:       files,
+				SourceDirs:  []string{"various"},
+				Type:        TypePhoto,
+			})
+		}
+	}
+
+	return albums
+}
+
+// organizeMisc groups the files of directories too small for an album by year
+// (Misc/2021), photos and videos together unless videos have a root of their own
+func organizeMisc(files []*MediaFile, config *Config) []*Album {
+	var albums []*Album
+	for base, baseFiles := range byTypeBase(files, config) {
+		byYear := make(map[string][]*MediaFile)
+		for _, mf := range baseFiles {
+			year := yearFolder(mf)
+			byYear[year] = append(byYear[year], mf)
+		}
+
+		for year, files := range byYear {
+			albums = append(albums, &Album{
+				Name:        "Misc " + year,
+				Destination: filepath.Join(base, "Misc", year),
+				Files:       files,
+				SourceDirs:  []string{"various"},
+				Type:        sharedAlbumType(files),
+			})
+		}
+	}
+
+	return albums
+}
+
+// organizeUndated puts the files no date could be found for, not even a file time, into
+// one album (config.NoDateFolder, Unsorted/NoDate by default), photos and videos together
+// unless videos have a root of their own
+func organizeUndated(files []*MediaFile, config *Config) []*Album {
+	var albums []*Album
+	for base, files := range byTypeBase(files, config) {
+		albums = append(albums, &Album{
+			Name:        "No Date",
+			Destination: filepath.Join(base, config.NoDateFolder),
+			Files:       files,
+			SourceDirs:  []string{"various"},
+			Type:        sharedAlbumType(files),
+		})
+	}
+	return albums
+}
+
+// byTypeBase groups files by the folder their type's Misc, Screenshots and NoDate
+// folders go below (Config.typeBase): all in the library, unless a type has its own root
+func byTypeBase(files []*MediaFile, config *Config) map[string][]*MediaFile {
+	byBase := make(map[string][]*MediaFile)
+	for _, mf := range files {
+		base := config.typeBase(mf.Type)
+		byBase[base] = append(byBase[base], mf)
+	}
+	return byBase
+}
+
+// sharedAlbumType is the type of an album holding photos and videos together: video only
+// if all its files are videos
+func sharedAlbumType(files []*MediaFile) MediaType {
+	for _, mf := range files {
+		if mf.Type != TypeVideo {
+			return TypePhoto
+		}
+	}
+	return TypeVideo
+}
+
+// yearFolder is the year folder of a file in Misc or a non-photo folder
+func yearFolder(mf *MediaFile) string {
+	if mf.DateTaken == nil {
+		return "Unknown"
+	}
+	return fmt.Sprintf("%d", mf.DateTaken.Year())
+}
+
 // organizeMusicFiles organizes music files by artist/album
 func organizeMusicFiles(files []*MediaFile, config *Config) []*Album {
-	byAlbum := make(map[string][]*MediaFile)
+	type albumKey struct{ artist, album string }
+	byAlbum := make(map[albumKey][]*MediaFile)
 
 	for _, mf := range files {
 		if mf.Type != TypeMusic {
 			continue
 		}
 
-		artist := mf.Artist
+		artist := sanitizeFolderName(mf.Artist)
 		if artist == "" {
 			artist = "Unknown Artist"
 		}
 
-		album := mf.Album
+		album := sanitizeFolderName(musicAlbumOf(mf))
 		if album == "" {
 			album = "Unknown Album"
 		}
 
-		key := fmt.Sprintf("%s - %s", artist, album)
+		key := albumKey{artist, album}
 		byAlbum[key] = append(byAlbum[key], mf)
 	}
 
 	var albums []*Album
-	for name, files := range byAlbum {
-		parts := strings.SplitN(name, " - ", 2)
-		artist, albumName := parts[0], parts[1]
-
-		destDir := filepath.Join(config.LibraryBase, "Music", artist, albumName)
+	for key, files := range byAlbum {
+		destDir := filepath.Join(config.typeRoot(TypeMusic), key.artist, key.album)
 
-		albums = append(albums, &Album{
-			Name:        name,
+		album := &Album{
+			Name:        fmt.Sprintf("%s - %s", key.artist, key.album),
 			Destination: destDir,
 			Files:       files,
 			SourceDirs:  []string{"various"},
 			Type:        TypeMusic,
-		})
+		}
+		if config.FolderTemplate != "" {
+			album.Destination = config.templateDestination(files[0], album)
+		}
+		albums = append(albums, album)
 	}
 
 	return albums
 }
+
+// musicAlbumOf returns the album a music file is filed under: its album tag or, for files
+// without artist and album tags, the folder it is in (files ripped or downloaded together)
+func musicAlbumOf(mf *MediaFile) string {
+	if mf.Artist == "" && mf.Album == "" {
+		return filepath.Base(filepath.Dir(mf.Path))
+	}
+	return mf.Album
+}
+
+// sanitizeFolderName makes a tag value safe to use as a single folder name (AC/DC → AC-DC)
+func sanitizeFolderName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':':
+			return '-'
+		}
+		if r < 32 {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.Trim(strings.TrimSpace(name), ".")
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUndatedFilesGoToNoDate(t *testing.T) {
+	// Not even a file time: left undated rather than dated to now
+	gone := &MediaFile{Path: filepath.Join(t.TempDir(), "gone.jpg"), Type: TypePhoto}
+	fallbackToFileTime(gone)
+	if gone.DateTaken != nil {
+		t.Fatalf("file without a file time dated %v", gone.DateTaken)
+	}
+
+	date := time.Date(2019, 7, 4, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name       string
+		videosRoot string
+		want       map[string]string // File name -> album folder, relative to root
+	}{
+		{"library", "", map[string]string{
+			"photo.jpg": "library/Unsorted/NoDate",
+			"clip.mp4":  "library/Unsorted/NoDate",
+		}},
+		{"videos root", "big/Videos", map[string]string{
+			"photo.jpg": "library/Unsorted/NoDate",
+			"clip.mp4":  "big/Videos/Unsorted/NoDate",
+		}},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		config := &Config{
+			LibraryBase:  filepath.Join(root, "library"),
+			NoDateFolder: defaultNoDateFolder,
+		}
+		if tt.videosRoot != "" {
+			config.VideosRoot = filepath.Join(root, tt.videosRoot)
+		}
+		files := []*MediaFile{
+			{Path: filepath.Join(root, "scan", "misc", "photo.jpg"), Type: TypePhoto},
+			{Path: filepath.Join(root, "scan", "misc", "clip.mp4"), Type: TypeVideo},
+			{Path: filepath.Join(root, "scan", "trip", "dated.jpg"), Type: TypePhoto, DateTaken: &date},
+		}
+
+		albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, album := range albums {
+			for _, mf := range album.Files {
+				if mf.DateTaken == nil {
+					rel, _ := filepath.Rel(root, album.Destination)
+					got[filepath.Base(mf.Path)] = filepath.ToSlash(rel)
+				}
+				if mf.DateTaken != nil && album.Destination == filepath.Join(config.LibraryBase, defaultNoDateFolder) {
+					t.Errorf("%s: dated %s in the NoDate album", tt.name, mf.Path)
+				}
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: undated files placed %v, want %v", tt.name, got, tt.want)
+		}
+		for name, want := range tt.want {
+			if got[name] != want {
+				t.Errorf("%s: %s in %q, want %q", tt.name, name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestMusicRoutedByTags(t *testing.T) {
+	root := t.TempDir()
+	scan := filepath.Join(root, "scan", "downloads")
+	if err := os.MkdirAll(scan, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := map[string][]byte{
+		"01.mp3":  buildID3v2(3, id3Frame{"TPE1", 0, []byte("AC/DC")}, id3Frame{"TALB", 0, []byte("Back in Black")}),
+		"02.m4a":  buildM4A(mp4Item("\xa9ART", "Radiohead"), mp4Item("\xa9alb", "OK Computer")),
+		"03.flac": buildFLAC("ARTIST=Massive Attack", "ALBUM=Mezzanine"),
+		"04.mp3":  {0xFF, 0xFB, 0x90, 0x00}, // Untagged: filed under its folder
+	}
+	want := map[string]string{
+		"01.mp3":  "library/Music/AC-DC/Back in Black",
+		"02.m4a":  "library/Music/Radiohead/OK Computer",
+		"03.flac": "library/Music/Massive Attack/Mezzanine",
+		"04.mp3":  "library/Music/Unknown Artist/downloads",
+	}
+
+	var files []*MediaFile
+	for name, data := range fixtures {
+		path := filepath.Join(scan, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mf := &MediaFile{Path: path, Type: TypeMusic}
+		extractMetadata(mf)
+		files = append(files, mf)
+	}
+
+	config := &Config{LibraryBase: filepath.Join(root, "library"), NoDateFolder: defaultNoDateFolder}
+	albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, album := range albums {
+		for _, mf := range album.Files {
+			rel, _ := filepath.Rel(root, album.Destination)
+			got[filepath.Base(mf.Path)] = filepath.ToSlash(rel)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("music placed %v, want %v", got, want)
+	}
+	for name, dest := range want {
+		if got[name] != dest {
+			t.Errorf("%s in %q, want %q", name, got[name], dest)
+		}
+	}
+}
+
+func TestNonPhotosPlacement(t *testing.T) {
+	date := time.Date(2021, 6, 1, 12, 0, 0, 0, time.Local)
+	for _, mode := range []string{NonPhotosAlbums, NonPhotosSeparate} {
+		root := t.TempDir()
+		config := &Config{
+			LibraryBase:  filepath.Join(root, "library"),
+			NoDateFolder: defaultNoDateFolder,
+			NonPhotos:    mode,
+		}
+		photo := &MediaFile{Path: filepath.Join(root, "scan", "trip", "beach.jpg"), Type: TypePhoto, DateTaken: &date}
+		shot := &MediaFile{Path: filepath.Join(root, "scan", "trip", "Screenshot_1.png"), Type: TypePhoto,
+			DateTaken: &date, ImageClass: ImageClassScreenshot}
+
+		albums, err := OrganizeIntoAlbums(t.Context(), []*MediaFile{photo, shot}, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := make(map[*MediaFile]string)
+		for _, album := range albums {
+			for _, mf := range album.Files {
+				dest[mf] = album.Destination
+			}
+		}
+
+		switch mode {
+		case NonPhotosAlbums:
+			if dest[shot] != dest[photo] {
+				t.Errorf("%s: screenshot in %q, want the photo's album %q", mode, dest[shot], dest[photo])
+			}
+		case NonPhotosSeparate:
+			if want := filepath.Join(config.LibraryBase, "Screenshots", "2021"); dest[shot] != want {
+				t.Errorf("%s: screenshot in %q, want %q", mode, dest[shot], want)
+			}
+			if dest[photo] == dest[shot] {
+				t.Errorf("%s: photo in the screenshots folder", mode)
+			}
+		}
+	}
+}
+
+func TestOrganizeProgressPerFolder(t *testing.T) {
+	stubOllama(t, func(w http.ResponseWriter, r *http.Request) {
+		writeOllamaReply(w, "Named Album")
+	})
+	root := t.TempDir()
+	folders := []string{"Beach", "Hike", "Party", "Zoo"}
+	files := namingTestFiles(root, append(folders, "Cached")...)
+	date := time.Date(2019, 7, 4, 12, 0, 0, 0, time.Local)
+	for i := range 2 { // Too few for an album: goes to Misc, not named
+		files = append(files, &MediaFile{Path: filepath.Join(root, "scan", "small", fmt.Sprintf("%d.jpg", i)), Type: TypePhoto, DateTaken: &date})
+	}
+	config := &Config{
+		LibraryBase:   filepath.Join(root, "library"),
+		NoDateFolder:  defaultNoDateFolder,
+		AlbumNaming:   AlbumNamingOllama,
+		OllamaModel:   "stub",
+		NamingWorkers: 2,
+		MinAlbumFiles: 3,
+	}
+	cache := openTestCache(t)
+	albumCache, _ := OpenAlbumSuggestionCache(cache)
+	var samples []string
+	for _, mf := range files {
+		if filepath.Base(filepath.Dir(mf.Path)) == "Cached" {
+			samples = append(samples, mf.Path)
+		}
+	}
+	albumCache.Put(filepath.Join(root, "scan", "Cached"), samples, "stub", "From the cache")
+	cache.flush()
+
+	progress := make(chan ScanProgress, 100)
+	if _, err := OrganizeIntoAlbums(t.Context(), files, config, progress, albumCache); err != nil {
+		t.Fatal(err)
+	}
+	close(progress)
+
+	// One update per named folder, counting up to the total
+	const processed = 5
+	seen := make(map[string]bool)
+	count := 0
+	for p := range progress {
+		count++
+		if p.ProcessedFiles != count || p.TotalFiles != processed {
+			t.Errorf("update %d: %d of %d", count, p.ProcessedFiles, p.TotalFiles)
+		}
+		seen[filepath.Base(p.CurrentFile)] = true
+	}
+	if count != processed {
+		t.Errorf("%d progress updates, want %d", count, processed)
+	}
+	for _, folder := range append(folders, "Cached") {
+		if !seen[folder] {
+			t.Errorf("no progress update for %s", folder)
+		}
+	}
+}
+
+func TestSmallFolderGoesToMisc(t *testing.T) {
+	wedding := time.Date(2018, 6, 9, 15, 0, 0, 0, time.Local)
+	trip := time.Date(2019, 7, 4, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		minFiles int
+		want     map[string]string // File name -> album folder, relative to the library
+	}{
+		{3, map[string]string{"ceremony.mp4": "Misc/2018", "dance.mp4": "Misc/2018"}},
+		{2, map[string]string{"ceremony.mp4": "Videos/2018/2018-06 wedding", "dance.mp4": "Videos/2018/2018-06 wedding"}},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		files := []*MediaFile{
+			{Path: filepath.Join(root, "scan", "wedding", "ceremony.mp4"), Type: TypeVideo, DateTaken: &wedding},
+			{Path: filepath.Join(root, "scan", "wedding", "dance.mp4"), Type: TypeVideo, DateTaken: &wedding},
+		}
+		for i := range 3 {
+			files = append(files, &MediaFile{Path: filepath.Join(root, "scan", "trip", fmt.Sprintf("%d.jpg", i)), Type: TypePhoto, DateTaken: &trip})
+		}
+		config := &Config{
+			LibraryBase:   filepath.Join(root, "library"),
+			NoDateFolder:  defaultNoDateFolder,
+			MinAlbumFiles: tt.minFiles,
+		}
+
+		albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every file is placed exactly once
+		placed := make(map[*MediaFile]int)
+		got := make(map[string]string)
+		for _, album := range albums {
+			for _, mf := range album.Files {
+				placed[mf]++
+				if mf.Type == TypeVideo {
+					rel, _ := filepath.Rel(config.LibraryBase, album.Destination)
+					got[filepath.Base(mf.Path)] = filepath.ToSlash(rel)
+				}
+			}
+		}
+		for _, mf := range files {
+			if placed[mf] != 1 {
+				t.Errorf("min %d: %s placed %d times, want once", tt.minFiles, mf.Path, placed[mf])
+			}
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("min %d: videos placed %v, want %v", tt.minFiles, got, tt.want)
+		}
+	}
+}
+
+func TestOrganizeMusicFilesGrouping(t *testing.T) {
+	root := t.TempDir()
+	music := func(rel, artist, album string) *MediaFile {
+		return &MediaFile{Path: filepath.Join(root, "scan", filepath.FromSlash(rel)), Type: TypeMusic, Artist: artist, Album: album}
+	}
+	files := []*MediaFile{
+		music("a/01.mp3", "Derek - The Dominos", "Layla"), // " - " in the artist stays in the artist
+		music("a/02.mp3", "Derek - The Dominos", "Layla"),
+		music("b/03.mp3", "Derek - The Dominos", ""),
+		music("rips/Abbey Road/01.mp3", "", ""), // Untagged: one album per folder
+		music("rips/Abbey Road/02.mp3", "", ""),
+		music("downloads/x.mp3", "", ""),
+		music("c/04.mp3", "", "Greatest Hits"),
+	}
+	want := map[string][2]string{ // Path -> album name, folder below the library
+		"a/01.mp3":               {"Derek - The Dominos - Layla", "Music/Derek - The Dominos/Layla"},
+		"a/02.mp3":               {"Derek - The Dominos - Layla", "Music/Derek - The Dominos/Layla"},
+		"b/03.mp3":               {"Derek - The Dominos - Unknown Album", "Music/Derek - The Dominos/Unknown Album"},
+		"rips/Abbey Road/01.mp3": {"Unknown Artist - Abbey Road", "Music/Unknown Artist/Abbey Road"},
+		"rips/Abbey Road/02.mp3": {"Unknown Artist - Abbey Road", "Music/Unknown Artist/Abbey Road"},
+		"downloads/x.mp3":        {"Unknown Artist - downloads", "Music/Unknown Artist/downloads"},
+		"c/04.mp3":               {"Unknown Artist - Greatest Hits", "Music/Unknown Artist/Greatest Hits"},
+	}
+
+	config := &Config{LibraryBase: filepath.Join(root, "library")}
+	albums := organizeMusicFiles(files, config)
+	if len(albums) != 5 {
+		t.Errorf("%d music albums, want 5", len(albums))
+	}
+	for _, album := range albums {
+		dest, _ := filepath.Rel(config.LibraryBase, album.Destination)
+		for _, mf := range album.Files {
+			rel, _ := filepath.Rel(filepath.Join(root, "scan"), mf.Path)
+			if got := [2]string{album.Name, filepath.ToSlash(dest)}; got != want[filepath.ToSlash(rel)] {
+				t.Errorf("%s: album %q in %s, want %q in %s", rel, got[0], got[1], want[filepath.ToSlash(rel)][0], want[filepath.ToSlash(rel)][1])
+			}
+		}
+	}
+}
+
+func TestMergeNestedFolders(t *testing.T) {
+	date := time.Date(2019, 7, 4, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name           string
+		mergeDepth     int
+		keepSubfolders bool
+		want           map[string]string // File -> destination below the library
+	}{
+		{"album per folder", 0, false, map[string]string{
+			"rome_0.jpg":   "Photos/2019/2019-07 Rome/rome_0.jpg",
+			"venice_0.jpg": "Photos/2019/2019-07 Venice/venice_0.jpg",
+		}},
+		{"merged into the parent", 1, false, map[string]string{
+			"rome_0.jpg":   "Photos/2019/2019-07 Italy/rome_0.jpg",
+			"venice_0.jpg": "Photos/2019/2019-07 Italy/venice_0.jpg",
+		}},
+		{"merged, subfolders kept", 1, true, map[string]string{
+			"rome_0.jpg":   "Photos/2019/2019-07 Italy/Rome/rome_0.jpg",
+			"venice_0.jpg": "Photos/2019/2019-07 Italy/Venice/venice_0.jpg",
+		}},
+		{"merged up to below the scan path", 5, true, map[string]string{
+			"rome_0.jpg":   "Photos/2019/2019-07 2019/Italy/Rome/rome_0.jpg",
+			"venice_0.jpg": "Photos/2019/2019-07 2019/Italy/Venice/venice_0.jpg",
+		}},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		scan := filepath.Join(root, "scan")
+		var files []*MediaFile
+		for _, city := range []string{"Rome", "Venice"} {
+			for i := range 3 {
+				name := fmt.Sprintf("%s_%d.jpg", strings.ToLower(city), i)
+				files = append(files, &MediaFile{Path: filepath.Join(scan, "2019", "Italy", city, name), Type: TypePhoto, DateTaken: &date})
+			}
+		}
+		config := &Config{
+			ScanPaths:      []string{scan},
+			LibraryBase:    filepath.Join(root, "library"),
+			NoDateFolder:   defaultNoDateFolder,
+			MinAlbumFiles:  3,
+			MergeDepth:     tt.mergeDepth,
+			KeepSubfolders: tt.keepSubfolders,
+		}
+
+		albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, album := range albums {
+			for _, mf := range album.Files {
+				if _, ok := tt.want[filepath.Base(mf.Path)]; ok {
+					rel, _ := filepath.Rel(config.LibraryBase, album.DestPath(mf))
+					got[filepath.Base(mf.Path)] = filepath.ToSlash(rel)
+				}
+			}
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: placed %v, want %v", tt.name, got, tt.want)
+		}
+		if wantAlbums := 2 - min(tt.mergeDepth, 1); len(albums) != wantAlbums {
+			t.Errorf("%s: %d albums, want %d", tt.name, len(albums), wantAlbums)
+		}
+	}
+}
+
+func TestSplitLargeAlbums(t *testing.T) {
+	root := t.TempDir()
+	start := time.Date(2019, 7, 1, 9, 0, 0, 0, time.Local)
+	var files []*MediaFile
+	for i := range 25 {
+		date := start.Add(time.Duration(24-i) * time.Hour) // Named in reverse date order
+		files = append(files, &MediaFile{Path: filepath.Join(root, "scan", "trip", fmt.Sprintf("IMG_%02d.jpg", i)), Type: TypePhoto, DateTaken: &date, IsNew: true})
+	}
+	config := &Config{LibraryBase: filepath.Join(root, "library"), NoDateFolder: defaultNoDateFolder, MaxAlbumFiles: 10}
+
+	albums, err := OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(albums) != 3 {
+		t.Fatalf("%d albums %v, want 3 parts", len(albums), albumNamesOf(albums))
+	}
+	sizes := []int{10, 10, 5}
+	destinations := make(map[string]bool)
+	var last time.Time
+	for i, album := range albums {
+		suffix := fmt.Sprintf(" (Part %d)", i+1)
+		if album.Part != i+1 || !strings.HasSuffix(album.Name, suffix) || !strings.HasSuffix(album.Destination, suffix) {
+			t.Errorf("album %d is %s at %s, want part %d", i, album.Name, album.Destination, i+1)
+		}
+		if len(album.Files) != sizes[i] {
+			t.Errorf("%s: %d files, want %d", album.Name, len(album.Files), sizes[i])
+		}
+		destinations[album.Destination] = true
+		for _, mf := range album.Files {
+			if mf.DateTaken.Before(last) {
+				t.Errorf("%s: %s out of date order", album.Name, filepath.Base(mf.Path))
+			}
+			last = *mf.DateTaken
+		}
+	}
+	if len(destinations) != 3 {
+		t.Errorf("parts share destinations: %v", destinations)
+	}
+
+	// A part whose files are all in place already has nothing left to do
+	for _, mf := range albums[0].Files {
+		mf.Path, mf.IsNew = albums[0].DestPath(mf), false
+	}
+	if got := albumNamesOf(filterAlbumsWithNewFiles(albums)); !slices.Equal(got, albumNamesOf(albums[1:])) {
+		t.Errorf("albums with new files %v, want parts 2 and 3", got)
+	}
+
+	// Albums up to the limit stay whole
+	config.MaxAlbumFiles = 25
+	for _, mf := range files {
+		mf.Path, mf.IsNew = filepath.Join(root, "scan", "trip", filepath.Base(mf.Path)), true
+	}
+	albums, err = OrganizeIntoAlbums(t.Context(), files, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(albums) != 1 || albums[0].Part != 0 || len(albums[0].Files) != 25 {
+		t.Errorf("albums %v, want one of 25 files", albumNamesOf(albums))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Pauser holds worker pools between files while paused (the TUI's p key). Workers check
+// it before taking the next file, so the file at hand is finished first and none is
+// skipped or processed twice.
+type Pauser struct {
+	mu     sync.Mutex
+	resume chan struct{} // Closed on resume, nil while running
+}
+
+// Toggle pauses or resumes, returns whether it is now paused
+func (p *Pauser) Toggle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		return true
+	}
+	close(p.resume)
+	p.resume = nil
+	return false
+}
+
+func (p *Pauser) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resume != nil
+}
+
+// wait blocks while paused, until resumed or ctx is cancelled
+func (p *Pauser) wait(ctx context.Context) {
+	p.mu.Lock()
+	resume := p.resume
+	p.mu.Unlock()
+	if resume == nil {
+		return
+	}
+	select {
+	case <-resume:
+	case <-ctx.Done():
+	}
+}
+
+type pauserKey struct{}
+
+// withPauser returns a context whose worker pools p can pause
+func withPauser(ctx context.Context, p *Pauser) context.Context {
+	return context.WithValue(ctx, pauserKey{}, p)
+}
+
+// waitIfPaused blocks a worker while the context's Pauser, if any, is paused. Cancelling
+// the context (quitting) ends the wait.
+func waitIfPaused(ctx context.Context) {
+	if p, ok := ctx.Value(pauserKey{}).(*Pauser); ok {
+		p.wait(ctx)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPauseHoldsWorkersBetweenFiles(t *testing.T) {
+	const n, workers = 200, 4
+	pause := &Pauser{}
+	ctx := withPauser(t.Context(), pause)
+
+	// A synthetic phase: each worker takes the next file after waitIfPaused
+	var counts [n]atomic.Int32
+	var processed atomic.Int32
+	paused := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		runParallel(workers, n, func(i int) {
+			waitIfPaused(ctx)
+			counts[i].Add(1)
+			if processed.Add(1) == 20 {
+				pause.Toggle() // The p key, pressed while files are being processed
+				close(paused)
+			}
+		})
+	}()
+
+	<-paused
+	if !pause.Paused() {
+		t.Fatal("Toggle didn't pause")
+	}
+	// Files already taken are finished, then nothing moves
+	time.Sleep(50 * time.Millisecond)
+	held := processed.Load()
+	if held > 20+workers {
+		t.Errorf("%d files processed after pausing at 20, want at most %d more", held, workers)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := processed.Load(); got != held {
+		t.Errorf("processed %d files while paused", got-held)
+	}
+
+	if pause.Toggle() {
+		t.Fatal("Toggle didn't resume")
+	}
+	<-finished
+	for i := range counts {
+		if c := counts[i].Load(); c != 1 {
+			t.Errorf("file %d processed %d times, want once", i, c)
+		}
+	}
+}
+
+func TestPausedPhaseResumes(t *testing.T) {
+	dir := t.TempDir()
+	var files []*MediaFile
+	for i := range 20 {
+		path := filepath.Join(dir, fmt.Sprintf("IMG_%02d.jpg", i))
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: int64(len(path)), Type: TypePhoto})
+	}
+
+	pause := &Pauser{}
+	pause.Toggle()
+	ctx := withPauser(t.Context(), pause)
+	progress := make(chan ScanProgress, len(files))
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ProcessMetadata(ctx, files, 4, progress, nil)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if len(progress) != 0 {
+		t.Fatalf("%d files processed while paused", len(progress))
+	}
+	pause.Toggle()
+	<-finished
+	close(progress)
+
+	updates := 0
+	for prog := range progress {
+		updates++
+		if prog.ProcessedFiles != updates {
+			t.Errorf("update %d counts %d files", updates, prog.ProcessedFiles)
+		}
+	}
+	if updates != len(files) {
+		t.Errorf("%d files processed, want %d", updates, len(files))
+	}
+	for _, mf := range files {
+		if mf.DateTaken == nil {
+			t.Errorf("%s: not processed", mf.Path)
+		}
+	}
+
+	// Quitting while paused ends the wait, leaving the rest unprocessed
+	cancelled, cancel := context.WithCancel(t.Context())
+	pause.Toggle()
+	ctx = withPauser(cancelled, pause)
+	for _, mf := range files {
+		mf.DateTaken = nil
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	ProcessMetadata(ctx, files, 4, nil, nil)
+	for _, mf := range files {
+		if mf.DateTaken != nil {
+			t.Errorf("%s processed after quitting while paused", mf.Path)
+		}
+	}
+}
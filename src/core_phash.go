@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// CalculatePerceptualHashes computes difference hashes (dHash) for photos in parallel,
+// so resized or re-encoded copies can be found. Returns the number of cache hits.
+func CalculatePerceptualHashes(files []*MediaFile, workers int, progressChan chan<- ScanProgress, cache *Cache) int {
+	var photos []*MediaFile
+	for _, mf := range files {
+		if mf.Type == TypePhoto && !isNonPhoto(mf) {
+			photos = append(photos, mf)
+		}
+	}
+
+	var wg sync.WaitGroup
+	fileChan := make(chan *MediaFile, len(photos))
+	processed := 0
+	cacheHits := 0
+	var mu sync.Mutex
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for mf := range fileChan {
+				// Try cache first
+				cached := mf.PHash != ""
+				if !cached && cache != nil {
+					if info, err := os.Stat(mf.Path); err == nil {
+						if cf, ok := cache.Get(mf.Path, mf.Size, info.ModTime()); ok && cf.PHash != "" {
+							mf.PHash = cf.PHash
+							cached = true
+						}
+					}
+				}
+
+				if cached {
+					mu.Lock()
+					cacheHits++
+					mu.Unlock()
+				} else if hash, err := calculatePerceptualHash(mf.Path); err == nil {
+					mf.PHash = hash
+					if cache != nil {
+						if info, err := os.Stat(mf.Path); err == nil {
+							cache.Put(mf, info.ModTime())
+						}
+					}
+				}
+
+				mu.Lock()
+				processed++
+				if progressChan != nil {
+					select {
+					case progressChan <- ScanProgress{
+						ProcessedFiles: processed,
+						TotalFiles:     len(photos),
+						CurrentFile:    mf.Path,
+					}:
+					default:
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, mf := range photos {
+		fileChan <- mf
+	}
+	close(fileChan)
+
+	wg.Wait()
+	return cacheHits
+}
+
+// calculatePerceptualHash returns the 64-bit dHash of an image as 16 hex digits
+// (fails for formats without a decoder, e.g. RAW and HEIC)
+func calculatePerceptualHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016x", dHash(img)), nil
+}
+
+// dHash shrinks the image to 9x8 grayscale cells and sets a bit wherever a cell
+// is darker than its right neighbour
+func dHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	b := img.Bounds()
+
+	var cells [rows][cols]float64
+	for y := 0; y < rows; y++ {
+		y0 := b.Min.Y + y*b.Dy()/rows
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/rows, y0+1)
+		for x := 0; x < cols; x++ {
+			x0 := b.Min.X + x*b.Dx()/cols
+			x1 := max(b.Min.X+(x+1)*b.Dx()/cols, x0+1)
+
+			// Average a grid of samples over the cell (decoding dominates the cost anyway)
+			stepX := max((x1-x0)/8, 1)
+			stepY := max((y1-y0)/8, 1)
+			var sum float64
+			var n int
+			for py := y0; py < y1; py += stepY {
+				for px := x0; px < x1; px += stepX {
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			cells[y][x] = sum / float64(n)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if cells[y][x] < cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// FindNearDuplicates groups photos whose perceptual hashes differ by at most threshold bits.
+// Byte-identical copies are left to FindDuplicates (only one of them is considered here).
+func FindNearDuplicates(files []*MediaFile, threshold int, policy *DuplicatePolicy) []*DuplicateGroup {
+	if threshold <= 0 {
+		return nil
+	}
+
+	var candidates []*MediaFile
+	var hashes []uint64
+	seenContent := make(map[string]bool)
+	for _, mf := range files {
+		if mf.PHash == "" {
+			continue
+		}
+		if mf.Hash != "" && mf.Hash != uniqueHash {
+			if seenContent[mf.Hash] {
+				continue
+			}
+			seenContent[mf.Hash] = true
+		}
+		h, err := strconv.ParseUint(mf.PHash, 16, 64)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, mf)
+		hashes = append(hashes, h)
+	}
+
+	// Multi-index lookup: split hashes into threshold+1 chunks, two hashes within
+	// threshold bits must share at least one chunk exactly
+	chunks := min(threshold+1, 64)
+	chunkBits := 64 / chunks
+	chunkKey := func(h uint64, c int) uint64 {
+		width := chunkBits
+		if c == chunks-1 {
+			width = 64 - c*chunkBits // Last chunk takes the remainder
+		}
+		return (h >> (c * chunkBits)) & (1<<width - 1)
+	}
+
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for c := 0; c < chunks; c++ {
+		buckets := make(map[uint64][]int)
+		for i, h := range hashes {
+			key := chunkKey(h, c)
+			for _, j := range buckets[key] {
+				if bits.OnesCount64(h^hashes[j]) <= threshold {
+					parent[find(i)] = find(j)
+				}
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	byRoot := make(map[int][]*MediaFile)
+	for i, mf := range candidates {
+		root := find(i)
+		byRoot[root] = append(byRoot[root], mf)
+	}
+
+	var groups []*DuplicateGroup
+	for root, group := range byRoot {
+		if len(group) > 1 {
+			groups = append(groups, &DuplicateGroup{
+				Hash:  candidates[root].PHash,
+				Files: group,
+				Best:  chooseBestDuplicate(group, policy),
+			})
+		}
+	}
+
+	// Deterministic order for display
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Best.Path < groups[j].Best.Path
+	})
+	return groups
+}
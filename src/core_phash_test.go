@@ -0,0 +1,95 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// waveImage is a smooth w x h test photo; the same scene at any size
+func waveImage(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			v := 128 + 60*math.Sin(fx*7) + 60*math.Cos(fy*5+fx*2)
+			img.SetGray(x, y, color.Gray{uint8(v)})
+		}
+	}
+	return img
+}
+
+// blockImage is a w x h image of random gray blocks, unrelated to waveImage
+func blockImage(w, h int, seed int64) image.Image {
+	rnd := rand.New(rand.NewSource(seed))
+	const block = 16
+	shades := make([]uint8, (w/block+1)*(h/block+1))
+	for i := range shades {
+		shades[i] = uint8(rnd.Intn(256))
+	}
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetGray(x, y, color.Gray{shades[(y/block)*(w/block+1)+x/block]})
+		}
+	}
+	return img
+}
+
+func writeTestJPEG(t *testing.T, path string, img image.Image, quality int) *MediaFile {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MediaFile{Path: path, Size: info.Size(), Type: TypePhoto}
+}
+
+func TestNearDuplicatesScaledCopy(t *testing.T) {
+	dir := t.TempDir()
+	original := writeTestJPEG(t, filepath.Join(dir, "original.jpg"), waveImage(640, 480), 95)
+	scaled := writeTestJPEG(t, filepath.Join(dir, "scaled.jpg"), waveImage(160, 120), 60)
+	unrelated := writeTestJPEG(t, filepath.Join(dir, "unrelated.jpg"), blockImage(640, 480, 1), 95)
+	files := []*MediaFile{original, scaled, unrelated}
+
+	CalculatePerceptualHashes(files, 2, nil, nil)
+	for _, mf := range files {
+		if mf.PHash == "" {
+			t.Fatalf("%s: no perceptual hash", mf.Path)
+		}
+	}
+
+	groups := FindNearDuplicates(files, 8, &DuplicatePolicy{})
+	if len(groups) != 1 {
+		t.Fatalf("%d near-duplicate groups, want 1", len(groups))
+	}
+	group := groups[0].Files
+	if len(group) != 2 || !slices.Contains(group, original) || !slices.Contains(group, scaled) {
+		var names []string
+		for _, mf := range group {
+			names = append(names, filepath.Base(mf.Path))
+		}
+		t.Errorf("grouped %v, want original.jpg and scaled.jpg", names)
+	}
+
+	// Nothing is near anything at threshold 0 (near-duplicates off)
+	if groups := FindNearDuplicates(files, 0, &DuplicatePolicy{}); len(groups) != 0 {
+		t.Errorf("threshold 0: %d groups, want none", len(groups))
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxPlaceDistanceKm is how far a photo may be from a known place to be named after it
+const maxPlaceDistanceKm = 50.0
+
+// Place is a named location used for offline reverse geocoding
+type Place struct {
+	Name string
+	GPSCoord
+}
+
+// LoadPlaces reads a CSV file of "name,lat,lon" rows (lines starting with # are ignored)
+func LoadPlaces(path string) (*Places, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 3
+	r.TrimLeadingSpace = true
+
+	var places []Place
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		lon, errLon := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if errLat != nil || errLon != nil {
+			continue // Header row or bad coordinates
+		}
+
+		places = append(places, Place{
+			Name:     strings.TrimSpace(record[0]),
+			GPSCoord: GPSCoord{Lat: lat, Lon: lon},
+		})
+	}
+
+	return newPlaces(places), nil
+}
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// kmPerDegree is the length of a degree of latitude
+const kmPerDegree = earthRadiusKm * math.Pi / 180
+
+// Places are the known places, bucketed into 1° cells so that finding the nearest one
+// only measures the places in the cells within maxPlaceDistanceKm (a GeoNames export
+// has over 100,000)
+type Places struct {
+	cells map[placeCell][]Place
+	count int
+}
+
+// placeCell is a 1° latitude/longitude cell, by the degrees of its south-west corner
+type placeCell struct{ lat, lon int }
+
+func newPlaces(list []Place) *Places {
+	p := &Places{cells: make(map[placeCell][]Place), count: len(list)}
+	for _, place := range list {
+		cell := placeCell{int(math.Floor(place.Lat)), wrapLonCell(int(math.Floor(place.Lon)))}
+		p.cells[cell] = append(p.cells[cell], place)
+	}
+	return p
+}
+
+// Len returns the number of places (0 for nil)
+func (p *Places) Len() int {
+	if p == nil {
+		return 0
+	}
+	return p.count
+}
+
+// wrapLonCell maps a longitude cell into [-180, 180), across the antimeridian
+func wrapLonCell(lon int) int {
+	return ((lon+180)%360+360)%360 - 180
+}
+
+// nearestPlace returns the closest place within maxPlaceDistanceKm, or "" if none
+// (the first by name when several are equally close)
+func nearestPlace(coord GPSCoord, places *Places) string {
+	if places.Len() == 0 {
+		return ""
+	}
+
+	// Cells the search radius reaches: a degree of longitude shrinks towards the poles
+	dLat := maxPlaceDistanceKm / kmPerDegree
+	latFrom, latTo := int(math.Floor(coord.Lat-dLat)), int(math.Floor(coord.Lat+dLat))
+	lonFrom, lonTo := -180, 179
+	maxLat := math.Min(90, math.Abs(coord.Lat)+dLat)
+	if cos := math.Cos(maxLat * math.Pi / 180); cos > 0 {
+		if dLon := dLat / cos; dLon < 179 {
+			lonFrom, lonTo = int(math.Floor(coord.Lon-dLon)), int(math.Floor(coord.Lon+dLon))
+		}
+	}
+
+	best := ""
+	bestDist := 0.0
+	for lat := latFrom; lat <= latTo; lat++ {
+		for lon := lonFrom; lon <= lonTo; lon++ {
+			for _, p := range places.cells[placeCell{lat, wrapLonCell(lon)}] {
+				d := distanceKm(coord, p.GPSCoord)
+				if d > maxPlaceDistanceKm {
+					continue
+				}
+				if best == "" || d < bestDist || (d == bestDist && p.Name < best) {
+					best = p.Name
+					bestDist = d
+				}
+			}
+		}
+	}
+	return best
+}
+
+// dominantPlace returns the place most of the geotagged files are nearest to
+func dominantPlace(files []*MediaFile, places *Places) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, mf := range files {
+		if mf.GPS == nil {
+			continue
+		}
+		name := nearestPlace(*mf.GPS, places)
+		if name == "" {
+			continue
+		}
+		counts[name]++
+		if best == "" || counts[name] > counts[best] ||
+			(counts[name] == counts[best] && name < best) {
+			best = name
+		}
+	}
+	return best
+}
+
+// locationCellDegrees is the grid cell size used to group photos when no place is near (about 55 km)
+const locationCellDegrees = 0.5
+
+// groupByLocation groups geotagged files into albums named "<month> <place>", e.g.
+// "2019-07 Barcelona". The place is the nearest known place, or the grid cell's
+// center ("41.25N 2.25E") when there is none nearby.
+func groupByLocation(files []*MediaFile, places *Places) map[string][]*MediaFile {
+	groups := make(map[string][]*MediaFile)
+	for _, mf := range files {
+		if mf.GPS == nil {
+			continue
+		}
+		place := nearestPlace(*mf.GPS, places)
+		if place == "" {
+			place = gridCellName(*mf.GPS)
+		}
+
+		name := place
+		if mf.DateTaken != nil {
+			name = mf.DateTaken.Format("2006-01") + " " + place
+		}
+		groups[name] = append(groups[name], mf)
+	}
+	return groups
+}
+
+// gridCellName names the locationCellDegrees grid cell containing coord by its center
+func gridCellName(coord GPSCoord) string {
+	center := func(deg float64) float64 {
+		return (math.Floor(deg/locationCellDegrees) + 0.5) * locationCellDegrees
+	}
+	lat, lon := center(coord.Lat), center(coord.Lon)
+
+	ns, ew := "N", "E"
+	if lat < 0 {
+		ns, lat = "S", -lat
+	}
+	if lon < 0 {
+		ew, lon = "W", -lon
+	}
+	return fmt.Sprintf("%.2f%s %.2f%s", lat, ns, lon, ew)
+}
+
+// distanceKm returns the great-circle distance between two coordinates
+func distanceKm(a, b GPSCoord) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(b.Lat - a.Lat)
+	dLon := toRad(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
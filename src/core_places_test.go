@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// nearestPlaceScan is nearestPlace measuring every place, to check the cell lookup against
+func nearestPlaceScan(coord GPSCoord, list []Place) string {
+	best := ""
+	bestDist := 0.0
+	for _, p := range list {
+		d := distanceKm(coord, p.GPSCoord)
+		if d <= maxPlaceDistanceKm && (best == "" || d < bestDist || (d == bestDist && p.Name < best)) {
+			best, bestDist = p.Name, d
+		}
+	}
+	return best
+}
+
+func TestNearestPlace(t *testing.T) {
+	list := []Place{
+		{"Barcelona", GPSCoord{41.3874, 2.1686}},
+		{"Girona", GPSCoord{41.9794, 2.8214}},
+		{"Suva", GPSCoord{-18.1416, 178.4419}},
+		{"Taveuni", GPSCoord{-16.85, -179.95}}, // Across the antimeridian from Suva's side
+		{"Longyearbyen", GPSCoord{78.2232, 15.6267}},
+	}
+	places := newPlaces(list)
+	tests := []struct {
+		coord GPSCoord
+		want  string
+	}{
+		{GPSCoord{41.40, 2.17}, "Barcelona"},
+		{GPSCoord{41.95, 2.80}, "Girona"},
+		{GPSCoord{40.4168, -3.7038}, ""}, // Madrid, nothing near
+		{GPSCoord{-16.9, 179.95}, "Taveuni"},
+		{GPSCoord{78.3, 16.9}, "Longyearbyen"}, // 1° of longitude is ~23 km here
+	}
+	for _, tt := range tests {
+		if got := nearestPlace(tt.coord, places); got != tt.want {
+			t.Errorf("nearestPlace(%v) = %q, want %q", tt.coord, got, tt.want)
+		}
+	}
+	if got := nearestPlace(GPSCoord{41.4, 2.17}, nil); got != "" {
+		t.Errorf("nearestPlace without places = %q", got)
+	}
+}
+
+func TestNearestPlaceMatchesFullScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var list []Place
+	for i := range 5000 {
+		list = append(list, Place{fmt.Sprintf("p%d", i), GPSCoord{rng.Float64()*180 - 90, rng.Float64()*360 - 180}})
+	}
+	places := newPlaces(list)
+	for range 2000 {
+		// Near a place, so most lookups find one
+		p := list[rng.Intn(len(list))]
+		coord := GPSCoord{p.Lat + rng.Float64() - 0.5, p.Lon + rng.Float64()*2 - 1}
+		if coord.Lon >= 180 {
+			coord.Lon -= 360
+		} else if coord.Lon < -180 {
+			coord.Lon += 360
+		}
+		if got, want := nearestPlace(coord, places), nearestPlaceScan(coord, list); got != want {
+			t.Fatalf("nearestPlace(%v) = %q, want %q", coord, got, want)
+		}
+	}
+}
+
+func TestGroupByLocation(t *testing.T) {
+	places := newPlaces([]Place{
+		{"Barcelona", GPSCoord{41.3874, 2.1686}},
+		{"Girona", GPSCoord{41.9794, 2.8214}},
+	})
+	july := time.Date(2019, 7, 10, 12, 0, 0, 0, time.UTC)
+	august := time.Date(2019, 8, 2, 12, 0, 0, 0, time.UTC)
+	photo := func(name string, lat, lon float64, date *time.Time) *MediaFile {
+		return &MediaFile{Path: "/photos/" + name, GPS: &GPSCoord{lat, lon}, DateTaken: date}
+	}
+	files := []*MediaFile{
+		photo("sagrada.jpg", 41.4036, 2.1744, &july),
+		photo("beach.jpg", 41.3784, 2.1925, &july),
+		photo("cathedral.jpg", 41.9875, 2.8260, &july),
+		photo("rambla.jpg", 41.3809, 2.1734, &august), // Same place, next month
+		photo("atlantic1.jpg", 38.1, -30.2, &july),    // No place nearby: grid cell
+		photo("atlantic2.jpg", 38.4, -30.4, &july),    // Same 0.5° cell
+		photo("undated.jpg", 41.39, 2.17, nil),
+		{Path: "/photos/no_gps.jpg", DateTaken: &july},
+	}
+
+	want := map[string][]string{
+		"2019-07 Barcelona":     {"sagrada.jpg", "beach.jpg"},
+		"2019-07 Girona":        {"cathedral.jpg"},
+		"2019-08 Barcelona":     {"rambla.jpg"},
+		"2019-07 38.25N 30.25W": {"atlantic1.jpg", "atlantic2.jpg"},
+		"Barcelona":             {"undated.jpg"},
+	}
+	got := groupByLocation(files, places)
+	if len(got) != len(want) {
+		t.Errorf("groups %v, want %v", slices.Collect(maps.Keys(got)), slices.Collect(maps.Keys(want)))
+	}
+	for name, wantFiles := range want {
+		var names []string
+		for _, mf := range got[name] {
+			names = append(names, filepath.Base(mf.Path))
+		}
+		if !slices.Equal(names, wantFiles) {
+			t.Errorf("%s: %v, want %v", name, names, wantFiles)
+		}
+	}
+
+	// Without a places file every photo is named by its grid cell
+	if got := groupByLocation(files[:2], nil); len(got) != 1 || len(got["2019-07 41.25N 2.25E"]) != 2 {
+		t.Errorf("without places: %v, want both in 2019-07 41.25N 2.25E", slices.Collect(maps.Keys(got)))
+	}
+}
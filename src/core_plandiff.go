@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// PlanDiff summarizes how a plan changes the existing library
+type PlanDiff struct {
+	NewAlbums     int             // Destination folder doesn't exist yet
+	GrowingAlbums int             // Destination folder exists and gains files
+	NewFiles      int             // Files coming from outside the library
+	Relocations   int             // Files already in the library moving to a new place
+	Conflicts     int             // Destination name already taken (file is renamed or skipped)
+	Collisions    []PlanCollision // Destinations wanted by several files of the plan
+	existing      map[string]bool // Album destinations that already exist
+}
+
+// PlanCollision is a destination path that several planned files resolve to (same-named
+// files merged into one album, albums sharing a folder): the first file keeps the name,
+// the others are renamed with a counter when executed
+type PlanCollision struct {
+	Destination string
+	Sources     []string
+	Renamed     []string // Where Sources[1:] end up
+}
+
+// ComputePlanDiff compares album destinations against what's already on disk in the library
+func ComputePlanDiff(albums []*Album, config *Config) *PlanDiff {
+	diff := &PlanDiff{existing: make(map[string]bool)}
+	planned := make(map[string]string) // Destination -> planned file that gets it
+	collisions := make(map[string]int) // Destination -> index in diff.Collisions
+
+	for _, album := range albums {
+		if _, seen := diff.existing[album.Destination]; !seen {
+			info, err := os.Stat(album.Destination)
+			exists := err == nil && info.IsDir()
+			diff.existing[album.Destination] = exists
+			if exists {
+				diff.GrowingAlbums++
+			} else {
+				diff.NewAlbums++
+			}
+		}
+
+		for _, file := range album.Files {
+			destPath := album.DestPath(file)
+			if file.Path == destPath {
+				continue // Already in place, nothing changes
+			}
+
+			if config.inLibrary(file.Path) {
+				diff.Relocations++
+			} else {
+				diff.NewFiles++
+			}
+
+			// Taken on disk, or by another file in this plan
+			source, taken := planned[destPath]
+			if _, err := os.Stat(destPath); err == nil || taken {
+				diff.Conflicts++
+			}
+
+			// Wanted by an earlier file: resolved as the executor will, with a counter
+			if !taken {
+				planned[destPath] = file.Path
+				continue
+			}
+			i, ok := collisions[destPath]
+			if !ok {
+				i = len(diff.Collisions)
+				collisions[destPath] = i
+				diff.Collisions = append(diff.Collisions, PlanCollision{Destination: destPath, Sources: []string{source}})
+			}
+			renamed, _ := ensureUniqueFilename(destPath, func(path string) bool {
+				_, taken := planned[path]
+				return taken
+			}, false)
+			planned[renamed] = file.Path
+			diff.Collisions[i].Sources = append(diff.Collisions[i].Sources, file.Path)
+			diff.Collisions[i].Renamed = append(diff.Collisions[i].Renamed, renamed)
+		}
+	}
+
+	return diff
+}
+
+// AlbumExists reports whether the album's destination was already in the library
+func (d *PlanDiff) AlbumExists(album *Album) bool {
+	return d.existing[album.Destination]
+}
+
+// String returns a one-line summary, e.g. "12 new albums, 5 existing albums gain files, 0 conflicts"
+func (d *PlanDiff) String() string {
+	s := fmt.Sprintf("%d new albums, %d existing albums gain files, %d conflicts",
+		d.NewAlbums, d.GrowingAlbums, d.Conflicts)
+	if len(d.Collisions) > 0 {
+		s += fmt.Sprintf(" (%d names wanted by several files)", len(d.Collisions))
+	}
+	return s
+}
+
+// FilesString summarizes where the planned files come from
+func (d *PlanDiff) FilesString() string {
+	return fmt.Sprintf("%d new files, %d relocated within the library", d.NewFiles, d.Relocations)
+}
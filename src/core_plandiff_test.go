@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlanDiffFlagsCollisions(t *testing.T) {
+	root := t.TempDir()
+	library := filepath.Join(root, "library")
+	scan := func(rel string) *MediaFile {
+		return &MediaFile{Path: filepath.Join(root, "scan", filepath.FromSlash(rel)), Type: TypePhoto}
+	}
+	trip := filepath.Join(library, "2019", "Trip")
+	albums := []*Album{
+		{Name: "Trip", Destination: trip, Files: []*MediaFile{scan("a/IMG_0001.jpg"), scan("a/IMG_0002.jpg")}},
+		// Another album resolving to the same folder (e.g. through a folder template)
+		{Name: "Trip", Destination: trip, Type: TypeVideo, Files: []*MediaFile{scan("b/IMG_0001.jpg"), scan("d/IMG_0001.jpg")}},
+		{Name: "Other", Destination: filepath.Join(library, "2019", "Other"), Files: []*MediaFile{scan("c/IMG_0001.jpg")}},
+	}
+	// Taken on disk already: a conflict, but not a collision within the plan
+	if err := os.MkdirAll(trip, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(trip, "IMG_0002.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diff := ComputePlanDiff(albums, &Config{LibraryBase: library})
+	if len(diff.Collisions) != 1 {
+		t.Fatalf("%d collisions, want 1: %+v", len(diff.Collisions), diff.Collisions)
+	}
+	c := diff.Collisions[0]
+	if c.Destination != filepath.Join(trip, "IMG_0001.jpg") {
+		t.Errorf("collision at %s, want %s", c.Destination, filepath.Join(trip, "IMG_0001.jpg"))
+	}
+	wantSources := []string{albums[0].Files[0].Path, albums[1].Files[0].Path, albums[1].Files[1].Path}
+	wantRenamed := []string{filepath.Join(trip, "IMG_0001_1.jpg"), filepath.Join(trip, "IMG_0001_2.jpg")}
+	if !slices.Equal(c.Sources, wantSources) || !slices.Equal(c.Renamed, wantRenamed) {
+		t.Errorf("collision %v → %v, want %v → %v", c.Sources, c.Renamed, wantSources, wantRenamed)
+	}
+	if diff.Conflicts != 3 {
+		t.Errorf("%d conflicts, want 3", diff.Conflicts)
+	}
+	if s := diff.String(); !strings.Contains(s, "1 names wanted by several files") {
+		t.Errorf("summary %q doesn't mention the collision", s)
+	}
+}
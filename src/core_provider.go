@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// MetadataProvider supplies metadata from an external source (taking precedence over EXIF)
+type MetadataProvider interface {
+	Name() string
+	// Provide overwrites the metadata of mf it knows (leaving the rest), returning false
+	// if it knows nothing about the file
+	Provide(mf *MediaFile) (bool, error)
+}
+
+// metadataProviders are consulted in registration order (set up before processing starts)
+var metadataProviders []MetadataProvider
+
+// RegisterMetadataProvider adds a provider to be consulted by extractMetadata
+func RegisterMetadataProvider(p MetadataProvider) {
+	metadataProviders = append(metadataProviders, p)
+}
+
+// applyMetadataProviders asks registered providers for metadata, returns true if one answered
+func applyMetadataProviders(mf *MediaFile) bool {
+	for _, p := range metadataProviders {
+		ok, err := p.Provide(mf)
+		if err != nil {
+			warnLog.Printf("metadata provider %s failed for %s: %v", p.Name(), mf.Path, err)
+			continue
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// providedMetadata is the JSON format expected from external metadata commands
+type providedMetadata struct {
+	DateTaken   string `json:"date_taken"` // RFC 3339 or "2006-01-02 15:04:05"
+	CameraMake  string `json:"camera_make"`
+	CameraModel string `json:"camera_model"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	Title       string `json:"title"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Orientation int    `json:"orientation"` // EXIF orientation (1-8) of width and height
+}
+
+// CommandProvider runs an external command with the file path as last argument
+// and reads JSON metadata from its stdout (empty output means "unknown")
+type CommandProvider struct {
+	Command []string
+	Timeout time.Duration
+}
+
+// NewCommandProvider creates a provider from a command line like "lookup-dates --db photos.db"
+func NewCommandProvider(commandLine string) *CommandProvider {
+	return &CommandProvider{
+		Command: strings.Fields(commandLine),
+		Timeout: 30 * time.Second,
+	}
+}
+
+func (p *CommandProvider) Name() string {
+	return p.Command[0]
+}
+
+func (p *CommandProvider) Provide(mf *MediaFile) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	args := append(append([]string{}, p.Command[1:]...), mf.Path)
+	cmd := exec.CommandContext(ctx, p.Command[0], args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return false, err
+	}
+
+	output := bytes.TrimSpace(stdout.Bytes())
+	if len(output) == 0 {
+		return false, nil
+	}
+
+	var meta providedMetadata
+	if err := json.Unmarshal(output, &meta); err != nil {
+		return false, fmt.Errorf("parse output: %w", err)
+	}
+
+	provided := false
+	if meta.DateTaken != "" {
+		tm, err := parseProvidedDate(meta.DateTaken)
+		if err != nil {
+			return false, err
+		}
+		mf.DateTaken = &tm
+		provided = true
+	}
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&mf.CameraMake, meta.CameraMake},
+		{&mf.CameraModel, meta.CameraModel},
+		{&mf.Artist, meta.Artist},
+		{&mf.Album, meta.Album},
+		{&mf.Title, meta.Title},
+	} {
+		if field.src != "" {
+			*field.dst = field.src
+			provided = true
+		}
+	}
+	if meta.Width > 0 && meta.Height > 0 {
+		mf.Width = meta.Width
+		mf.Height = meta.Height
+		provided = true
+	}
+	if meta.Orientation >= 1 && meta.Orientation <= 8 {
+		mf.Orientation = meta.Orientation
+		provided = true
+	}
+
+	return provided, nil
+}
+
+// parseProvidedDate accepts RFC 3339 or a plain local date/time
+func parseProvidedDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if tm, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// planReport is the JSON form of an organization plan (--report)
+type planReport struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Mode        string            `json:"mode"` // Organize mode (OrganizeMove, OrganizeCopy, ...)
+	Library     string            `json:"library"`
+	Trash       string            `json:"trash"`
+	Albums      []reportAlbum     `json:"albums"`
+	Duplicates  []reportDuplicate `json:"duplicates"`
+}
+
+type reportAlbum struct {
+	Name        string       `json:"name"`
+	Destination string       `json:"destination"`
+	Type        string       `json:"type"`
+	SourceDirs  []string     `json:"source_dirs"`
+	Keywords    []string     `json:"keywords,omitempty"` // Most common keywords of its files
+	Files       []reportFile `json:"files"`
+}
+
+type reportFile struct {
+	Path        string     `json:"path"`
+	Destination string     `json:"destination"`
+	Size        int64      `json:"size"`
+	Date        *time.Time `json:"date,omitempty"`
+	Keywords    []string   `json:"keywords,omitempty"` // From the XMP sidecar
+}
+
+type reportDuplicate struct {
+	Hash      string       `json:"hash"`
+	Algorithm string       `json:"algorithm"`
+	Kept      reportFile   `json:"kept"`
+	Trashed   []reportFile `json:"trashed"`
+}
+
+// duplicateReport is the JSON form of the duplicate groups (--dup-report)
+type duplicateReport struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Mode        string            `json:"mode"`
+	Trash       string            `json:"trash"`
+	Groups      []reportDuplicate `json:"groups"`
+	Files       int               `json:"files"`       // Files that would be trashed
+	Reclaimable int64             `json:"reclaimable"` // Bytes freed by trashing them
+}
+
+// validateReportPath checks that the report format can be told from the extension
+func validateReportPath(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".csv":
+		return nil
+	}
+	return fmt.Errorf("%q must end in .json or .csv", path)
+}
+
+// WritePlanReport writes the planned album moves and duplicate trashing to path, as JSON
+// or as CSV (one row per file) depending on the extension. Nothing else is touched.
+func WritePlanReport(path string, albums []*Album, duplicates []*DuplicateGroup, config *Config) error {
+	report := buildPlanReport(albums, duplicates, config)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		err = writeReportCSV(f, report)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// buildPlanReport collects the plan, with the destination each file will get
+func buildPlanReport(albums []*Album, duplicates []*DuplicateGroup, config *Config) *planReport {
+	report := &planReport{
+		GeneratedAt: time.Now(),
+		Mode:        config.OrganizeMode,
+		Library:     config.LibraryBase,
+		Trash:       config.DuplicatesTrash,
+		Albums:      []reportAlbum{},
+		Duplicates:  reportDuplicates(duplicates, config),
+	}
+
+	for _, album := range albums {
+		entry := reportAlbum{
+			Name:        album.Name,
+			Destination: album.Destination,
+			Type:        album.Type.String(),
+			SourceDirs:  album.SourceDirs,
+			Keywords:    topKeywords(album.Files, maxAlbumKeywords),
+		}
+		for _, file := range album.Files {
+			entry.Files = append(entry.Files, reportFile{
+				Path:        file.Path,
+				Destination: album.DestPath(file),
+				Size:        file.Size,
+				Date:        file.DateTaken,
+				Keywords:    file.Keywords,
+			})
+		}
+		report.Albums = append(report.Albums, entry)
+	}
+
+	return report
+}
+
+// reportDuplicates lists each duplicate group's kept file and the files that would be trashed
+func reportDuplicates(duplicates []*DuplicateGroup, config *Config) []reportDuplicate {
+	entries := []reportDuplicate{}
+	for _, group := range duplicates {
+		entry := reportDuplicate{
+			Hash:      group.Hash,
+			Algorithm: group.Best.HashAlgo,
+			Kept:      reportFile{Path: group.Best.Path, Size: group.Best.Size, Date: group.Best.DateTaken},
+		}
+		for _, file := range group.Files {
+			if file == group.Best {
+				continue
+			}
+			trashed := reportFile{Path: file.Path, Size: file.Size, Date: file.DateTaken}
+			if config.OrganizeMode == OrganizeMove {
+				trashed.Destination = trashPath(file, config) // Other modes leave duplicates alone
+			}
+			entry.Trashed = append(entry.Trashed, trashed)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// WriteDuplicateReport writes the duplicate groups with their kept file, the files that would
+// be trashed and the reclaimable total to path, as JSON or CSV like WritePlanReport
+func WriteDuplicateReport(path string, duplicates []*DuplicateGroup, config *Config) error {
+	report := &duplicateReport{
+		GeneratedAt: time.Now(),
+		Mode:        config.OrganizeMode,
+		Trash:       config.DuplicatesTrash,
+		Groups:      reportDuplicates(duplicates, config),
+		Reclaimable: ReclaimableBytes(duplicates),
+	}
+	for _, group := range report.Groups {
+		report.Files += len(group.Trashed)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		err = writeReportCSV(f, &planReport{Mode: report.Mode, Duplicates: report.Groups})
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeReportCSV writes one row per planned file: album files, then kept and trashed duplicates
+func writeReportCSV(f *os.File, report *planReport) error {
+	w := csv.NewWriter(f)
+	w.Write([]string{"action", "album", "path", "destination", "size", "date", "hash"})
+
+	row := func(action, album string, file reportFile, hash string) {
+		date := ""
+		if file.Date != nil {
+			date = file.Date.Format(time.RFC3339)
+		}
+		w.Write([]string{action, album, file.Path, file.Destination, strconv.FormatInt(file.Size, 10), date, hash})
+	}
+
+	for _, album := range report.Albums {
+		for _, file := range album.Files {
+			row(report.Mode, album.Name, file, "")
+		}
+	}
+	trashAction := JournalTrash
+	if report.Mode != OrganizeMove {
+		trashAction = "duplicate" // Reported only, not trashed
+	}
+	for _, group := range report.Duplicates {
+		row("keep", "", group.Kept, group.Hash)
+		for _, file := range group.Trashed {
+			row(trashAction, "", file, group.Hash)
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
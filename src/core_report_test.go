@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanReport(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg", "c.jpg")
+	date := time.Date(2020, 8, 1, 10, 0, 0, 0, time.UTC)
+	for _, mf := range album.Files {
+		mf.DateTaken = &date
+	}
+	otherDir := filepath.Join(config.ScanPaths[0], "other")
+	if err := os.MkdirAll(otherDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(otherDir, "a copy.jpg")
+	if err := os.WriteFile(copyPath, []byte("a.jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := append([]*MediaFile{{Path: copyPath, Size: 5, Type: TypePhoto, DateTaken: &date}}, album.Files...)
+	CalculateHashes(t.Context(), files, 1, config.HashAlgorithm, nil, nil)
+	duplicates := FindDuplicates(files, 0, &DuplicatePolicy{})
+	if len(duplicates) != 1 {
+		t.Fatalf("%d duplicate groups, want 1", len(duplicates))
+	}
+	before := treeOf(t, filepath.Dir(config.ScanPaths[0]))
+
+	// JSON: every album with its files, every duplicate group with its kept and trashed files
+	jsonPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := WritePlanReport(jsonPath, []*Album{album}, duplicates, config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		GeneratedAt *time.Time `json:"generated_at"`
+		Mode        string     `json:"mode"`
+		Library     string     `json:"library"`
+		Trash       string     `json:"trash"`
+		Albums      []struct {
+			Name        string   `json:"name"`
+			Destination string   `json:"destination"`
+			Type        string   `json:"type"`
+			SourceDirs  []string `json:"source_dirs"`
+			Files       []struct {
+				Path        string     `json:"path"`
+				Destination string     `json:"destination"`
+				Size        *int64     `json:"size"`
+				Date        *time.Time `json:"date"`
+			} `json:"files"`
+		} `json:"albums"`
+		Duplicates []struct {
+			Hash      string `json:"hash"`
+			Algorithm string `json:"algorithm"`
+			Kept      struct {
+				Path        string `json:"path"`
+				Destination string `json:"destination"` // Empty: the kept file stays
+				Size        int64  `json:"size"`
+				Date        string `json:"date"`
+			} `json:"kept"`
+			Trashed []struct {
+				Path        string `json:"path"`
+				Destination string `json:"destination"`
+				Size        int64  `json:"size"`
+				Date        string `json:"date"`
+			} `json:"trashed"`
+		} `json:"duplicates"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&report); err != nil {
+		t.Fatalf("report doesn't match the schema: %v\n%s", err, data)
+	}
+
+	if report.GeneratedAt == nil || report.Mode != OrganizeMove || report.Library != config.LibraryBase || report.Trash != config.DuplicatesTrash {
+		t.Errorf("header = %v %q %q %q", report.GeneratedAt, report.Mode, report.Library, report.Trash)
+	}
+	if len(report.Albums) != 1 {
+		t.Fatalf("%d albums, want 1", len(report.Albums))
+	}
+	got := report.Albums[0]
+	if got.Name != "trip" || got.Destination != album.Destination || got.Type != TypePhoto.String() ||
+		len(got.SourceDirs) != 1 || got.SourceDirs[0] != album.SourceDirs[0] {
+		t.Errorf("album = %+v", got)
+	}
+	if len(got.Files) != 3 {
+		t.Fatalf("%d album files, want 3", len(got.Files))
+	}
+	for i, file := range got.Files {
+		mf := album.Files[i]
+		if file.Path != mf.Path || file.Destination != album.DestPath(mf) || file.Size == nil || *file.Size != mf.Size ||
+			file.Date == nil || !file.Date.Equal(date) {
+			t.Errorf("file %d = %+v, want %s → %s", i, file, mf.Path, album.DestPath(mf))
+		}
+	}
+
+	if len(report.Duplicates) != 1 {
+		t.Fatalf("%d duplicate groups in the report, want 1", len(report.Duplicates))
+	}
+	dup := report.Duplicates[0]
+	group := duplicates[0]
+	if dup.Hash != group.Hash || dup.Algorithm != config.HashAlgorithm || dup.Kept.Path != group.Best.Path || dup.Kept.Destination != "" {
+		t.Errorf("duplicate group = %+v, want hash %s kept %s", dup, group.Hash, group.Best.Path)
+	}
+	if len(dup.Trashed) != 1 || dup.Trashed[0].Path == dup.Kept.Path || dup.Trashed[0].Size != 5 ||
+		!strings.HasPrefix(dup.Trashed[0].Destination, config.DuplicatesTrash+string(filepath.Separator)) {
+		t.Errorf("trashed = %+v, want the other copy, bound for %s", dup.Trashed, config.DuplicatesTrash)
+	}
+
+	// CSV: a header, then one row per album file and per duplicate
+	csvPath := filepath.Join(t.TempDir(), "plan.csv")
+	if err := WritePlanReport(csvPath, []*Album{album}, duplicates, config); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+3+2 {
+		t.Errorf("%d CSV rows, want 6", len(rows))
+	}
+	actions := make(map[string]int)
+	for _, row := range rows[1:] {
+		actions[row[0]]++
+	}
+	if actions[OrganizeMove] != 3 || actions["keep"] != 1 || actions[JournalTrash] != 1 {
+		t.Errorf("CSV actions = %v", actions)
+	}
+
+	// Writing the plan touches nothing
+	if after := treeOf(t, filepath.Dir(config.ScanPaths[0])); !maps.Equal(after, before) {
+		t.Errorf("files changed by writing the report: %v → %v", before, after)
+	}
+}
+
+func TestDuplicateReport(t *testing.T) {
+	_, config := newTestAlbum(t)
+	config.DryRun = true
+	scan := config.ScanPaths[0]
+	contents := map[string]string{
+		"Pictures/beach.jpg":  strings.Repeat("b", 3000), // Kept: organized folder
+		"dump/beach.jpg":      strings.Repeat("b", 3000),
+		"Recovered/beach.jpg": strings.Repeat("b", 3000),
+		"dump/x.jpg":          strings.Repeat("x", 100),
+		"dump/x (1).jpg":      strings.Repeat("x", 100), // Kept: first path of equals
+		"dump/unique.jpg":     "only one",
+	}
+	var files []*MediaFile
+	for name, content := range contents {
+		path := filepath.Join(scan, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &MediaFile{Path: path, Size: int64(len(content)), Type: TypePhoto})
+	}
+	CalculateHashes(t.Context(), files, 2, config.HashAlgorithm, nil, nil)
+	policy := defaultDuplicatePolicy
+	duplicates := FindDuplicates(files, 0, &policy)
+	before := treeOf(t, filepath.Dir(scan))
+
+	path := filepath.Join(t.TempDir(), "duplicates.json")
+	if err := WriteDuplicateReport(path, duplicates, config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Groups []struct {
+			Hash string `json:"hash"`
+			Kept struct {
+				Path string `json:"path"`
+			} `json:"kept"`
+			Trashed []struct {
+				Path string `json:"path"`
+				Size int64  `json:"size"`
+			} `json:"trashed"`
+		} `json:"groups"`
+		Files       int   `json:"files"`
+		Reclaimable int64 `json:"reclaimable"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Files != 3 || report.Reclaimable != 2*3000+100 {
+		t.Errorf("%d files, %d bytes reclaimable, want 3, %d", report.Files, report.Reclaimable, 2*3000+100)
+	}
+	kept := make(map[string][]string)
+	for _, group := range report.Groups {
+		rel, _ := filepath.Rel(scan, group.Kept.Path)
+		for _, trashed := range group.Trashed {
+			other, _ := filepath.Rel(scan, trashed.Path)
+			kept[filepath.ToSlash(rel)] = append(kept[filepath.ToSlash(rel)], filepath.ToSlash(other))
+			if trashed.Size != int64(len(contents[filepath.ToSlash(other)])) {
+				t.Errorf("%s: size %d, want %d", other, trashed.Size, len(contents[filepath.ToSlash(other)]))
+			}
+		}
+	}
+	want := map[string][]string{
+		"Pictures/beach.jpg": {"Recovered/beach.jpg", "dump/beach.jpg"},
+		"dump/x (1).jpg":     {"dump/x.jpg"},
+	}
+	for _, trashed := range kept {
+		slices.Sort(trashed)
+	}
+	if !maps.EqualFunc(kept, want, slices.Equal) {
+		t.Errorf("kept → trashed = %v, want %v", kept, want)
+	}
+
+	// Reporting in a dry run moves nothing
+	if after := treeOf(t, filepath.Dir(scan)); !maps.Equal(after, before) {
+		t.Errorf("files changed by writing the report: %v → %v", before, after)
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -12,6 +19,7 @@ var (
 		".jpg": true, ".jpeg": true, ".jpe": true, ".png": true,
 		".tiff": true, ".tif": true, ".heic": true, ".heif": true,
 		".raw": true, ".cr2": true, ".nef": true, ".arw": true,
+		".gif": true,
 	}
 
 	videoExtensions = map[string]bool{
@@ -25,16 +33,84 @@ var (
 		".aac": true, ".ogg": true, ".wma": true, ".alac": true,
 	}
 
-	excludePatterns = []string{
-		"/.Trash/", "/.Thumbnails/", "/Thumbnails/",
-		"/.deleted_media/", "/.duplicates-trash/",
-		"/System/", "/Library/", "/Applications/",
-		"/.config/", "/retropie/", "/OFFICE/",
-		"/Template/", "/Software/", "/Windows/",
-		"/Program Files/",
+	// defaultExcludePatterns are skipped unless exclude_patterns is set in the config
+	defaultExcludePatterns = []string{
+		".Trash", ".Thumbnails", "Thumbnails",
+		".deleted_media", ".duplicates-trash",
+		"System", "Library", "Applications",
+		".config", "retropie", "OFFICE",
+		"Template", "Software", "Windows",
+		"Program Files",
 	}
 )
 
+// mediaTypeNames are the type names used by extra_extensions and --include-ext
+var mediaTypeNames = map[string]MediaType{"photo": TypePhoto, "video": TypeVideo, "music": TypeMusic}
+
+// normalizeExtension lowercases an extension and adds its dot ("DNG" -> ".dng")
+func normalizeExtension(ext string) (string, error) {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if len(ext) < 2 || strings.ContainsAny(ext[1:], "./\\") {
+		return "", fmt.Errorf("invalid extension %q", ext)
+	}
+	return ext, nil
+}
+
+// registerExtensions adds extra extensions to the media types and stops recognizing the
+// excluded ones (extra_extensions/exclude_extensions, --include-ext/--exclude-ext).
+// An extension belongs to one type only: adding one to a second type, or adding and
+// excluding it, is an error and changes nothing.
+func registerExtensions(extra map[MediaType][]string, exclude []string) error {
+	maps := map[MediaType]map[string]bool{TypePhoto: photoExtensions, TypeVideo: videoExtensions, TypeMusic: musicExtensions}
+
+	excluded := make(map[string]bool)
+	for _, ext := range exclude {
+		n, err := normalizeExtension(ext)
+		if err != nil {
+			return err
+		}
+		excluded[n] = true
+	}
+
+	added := make(map[string]MediaType)
+	for _, t := range []MediaType{TypePhoto, TypeVideo, TypeMusic} {
+		for _, ext := range extra[t] {
+			n, err := normalizeExtension(ext)
+			if err != nil {
+				return err
+			}
+			if excluded[n] {
+				return fmt.Errorf("%s is both added and excluded", n)
+			}
+			if other, ok := added[n]; ok && other != t {
+				return fmt.Errorf("%s is added as both %s and %s", n, other, t)
+			}
+			for other, m := range maps {
+				if other != t && m[n] {
+					return fmt.Errorf("%s is already a %s extension", n, other)
+				}
+			}
+			added[n] = t
+		}
+	}
+
+	for ext, t := range added {
+		maps[t][ext] = true
+	}
+	for ext := range excluded {
+		for _, m := range maps {
+			delete(m, ext)
+		}
+	}
+	return nil
+}
+
+// errScanLimit stops the walk once FileLimit files (or ByteLimit bytes) are collected
+var errScanLimit = errors.New("file limit reached")
+
 // detectMediaType detects the type of media file from extension
 func detectMediaType(path string) MediaType {
 	ext := strings.ToLower(filepath.Ext(path))
@@ -51,64 +127,228 @@ func detectMediaType(path string) MediaType {
 	return TypeUnknown
 }
 
-// shouldExclude checks if a path should be excluded
-func shouldExclude(path string) bool {
-	for _, pattern := range excludePatterns {
-		if strings.Contains(path, pattern) {
-			return true
+// shouldExclude checks if a path matches an exclude pattern, relative to the scan root.
+// Patterns without a slash match any single path component ("Thumbnails", "*.photoslibrary");
+// patterns with one are anchored at the root ("Backups/old", "/Temp") and exclude everything below.
+func shouldExclude(filePath, root string, patterns []string) bool {
+	rel, err := filepath.Rel(root, filePath)
+	inside := err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	if !inside {
+		rel = filePath // Outside the root (e.g. from --files-from): only component patterns apply
+	} else if rel == "." {
+		return false
+	}
+	parts := strings.Split(strings.Trim(filepath.ToSlash(rel), "/"), "/")
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		anchored := strings.Contains(pattern, "/")
+		pattern = strings.TrimPrefix(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+
+		if !anchored {
+			for _, part := range parts {
+				if ok, _ := path.Match(pattern, part); ok {
+					return true
+				}
+			}
+			continue
+		}
+
+		n := strings.Count(pattern, "/") + 1
+		if inside && len(parts) >= n {
+			if ok, _ := path.Match(pattern, strings.Join(parts[:n], "/")); ok {
+				return true
+			}
 		}
 	}
 	return false
 }
 
-// ScanMediaFiles scans directory for media files using parallel workers
-func ScanMediaFiles(basePath string, limit int, progressChan chan<- ScanProgress) ([]*MediaFile, error) {
+// validateExcludePatterns checks the glob syntax of exclude patterns
+func validateExcludePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(filepath.ToSlash(pattern), ""); err != nil {
+			return fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// fileIdentity identifies a physical file (device + inode)
+type fileIdentity struct {
+	dev uint64
+	ino uint64
+}
+
+// isWithinDir checks if path is dir itself or somewhere below it
+func isWithinDir(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// dirDepth returns how many levels path is below basePath (0 for basePath itself)
+func dirDepth(basePath, path string) int {
+	rel, err := filepath.Rel(basePath, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// scanRootOf returns the scan path a file is under (the deepest one if they nest), "" if none
+func scanRootOf(path string, roots []string) string {
+	best := ""
+	for _, root := range roots {
+		if isWithinDir(path, root) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// linkTargetInScanPaths returns a symlink's resolved target as a path below the scan
+// path it lies in (resolvedRoots maps their real paths to them), or "" when it lies
+// outside all of them
+func linkTargetInScanPaths(resolved string, resolvedRoots map[string]string) string {
+	best, bestReal := "", ""
+	for real, root := range resolvedRoots {
+		if !isWithinDir(resolved, real) || len(real) <= len(bestReal) {
+			continue
+		}
+		if rel, err := filepath.Rel(real, resolved); err == nil {
+			best, bestReal = filepath.Join(root, rel), real
+		}
+	}
+	return best
+}
+
+// ScanMediaFiles scans the scan paths (or the --files-from list) for media files.
+// A file reachable from several scan paths is returned once. With a cache, folders
+// unchanged since the last scan are listed from it instead of read again (unless
+// config.FullScan), and the folders read are recorded for the next scan.
+func ScanMediaFiles(config *Config, progressChan chan<- ScanProgress, cache *Cache) ([]*MediaFile, error) {
+	limit, byteLimit := config.FileLimit, config.ByteLimit
+
+	// inTrash skips our own duplicates trash, unless the user is explicitly scanning inside it
+	inTrash := func(path, root string) bool {
+		return isWithinDir(path, config.DuplicatesTrash) && !isWithinDir(root, config.DuplicatesTrash)
+	}
+	// inLibrary likewise skips the library below a scan path: its files are already
+	// organized, and regrouping them would nest albums into albums
+	inLibrary := func(path, root string) bool {
+		library := config.libraryRootOf(path)
+		return library != "" && !isWithinDir(root, library)
+	}
+
 	var (
 		files  []*MediaFile
 		mu     sync.Mutex
 		count  int
+		bytes  int64 // Size of the files collected, for byteLimit
 		photos int
 		videos int
 		music  int
 	)
 
-	// Walk directory and collect paths
-	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
+	// Scan paths by their real path, to place link targets below them
+	resolvedRoots := make(map[string]string)
+	for _, root := range config.ScanPaths {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			resolvedRoots[real] = root
 		}
+	}
 
-		if info.IsDir() {
-			if shouldExclude(path) {
-				return filepath.SkipDir
+	// Each physical file is recorded once, however many paths lead to it
+	seen := make(map[fileIdentity]bool)
+	seenPaths := make(map[string]bool)
+
+	// addFile records a media file, returns false once a limit is reached (no more files wanted)
+	addFile := func(path string, info os.FileInfo) bool {
+		// Resolve symlinks to the real file
+		if info.Mode()&os.ModeSymlink != 0 {
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return true // Dangling link
 			}
-			return nil
+			if info, err = os.Stat(resolved); err != nil || !info.Mode().IsRegular() {
+				return true
+			}
+			// Only targets inside a scan path are organized (moved, or trashed as duplicates)
+			inside := linkTargetInScanPaths(resolved, resolvedRoots)
+			if inside == "" {
+				debugLog.Printf("skip %s: links to %s, outside the scan paths", path, resolved)
+				return true
+			}
+			path = inside
 		}
 
 		// Check if it's a media file
 		mediaType := detectMediaType(path)
-		if mediaType == TypeUnknown {
-			return nil
+		if mediaType == TypeUnknown && !config.Sniff {
+			return true
 		}
 
-		if shouldExclude(path) {
-			return nil
+		root := scanRootOf(path, config.ScanPaths)
+		if shouldExclude(path, root, config.ExcludePatterns) || inTrash(path, root) || inLibrary(path, root) {
+			debugLog.Printf("skip %s: excluded", path)
+			return true
+		}
+
+		// The content wins over a missing or misleading extension
+		if config.Sniff {
+			if sniffed := sniffMediaType(path); sniffed != TypeUnknown {
+				if sniffed != mediaType {
+					debugLog.Printf("%s: content is %s, not what the extension says", path, sniffed)
+				}
+				mediaType = sniffed
+			}
+			if mediaType == TypeUnknown {
+				return true
+			}
 		}
 
-		// Apply limit
 		mu.Lock()
-		if limit > 0 && count >= limit {
+		// Skip files already seen via another path (symlink, hardlink, overlapping roots)
+		if seenPaths[path] {
 			mu.Unlock()
-			return filepath.SkipDir
+			return true
+		}
+		if id, ok := fileIdentityOf(info); ok {
+			if seen[id] {
+				mu.Unlock()
+				debugLog.Printf("skip %s: same file as one already found (link or overlapping scan path)", path)
+				return true
+			}
+			seen[id] = true
+		}
+		seenPaths[path] = true
+
+		// Apply limits. A file that would take the total past byteLimit ends the scan, so
+		// the files collected are the same from run to run.
+		if (limit > 0 && count >= limit) || (byteLimit > 0 && bytes+info.Size() > byteLimit) {
+			mu.Unlock()
+			return false
 		}
 		count++
+		bytes += info.Size()
+		more := (limit == 0 || count < limit) && (byteLimit == 0 || bytes < byteLimit)
 		mu.Unlock()
 
 		// Create MediaFile
 		mf := &MediaFile{
-			Path: path,
-			Size: info.Size(),
-			Type: mediaType,
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Type:    mediaType,
 		}
 
 		mu.Lock()
@@ -138,18 +378,231 @@ func ScanMediaFiles(basePath string, limit int, progressChan chan<- ScanProgress
 		}
 		mu.Unlock()
 
-		return nil
-	})
+		return more
+	}
+
+	// Explicit file list instead of walking
+	if config.FilesFrom != "" {
+		if err := scanFileList(config.FilesFrom, addFile); err != nil {
+			return nil, err
+		}
+		for _, mf := range files {
+			mf.Sidecar, mf.SidecarTime = findSidecar(mf.Path)
+		}
+		return files, nil
+	}
 
-	if err != nil {
-		return nil, err
+	// XMP sidecars met along the way, by sidecarKey, matched with their files at the end
+	sidecars := make(map[string]sidecarFile)
+	addSidecar := func(path string, info os.FileInfo) {
+		sidecars[sidecarKey(path)] = sidecarFile{path: path, modTime: info.ModTime()}
+	}
+
+	// Folder listings of earlier scans, and those read by this one (saved for the next)
+	var known map[string]*scanDir
+	found := make(map[string]*scanDir)
+	if cache != nil && !config.FullScan {
+		var err error
+		if known, err = cache.LoadScanDirs(); err != nil {
+			warnLog.Printf("scan: reading every folder, the cached listings are unavailable: %v", err)
+		}
+	}
+	listed := 0 // Folders taken from the cache
+
+	// Walk each scan path and collect files, honoring .mediaignore files along the way
+	complete := true
+	for _, basePath := range config.ScanPaths {
+		ignores := make(ignoreRules)
+		var walk filepath.WalkFunc
+		walk = func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				warnLog.Printf("scan: skipping %v", err)
+				// The listing misses the entry (or the folder's content)
+				delete(found, path)
+				delete(found, filepath.Dir(path))
+				return nil
+			}
+			if dir, ok := found[filepath.Dir(path)]; ok && path != basePath {
+				dir.Entries = append(dir.Entries, newScanDirEntry(info))
+			}
+
+			if info.IsDir() {
+				if shouldExclude(path, basePath, config.ExcludePatterns) {
+					return filepath.SkipDir
+				}
+				if path != basePath && ignores.ignored(path, true) {
+					debugLog.Printf("skip %s: %s", path, ignoreFileName)
+					return filepath.SkipDir
+				}
+				// Files in trash were already deduped, don't regroup them
+				if inTrash(path, basePath) {
+					return filepath.SkipDir
+				}
+				if inLibrary(path, basePath) {
+					debugLog.Printf("skip %s: library", path)
+					return filepath.SkipDir
+				}
+				// Don't descend beyond max depth
+				if config.MaxDepth > 0 && dirDepth(basePath, path) > config.MaxDepth {
+					return filepath.SkipDir
+				}
+				ignores.load(path)
+
+				// Unchanged since the last scan: its files as listed then (stat'ed again),
+				// its subfolders walked (and checked) one by one
+				if dir, ok := known[path]; ok && dir.trusted(info.ModTime()) {
+					listed++
+					for _, entry := range dir.Entries {
+						entryPath := filepath.Join(path, entry.Name)
+						if entry.Mode.IsDir() {
+							if err := filepath.Walk(entryPath, walk); err != nil {
+								return err
+							}
+							continue
+						}
+						if ignores.ignored(entryPath, false) {
+							debugLog.Printf("skip %s: %s", entryPath, ignoreFileName)
+							continue
+						}
+						// Sidecars are edited in place, which leaves the folder's mtime alone
+						if isSidecar(entryPath) {
+							if info, err := os.Stat(entryPath); err == nil {
+								addSidecar(entryPath, info)
+							}
+							continue
+						}
+						// Size and time are current only after a stat (edited in place, or gone)
+						info, err := os.Lstat(entryPath)
+						if err != nil {
+							debugLog.Printf("skip %s: listed by the last scan, now %v", entryPath, err)
+							continue
+						}
+						if !addFile(entryPath, info) {
+							return errScanLimit
+						}
+					}
+					return filepath.SkipDir
+				}
+				if cache != nil {
+					found[path] = &scanDir{ModTime: info.ModTime().UnixNano(), ScannedAt: time.Now().UnixNano()}
+				}
+				return nil
+			}
+
+			if ignores.ignored(path, false) {
+				debugLog.Printf("skip %s: %s", path, ignoreFileName)
+				return nil
+			}
+			if isSidecar(path) {
+				addSidecar(path, info)
+				return nil
+			}
+			if !addFile(path, info) {
+				return errScanLimit // Stop the whole walk, not just this directory
+			}
+			return nil
+		}
+		err := filepath.Walk(basePath, walk)
+
+		if errors.Is(err, errScanLimit) {
+			if limit > 0 && count >= limit {
+				infoLog.Printf("scan: stopped at the limit of %d files", limit)
+			} else {
+				infoLog.Printf("scan: stopped at the limit of %s (%d files, %s)", formatBytes(byteLimit), count, formatBytes(bytes))
+			}
+			complete = false
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A walk stopped at the limit left folders half listed
+	if cache != nil && complete && len(found) > 0 {
+		if err := cache.SaveScanDirs(found); err != nil {
+			warnLog.Printf("scan: could not cache folder listings: %v", err)
+		}
+	}
+	if listed > 0 {
+		infoLog.Printf("scan: %d folders unchanged since the last scan, listed from the cache", listed)
 	}
 
+	// A walk stopped at the limit may not have reached a file's sidecar yet
+	for _, mf := range files {
+		for _, key := range sidecarCandidates(mf.Path) {
+			if sidecar, ok := sidecars[key]; ok {
+				mf.Sidecar, mf.SidecarTime = sidecar.path, sidecar.modTime
+				break
+			}
+		}
+		if mf.Sidecar == "" && !complete {
+			mf.Sidecar, mf.SidecarTime = findSidecar(mf.Path)
+		}
+	}
+
+	infoLog.Printf("scan: %d media files (%d photos, %d videos, %d music)", len(files), photos, videos, music)
 	return files, nil
 }
 
-// ProcessMetadata extracts metadata from files in parallel
-func ProcessMetadata(files []*MediaFile, workers int, progressChan chan<- ScanProgress, cache *Cache) int {
+// scanFileList feeds paths listed one per line in listPath ("-" for stdin) to addFile,
+// reporting entries that are missing or not regular files
+func scanFileList(listPath string, addFile func(string, os.FileInfo) bool) error {
+	var r io.Reader = os.Stdin
+	if listPath != "-" {
+		f, err := os.Open(listPath)
+		if err != nil {
+			return fmt.Errorf("open file list: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	invalid := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		path, err := filepath.Abs(line)
+		if err != nil {
+			warnLog.Printf("file list: invalid path %s: %v", line, err)
+			invalid++
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			warnLog.Printf("file list: skipping %s: %v", line, err)
+			invalid++
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			warnLog.Printf("file list: skipping %s: not a regular file", line)
+			invalid++
+			continue
+		}
+
+		if !addFile(path, info) {
+			break // Limit reached
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read file list: %w", err)
+	}
+
+	if invalid > 0 {
+		warnLog.Printf("file list: %d entries were missing or invalid", invalid)
+	}
+	return nil
+}
+
+// ProcessMetadata extracts metadata from files in parallel. Cancelling ctx stops it
+// between files, leaving the rest without metadata; a Pauser in ctx holds it between files.
+func ProcessMetadata(ctx context.Context, files []*MediaFile, workers int, progressChan chan<- ScanProgress, cache *Cache) int {
 	var wg sync.WaitGroup
 	fileChan := make(chan *MediaFile, len(files))
 	cacheHits := 0
@@ -162,12 +615,19 @@ func ProcessMetadata(files []*MediaFile, workers int, progressChan chan<- ScanPr
 		go func() {
 			defer wg.Done()
 			for mf := range fileChan {
+				waitIfPaused(ctx)
+				if ctx.Err() != nil {
+					continue // Drain the queue
+				}
+
 				// Try cache first
 				cached := false
 				if cache != nil {
 					info, err := os.Stat(mf.Path)
 					if err == nil {
-						if cf, ok := cache.Get(mf.Path, mf.Size, info.ModTime()); ok {
+						// A sidecar edited since (e.g. new keywords) is read again; one gone
+						// since, e.g. left behind by a move, keeps what it said
+						if cf, ok := cache.Get(mf.Path, mf.Size, info.ModTime()); ok && (mf.Sidecar == "" || cf.SidecarTime == mf.SidecarTime.Unix()) {
 							// Use cached metadata
 							mf.DateTaken = cf.DateTaken
 							mf.CameraMake = cf.CameraMake
@@ -177,11 +637,31 @@ func ProcessMetadata(files []*MediaFile, workers int, progressChan chan<- ScanPr
 							mf.Title = cf.Title
 							mf.Width = cf.Width
 							mf.Height = cf.Height
+							mf.Orientation = cf.Orientation
+							mf.GPS = cf.GPS
+							mf.Duration = cf.Duration
+							mf.ImageClass = cf.ImageClass
+							mf.PHash = cf.PHash
+							mf.Keywords = cf.Keywords
 							mf.IsNew = false // File was in cache
 							cached = true
+							debugLog.Printf("metadata %s: cached", mf.Path)
 							mu.Lock()
 							cacheHits++
 							mu.Unlock()
+
+							// Entries cached before image classification or orientation existed
+							if mf.Type == TypePhoto && (mf.ImageClass == "" || mf.Orientation == 0) {
+								if mf.Orientation == 0 {
+									readOrientation(mf)
+								}
+								classifyImage(mf)
+								updated := *mf
+								updated.Hash = cf.Hash // Keep the cached hash
+								updated.HashAlgo = cf.HashAlgo
+								updated.ContentHash = cf.ContentHash
+								cache.Put(&updated, info.ModTime())
+							}
 						}
 					}
 				}
@@ -189,6 +669,7 @@ func ProcessMetadata(files []*MediaFile, workers int, progressChan chan<- ScanPr
 				// Extract if not cached
 				if !cached {
 					mf.IsNew = true // New file, not in cache
+					debugLog.Printf("metadata %s: not cached, extracting", mf.Path)
 					extractMetadata(mf)
 
 					// Store in cache (queued asynchronously)
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIncrementalScan(t *testing.T) {
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	unchanged := filepath.Join(scanPath, "unchanged")
+	touched := filepath.Join(scanPath, "touched")
+	for _, dir := range []string{unchanged, touched} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(unchanged, "a.jpg"), "a")
+	write(filepath.Join(unchanged, "b.jpg"), "b")
+	write(filepath.Join(touched, "c.jpg"), "c")
+
+	// Folder times well before the scan, outside scanDirRacyWindow
+	past := time.Now().Add(-time.Hour)
+	setDirTimes := func() {
+		t.Helper()
+		for _, dir := range []string{scanPath, unchanged, touched} {
+			if err := os.Chtimes(dir, past, past); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	setDirTimes()
+
+	config := &Config{
+		ScanPaths:       []string{scanPath},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		Workers:         2,
+	}
+	cache, err := OpenCache(config.LibraryBase, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	scan := func() map[string]*MediaFile {
+		t.Helper()
+		files, err := ScanMediaFiles(config, nil, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byName := make(map[string]*MediaFile)
+		for _, mf := range files {
+			byName[filepath.Base(mf.Path)] = mf
+		}
+		return byName
+	}
+	if got := scan(); len(got) != 3 {
+		t.Fatalf("first scan found %d files, want 3", len(got))
+	}
+
+	// A file added to each folder, then the unchanged folder's time put back: its listing
+	// from the cache is trusted, so the new file there is not seen (proving it wasn't read)
+	write(filepath.Join(unchanged, "new.jpg"), "new")
+	write(filepath.Join(touched, "new2.jpg"), "new2")
+	// Edited in place and deleted: noticed although the folder's time is unchanged
+	write(filepath.Join(unchanged, "a.jpg"), "edited")
+	if err := os.Remove(filepath.Join(unchanged, "b.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(unchanged, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	got := scan()
+	tests := []struct {
+		name  string
+		found bool
+		size  int64
+	}{
+		{"a.jpg", true, int64(len("edited"))},
+		{"b.jpg", false, 0},
+		{"c.jpg", true, 1},
+		{"new.jpg", false, 0}, // Unchanged folder listed from the cache
+		{"new2.jpg", true, 4}, // Touched folder read again
+	}
+	for _, tt := range tests {
+		mf, ok := got[tt.name]
+		if ok != tt.found {
+			t.Errorf("%s: found = %v, want %v", tt.name, ok, tt.found)
+			continue
+		}
+		if ok && mf.Size != tt.size {
+			t.Errorf("%s: size = %d, want %d", tt.name, mf.Size, tt.size)
+		}
+	}
+
+	// --full-scan reads every folder
+	config.FullScan = true
+	if _, ok := scan()["new.jpg"]; !ok {
+		t.Errorf("full scan missed new.jpg")
+	}
+}
+
+func TestScanSymlinks(t *testing.T) {
+	root := t.TempDir()
+	real := filepath.Join(root, "real")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(real, "trip"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{filepath.Join(real, "trip", "a.jpg"), filepath.Join(outside, "b.jpg")} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(real, "a-link.jpg"): filepath.Join(real, "trip", "a.jpg"), // Same file as a.jpg
+		filepath.Join(real, "b-link.jpg"): filepath.Join(outside, "b.jpg"),      // Outside the scan path
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{
+		ScanPaths:       []string{real},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		Workers:         2,
+		FullScan:        true,
+	}
+	files, err := ScanMediaFiles(config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, mf := range files {
+		paths = append(paths, mf.Path)
+	}
+	// Found once, the link outside skipped
+	if want := []string{filepath.Join(real, "trip", "a.jpg")}; !slices.Equal(paths, want) {
+		t.Errorf("scan found %v, want %v", paths, want)
+	}
+}
+
+func TestShouldExclude(t *testing.T) {
+	root := filepath.FromSlash("/media/scan")
+	tests := []struct {
+		path     string
+		patterns []string
+		want     bool
+	}{
+		// Component patterns match a whole folder or file name anywhere below the root
+		{"/media/scan/OFFICE/a.jpg", defaultExcludePatterns, true},
+		{"/media/scan/home/OFFICEPARTY/a.jpg", defaultExcludePatterns, false},
+		{"/media/scan/home/OFFICEPARTY/a.jpg", []string{"OFFICE*"}, true},
+		{"/media/scan/trip/Thumbnails/a.jpg", defaultExcludePatterns, true},
+		{"/media/scan/trip/MyThumbnails/a.jpg", defaultExcludePatterns, false},
+		{"/media/scan/Photos.photoslibrary/originals/a.jpg", []string{"*.photoslibrary"}, true},
+		{"/media/scan/trip/a.tmp.jpg", []string{"*.tmp.jpg"}, true},
+		// Only what is below the root is matched: the root's own path doesn't count
+		{"/media/scan/trip/a.jpg", []string{"media", "scan"}, false},
+		// Patterns with a slash are anchored at the root
+		{"/media/scan/Backups/old/a.jpg", []string{"Backups/old"}, true},
+		{"/media/scan/Backups/new/a.jpg", []string{"Backups/old"}, false},
+		{"/media/scan/trip/Backups/old/a.jpg", []string{"Backups/old"}, false},
+		{"/media/scan/Temp/a.jpg", []string{"/Temp"}, true},
+		{"/media/scan/trip/Temp/a.jpg", []string{"/Temp"}, false},
+		{"/media/scan/2019/raw/a.cr2", []string{"20??/raw/"}, true},
+		{"/media/scan/1999/raw/a.cr2", []string{"20??/raw/"}, false},
+		// Files outside the root (--files-from) only match component patterns
+		{"/elsewhere/Thumbnails/a.jpg", []string{"Thumbnails"}, true},
+		{"/elsewhere/Backups/old/a.jpg", []string{"Backups/old"}, false},
+		{"/media/scan", defaultExcludePatterns, false},
+	}
+	for _, tt := range tests {
+		if got := shouldExclude(filepath.FromSlash(tt.path), root, tt.patterns); got != tt.want {
+			t.Errorf("%s with %v: excluded = %v, want %v", tt.path, tt.patterns, got, tt.want)
+		}
+	}
+
+	if err := validateExcludePatterns([]string{"Backups/[old"}); err == nil {
+		t.Errorf("malformed pattern accepted")
+	}
+}
+
+func TestScanExcludePatterns(t *testing.T) {
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	for _, name := range []string{"home/OFFICEPARTY/party.jpg", "OFFICE/memo.jpg", "trip/Thumbnails/thumb.jpg", "trip/beach.jpg"} {
+		path := filepath.Join(scanPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{
+		ScanPaths:       []string{scanPath},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		ExcludePatterns: defaultExcludePatterns,
+		Workers:         1,
+	}
+	files, err := ScanMediaFiles(config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range files {
+		got = append(got, filepath.Base(mf.Path))
+	}
+	slices.Sort(got)
+	if want := []string{"beach.jpg", "party.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("scanned %v, want %v", got, want)
+	}
+}
+
+// writeFiles creates files below root (slash-separated relative paths), each holding its name
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// scannedPaths scans and returns the files found, relative to root and sorted
+func scannedPaths(t *testing.T, config *Config, root string) []string {
+	t.Helper()
+	files, err := ScanMediaFiles(config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, mf := range files {
+		rel, err := filepath.Rel(root, mf.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestScanNestedIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	writeFiles(t, scanPath,
+		"a.jpg", "a.png", "drafts/top.jpg", "drafts/x/y/deep.jpg", "drafts/notes.mp4",
+		"trip/beach.jpg", "trip/keep.png", "trip/other.png", "trip/raw/r.jpg",
+		"trip/Thumbnails/t.jpg",
+		"trip/sub/local.jpg", "trip/sub/deeper/local.jpg", "trip/sub/#1.jpg")
+
+	ignoreFiles := map[string]string{
+		".mediaignore":          "# Screenshots\n*.png\n\nraw/\ndrafts/**/*.jpg\n",
+		"trip/.mediaignore":     "!keep.png\n",            // Re-includes what the parent excluded
+		"trip/sub/.mediaignore": "/local.jpg\n\\#1.jpg\n", // Anchored to its own folder
+	}
+	for name, content := range ignoreFiles {
+		if err := os.WriteFile(filepath.Join(scanPath, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{
+		ScanPaths:       []string{scanPath},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		ExcludePatterns: []string{"Thumbnails"}, // Applies on top of the ignore files
+		Workers:         1,
+	}
+	want := []string{
+		"a.jpg",
+		"drafts/notes.mp4",
+		"trip/beach.jpg",
+		"trip/keep.png",
+		"trip/sub/deeper/local.jpg",
+	}
+	if got := scannedPaths(t, config, scanPath); !slices.Equal(got, want) {
+		t.Errorf("scanned %v, want %v", got, want)
+	}
+}
+
+func TestScanFileLimit(t *testing.T) {
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	var names []string
+	for _, dir := range []string{"a", "b", "c/nested"} {
+		for i := range 4 {
+			names = append(names, fmt.Sprintf("%s/%d.jpg", dir, i))
+		}
+	}
+	writeFiles(t, scanPath, names...)
+
+	for _, tt := range []struct{ limit, want int }{
+		{1, 1},
+		{4, 4}, // Exactly one folder's worth
+		{5, 5}, // Stops inside the second folder
+		{12, 12},
+		{50, 12}, // Fewer files than the limit
+	} {
+		config := &Config{
+			ScanPaths:       []string{scanPath},
+			LibraryBase:     filepath.Join(root, "library"),
+			DuplicatesTrash: filepath.Join(root, "trash"),
+			FileLimit:       tt.limit,
+			Workers:         1,
+		}
+		got := scannedPaths(t, config, scanPath)
+		if len(got) != tt.want {
+			t.Errorf("limit %d: %d files %v, want %d", tt.limit, len(got), got, tt.want)
+		}
+		if len(slices.Compact(slices.Clone(got))) != len(got) {
+			t.Errorf("limit %d: a file found twice in %v", tt.limit, got)
+		}
+	}
+}
+
+func TestScanByteLimit(t *testing.T) {
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	const size = 100
+	for _, dir := range []string{"a", "b", "c/nested"} {
+		for i := range 4 {
+			path := filepath.Join(scanPath, dir, fmt.Sprintf("%d.jpg", i))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		bytes int64
+		files int // FileLimit, 0 = none
+		want  int
+	}{
+		{size, 0, 1},
+		{size - 1, 0, 0}, // Not even the first file fits
+		{2*size + 50, 0, 2},
+		{4 * size, 0, 4}, // Exactly one folder's worth
+		{5 * size, 0, 5}, // Stops inside the second folder
+		{12 * size, 0, 12},
+		{100 * size, 0, 12}, // Fewer bytes than the cap
+		{10 * size, 3, 3},   // The file limit comes first
+		{3 * size, 10, 3},   // The byte cap comes first
+	} {
+		config := &Config{
+			ScanPaths:       []string{scanPath},
+			LibraryBase:     filepath.Join(root, "library"),
+			DuplicatesTrash: filepath.Join(root, "trash"),
+			ByteLimit:       tt.bytes,
+			FileLimit:       tt.files,
+			Workers:         1,
+		}
+		if !config.IsPartialScan() {
+			t.Errorf("byte cap %d: not a partial scan", tt.bytes)
+		}
+		got := scannedPaths(t, config, scanPath)
+		if len(got) != tt.want {
+			t.Errorf("byte cap %d, file limit %d: %d files %v, want %d", tt.bytes, tt.files, len(got), got, tt.want)
+		}
+		if total := int64(len(got)) * size; total > tt.bytes {
+			t.Errorf("byte cap %d: scanned %d bytes", tt.bytes, total)
+		}
+		if again := scannedPaths(t, config, scanPath); !slices.Equal(again, got) {
+			t.Errorf("byte cap %d: second scan found %v, first %v", tt.bytes, again, got)
+		}
+	}
+}
+
+func TestScanMultipleRootsTrashPerRoot(t *testing.T) {
+	root := t.TempDir()
+	photosA := filepath.Join(root, "A", "Photos")
+	photosB := filepath.Join(root, "B", "Photos") // Same folder name as the first root
+	old := filepath.Join(root, "Old")
+	writeFiles(t, photosA, "trip/x.jpg", "solo.jpg")
+	writeFiles(t, photosB, "trip/x.jpg")
+	writeFiles(t, old, "misc/x.jpg")
+	for _, path := range []string{filepath.Join(photosB, "trip", "x.jpg"), filepath.Join(old, "misc", "x.jpg")} {
+		if err := os.WriteFile(path, []byte("trip/x.jpg"), 0644); err != nil { // Duplicates of A's trip/x.jpg
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{
+		ScanPaths:       []string{photosA, photosB, old, photosA + string(filepath.Separator)},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		TrashRun:        newTrashRun(),
+		OrganizeMode:    OrganizeMove,
+		HashAlgorithm:   HashXXHash,
+		Workers:         1,
+		MoveWorkers:     1,
+	}
+	files, err := ScanMediaFiles(config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range files {
+		rel, _ := filepath.Rel(root, mf.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	slices.Sort(got)
+	if want := []string{"A/Photos/solo.jpg", "A/Photos/trip/x.jpg", "B/Photos/trip/x.jpg", "Old/misc/x.jpg"}; !slices.Equal(got, want) {
+		t.Fatalf("scanned %v, want %v (each file once)", got, want)
+	}
+
+	CalculateHashes(t.Context(), files, 1, config.HashAlgorithm, nil, nil)
+	duplicates := FindDuplicates(files, 0, &DuplicatePolicy{})
+	if len(duplicates) != 1 || len(duplicates[0].Files) != 3 {
+		t.Fatalf("duplicate groups = %v, want one of 3 files", duplicates)
+	}
+	result, err := ExecuteOrganization(t.Context(), nil, duplicates, config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each trashed copy keeps its path below its own root, under that root's name
+	rootNames := map[string]string{photosA: "Photos", photosB: "Photos-2", old: "Old"}
+	want := make(map[string]string)
+	for _, mf := range duplicates[0].Files {
+		if mf == duplicates[0].Best {
+			continue
+		}
+		scanRoot := scanRootOf(mf.Path, config.ScanPaths)
+		rel, _ := filepath.Rel(scanRoot, mf.Path)
+		want[filepath.Join(config.TrashRun, rootNames[filepath.Clean(scanRoot)], rel)] = "trip/x.jpg"
+	}
+	trash := treeOf(t, config.DuplicatesTrash)
+	if !maps.Equal(trash, want) {
+		t.Errorf("trash = %v, want %v", trash, want)
+	}
+	if result.Moved != 2 || result.Failed != 0 {
+		t.Errorf("Moved = %d, Failed = %d, want 2, 0", result.Moved, result.Failed)
+	}
+	if _, err := os.Stat(duplicates[0].Best.Path); err != nil {
+		t.Errorf("kept copy gone: %v", err)
+	}
+}
+
+func TestScanSkipsLibraryInScanPath(t *testing.T) {
+	scanPath := t.TempDir()
+	writeFiles(t, scanPath,
+		"trip/beach.jpg", "MediaLibrary/Photos/2019/2019-07 Trip/beach.jpg",
+		"Videos/2019/clip.mp4", ".duplicates-trash/run/trip/copy.jpg", "MediaLibrary-old/kept.jpg")
+
+	config := &Config{
+		ScanPaths:       []string{scanPath},
+		LibraryBase:     filepath.Join(scanPath, "MediaLibrary"),
+		VideosRoot:      filepath.Join(scanPath, "Videos"),
+		DuplicatesTrash: filepath.Join(scanPath, ".duplicates-trash"),
+		Workers:         1,
+	}
+	if !config.libraryInScan() {
+		t.Error("library inside the scan path not detected")
+	}
+	want := []string{"MediaLibrary-old/kept.jpg", "trip/beach.jpg"}
+	if got := scannedPaths(t, config, scanPath); !slices.Equal(got, want) {
+		t.Errorf("scanned %v, want %v (library, type root and trash skipped)", got, want)
+	}
+
+	// Scanning the library itself reorganizes it
+	config.ScanPaths = []string{config.LibraryBase}
+	if config.libraryInScan() {
+		t.Error("scanning the library reported as the library inside the scan path")
+	}
+	want = []string{"Photos/2019/2019-07 Trip/beach.jpg"}
+	if got := scannedPaths(t, config, config.LibraryBase); !slices.Equal(got, want) {
+		t.Errorf("scanning the library found %v, want %v", got, want)
+	}
+}
+
+func TestScanExtraExtensions(t *testing.T) {
+	// registerExtensions changes the package's extension sets: put them back afterwards
+	for _, m := range []map[string]bool{photoExtensions, videoExtensions, musicExtensions} {
+		saved := maps.Clone(m)
+		t.Cleanup(func() {
+			clear(m)
+			maps.Copy(m, saved)
+		})
+	}
+
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	writeFiles(t, scanPath, "trip/IMG_1.DNG", "trip/IMG_2.jpg", "trip/song.opus", "trip/memo.wav", "trip/clip.mp4")
+
+	// A conflicting registration is refused as a whole
+	if err := registerExtensions(map[MediaType][]string{TypePhoto: {"dng"}, TypeVideo: {".jpg"}}, nil); err == nil {
+		t.Error(".jpg registered as video as well as photo")
+	}
+	if err := registerExtensions(map[MediaType][]string{TypePhoto: {"dng"}}, []string{"DNG"}); err == nil {
+		t.Error(".dng both added and excluded")
+	}
+	if photoExtensions[".dng"] {
+		t.Fatal("a refused registration added .dng")
+	}
+
+	if err := registerExtensions(map[MediaType][]string{TypePhoto: {"DNG"}, TypeMusic: {".opus"}}, []string{"wav"}); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		ScanPaths:       []string{scanPath},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		Workers:         1,
+	}
+	files, err := ScanMediaFiles(config, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]MediaType)
+	for _, mf := range files {
+		got[filepath.Base(mf.Path)] = mf.Type
+	}
+	want := map[string]MediaType{"IMG_1.DNG": TypePhoto, "IMG_2.jpg": TypePhoto, "song.opus": TypeMusic, "clip.mp4": TypeVideo}
+	if !maps.Equal(got, want) {
+		t.Errorf("scanned %v, want %v (.wav excluded)", got, want)
+	}
+
+	// The registered photo is organized as one, in the folder's photo album
+	ProcessMetadata(t.Context(), files, 1, nil, nil)
+	albums, err := OrganizeIntoAlbums(t.Context(), files, &Config{LibraryBase: config.LibraryBase, NoDateFolder: defaultNoDateFolder, MinAlbumFiles: 2}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	organized := false
+	for _, album := range albums {
+		for _, mf := range album.Files {
+			if filepath.Base(mf.Path) != "IMG_1.DNG" {
+				continue
+			}
+			organized = true
+			if album.Type != TypePhoto || len(album.Files) != 2 {
+				t.Errorf("IMG_1.DNG in %s album %s of %d files, want the photo album of 2", album.Type, album.Name, len(album.Files))
+			}
+		}
+	}
+	if !organized {
+		t.Error("IMG_1.DNG in no album")
+	}
+}
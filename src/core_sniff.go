@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sniffLen is how much of a file content sniffing looks at (all http.DetectContentType reads)
+const sniffLen = 512
+
+// Content types from http.DetectContentType that are organized
+var sniffedTypes = map[string]MediaType{
+	"image/jpeg":      TypePhoto,
+	"image/png":       TypePhoto,
+	"image/gif":       TypePhoto,
+	"video/mp4":       TypeVideo,
+	"video/avi":       TypeVideo,
+	"video/webm":      TypeVideo, // Also Matroska (.mkv), same EBML header
+	"audio/mpeg":      TypeMusic,
+	"audio/wave":      TypeMusic,
+	"audio/ogg":       TypeMusic,
+	"application/ogg": TypeMusic,
+}
+
+// ISO base media brands (ftyp box) and what they hold; brands not listed count as video
+var ftypBrands = map[string]MediaType{
+	"heic": TypePhoto, "heix": TypePhoto, "heim": TypePhoto, "heis": TypePhoto,
+	"hevc": TypePhoto, "hevx": TypePhoto, "mif1": TypePhoto, "msf1": TypePhoto,
+	"avif": TypePhoto,
+	"M4A ": TypeMusic, "M4B ": TypeMusic, "M4P ": TypeMusic,
+}
+
+// sniffMediaType tells the media type from a file's first bytes: magic numbers checked by
+// http.DetectContentType, plus ISO media brands (HEIC, MOV, M4A), TIFF-based RAW, FLAC
+// and MPEG program streams. Returns TypeUnknown when the content isn't recognized.
+func sniffMediaType(path string) MediaType {
+	f, err := os.Open(path)
+	if err != nil {
+		return TypeUnknown
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return TypeUnknown
+	}
+	return sniffContent(head[:n])
+}
+
+// sniffContent classifies the first bytes of a file
+func sniffContent(head []byte) MediaType {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if mediaType, ok := ftypBrands[string(head[8:12])]; ok {
+			return mediaType
+		}
+		return TypeVideo // mp4, qt (MOV), 3gp, ...
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")),
+		bytes.HasPrefix(head, []byte("IIRO")), bytes.HasPrefix(head, []byte("IIU\x00")),
+		bytes.HasPrefix(head, []byte("FUJIFILMCCD-RAW")):
+		return TypePhoto // TIFF, and the RAW formats built on it (CR2, NEF, ARW, DNG, ORF, RW2, RAF)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return TypeMusic
+	case bytes.HasPrefix(head, []byte("\x00\x00\x01\xba")):
+		return TypeVideo // MPEG program stream (.mpg)
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if mediaType, ok := sniffedTypes[contentType]; ok {
+		return mediaType
+	}
+	return TypeUnknown
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanSniffsContent(t *testing.T) {
+	var jpgBuf, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpgBuf, testImage(0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngBuf, testImage(0)); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	scan := filepath.Join(root, "scan")
+	contents := map[string][]byte{
+		"renamed.dat":  jpgBuf.Bytes(), // A JPEG under an extension that isn't organized
+		"noext":        jpgBuf.Bytes(),
+		"actually.mp3": pngBuf.Bytes(), // A PNG under a music extension
+		"unknown.bin":  {0x00, 0x13, 0x37, 0xde, 0xad, 0xbe, 0xef, 0x42, 0x00, 0x01},
+		"notes.txt":    []byte("not media at all"),
+		"photo.jpg":    jpgBuf.Bytes(),
+	}
+	if err := os.MkdirAll(scan, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range contents {
+		if err := os.WriteFile(filepath.Join(scan, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sniff bool
+		want  map[string]MediaType
+	}{
+		{false, map[string]MediaType{"actually.mp3": TypeMusic, "photo.jpg": TypePhoto}},
+		{true, map[string]MediaType{"renamed.dat": TypePhoto, "noext": TypePhoto, "actually.mp3": TypePhoto, "photo.jpg": TypePhoto}},
+	}
+	for _, tt := range tests {
+		config := &Config{ScanPaths: []string{scan}, LibraryBase: filepath.Join(root, "library"), Workers: 2, Sniff: tt.sniff}
+		files, err := ScanMediaFiles(config, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]MediaType)
+		for _, mf := range files {
+			got[filepath.Base(mf.Path)] = mf.Type
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("sniff %v: found %v, want %v", tt.sniff, got, tt.want)
+			continue
+		}
+		for name, mediaType := range tt.want {
+			if got[name] != mediaType {
+				t.Errorf("sniff %v: %s is %v, want %v", tt.sniff, name, got[name], mediaType)
+			}
+		}
+	}
+}
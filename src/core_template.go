@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// templateToken matches a {token} in a folder template
+var templateToken = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// templateTokens expand folder template tokens for an album (mf is the album's first file)
+var templateTokens = map[string]func(mf *MediaFile, album *Album) string{
+	"type": func(mf *MediaFile, album *Album) string { return typeFolder(album.Type) },
+	"year": func(mf *MediaFile, album *Album) string {
+		if date := templateDate(mf, album); date != nil {
+			return date.Format("2006")
+		}
+		return ""
+	},
+	"month": func(mf *MediaFile, album *Album) string {
+		if date := templateDate(mf, album); date != nil {
+			return date.Format("01")
+		}
+		return ""
+	},
+	"album": func(mf *MediaFile, album *Album) string {
+		if album.Type == TypeMusic {
+			return musicAlbumOf(mf) // Album.Name is "Artist - Album" for music
+		}
+		return album.Name
+	},
+	"camera_make": func(mf *MediaFile, album *Album) string { return mf.CameraMake },
+	"artist":      func(mf *MediaFile, album *Album) string { return mf.Artist },
+}
+
+// typeFolder returns the top-level library folder for a media type
+func typeFolder(t MediaType) string {
+	switch t {
+	case TypeVideo:
+		return "Videos"
+	case TypeMusic:
+		return "Music"
+	default:
+		return "Photos"
+	}
+}
+
+// templateDate returns the album date, or the file's date for albums without one (music)
+func templateDate(mf *MediaFile, album *Album) *time.Time {
+	if album.Date != nil {
+		return album.Date
+	}
+	return mf.DateTaken
+}
+
+// validateTemplate checks that a folder template only uses known tokens and stays inside the library
+func validateTemplate(template string) error {
+	for _, match := range templateToken.FindAllStringSubmatch(template, -1) {
+		if _, ok := templateTokens[match[1]]; !ok {
+			return fmt.Errorf("unknown token {%s} (known: %s)", match[1], knownTemplateTokens())
+		}
+	}
+
+	rest := templateToken.ReplaceAllString(template, "")
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("unbalanced braces in %q", template)
+	}
+	if filepath.IsAbs(template) {
+		return fmt.Errorf("%q must be relative to the library", template)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(template), "/") {
+		if part == ".." {
+			return fmt.Errorf("%q must stay inside the library", template)
+		}
+	}
+	return nil
+}
+
+// knownTemplateTokens lists the supported tokens for error messages
+func knownTemplateTokens() string {
+	var names []string
+	for name := range templateTokens {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// renderTemplate expands a folder template (e.g. "{type}/{year}/{month}/{album}") into a
+// path relative to the library; empty values become "Unknown" and separators inside values are replaced
+func renderTemplate(template string, mf *MediaFile, album *Album) string {
+	rendered := templateToken.ReplaceAllStringFunc(template, func(token string) string {
+		value := sanitizeFolderName(templateTokens[token[1:len(token)-1]](mf, album))
+		if value == "" {
+			return "Unknown"
+		}
+		return value
+	})
+	return filepath.Clean(filepath.FromSlash(rendered))
+}
+
+// templateDestination returns where the folder template puts an album (mf is its first
+// file). A type with its own root is already named by that root, so "{type}" folder
+// levels are dropped there rather than giving e.g. Photos/Photos.
+func (c *Config) templateDestination(mf *MediaFile, album *Album) string {
+	template := c.FolderTemplate
+	if c.customTypeRoot(album.Type) != "" {
+		var parts []string
+		for _, part := range strings.Split(filepath.ToSlash(template), "/") {
+			if part != "{type}" {
+				parts = append(parts, part)
+			}
+		}
+		template = strings.Join(parts, "/")
+	}
+	return filepath.Join(c.typeBase(album.Type), renderTemplate(template, mf, album))
+}
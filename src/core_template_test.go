@@ -0,0 +1,88 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateDestinationWithTypeRoots(t *testing.T) {
+	date := time.Date(2023, 7, 14, 12, 0, 0, 0, time.UTC)
+	config := &Config{
+		LibraryBase: "/library",
+		VideosRoot:  "/big/Videos",
+	}
+	tests := []struct {
+		name     string
+		template string
+		typ      MediaType
+		want     string
+	}{
+		{"library keeps type level", "{type}/{year}/{album}", TypePhoto, "/library/Photos/2023/Trip"},
+		{"root drops type level", "{type}/{year}/{album}", TypeVideo, "/big/Videos/2023/Trip"},
+		{"root drops inner type level", "{year}/{type}/{album}", TypeVideo, "/big/Videos/2023/Trip"},
+		{"type inside a level is kept", "{year}/{type} {album}", TypeVideo, "/big/Videos/2023/Videos Trip"},
+	}
+	for _, tt := range tests {
+		config.FolderTemplate = tt.template
+		album := &Album{Name: "Trip", Type: tt.typ, Date: &date}
+		got := config.templateDestination(&MediaFile{}, album)
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("%s: %s → %s, want %s", tt.name, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	date := time.Date(2021, 3, 9, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		mf       *MediaFile
+		album    *Album
+		want     string
+	}{
+		{"dated", "{year}/{month}/{album}", &MediaFile{}, &Album{Name: "Trip", Date: &date}, "2021/03/Trip"},
+		{"file date for albums without one", "{year}/{album}", &MediaFile{DateTaken: &date}, &Album{Name: "Trip"}, "2021/Trip"},
+		{"no date", "{year}/{month}/{album}", &MediaFile{}, &Album{Name: "Trip"}, "Unknown/Unknown/Trip"},
+		{"empty value", "{camera_make}/{album}", &MediaFile{}, &Album{Name: "Trip"}, "Unknown/Trip"},
+		{"separators replaced", "{artist}/{album}", &MediaFile{Artist: "AC/DC", Album: `Live: 1991\92`, Path: "/m/a.mp3"},
+			&Album{Type: TypeMusic}, `AC-DC/Live- 1991-92`},
+		{"control characters dropped", "{album}", &MediaFile{}, &Album{Name: "Beach\x00\tDay"}, "BeachDay"},
+		{"dot names can't climb", "{artist}/{album}", &MediaFile{Artist: "..", Album: "...", Path: "/m/a.mp3"},
+			&Album{Type: TypeMusic}, "Unknown/Unknown"},
+		{"literal text kept", "By year/{year} {album}", &MediaFile{}, &Album{Name: "Trip", Date: &date}, "By year/2021 Trip"},
+	}
+	for _, tt := range tests {
+		got := renderTemplate(tt.template, tt.mf, tt.album)
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("%s: %s → %q, want %q", tt.name, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  string // Substring of the error, empty for valid
+	}{
+		{"{type}/{year}/{month}/{album}", ""},
+		{"Music/{artist}/{album}", ""},
+		{"{year}/{camera_make}", ""},
+		{"{year}/{location}", "unknown token {location}"},
+		{"{Year}/{album}", "unbalanced braces"},
+		{"{year/{album}", "unbalanced braces"},
+		{"/abs/{year}", "relative to the library"},
+		{"{year}/../{album}", "inside the library"},
+	}
+	for _, tt := range tests {
+		err := validateTemplate(tt.template)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.template, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %q", tt.template, err, tt.wantErr)
+		}
+	}
+}
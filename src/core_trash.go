@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trashRunLayout names the quarantine folder each execution trashes its duplicates
+// into (local time), so emptying the trash can tell how long files have been there.
+// The milliseconds keep watch batches within one second apart.
+const trashRunLayout = "2006-01-02_150405.000"
+
+// trashRunParseLayout reads run folder names, with or without the milliseconds (older
+// versions named runs by the second)
+const trashRunParseLayout = "2006-01-02_150405"
+
+// lastTrashRun is the time of the last run folder named, so that no two share one
+var lastTrashRun struct {
+	sync.Mutex
+	time time.Time
+}
+
+// newTrashRun returns the quarantine folder name for an execution starting now
+func newTrashRun() string {
+	lastTrashRun.Lock()
+	defer lastTrashRun.Unlock()
+	now := time.Now().Truncate(time.Millisecond)
+	if !now.After(lastTrashRun.time) {
+		now = lastTrashRun.time.Add(time.Millisecond)
+	}
+	lastTrashRun.time = now
+	return now.Format(trashRunLayout)
+}
+
+// EmptyTrashResult summarizes what EmptyTrash deleted (or would delete)
+type EmptyTrashResult struct {
+	Runs  []string // Quarantine folders past the cutoff, oldest first
+	Files int
+	Bytes int64
+	Kept  int // Quarantine folders younger than the cutoff
+}
+
+// EmptyTrash permanently deletes the quarantine folders in trashDir whose run time is
+// before cutoff. Anything not named like a run (e.g. duplicates trashed by older
+// versions) is left alone. With dryRun, only reports what would be deleted.
+func EmptyTrash(trashDir string, cutoff time.Time, dryRun bool) (*EmptyTrashResult, error) {
+	result := &EmptyTrashResult{}
+	entries, err := os.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		runTime, err := time.ParseInLocation(trashRunParseLayout, entry.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+		if !runTime.Before(cutoff) {
+			result.Kept++
+			continue
+		}
+
+		dir := filepath.Join(trashDir, entry.Name())
+		err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					result.Files++
+					result.Bytes += info.Size()
+				}
+			}
+			return nil
+		})
+		if err == nil && !dryRun {
+			err = os.RemoveAll(dir)
+		}
+		if err != nil {
+			return result, fmt.Errorf("empty %s: %w", dir, err)
+		}
+		result.Runs = append(result.Runs, entry.Name())
+	}
+
+	sort.Strings(result.Runs) // The layout sorts chronologically
+	return result, nil
+}
+
+// parseTrashAge reads the age argument of --empty-trash: "older-than=30d" or just "30d".
+// Besides whole days (d) and weeks (w), Go durations such as "36h" are accepted.
+func parseTrashAge(s string) (time.Duration, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(s), "older-than=")
+	var age time.Duration
+	var err error
+	if n, ok := strings.CutSuffix(value, "d"); ok {
+		var days int
+		days, err = strconv.Atoi(n)
+		age = time.Duration(days) * 24 * time.Hour
+	} else if n, ok := strings.CutSuffix(value, "w"); ok {
+		var weeks int
+		weeks, err = strconv.Atoi(n)
+		age = time.Duration(weeks) * 7 * 24 * time.Hour
+	} else {
+		age, err = time.ParseDuration(value)
+	}
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. older-than=30d, 2w or 36h)", s)
+	}
+	return age, nil
+}
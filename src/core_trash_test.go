@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewTrashRunUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		run := newTrashRun()
+		if seen[run] {
+			t.Fatalf("run folder %s named twice", run)
+		}
+		seen[run] = true
+		if _, err := time.ParseInLocation(trashRunParseLayout, run, time.Local); err != nil {
+			t.Fatalf("run folder %s can't be parsed: %v", run, err)
+		}
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	trashDir := t.TempDir()
+	cutoff := time.Date(2024, 6, 1, 9, 30, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		deleted bool
+	}{
+		{"2024-05-01_120000", true},      // Named by the second (older versions)
+		{"2024-06-01_092959.999", true},  // Named by the millisecond
+		{"2024-06-01_093000.000", false}, // At the cutoff
+		{"2024-06-02_080000", false},
+		{"photos", false}, // Not a run
+	}
+	for _, tt := range tests {
+		if err := os.MkdirAll(filepath.Join(trashDir, tt.name, "a"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(trashDir, tt.name, "a", "dup.jpg"), []byte("dup"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := EmptyTrash(trashDir, cutoff, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRuns := []string{"2024-05-01_120000", "2024-06-01_092959.999"}
+	if !slices.Equal(result.Runs, wantRuns) || result.Files != 2 || result.Kept != 2 {
+		t.Errorf("EmptyTrash = runs %v, %d files, %d kept, want %v, 2 files, 2 kept", result.Runs, result.Files, result.Kept, wantRuns)
+	}
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(trashDir, tt.name))
+		if deleted := os.IsNotExist(err); deleted != tt.deleted {
+			t.Errorf("%s: deleted = %v, want %v", tt.name, deleted, tt.deleted)
+		}
+	}
+}
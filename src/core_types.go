@@ -1,6 +1,8 @@
 package main
 
 import (
+	"path/filepath"
+	"slices"
 	"time"
 )
 
@@ -22,7 +24,9 @@ func (mt MediaType) String() string {
 type MediaFile struct {
 	Path         string
 	Size         int64
+	ModTime      time.Time // When last modified at scan time (files changed since are left in place)
 	Hash         string
+	HashAlgo     string // Algorithm that produced Hash (HashXXHash, HashMD5, HashSHA256)
 	Type         MediaType
 	DateTaken    *time.Time
 	CameraMake   string
@@ -32,9 +36,24 @@ type MediaFile struct {
 	Title        string
 	Width        int
 	Height       int
+	Orientation  int           // EXIF orientation, photos only (1-8, 0 = not read yet); Width/Height are as displayed
+	GPS          *GPSCoord     // nil if no location in metadata
+	Duration     time.Duration // Video/music length (0 if unknown)
+	ImageClass   string        // Photos only: ImageClassPhoto, ImageClassScreenshot, ... ("" = not classified yet)
+	PHash        string        // Perceptual hash (dHash, hex) for near-duplicate detection, "" if not computed
+	ContentHash  string        // Hash of the image data without metadata (JPEG/PNG, Config.ContentHash), "" if not computed
+	Keywords     []string      // Keywords (dc:subject) from the XMP sidecar
+	Sidecar      string        // XMP sidecar of the file, "" if it has none
+	SidecarTime  time.Time     // Sidecar's modification time at scan time
 	IsNew        bool // True if not in cache (needs processing)
 }
 
+// GPSCoord is a location in decimal degrees
+type GPSCoord struct {
+	Lat float64
+	Lon float64
+}
+
 // Album represents a collection of media files
 type Album struct {
 	Name        string
@@ -43,6 +62,11 @@ type Album struct {
 	SourceDirs  []string
 	Date        *time.Time
 	Type        MediaType
+	Keep        bool // Curated folder (has keep marker), never split, merged or renamed
+	Location    bool // Grouped by place and month (GroupByLocation) rather than by folder
+	Event       bool // One of the events a folder was split into (GroupByEvent), named by its dates
+	Part        int  // Part number of an album split at MaxAlbumFiles (0 = not split)
+	Subfolders  bool // Files keep their path below their SourceDirs entry (KeepSubfolders)
 }
 
 // DuplicateGroup represents a group of duplicate files
@@ -60,16 +84,315 @@ type ScanProgress struct {
 	VideosFound   int
 	MusicFound    int
 	CurrentFile   string
+
+	// Sizes of the files, for phases where reading or copying dominates (hashing,
+	// execution); TotalBytes is 0 in phases that only count files
+	TotalBytes     int64
+	ProcessedBytes int64
+}
+
+// Fraction returns how far the phase is (0-1), by bytes when it reports them
+func (p ScanProgress) Fraction() float64 {
+	if p.TotalBytes > 0 {
+		return float64(p.ProcessedBytes) / float64(p.TotalBytes)
+	}
+	if p.TotalFiles > 0 {
+		return float64(p.ProcessedFiles) / float64(p.TotalFiles)
+	}
+	return 0
 }
 
+// Library layouts for the date part of photo/video destinations
+const (
+	LayoutYear          = "year"       // Photos/2021/Album
+	LayoutYearMonth     = "year/month" // Photos/2021/10/Album
+	LayoutYearDashMonth = "year-month" // Photos/2021-10/Album
+)
+
+// Handling of directories containing both photos and videos
+const (
+	MixedDirsSplit    = "split"    // Photos and videos become separate albums with the same name
+	MixedDirsMajority = "majority" // Whole directory goes to the tree of its most common type
+)
+
+// Album naming strategies
+const (
+	AlbumNamingOllama = "ollama" // Ollama suggestion, folder name fallback
+	AlbumNamingGPS    = "gps"    // Dominant month + nearest place from GPS, then Ollama/folder name
+)
+
+// Backends for album name suggestions (AlbumNamer)
+const (
+	NamingOllama = "ollama" // Local Ollama (default)
+	NamingOpenAI = "openai" // OpenAI-compatible chat completions API
+)
+
+// How photos and videos are grouped into albums
+const (
+	GroupByFolder   = "folder"   // One album per source directory
+	GroupByLocation = "location" // Geotagged files by place and month, others by directory
+	GroupByEvent    = "event"    // Directories split where shots are more than EventGap apart
+)
+
+// Orderings of files within an album
+const (
+	AlbumSortDate = "date" // Chronological by DateTaken, then name
+	AlbumSortName = "name" // By file name
+)
+
+// Kinds of image told apart by classifyImage
+const (
+	ImageClassPhoto      = "photo"      // Camera photograph
+	ImageClassScreenshot = "screenshot" // Screen capture
+	ImageClassAnimation  = "animation"  // Animated GIF
+	ImageClassGraphic    = "graphic"    // Other non-photographic image (static GIF)
+)
+
+// Where non-photographic images (screenshots, animations, graphics) go
+const (
+	NonPhotosSeparate = "separate" // Own folders per kind and year (Screenshots/2021)
+	NonPhotosAlbums   = "albums"   // Into the folder's album like any photo
+)
+
+// How files are placed in the library
+const (
+	OrganizeMove     = "move"     // Move the original (default)
+	OrganizeCopy     = "copy"     // Copy, originals stay untouched
+	OrganizeHardlink = "hardlink" // Hardlink to the original (copy across filesystems)
+	OrganizeSymlink  = "symlink"  // Symlink to the original's absolute path
+)
+
+// Hash algorithms for duplicate detection
+const (
+	HashXXHash = "xxhash" // XXH64, fastest (default)
+	HashMD5    = "md5"
+	HashSHA256 = "sha256"
+)
+
+// Which file of a duplicate group is kept
+const (
+	DuplicateKeepBestScore    = "best-score"    // Highest score from rules, size and metadata (default)
+	DuplicateKeepOldest       = "oldest"        // Earliest date taken, then modification time
+	DuplicateKeepNewest       = "newest"        // Latest date taken, then modification time
+	DuplicateKeepLargest      = "largest"       // Biggest file
+	DuplicateKeepShortestPath = "shortest-path" // Fewest characters in the path
+)
+
+// What to do when a file with identical content already exists at the destination
+const (
+	DestExistsSkip     = "skip"      // Leave the source where it is
+	DestExistsReplace  = "replace"   // Move the source over the existing file
+	DestExistsKeepBoth = "keep-both" // Keep both, renaming the new one (name_1.jpg)
+)
+
+// What to do when a destination name is taken, identical content or not (on_conflict,
+// which sets DestExists)
+const (
+	ConflictRename        = "rename"            // Always rename the new file (name_1.jpg)
+	ConflictSkipIdentical = "skip-if-identical" // Skip identical files, trashing the source when moving; rename others
+	ConflictOverwrite     = "overwrite"         // Replace the existing file, trashing it if it differs
+)
+
+// Output formats of the CLI (--no-tui)
+const (
+	OutputText = "text" // Human-readable progress and summaries (default)
+	OutputJSON = "json" // Newline-delimited JSON events, one per phase
+)
+
 // Config holds application configuration
 type Config struct {
-	ScanPath        string
+	ScanPaths       []string // Roots to scan (absolute), merged into one library
+	FilesFrom       string   // Read file paths from this list ("-" = stdin) instead of walking ScanPaths
+	ReportPath      string   // Write the plan to this .json or .csv file (optional)
+	DupReportPath   string   // Write the duplicate groups to this .json or .csv file (optional)
+	Output          string   // CLI output format (OutputText, OutputJSON)
 	LibraryBase     string
+	PhotosRoot      string // Photo albums go here instead of LibraryBase/Photos ("" = default)
+	VideosRoot      string // Likewise for videos, e.g. on a larger drive
+	MusicRoot       string // Likewise for music
 	DuplicatesTrash string
+	TrashRun        string // Quarantine folder of this run's duplicates in DuplicatesTrash (trashRunLayout)
+	CachePath       string // Cache database file, may be shared by libraries ("" = in the library)
 	OllamaModel     string
+	NamingProvider  string        // Album name suggestion backend (NamingOllama, NamingOpenAI)
+	OpenAIBaseURL   string        // OpenAI-compatible API base URL ("" = defaultOpenAIBaseURL)
+	OpenAIModel     string        // Model for NamingOpenAI
+	VisionModel     string        // Multimodal Ollama model shown sample photos ("" = names from paths only)
+	NamingTimeout   time.Duration // Limit for one album name suggestion (0 = defaultNamingTimeout)
+	NamingRetries   int           // Retries of transient naming failures (5xx, dropped connection)
+	NamingWorkers   int           // Parallel album name suggestions (default 1)
+	MetadataCommand string        // External metadata provider command (optional)
+	PostCommand     string        // Command run after a successful execution (optional)
+	PostTimeout     time.Duration
+	EventGap        time.Duration
+	// Extensions recognized on top of the built-in ones, by type, and built-in ones ignored
+	ExtraExtensions map[MediaType][]string
+	ExcludeExts     []string
+	Layout          string   // Date folder layout (LayoutYear, LayoutYearMonth, LayoutYearDashMonth)
+	FolderTemplate  string   // Album folder template, e.g. "{type}/{year}/{album}" (overrides Layout)
+	MixedDirs       string   // Mixed photo/video directory handling (MixedDirsSplit, MixedDirsMajority)
+	AlbumNaming     string   // Album naming strategy (AlbumNamingOllama, AlbumNamingGPS)
+	GroupBy         string   // Album grouping (GroupByFolder, GroupByLocation, GroupByEvent)
+	PlacesFile      string   // CSV of "name,lat,lon" used for offline reverse geocoding
+	ExcludePatterns []string // Glob patterns of paths to skip, relative to their scan path
+	AlbumSort       string   // File order within albums (AlbumSortDate, AlbumSortName)
+	NonPhotos       string   // Screenshots, animations and graphics (NonPhotosSeparate, NonPhotosAlbums)
+	MinAlbumFiles   int      // Folders with fewer media files go to Misc/<year> (default 3)
+	NoDateFolder    string   // Files without any date go here, relative to the library (default Unsorted/NoDate)
+	MaxAlbumFiles   int      // Larger albums are split into parts of this many files (0 = no limit)
+	MergeDepth      int      // Folders join the album of their ancestor this many levels up (0 = album per folder)
+	KeepSubfolders  bool     // Merged folders keep their path below the ancestor inside the album
+	OrganizeMode    string   // How files are placed in the library (OrganizeMove, OrganizeCopy, ...)
 	DryRun          bool
+	Simulate        bool            // Execute with empty placeholder files, leaving sources untouched
+	LinkBack        bool            // Leave a hardlink at each source path after moving into the library
+	Verify          bool            // Re-hash copies and compare them with their source before deleting it
+	Resume          bool            // Skip operations finished by the interrupted last execution, appending to its journal
+	DedupThreshold  int64           // Duplicates smaller than this many bytes are ignored
+	DuplicatePolicy DuplicatePolicy // Which file of a duplicate group is kept
+	HashAlgorithm   string          // Content hash for duplicate detection (HashXXHash, HashMD5, HashSHA256)
+	NearDupBits     int             // Max perceptual hash distance for near-duplicate photos (0 = off)
+	DedupLibrary    bool            // Also trash scanned files identical to one already in the library
+	ContentHash     bool            // Also treat JPEGs/PNGs with the same image data as duplicates (metadata ignored)
+	DestExists      string          // Identical file at destination (DestExistsSkip, DestExistsReplace, DestExistsKeepBoth)
+	OnConflict      string          // Destination name taken (ConflictRename, ...), "" = DestExists alone
 	FileLimit       int
+	ByteLimit       int64 // Stop scanning before the files found add up to more than this (0 = no limit)
+	MaxDepth        int   // Max directory depth below each scan path (0 = unlimited)
 	Workers         int
+	MoveWorkers     int // Parallel moves during execution (separate from scan workers)
 	PruneCache      bool
+	FullScan        bool     // Read every folder, even those unchanged since the last scan
+	ThreadsSQLite   bool     // Separate read-only connection pool for cache reads
+	Sniff           bool     // Tell media types from file content, not only the extension
+	PreferSidecar   bool     // XMP sidecar dates win over embedded ones
+	FilenameDates   []string // Regexps reading dates from file names (nil = defaultFilenameDatePatterns)
+	AssumeYes       bool     // Accept the plan without prompting (CLI)
+	MaxMoves        int      // Refuse plans with more file operations than this (0 = no limit)
+	Force           bool     // Ignore MaxMoves
+}
+
+// ExecutionResult summarizes what ExecuteOrganization did
+type ExecutionResult struct {
+	Moved        int // Files moved into the library or trash
+	Failed       int
+	VerifyFailed int      // Failed files whose copy didn't match the source (--verify; source kept)
+	Skipped      int      // Identical file already at destination
+	Resumed      int      // Already placed by the interrupted run continued with --resume
+	LinkFailed   int      // Link-back hardlinks that could not be created
+	Changed      []string // Files modified since they were scanned, left in place
+	Errors       []error  // Why each Failed file and LinkFailed link failed
+	Cancelled    bool     // Stopped before every file was processed, the rest were left in place
+}
+
+// verifyAlgorithm is the hash copies are verified with ("" when --verify is off)
+func (c *Config) verifyAlgorithm() string {
+	if !c.Verify {
+		return ""
+	}
+	return c.HashAlgorithm
+}
+
+// IsPartialScan reports whether the scan covers only part of the scan paths
+// (cache entries missing from such a scan may still exist on disk)
+func (c *Config) IsPartialScan() bool {
+	return c.FileLimit > 0 || c.ByteLimit > 0 || c.MaxDepth > 0 || c.FilesFrom != ""
+}
+
+// typeRoot is the folder a media type's albums go to: its own root if configured,
+// otherwise its folder in the library (LibraryBase/Photos, ...)
+func (c *Config) typeRoot(t MediaType) string {
+	if root := c.customTypeRoot(t); root != "" {
+		return root
+	}
+	return filepath.Join(c.LibraryBase, typeFolder(t))
+}
+
+// typeBase is what a media type's files go below outside the album trees (a folder
+// template, Misc, Screenshots, Unsorted/NoDate): its own root if configured, otherwise
+// the library
+func (c *Config) typeBase(t MediaType) string {
+	if root := c.customTypeRoot(t); root != "" {
+		return root
+	}
+	return c.LibraryBase
+}
+
+func (c *Config) customTypeRoot(t MediaType) string {
+	switch t {
+	case TypeVideo:
+		return c.VideosRoot
+	case TypeMusic:
+		return c.MusicRoot
+	default:
+		return c.PhotosRoot
+	}
+}
+
+// libraryRoots are the folders organized files live in: the library and the type roots
+// outside it
+func (c *Config) libraryRoots() []string {
+	roots := []string{c.LibraryBase}
+	for _, root := range []string{c.PhotosRoot, c.VideosRoot, c.MusicRoot} {
+		if root != "" && !isWithinDir(root, c.LibraryBase) && !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// libraryRootOf returns the library root a path lies in, "" if it is outside the library
+func (c *Config) libraryRootOf(path string) string {
+	for _, root := range c.libraryRoots() {
+		if isWithinDir(path, root) {
+			return root
+		}
+	}
+	return ""
+}
+
+// inLibrary reports whether a path lies in the library (or a type root)
+func (c *Config) inLibrary(path string) bool {
+	return c.libraryRootOf(path) != ""
+}
+
+// libraryInScan reports whether the library lies below a scan path, which the scan then
+// skips (scan the library itself, e.g. --path <library>, to reorganize it)
+func (c *Config) libraryInScan() bool {
+	for _, root := range c.ScanPaths {
+		for _, library := range c.libraryRoots() {
+			if isWithinDir(library, root) && !isWithinDir(root, library) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pruneKeep are the folders whose cache entries pruning leaves alone: the library roots
+// the scan doesn't read (their entries let the next scans and --dedup-library recognize
+// files already organized)
+func (c *Config) pruneKeep() []string {
+	var keep []string
+	for _, library := range c.libraryRoots() {
+		scanned := false
+		for _, root := range c.ScanPaths {
+			if isWithinDir(root, library) {
+				scanned = true
+			}
+		}
+		if !scanned {
+			keep = append(keep, library)
+		}
+	}
+	return keep
+}
+
+// pruneRoots limits cache pruning to the scan paths when the cache may be shared with
+// other libraries (nil = prune anything not found by the scan)
+func (c *Config) pruneRoots() []string {
+	if c.CachePath == "" {
+		return nil
+	}
+	return c.ScanPaths
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+)
+
+// fileStamp tells whether a file changed between two polls
+type fileStamp struct {
+	size    int64
+	modTime int64 // UnixNano
+}
+
+func stampOf(file *MediaFile) fileStamp {
+	return fileStamp{size: file.Size, modTime: file.ModTime.UnixNano()}
+}
+
+// Watcher polls the scan paths (--watch) and hands out media files in batches. A batch
+// is only handed out once a whole poll interval passed without new or growing files,
+// so files still being copied wait, and a card dumped into the import folder is
+// organized as one batch (its folders become albums, not Misc leftovers).
+type Watcher struct {
+	config  *Config
+	last    map[string]fileStamp // What the previous poll found
+	handled map[string]fileStamp // Files already organized (or planned, in a dry run)
+}
+
+func NewWatcher(config *Config) *Watcher {
+	return &Watcher{
+		config:  config,
+		last:    make(map[string]fileStamp),
+		handled: make(map[string]fileStamp),
+	}
+}
+
+// Poll scans the scan paths once and returns the files ready to organize: none while
+// anything appeared or changed since the previous poll, otherwise every file not handled
+// yet. Files that disappear (e.g. moved into the library) don't hold a batch back.
+func (w *Watcher) Poll() ([]*MediaFile, error) {
+	// Always read every folder: a file growing in place leaves its folder's mtime alone
+	files, err := ScanMediaFiles(w.config, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	quiet := true
+	current := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		stamp := stampOf(file)
+		current[file.Path] = stamp
+		if last, ok := w.last[file.Path]; !ok || last != stamp {
+			quiet = false
+		}
+	}
+	w.last = current
+	for path := range w.handled {
+		if _, ok := current[path]; !ok {
+			delete(w.handled, path)
+		}
+	}
+	if !quiet {
+		debugLog.Printf("watch: files still arriving, waiting for the next poll")
+		return nil, nil
+	}
+
+	var ready []*MediaFile
+	for _, file := range files {
+		if stamp, ok := w.handled[file.Path]; !ok || stamp != current[file.Path] {
+			ready = append(ready, file)
+		}
+	}
+	return ready, nil
+}
+
+// Done marks files as handled, so later polls skip them until they change. Files that
+// failed to move are included: they are retried once they change, not on every poll.
+func (w *Watcher) Done(files []*MediaFile) {
+	for _, file := range files {
+		w.handled[file.Path] = stampOf(file)
+	}
+}
+
+// PlanBatch runs a batch of files through the same steps as a full run (metadata,
+// hashes, duplicates, albums), reusing the cache for files seen before
+func PlanBatch(ctx context.Context, files []*MediaFile, config *Config, cache *Cache) ([]*Album, []*DuplicateGroup, error) {
+	ProcessMetadata(ctx, files, config.Workers, nil, cache)
+	CalculateHashes(ctx, files, config.Workers, config.HashAlgorithm, nil, cache)
+	if config.ContentHash {
+		CalculateContentHashes(ctx, files, config.Workers, nil, cache)
+	}
+	duplicates := FindDuplicates(files, config.DedupThreshold, &config.DuplicatePolicy)
+	if config.DedupLibrary {
+		duplicates = AddLibraryDuplicates(ctx, duplicates, files, config, cache)
+		files = withoutLibraryDuplicates(files, duplicates, config)
+	}
+
+	var albumCache *AlbumSuggestionCache
+	if cache != nil {
+		albumCache, _ = OpenAlbumSuggestionCache(cache)
+	}
+	albums, err := OrganizeIntoAlbums(ctx, files, config, nil, albumCache)
+	if err != nil {
+		return nil, nil, err
+	}
+	return albums, duplicates, nil
+}
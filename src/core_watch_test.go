@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWatchProcessesNewFiles(t *testing.T) {
+	root := t.TempDir()
+	scanPath := filepath.Join(root, "scan")
+	if err := os.Mkdir(scanPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		ScanPaths:       []string{scanPath},
+		LibraryBase:     filepath.Join(root, "library"),
+		DuplicatesTrash: filepath.Join(root, "trash"),
+		NoDateFolder:    defaultNoDateFolder,
+		MinAlbumFiles:   defaultMinAlbumFiles,
+		OrganizeMode:    OrganizeMove,
+		DestExists:      DestExistsSkip,
+		HashAlgorithm:   HashXXHash,
+		AssumeYes:       true,
+		Workers:         1,
+		MoveWorkers:     1,
+	}
+	watcher := NewWatcher(config)
+
+	// poll runs one watch cycle, organizing the batch handed out, and returns its file names
+	poll := func() []string {
+		t.Helper()
+		files, err := watcher.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, mf := range files {
+			names = append(names, filepath.Base(mf.Path))
+		}
+		slices.Sort(names)
+		if len(files) > 0 {
+			watchBatch(t.Context(), files, config, nil)
+			watcher.Done(files)
+		}
+		return names
+	}
+
+	if got := poll(); got != nil {
+		t.Fatalf("empty scan path handed out %v", got)
+	}
+	writeFiles(t, scanPath, "trip/a.jpg", "trip/b.jpg", "trip/c.jpg")
+	info, err := os.Stat(filepath.Join(scanPath, "trip", "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := poll(); got != nil {
+		t.Errorf("files handed out %v while arriving, want them held for a quiet poll", got)
+	}
+	if got, want := poll(), []string{"a.jpg", "b.jpg", "c.jpg"}; !slices.Equal(got, want) {
+		t.Fatalf("quiet poll handed out %v, want %v", got, want)
+	}
+
+	month := info.ModTime().Format("2006-01") // Undated files go by their file time
+	album := filepath.Join(config.LibraryBase, "Photos", month[:4], month+" trip")
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		if _, err := os.Stat(filepath.Join(album, name)); err != nil {
+			t.Errorf("%s not organized: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(scanPath, "trip", name)); !os.IsNotExist(err) {
+			t.Errorf("%s still in the watched folder", name)
+		}
+	}
+	if got := poll(); got != nil {
+		t.Errorf("poll after the batch handed out %v again", got)
+	}
+}
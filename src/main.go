@@ -1,12 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -21,15 +30,79 @@ func main() {
 	// Define all flags
 	var (
 		reconfigure = flag.Bool("reconfigure", false, "Re-run setup wizard to change configuration")
-		scanPath    = flag.String("path", "", "Path to scan for media files (overrides config)")
+		initConfig  = flag.Bool("init", false, "Write the config from --path, --library, --workers and --mode plus defaults, without the setup wizard")
 		libraryBase = flag.String("library", "", "Base path for organized library (overrides config)")
 		dryRun      = flag.Bool("dry-run", true, "Dry run mode (no actual changes)")
 		fileLimit   = flag.Int("limit", 0, "Limit number of files to process (0 = no limit)")
+		limitBytes  = flag.String("limit-bytes", "", "Stop scanning before the files found add up to more than this size, e.g. 5GB (testing)")
+		maxDepth    = flag.Int("max-depth", 0, "Max directory depth to scan below scan path (0 = no limit)")
 		workers     = flag.Int("workers", 0, "Number of parallel workers (overrides config)")
+		moveWorkers = flag.Int("move-workers", 0, "Number of parallel moves during execution (overrides config, default 1)")
 		pruneCache  = flag.Bool("prune-cache", false, "Prune deleted files from cache (auto if no --limit)")
+		fullScan    = flag.Bool("full-scan", false, "Read every folder, instead of listing those unchanged since the last scan from the cache")
+		cachePath   = flag.String("cache-path", "", "Cache database file, e.g. one shared by several libraries (overrides config, default inside the library)")
 		noTUI       = flag.Bool("no-tui", false, "Disable TUI, use simple CLI output")
 		execute     = flag.Bool("execute", false, "Actually perform operations (disables dry-run)")
+		metadataCmd = flag.String("metadata-command", "", "External command that prints JSON metadata for a file path (overrides config)")
+		layout      = flag.String("layout", "", "Date folder layout: year, year/month or year-month (overrides config)")
+		folderTmpl  = flag.String("folder-template", "", "Album folder template, e.g. {type}/{year}/{month}/{album} (overrides config and --layout)")
+		mode        = flag.String("mode", "", "How files are placed in the library: move, copy, hardlink or symlink (overrides config)")
+		simulate    = flag.Bool("simulate", false, "Execute by creating empty placeholder files at destinations (sources untouched)")
+		mixedDirs   = flag.String("mixed-dirs", "", "Mixed photo/video directories: split or majority (overrides config)")
+		albumNaming = flag.String("album-naming", "", "Album naming strategy: ollama or gps (overrides config)")
+		groupBy     = flag.String("group-by", "", "Album grouping: folder, location for geotagged photos/videos, or event to split folders by time gaps (overrides config)")
+		eventGap    = flag.Duration("event-gap", 0, "With --group-by event, the pause between shots that starts a new event, e.g. 3h (overrides config, default 6h)")
+		minAlbum    = flag.Int("min-album-files", 0, "Folders with fewer media files go to Misc/<year> instead of an album of their own (overrides config, default 3)")
+		maxAlbum    = flag.Int("max-album-files", 0, "Split albums with more files into parts, \"Name (Part 1)\", \"Name (Part 2)\", ... by date (overrides config, default 0 = no limit)")
+		mergeDepth  = flag.Int("merge-depth", -1, "Merge folders into the album of their ancestor this many levels up, e.g. 1 for Italy/Rome and Italy/Venice (overrides config, default 0)")
+		keepSubdirs = flag.Bool("keep-subfolders", false, "With --merge-depth, keep the merged subfolders inside the album (or config keep_subfolders)")
+		nonPhotos   = flag.String("non-photos", "", "Screenshots, animated GIFs and graphics: separate or albums (overrides config)")
+		placesFile  = flag.String("places", "", "CSV of name,lat,lon places for GPS album naming (overrides config)")
+		yes         = flag.Bool("yes", false, "Accept the plan without prompting (CLI with --execute, for scripts/cron)")
+		maxMoves    = flag.Int("max-moves", 0, "Refuse to execute plans with more file operations than this (0 = no limit, overrides config)")
+		force       = flag.Bool("force", false, "Execute even if the plan exceeds --max-moves")
+		sniff       = flag.Bool("sniff", false, "Detect media files by content, for missing or wrong extensions (reads the first bytes of every file; or config sniff_content)")
+		sidecarDate = flag.Bool("prefer-sidecar-dates", false, "Date files by their XMP sidecar (exif:DateTimeOriginal, photoshop:DateCreated) even when they carry a date of their own (or config prefer_sidecar_dates)")
+		sqliteReads = flag.Bool("threads-sqlite", false, "Use a separate read-only SQLite connection pool for concurrent cache reads")
+		verify      = flag.Bool("verify", false, "Re-hash every copy (copy mode, moves across filesystems) and keep the source if it doesn't match (or config verify_copies)")
+		linkBack    = flag.Bool("link-back", false, "After moving into the library, leave a hardlink at the original path (same filesystem only)")
+		dedupMin    = flag.String("dedup-threshold", "", "Ignore duplicates smaller than this size, e.g. 100KB (overrides config)")
+		dupKeep     = flag.String("duplicate-keep", "", "Which duplicate is kept: best-score (default), oldest, newest, largest or shortest-path (overrides config)")
+		hashAlgo    = flag.String("hash", "", "Hash algorithm for duplicate detection: xxhash (default), md5 or sha256 (overrides config)")
+		dedupLib    = flag.Bool("dedup-library", false, "Also trash scanned files identical to a file already in the library, instead of importing them again (or config dedup_library)")
+		contentHash = flag.Bool("content-hash", false, "Also treat JPEGs and PNGs with the same image data as duplicates, whatever their metadata (or config content_hash)")
+		nearDup     = flag.Int("near-dup-threshold", 0, "Report photos whose perceptual hashes differ by at most this many bits (0 = off, try 6-10; overrides config)")
+		destExists  = flag.String("dest-exists-policy", "", "Identical file already at destination: skip, replace or keep-both (overrides config)")
+		onConflict  = flag.String("on-conflict", "", "Destination name already taken: rename, skip-if-identical or overwrite (overrides config and --dest-exists-policy)")
+		resume      = flag.Bool("resume", false, "Continue the last execution if it was interrupted, skipping the files it already placed")
+		undo        = flag.Bool("undo", false, "Reverse the most recent execution from its journal (preview unless --execute) and exit")
+		emptyTrash  = flag.String("empty-trash", "", "Permanently delete duplicates quarantined longer than an age, e.g. older-than=30d (preview unless --execute) and exit")
+		clearSugg   = flag.Bool("clear-suggestions", false, "Clear cached album name suggestions and exit")
+		stats       = flag.Bool("stats", false, "Summarize the cached library (types, size, dates, cameras, duplicates, cache coverage) without scanning and exit")
+		compactDB   = flag.Bool("compact-cache", false, "Reclaim unused space in the cache database (e.g. after pruning) and exit")
+		exportCache = flag.String("export-cache", "", "Write the metadata cache as JSON lines to this file (- for stdout) and exit")
+		importCache = flag.String("import-cache", "", "Merge a cache exported with --export-cache (- for stdin) into this library's cache and exit")
+		watch       = flag.Bool("watch", false, "Keep running and organize media files as they arrive in the scan paths (plans only, unless --execute --yes)")
+		watchEvery  = flag.Duration("watch-interval", 10*time.Second, "How often --watch looks for new files; a batch waits until one interval passes without changes")
+		filesFrom   = flag.String("files-from", "", "Organize exactly the files listed in this file, one path per line (- for stdin)")
+		postCmd     = flag.String("post-command", "", "Command to run after a successful --execute/--simulate; gets the summary as MEDIAORG_* env vars and JSON on stdin (overrides config)")
+		postTimeout = flag.Duration("post-command-timeout", 0, "Time limit for --post-command, e.g. 30s (overrides config, default 5m)")
+		namingProv  = flag.String("naming-provider", "", "Album name suggestions from: ollama or openai (OpenAI-compatible API, overrides config)")
+		namingTime  = flag.Duration("naming-timeout", 0, "Time limit for one album name suggestion, e.g. 20s (overrides config, default 1m)")
+		namingRetry = flag.Int("naming-retries", -1, "Retries of a naming request failing with a server error or dropped connection (overrides config, default 2)")
+		namingWork  = flag.Int("naming-workers", 0, "Number of album name suggestions requested in parallel (overrides config, default 1)")
+		debug       = flag.Bool("debug", false, "Same as --log-level debug")
+		logLevel    = flag.String("log-level", LogLevelWarn, "Log to stderr up to this level: error, warn, info or debug (in the TUI only when stderr is redirected)")
+		findDups    = flag.Bool("find-duplicates", false, "Report duplicates in the library (and --path if given) without moving anything")
+		reportPath  = flag.String("report", "", "Write the organization plan (albums, files, duplicates) to this .json or .csv file")
+		dupReport   = flag.String("dup-report", "", "Write the duplicate groups (kept file, files to trash, reclaimable space) to this .json or .csv file")
+		output      = flag.String("output", OutputText, "CLI output: text, or json for one JSON event per line and phase on stdout (implies --no-tui)")
 	)
+	var scanPaths pathList
+	flag.Var(&scanPaths, "path", "Path to scan for media files, repeat for several (overrides config)")
+	var includeExts, excludeExts pathList
+	flag.Var(&includeExts, "include-ext", "Also recognize extensions as a type, e.g. photo:.dng or music:.opus,.ape; repeatable (added to config extra_extensions)")
+	flag.Var(&excludeExts, "exclude-ext", "Stop recognizing extensions, e.g. .wav or .wav,.gif; repeatable (added to config exclude_extensions)")
 
 	flag.Parse()
 
@@ -37,7 +110,14 @@ func main() {
 	var configFile *ConfigFile
 	var err error
 
-	if *reconfigure || !configExists() {
+	if *initConfig || (!*reconfigure && !configExists() && (len(scanPaths) > 0 || *libraryBase != "" || *yes)) {
+		// Non-interactive setup, e.g. a first run from a script
+		configFile, err = initConfigFile(scanPaths, *libraryBase, *workers, *mode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Setup error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *reconfigure || !configExists() {
 		// Run setup wizard
 		configFile, err = runSetupWizard()
 		if err != nil {
@@ -54,253 +134,1699 @@ func main() {
 		}
 	}
 
-	// Create Config from file, with command-line overrides
-	config := &Config{
-		ScanPath:        configFile.ScanPath,
-		LibraryBase:     configFile.LibraryBase,
-		DuplicatesTrash: configFile.DuplicatesTrash,
-		OllamaModel:     configFile.OllamaModel,
-		DryRun:          *dryRun,
-		Workers:         configFile.Workers,
-		FileLimit:       *fileLimit,
-		PruneCache:      *pruneCache,
+	// Create Config from file, with command-line overrides
+	config := &Config{
+		ScanPaths:       configFile.ScanPaths,
+		LibraryBase:     configFile.LibraryBase,
+		PhotosRoot:      configFile.PhotosRoot,
+		VideosRoot:      configFile.VideosRoot,
+		MusicRoot:       configFile.MusicRoot,
+		DuplicatesTrash: configFile.DuplicatesTrash,
+		TrashRun:        newTrashRun(),
+		CachePath:       configFile.CachePath,
+		OllamaModel:     configFile.OllamaModel,
+		NamingProvider:  configFile.NamingProvider,
+		OpenAIBaseURL:   configFile.OpenAIBaseURL,
+		OpenAIModel:     configFile.OpenAIModel,
+		VisionModel:     configFile.VisionModel,
+		MetadataCommand: configFile.MetadataCommand,
+		PostCommand:     configFile.PostCommand,
+		Layout:          configFile.Layout,
+		FolderTemplate:  configFile.FolderTemplate,
+		MixedDirs:       configFile.MixedDirs,
+		AlbumNaming:     configFile.AlbumNaming,
+		GroupBy:         configFile.GroupBy,
+		PlacesFile:      configFile.PlacesFile,
+		ExcludePatterns: configFile.ExcludePatterns,
+		AlbumSort:       configFile.AlbumSort,
+		NonPhotos:       configFile.NonPhotos,
+		DestExists:      configFile.DestExists,
+		OnConflict:      configFile.OnConflict,
+		DryRun:          *dryRun,
+		Workers:         configFile.Workers,
+		MoveWorkers:     configFile.MoveWorkers,
+		NamingWorkers:   configFile.NamingWorkers,
+		OrganizeMode:    configFile.OrganizeMode,
+		FilesFrom:       *filesFrom,
+		ReportPath:      *reportPath,
+		Output:          *output,
+		DupReportPath:   *dupReport,
+		FileLimit:       *fileLimit,
+		MaxDepth:        *maxDepth,
+		PruneCache:      *pruneCache,
+		FullScan:        *fullScan,
+		ThreadsSQLite:   *sqliteReads,
+		Sniff:           configFile.SniffContent || *sniff,
+		PreferSidecar:   configFile.SidecarDates || *sidecarDate,
+		FilenameDates:   configFile.FilenameDates,
+		AssumeYes:       *yes,
+		LinkBack:        *linkBack,
+		Verify:          configFile.VerifyCopies || *verify,
+		MaxMoves:        configFile.MaxMoves,
+		MinAlbumFiles:   configFile.MinAlbumFiles,
+		NoDateFolder:    configFile.NoDateFolder,
+		MaxAlbumFiles:   configFile.MaxAlbumFiles,
+		MergeDepth:      configFile.MergeDepth,
+		KeepSubfolders:  configFile.KeepSubfolders || *keepSubdirs,
+		HashAlgorithm:   configFile.HashAlgorithm,
+		NearDupBits:     configFile.NearDupBits,
+		DedupLibrary:    configFile.DedupLibrary || *dedupLib,
+		ContentHash:     configFile.ContentHash || *contentHash,
+		Force:           *force,
+	}
+
+	// Command-line flags override config file
+	if len(config.ScanPaths) == 0 && configFile.ScanPath != "" {
+		config.ScanPaths = []string{configFile.ScanPath}
+	}
+	if len(scanPaths) > 0 {
+		config.ScanPaths = scanPaths
+	}
+	for i, path := range config.ScanPaths {
+		if abs, err := filepath.Abs(path); err == nil {
+			config.ScanPaths[i] = abs
+		}
+	}
+	if *libraryBase != "" {
+		config.LibraryBase = *libraryBase
+	}
+	// Absolute, so the scan can tell when it reaches the library or the trash
+	for _, path := range []*string{&config.LibraryBase, &config.DuplicatesTrash, &config.PhotosRoot, &config.VideosRoot, &config.MusicRoot} {
+		if *path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(*path); err == nil {
+			*path = abs
+		}
+	}
+	if *cachePath != "" {
+		config.CachePath = *cachePath
+	}
+	if config.CachePath != "" {
+		if abs, err := filepath.Abs(config.CachePath); err == nil {
+			config.CachePath = abs
+		}
+	}
+	if *workers > 0 {
+		config.Workers = *workers
+	}
+	if *moveWorkers > 0 {
+		config.MoveWorkers = *moveWorkers
+	}
+	if config.MoveWorkers < 1 {
+		config.MoveWorkers = 1
+	}
+	if *namingWork > 0 {
+		config.NamingWorkers = *namingWork
+	}
+	if config.NamingWorkers < 1 {
+		config.NamingWorkers = 1
+	}
+	if *minAlbum > 0 {
+		config.MinAlbumFiles = *minAlbum
+	}
+	if config.MinAlbumFiles < 1 {
+		config.MinAlbumFiles = defaultMinAlbumFiles
+	}
+	if config.NoDateFolder == "" {
+		config.NoDateFolder = defaultNoDateFolder
+	} else if !filepath.IsLocal(config.NoDateFolder) {
+		fmt.Fprintf(os.Stderr, "Invalid no_date_folder %q: use a folder inside the library, e.g. %s\n", config.NoDateFolder, defaultNoDateFolder)
+		os.Exit(1)
+	}
+	if *maxAlbum > 0 {
+		config.MaxAlbumFiles = *maxAlbum
+	}
+	if config.MaxAlbumFiles < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid max_album_files %d: must be 0 (no limit) or more\n", config.MaxAlbumFiles)
+		os.Exit(1)
+	}
+	if *mergeDepth >= 0 {
+		config.MergeDepth = *mergeDepth
+	}
+	if config.MergeDepth < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid merge_depth %d: must be 0 or more\n", config.MergeDepth)
+		os.Exit(1)
+	}
+	if *metadataCmd != "" {
+		config.MetadataCommand = *metadataCmd
+	}
+	if *layout != "" {
+		config.Layout = *layout
+	}
+	if *folderTmpl != "" {
+		config.FolderTemplate = *folderTmpl
+	}
+	if *mode != "" {
+		config.OrganizeMode = *mode
+	}
+	if *mixedDirs != "" {
+		config.MixedDirs = *mixedDirs
+	}
+	if *albumNaming != "" {
+		config.AlbumNaming = *albumNaming
+	}
+	if *groupBy != "" {
+		config.GroupBy = *groupBy
+	}
+	if *placesFile != "" {
+		config.PlacesFile = *placesFile
+	}
+	if *nonPhotos != "" {
+		config.NonPhotos = *nonPhotos
+	}
+	if *maxMoves > 0 {
+		config.MaxMoves = *maxMoves
+	}
+	if *destExists != "" {
+		config.DestExists = *destExists
+	}
+	if *onConflict != "" {
+		config.OnConflict = *onConflict
+	}
+	if *hashAlgo != "" {
+		config.HashAlgorithm = *hashAlgo
+	}
+	if *nearDup > 0 {
+		config.NearDupBits = *nearDup
+	}
+	if *postCmd != "" {
+		config.PostCommand = *postCmd
+	}
+
+	if configFile.PostTimeout != "" {
+		config.PostTimeout, err = time.ParseDuration(configFile.PostTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid post command timeout: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *postTimeout > 0 {
+		config.PostTimeout = *postTimeout
+	}
+
+	if *namingProv != "" {
+		config.NamingProvider = *namingProv
+	}
+
+	if configFile.NamingTimeout != "" {
+		config.NamingTimeout, err = time.ParseDuration(configFile.NamingTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid naming timeout: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *namingTime > 0 {
+		config.NamingTimeout = *namingTime
+	}
+
+	config.EventGap = defaultEventGap
+	if configFile.EventGap != "" {
+		config.EventGap, err = time.ParseDuration(configFile.EventGap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid event gap: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *eventGap > 0 {
+		config.EventGap = *eventGap
+	}
+	if config.EventGap <= 0 {
+		fmt.Fprintf(os.Stderr, "Invalid event gap %v (use a positive duration, e.g. 6h)\n", config.EventGap)
+		os.Exit(1)
+	}
+
+	config.NamingRetries = defaultNamingRetries
+	if configFile.NamingRetries != nil {
+		config.NamingRetries = *configFile.NamingRetries
+	}
+	if *namingRetry >= 0 {
+		config.NamingRetries = *namingRetry
+	}
+	if config.NamingRetries < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid naming retries %d (use 0 or more)\n", config.NamingRetries)
+		os.Exit(1)
+	}
+
+	if *debug {
+		*logLevel = LogLevelDebug
+	}
+	// The TUI owns the terminal, log lines would garble it unless stderr goes elsewhere
+	logOutput := io.Writer(os.Stderr)
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && !*noTUI && config.Output != OutputJSON {
+		logOutput = io.Discard
+	}
+	if err := setLogLevel(*logLevel, logOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		os.Exit(1)
+	}
+
+	dedupThreshold := configFile.DedupThreshold
+	if *dedupMin != "" {
+		dedupThreshold = *dedupMin
+	}
+	if dedupThreshold != "" {
+		config.DedupThreshold, err = parseByteSize(dedupThreshold)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid dedup threshold: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *limitBytes != "" {
+		config.ByteLimit, err = parseByteSize(*limitBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid byte limit: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	config.DuplicatePolicy = defaultDuplicatePolicy
+	if configFile.DuplicateRules != nil {
+		config.DuplicatePolicy.Rules = configFile.DuplicateRules
+	}
+	if configFile.PreferLarger != nil {
+		config.DuplicatePolicy.PreferLarger = *configFile.PreferLarger
+	}
+	if configFile.PreferMetadata != nil {
+		config.DuplicatePolicy.PreferMetadata = *configFile.PreferMetadata
+	}
+	config.DuplicatePolicy.PreferOldest = configFile.PreferOldest
+	config.DuplicatePolicy.Keep = configFile.DuplicateKeep
+	if *dupKeep != "" {
+		config.DuplicatePolicy.Keep = *dupKeep
+	}
+	switch config.DuplicatePolicy.Keep {
+	case "":
+		config.DuplicatePolicy.Keep = DuplicateKeepBestScore
+	case DuplicateKeepBestScore, DuplicateKeepOldest, DuplicateKeepNewest, DuplicateKeepLargest, DuplicateKeepShortestPath:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid duplicate keep %q (use %s, %s, %s, %s or %s)\n", config.DuplicatePolicy.Keep,
+			DuplicateKeepBestScore, DuplicateKeepOldest, DuplicateKeepNewest, DuplicateKeepLargest, DuplicateKeepShortestPath)
+		os.Exit(1)
+	}
+	for _, rule := range config.DuplicatePolicy.Rules {
+		if len(rule.Contains) == 0 {
+			fmt.Fprintf(os.Stderr, "Invalid duplicate rule (score %d): needs at least one contains pattern\n", rule.Score)
+			os.Exit(1)
+		}
+	}
+
+	if config.ExcludePatterns == nil {
+		config.ExcludePatterns = defaultExcludePatterns
+	}
+
+	// Extra and excluded extensions from the config, then the flags
+	config.ExtraExtensions = make(map[MediaType][]string)
+	addExtensions := func(typeName string, exts []string) {
+		mediaType, ok := mediaTypeNames[strings.ToLower(strings.TrimSpace(typeName))]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Invalid extension type %q (use photo, video or music)\n", typeName)
+			os.Exit(1)
+		}
+		config.ExtraExtensions[mediaType] = append(config.ExtraExtensions[mediaType], exts...)
+	}
+	for typeName, exts := range configFile.ExtraExtensions {
+		addExtensions(typeName, exts)
+	}
+	for _, value := range includeExts {
+		typeName, exts, ok := strings.Cut(value, ":")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Invalid --include-ext %q (use type:.ext, e.g. photo:.dng)\n", value)
+			os.Exit(1)
+		}
+		addExtensions(typeName, strings.Split(exts, ","))
+	}
+	config.ExcludeExts = configFile.ExcludeExts
+	for _, value := range excludeExts {
+		config.ExcludeExts = append(config.ExcludeExts, strings.Split(value, ",")...)
+	}
+	if err := registerExtensions(config.ExtraExtensions, config.ExcludeExts); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid extensions: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateExcludePatterns(config.ExcludePatterns); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid exclude pattern %v\n", err)
+		os.Exit(1)
+	}
+
+	if config.ReportPath != "" {
+		if err := validateReportPath(config.ReportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid report path: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if config.DupReportPath != "" {
+		if err := validateReportPath(config.DupReportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid duplicate report path: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch config.HashAlgorithm {
+	case "":
+		config.HashAlgorithm = HashXXHash
+	case HashXXHash, HashMD5, HashSHA256:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid hash algorithm %q (use %s, %s or %s)\n", config.HashAlgorithm, HashXXHash, HashMD5, HashSHA256)
+		os.Exit(1)
+	}
+
+	if config.NearDupBits < 0 || config.NearDupBits > 64 {
+		fmt.Fprintf(os.Stderr, "Invalid near-dup threshold %d (use 0-64 bits)\n", config.NearDupBits)
+		os.Exit(1)
+	}
+
+	switch config.Layout {
+	case "":
+		config.Layout = LayoutYear
+	case LayoutYear, LayoutYearMonth, LayoutYearDashMonth:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid layout %q (use %s, %s or %s)\n", config.Layout, LayoutYear, LayoutYearMonth, LayoutYearDashMonth)
+		os.Exit(1)
+	}
+
+	if config.FolderTemplate != "" {
+		if err := validateTemplate(config.FolderTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid folder template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch config.MixedDirs {
+	case "":
+		config.MixedDirs = MixedDirsSplit
+	case MixedDirsSplit, MixedDirsMajority:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid mixed-dirs %q (use %s or %s)\n", config.MixedDirs, MixedDirsSplit, MixedDirsMajority)
+		os.Exit(1)
+	}
+
+	switch config.NamingProvider {
+	case "":
+		config.NamingProvider = NamingOllama
+	case NamingOllama:
+	case NamingOpenAI:
+		if config.OpenAIModel == "" {
+			fmt.Fprintln(os.Stderr, "OpenAI naming needs a model (openai_model in config)")
+			os.Exit(1)
+		}
+		if config.VisionModel != "" {
+			fmt.Fprintln(os.Stderr, "ollama_vision_model needs the ollama naming provider")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid naming provider %q (use %s or %s)\n", config.NamingProvider, NamingOllama, NamingOpenAI)
+		os.Exit(1)
+	}
+
+	switch config.AlbumNaming {
+	case "":
+		config.AlbumNaming = AlbumNamingOllama
+	case AlbumNamingOllama:
+	case AlbumNamingGPS:
+		if config.PlacesFile == "" {
+			fmt.Fprintln(os.Stderr, "GPS album naming needs a places file (--places or places_file in config)")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid album naming %q (use %s or %s)\n", config.AlbumNaming, AlbumNamingOllama, AlbumNamingGPS)
+		os.Exit(1)
+	}
+
+	switch config.GroupBy {
+	case "":
+		config.GroupBy = GroupByFolder
+	case GroupByFolder, GroupByLocation, GroupByEvent:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid group-by %q (use %s, %s or %s)\n", config.GroupBy, GroupByFolder, GroupByLocation, GroupByEvent)
+		os.Exit(1)
+	}
+
+	switch config.AlbumSort {
+	case "":
+		config.AlbumSort = AlbumSortDate
+	case AlbumSortDate, AlbumSortName:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid album sort %q (use %s or %s)\n", config.AlbumSort, AlbumSortDate, AlbumSortName)
+		os.Exit(1)
+	}
+
+	switch config.NonPhotos {
+	case "":
+		config.NonPhotos = NonPhotosAlbums // Separate folders are opt-in, so existing layouts don't change
+	case NonPhotosSeparate, NonPhotosAlbums:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid non-photos %q (use %s or %s)\n", config.NonPhotos, NonPhotosSeparate, NonPhotosAlbums)
+		os.Exit(1)
+	}
+
+	switch config.OrganizeMode {
+	case "":
+		config.OrganizeMode = OrganizeMove
+	case OrganizeMove, OrganizeCopy, OrganizeHardlink, OrganizeSymlink:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid mode %q (use %s, %s, %s or %s)\n", config.OrganizeMode, OrganizeMove, OrganizeCopy, OrganizeHardlink, OrganizeSymlink)
+		os.Exit(1)
+	}
+	if config.LinkBack && config.OrganizeMode != OrganizeMove {
+		fmt.Fprintf(os.Stderr, "--link-back only applies to mode %s (originals already stay in place with %s)\n", OrganizeMove, config.OrganizeMode)
+		os.Exit(1)
+	}
+
+	// on_conflict decides identical files too, over dest_exists_policy
+	switch config.OnConflict {
+	case "":
+	case ConflictRename:
+		config.DestExists = DestExistsKeepBoth
+	case ConflictSkipIdentical:
+		config.DestExists = DestExistsSkip
+	case ConflictOverwrite:
+		config.DestExists = DestExistsReplace
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid on-conflict policy %q (use %s, %s or %s)\n", config.OnConflict, ConflictRename, ConflictSkipIdentical, ConflictOverwrite)
+		os.Exit(1)
+	}
+	switch config.DestExists {
+	case "":
+		config.DestExists = DestExistsSkip
+	case DestExistsSkip, DestExistsReplace, DestExistsKeepBoth:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid dest-exists policy %q (use %s, %s or %s)\n", config.DestExists, DestExistsSkip, DestExistsReplace, DestExistsKeepBoth)
+		os.Exit(1)
+	}
+
+	// Register external metadata provider (its fields win over EXIF)
+	if config.MetadataCommand != "" {
+		if strings.TrimSpace(config.MetadataCommand) == "" {
+			fmt.Fprintf(os.Stderr, "Invalid metadata command %q (give the program to run)\n", config.MetadataCommand)
+			os.Exit(1)
+		}
+		RegisterMetadataProvider(NewCommandProvider(config.MetadataCommand))
+	}
+	preferSidecarDates = config.PreferSidecar
+	if config.FilenameDates != nil {
+		patterns, err := compileFilenameDatePatterns(config.FilenameDates)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid filename_date_patterns: %v\n", err)
+			os.Exit(1)
+		}
+		filenameDatePatterns = patterns
+	}
+
+	if *execute {
+		config.DryRun = false
+	}
+	if *simulate {
+		config.DryRun = false
+		config.Simulate = true
+	}
+
+	switch config.Output {
+	case OutputText:
+	case OutputJSON:
+		// Nobody to answer the prompt, the text goes nowhere
+		if !config.DryRun && !config.AssumeYes {
+			fmt.Fprintln(os.Stderr, "--output json executes only with --yes (there is no prompt)")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid output %q (use text or json)\n", config.Output)
+		os.Exit(1)
+	}
+
+	if *undo {
+		runUndo(config)
+		return
+	}
+
+	if *emptyTrash != "" {
+		age, err := parseTrashAge(*emptyTrash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --empty-trash: %v\n", err)
+			os.Exit(1)
+		}
+		runEmptyTrash(config, age)
+		return
+	}
+
+	if *resume {
+		if config.Simulate {
+			fmt.Fprintln(os.Stderr, "--resume continues a real execution, it can't be combined with --simulate")
+			os.Exit(1)
+		}
+		path, entries, err := ResumableJournal(config.LibraryBase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading journal: %v\n", err)
+			os.Exit(1)
+		}
+		if path == "" {
+			fmt.Println("Nothing to resume (the last execution finished)")
+			return
+		}
+		fmt.Printf("Resuming %s (%d operations already done)\n", path, len(entries))
+		config.Resume = true
+	}
+
+	if *clearSugg {
+		runClearSuggestions(config)
+		return
+	}
+
+	if *stats {
+		runStats(config)
+		return
+	}
+
+	if *compactDB {
+		runCompactCache(config)
+		return
+	}
+
+	if *exportCache != "" {
+		runExportCache(config, *exportCache)
+		return
+	}
+
+	if *importCache != "" {
+		runImportCache(config, *importCache)
+		return
+	}
+
+	// Read-only duplicate audit
+	if *findDups {
+		runFindDuplicates(config, len(scanPaths) > 0)
+		return
+	}
+
+	if *watch {
+		switch {
+		case config.FilesFrom != "":
+			fmt.Fprintln(os.Stderr, "--watch watches the scan paths, it can't be combined with --files-from")
+			os.Exit(1)
+		case config.Output == OutputJSON:
+			fmt.Fprintln(os.Stderr, "--watch prints text, it can't be combined with --output json")
+			os.Exit(1)
+		case !config.DryRun && !config.AssumeYes:
+			fmt.Fprintln(os.Stderr, "--watch executes only with --yes (nobody is there to confirm each batch)")
+			os.Exit(1)
+		case *watchEvery <= 0:
+			fmt.Fprintf(os.Stderr, "Invalid --watch-interval %s (must be positive)\n", *watchEvery)
+			os.Exit(1)
+		}
+		runWatch(config, *watchEvery)
+		return
+	}
+
+	// Run with or without TUI
+	if *noTUI || config.Output == OutputJSON {
+		runCLI(config)
+	} else {
+		runTUI(config)
+	}
+}
+
+func runCLI(config *Config) {
+	// With --output json, stdout only carries the events (post command output goes to stderr)
+	var out, postOut io.Writer = os.Stdout, os.Stdout
+	var events *jsonEvents
+	if config.Output == OutputJSON {
+		events = newJSONEvents(os.Stdout)
+		out, postOut = io.Discard, os.Stderr
+	}
+
+	// fatal reports an error that stops the run
+	fatal := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		events.emit(errorEvent{Event: "error", Message: msg})
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(out, "Media Library Organizer")
+	fmt.Fprintln(out, "======================")
+	fmt.Fprintln(out)
+
+	// Configuration display
+	fmt.Fprintln(out, "Configuration:")
+	if config.FilesFrom != "" {
+		fmt.Fprintf(out, "  File List:    %s\n", config.FilesFrom)
+	} else {
+		for i, path := range config.ScanPaths {
+			note := ""
+			if same, err := sameDevice(path, config.LibraryBase); err == nil && !same &&
+				(config.OrganizeMode == OrganizeMove || config.OrganizeMode == OrganizeHardlink) {
+				note = " (other filesystem than the library, files are copied)"
+			}
+			if i == 0 {
+				fmt.Fprintf(out, "  Scan Path:    %s%s\n", path, note)
+			} else {
+				fmt.Fprintf(out, "                %s%s\n", path, note)
+			}
+		}
+	}
+	if config.libraryInScan() {
+		fmt.Fprintf(out, "  Library:      %s (inside the scan path, skipped while scanning)\n", config.LibraryBase)
+	} else {
+		fmt.Fprintf(out, "  Library:      %s\n", config.LibraryBase)
+	}
+	for _, t := range []MediaType{TypePhoto, TypeVideo, TypeMusic} {
+		if root := config.customTypeRoot(t); root != "" {
+			fmt.Fprintf(out, "  %-13s %s\n", typeFolder(t)+":", root)
+		}
+	}
+	fmt.Fprintf(out, "  Trash:       %s\n", config.DuplicatesTrash)
+	if config.CachePath != "" {
+		fmt.Fprintf(out, "  Cache:        %s\n", config.CachePath)
+	}
+	if config.NamingProvider == NamingOpenAI {
+		fmt.Fprintf(out, "  OpenAI Model: %s\n", config.OpenAIModel)
+	} else {
+		fmt.Fprintf(out, "  Ollama Model: %s\n", config.OllamaModel)
+		if config.VisionModel != "" {
+			fmt.Fprintf(out, "  Vision Model: %s\n", config.VisionModel)
+		}
+	}
+	fmt.Fprintf(out, "  Workers:      %d\n", config.Workers)
+	if config.MoveWorkers > 1 {
+		fmt.Fprintf(out, "  Move Workers: %d\n", config.MoveWorkers)
+	}
+	if config.FolderTemplate != "" {
+		fmt.Fprintf(out, "  Template:     %s\n", config.FolderTemplate)
+	} else if config.Layout != LayoutYear {
+		fmt.Fprintf(out, "  Layout:       %s\n", config.Layout)
+	}
+	if config.FileLimit > 0 {
+		fmt.Fprintf(out, "  File Limit:   %d (testing mode)\n", config.FileLimit)
+	}
+	if config.ByteLimit > 0 {
+		fmt.Fprintf(out, "  Size Limit:   %s (testing mode)\n", formatBytes(config.ByteLimit))
+	}
+	if config.MaxDepth > 0 {
+		fmt.Fprintf(out, "  Max Depth:    %d\n", config.MaxDepth)
+	}
+	if config.PruneCache {
+		fmt.Fprintf(out, "  Cache Prune:  Enabled\n")
+	}
+	if config.DedupThreshold > 0 {
+		fmt.Fprintf(out, "  Dedup Min:    %s\n", formatBytes(config.DedupThreshold))
+	}
+	if config.OrganizeMode != OrganizeMove {
+		fmt.Fprintf(out, "  Organize:     %s (originals stay in place, duplicates are not trashed)\n", config.OrganizeMode)
+	}
+	if config.LinkBack {
+		fmt.Fprintf(out, "  Link Back:    Enabled (hardlinks left at source paths)\n")
+	}
+	if config.Verify {
+		fmt.Fprintf(out, "  Verify:       Enabled (copies re-hashed with %s)\n", config.HashAlgorithm)
+	}
+	if config.PostCommand != "" {
+		fmt.Fprintf(out, "  Post Command: %s\n", config.PostCommand)
+	}
+
+	fmt.Fprintln(out)
+	if config.DryRun {
+		fmt.Fprintln(out, "Mode: DRY RUN (no changes will be made)")
+	} else if config.Simulate {
+		fmt.Fprintln(out, "Mode: SIMULATE (empty placeholders will be created, sources untouched)")
+	} else {
+		fmt.Fprintf(out, "Mode: EXECUTE (files will be %s)\n", placedVerb(config.OrganizeMode))
+	}
+	fmt.Fprintln(out)
+
+	// Open cache
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Fprintf(out, "Warning: cache disabled: %v\n", err)
+		cache = nil
+	} else {
+		defer cache.Close()
+		total, withHash, withMetadata := cache.GetStats()
+		fmt.Fprintf(out, "Cache: %d files (%d with hashes, %d with metadata)\n", total, withHash, withMetadata)
+	}
+	fmt.Fprintln(out)
+
+	// Scan for media files
+	fmt.Fprintln(out, "Scanning for media files...")
+	files, err := ScanMediaFiles(config, nil, cache)
+	if err != nil {
+		fatal("Error scanning: %v", err)
+	}
+
+	fmt.Fprintf(out, "Found %d media files\n", len(files))
+
+	// From here on Ctrl-C stops the phase that is running instead of killing the process
+	// (the scan above can't be cancelled, so it is still killed outright)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// stopIfInterrupted ends a run interrupted before execution, when no file was touched yet
+	stopIfInterrupted := func() {
+		if ctx.Err() == nil {
+			return
+		}
+		fmt.Fprintln(out, "\nInterrupted: no files were changed")
+		events.emit(errorEvent{Event: "error", Message: "interrupted"})
+		if cache != nil {
+			cache.Close() // os.Exit skips the deferred Close, keep what was computed so far
+		}
+		os.Exit(130)
+	}
+
+	// Prune deleted files from cache (auto on full scans, or when --prune-cache flag set)
+	var pruned int64
+	if cache != nil && (!config.IsPartialScan() || config.PruneCache) {
+		validPaths := make(map[string]bool)
+		for _, f := range files {
+			validPaths[f.Path] = true
+		}
+		var err error
+		pruned, err = cache.PruneDeleted(validPaths, config.pruneRoots(), config.pruneKeep())
+		if err == nil && pruned > 0 {
+			fmt.Fprintf(out, "  Pruned %d deleted files from cache\n", pruned)
+		}
+	}
+	fmt.Fprintf(out, "  Photos: %d\n", countByType(files, TypePhoto))
+	fmt.Fprintf(out, "  Videos: %d\n", countByType(files, TypeVideo))
+	fmt.Fprintf(out, "  Music:  %d\n", countByType(files, TypeMusic))
+	newCount := countNewFiles(files)
+	if cache != nil {
+		fmt.Fprintf(out, "  New files: %d (rest already in library)\n", newCount)
+	}
+	fmt.Fprintln(out)
+	events.emit(scanEvent{
+		Event:  "scan",
+		Files:  len(files),
+		Photos: countByType(files, TypePhoto),
+		Videos: countByType(files, TypeVideo),
+		Music:  countByType(files, TypeMusic),
+		New:    newCount,
+		Pruned: pruned,
+	})
+
+	// Extract metadata
+	fmt.Fprintln(out, "Extracting metadata...")
+	metadataProgress := make(chan ScanProgress, 10)
+	go func() {
+		for prog := range metadataProgress {
+			if prog.TotalFiles > 0 {
+				percent := prog.Fraction() * 100
+				currentFile := truncateFilePath(prog.CurrentFile, 60)
+				fmt.Fprintf(out, "\r  Progress: [%-50s] %3.0f%% (%s) %s",
+					progressBar(percent),
+					percent,
+					progressCounts(prog),
+					currentFile)
+			}
+		}
+		fmt.Fprintf(out, "\r%s\r", strings.Repeat(" ", 150)) // Clear line
+	}()
+
+	metadataHits := ProcessMetadata(ctx, files, config.Workers, metadataProgress, cache)
+	close(metadataProgress)
+	stopIfInterrupted()
+
+	if cache != nil {
+		fmt.Fprintf(out, "Done (%d from cache, %d processed)\n", metadataHits, len(files)-metadataHits)
+	} else {
+		fmt.Fprintln(out, "Done")
+	}
+	fmt.Fprintln(out)
+	events.emit(phaseEvent{Event: "metadata", Files: len(files), FromCache: metadataHits})
+
+	// Calculate hashes
+	fmt.Fprintln(out, "Calculating hashes for duplicate detection...")
+	hashProgress := make(chan ScanProgress, 10)
+	go func() {
+		for prog := range hashProgress {
+			if prog.TotalFiles > 0 {
+				percent := prog.Fraction() * 100
+				currentFile := truncateFilePath(prog.CurrentFile, 60)
+				fmt.Fprintf(out, "\r  Progress: [%-50s] %3.0f%% (%s) %s",
+					progressBar(percent),
+					percent,
+					progressCounts(prog),
+					currentFile)
+			}
+		}
+		fmt.Fprintf(out, "\r%s\r", strings.Repeat(" ", 150)) // Clear line
+	}()
+
+	hashHits := CalculateHashes(ctx, files, config.Workers, config.HashAlgorithm, hashProgress, cache)
+	close(hashProgress)
+	stopIfInterrupted()
+
+	if cache != nil {
+		fmt.Fprintf(out, "Done (%d from cache, %d checked)\n", hashHits, len(files)-hashHits)
+	} else {
+		fmt.Fprintln(out, "Done")
+	}
+	fmt.Fprintln(out)
+	events.emit(phaseEvent{Event: "hashes", Files: len(files), FromCache: hashHits})
+
+	// Content hashes catch copies that differ only in metadata (opt-in, reads every JPEG/PNG)
+	if config.ContentHash {
+		fmt.Fprintln(out, "Calculating image content hashes...")
+		contentHits := CalculateContentHashes(ctx, files, config.Workers, nil, cache)
+		stopIfInterrupted()
+		fmt.Fprintf(out, "Done (%d from cache)\n", contentHits)
+		fmt.Fprintln(out)
+	}
+
+	// Perceptual hashes for near-duplicate photos (opt-in, decoding images is slow)
+	if config.NearDupBits > 0 {
+		fmt.Fprintln(out, "Calculating perceptual hashes for near-duplicate detection...")
+		phashProgress := make(chan ScanProgress, 10)
+		phashDone := make(chan struct{})
+		go func() {
+			defer close(phashDone)
+			for prog := range phashProgress {
+				if prog.TotalFiles > 0 {
+					percent := prog.Fraction() * 100
+					currentFile := truncateFilePath(prog.CurrentFile, 60)
+					fmt.Fprintf(out, "\r  Progress: [%-50s] %3.0f%% (%s) %s",
+						progressBar(percent),
+						percent,
+						progressCounts(prog),
+						currentFile)
+				}
+			}
+			fmt.Fprintf(out, "\r%s\r", strings.Repeat(" ", 150)) // Clear line
+		}()
+
+		phashHits := CalculatePerceptualHashes(files, config.Workers, phashProgress, cache)
+		close(phashProgress)
+		<-phashDone
+		stopIfInterrupted()
+		fmt.Fprintf(out, "Done (%d from cache)\n", phashHits)
+		fmt.Fprintln(out)
+		events.emit(phaseEvent{Event: "perceptual_hashes", Files: countByType(files, TypePhoto), FromCache: phashHits})
+	}
+
+	// Find duplicates
+	fmt.Fprintln(out, "Finding duplicates...")
+	duplicates := FindDuplicates(files, config.DedupThreshold, &config.DuplicatePolicy)
+	fmt.Fprintf(out, "Found %d duplicate groups\n", len(duplicates))
+	albumFiles := files
+	if config.DedupLibrary {
+		duplicates = AddLibraryDuplicates(ctx, duplicates, files, config, cache)
+		stopIfInterrupted()
+		albumFiles = withoutLibraryDuplicates(files, duplicates, config)
+		fmt.Fprintf(out, "Found %d files already in the library\n", len(files)-len(albumFiles))
+	}
+	duplicatesSummary := duplicatesEvent{Event: "duplicates", Groups: len(duplicates), Reclaimable: ReclaimableBytes(duplicates)}
+	for _, group := range duplicates {
+		duplicatesSummary.Files += len(group.Files) - 1
+	}
+	if config.NearDupBits > 0 {
+		nearDuplicates := FindNearDuplicates(files, config.NearDupBits, &config.DuplicatePolicy)
+		duplicatesSummary.NearDuplicates = len(nearDuplicates)
+		fmt.Fprintf(out, "Found %d near-duplicate photo groups (resized/re-encoded copies, not moved to trash)\n", len(nearDuplicates))
+		for i, group := range nearDuplicates {
+			if i >= 10 {
+				fmt.Fprintf(out, "  ... and %d more groups\n", len(nearDuplicates)-10)
+				break
+			}
+			fmt.Fprintf(out, "  Group %d:\n", i+1)
+			for _, mf := range group.Files {
+				marker := " "
+				if mf == group.Best {
+					marker = "*"
+				}
+				fmt.Fprintf(out, "   %s %s (%s)\n", marker, mf.Path, formatBytes(mf.Size))
+			}
+		}
+	}
+	fmt.Fprintln(out)
+	events.emit(duplicatesSummary)
+
+	// Organize into albums
+	fmt.Fprintln(out, "Organizing into albums...")
+	var albumCache *AlbumSuggestionCache
+	if cache != nil {
+		albumCache, _ = OpenAlbumSuggestionCache(cache)
+	}
+	organizeProgress := make(chan ScanProgress, 10)
+	organizeDone := make(chan struct{})
+	go func() {
+		defer close(organizeDone)
+		for prog := range organizeProgress {
+			percent := float64(prog.ProcessedFiles) * 100 / float64(prog.TotalFiles)
+			fmt.Fprintf(out, "\r  Progress: [%-50s] %3.0f%% (%d/%d folders) %s",
+				progressBar(percent),
+				percent,
+				prog.ProcessedFiles,
+				prog.TotalFiles,
+				truncateFilePath(prog.CurrentFile, 60))
+		}
+		fmt.Fprintf(out, "\r%s\r", strings.Repeat(" ", 150)) // Clear line
+	}()
+	albums, err := OrganizeIntoAlbums(ctx, albumFiles, config, organizeProgress, albumCache)
+	close(organizeProgress)
+	<-organizeDone
+	stopIfInterrupted()
+	if err != nil {
+		fatal("Error organizing: %v", err)
+	}
+
+	fmt.Fprintf(out, "Created %d albums\n", len(albums))
+	fmt.Fprintln(out)
+
+	if config.ReportPath != "" {
+		if err := WritePlanReport(config.ReportPath, albums, duplicates, config); err != nil {
+			fatal("Error writing report: %v", err)
+		}
+		fmt.Fprintf(out, "Plan report written to %s\n\n", config.ReportPath)
+	}
+	if config.DupReportPath != "" {
+		if err := WriteDuplicateReport(config.DupReportPath, duplicates, config); err != nil {
+			fatal("Error writing duplicate report: %v", err)
+		}
+		fmt.Fprintf(out, "Duplicate report written to %s\n\n", config.DupReportPath)
+	}
+
+	// Show summary
+	if len(albums) == 0 {
+		fmt.Fprintln(out, "No new files to organize! All files are already in the library.")
+		// Copies of library files (e.g. a re-imported card) still go to the trash
+		if len(duplicates) == 0 || config.OrganizeMode != OrganizeMove {
+			events.emit(planEvent{Event: "plan", DryRun: config.DryRun, Albums: []planEventAlbum{}})
+			return
+		}
+		fmt.Fprintln(out)
+	}
+
+	totalFilesToMove := 0
+	for _, album := range albums {
+		totalFilesToMove += len(album.Files)
+	}
+
+	fmt.Fprintln(out, "Organization Plan:")
+	fmt.Fprintln(out, "==================")
+	fmt.Fprintf(out, "Found %d new/moved files to organize into %d albums\n", totalFilesToMove, len(albums))
+	diff := ComputePlanDiff(albums, config)
+	fmt.Fprintf(out, "Library changes: %s\n", diff)
+	fmt.Fprintf(out, "                 %s\n", diff.FilesString())
+	estimate := EstimatePlan(albums, duplicates, config)
+	fmt.Fprintf(out, "Estimate:        %s\n", estimate)
+	if estimate.Copies > 0 && config.MoveWorkers == 1 {
+		fmt.Fprintln(out, "                 (--move-workers 4 copies several files at once)")
+	}
+	fmt.Fprintln(out)
+	for i, album := range albums {
+		if i >= 10 {
+			fmt.Fprintf(out, "... and %d more albums\n", len(albums)-10)
+			break
+		}
+		if diff.AlbumExists(album) {
+			fmt.Fprintf(out, "%s (existing)\n", album.Name)
+		} else {
+			fmt.Fprintf(out, "%s (new)\n", album.Name)
+		}
+		fmt.Fprintf(out, "  → %s\n", album.Destination)
+		if bytes := estimate.CrossDeviceBytes(album); bytes > 0 {
+			fmt.Fprintf(out, "  → %d files (will copy %s across devices)\n", len(album.Files), formatBytes(bytes))
+		} else {
+			fmt.Fprintf(out, "  → %d files\n", len(album.Files))
+		}
+		fmt.Fprintln(out)
+	}
+
+	// Files that resolve to the same destination (renamed with a counter when executed)
+	if len(diff.Collisions) > 0 {
+		fmt.Fprintln(out, "Destination collisions:")
+		for i, collision := range diff.Collisions {
+			if i >= 10 {
+				fmt.Fprintf(out, "  ... and %d more\n", len(diff.Collisions)-10)
+				break
+			}
+			fmt.Fprintf(out, "  %s\n", collision.Destination)
+			fmt.Fprintf(out, "    %s\n", collision.Sources[0])
+			for j, source := range collision.Sources[1:] {
+				fmt.Fprintf(out, "    %s → %s\n", source, filepath.Base(collision.Renamed[j]))
+			}
+		}
+		fmt.Fprintln(out)
+	}
+
+	plan := planEvent{
+		Event:         "plan",
+		DryRun:        config.DryRun,
+		Files:         totalFilesToMove,
+		NewAlbums:     diff.NewAlbums,
+		GrowingAlbums: diff.GrowingAlbums,
+		NewFiles:      diff.NewFiles,
+		Relocations:   diff.Relocations,
+		Conflicts:     diff.Conflicts,
+		Collisions:    len(diff.Collisions),
+		Renames:       estimate.Renames,
+		Copies:        estimate.Copies,
+		CopyBytes:     estimate.CopyBytes,
+	}
+	for _, album := range albums {
+		plan.Albums = append(plan.Albums, planEventAlbum{
+			Name:        album.Name,
+			Destination: album.Destination,
+			Type:        album.Type.String(),
+			Files:       len(album.Files),
+			Existing:    diff.AlbumExists(album),
+		})
+	}
+	events.emit(plan)
+
+	if config.DryRun {
+		fmt.Fprintln(out, "This was a DRY RUN. Use --execute to actually organize files.")
+	} else {
+		// Guardrail and confirmation before touching files
+		summary := summarizeExecution(albums, duplicates, config)
+		stop() // Ctrl-C at the prompt quits, as nothing was changed yet
+		if !confirmExecute(out, config, summary) {
+			events.emit(abortedEvent{Event: "aborted", Operations: summary.Operations(), MaxMoves: config.MaxMoves})
+			return
+		}
+
+		// Execute the organization
+		fmt.Fprintln(out, "\nExecuting organization...")
+		execProgress := make(chan ScanProgress, 10)
+		execDone := make(chan struct{})
+		go func() {
+			defer close(execDone)
+			for prog := range execProgress {
+				if prog.TotalFiles > 0 {
+					percent := prog.Fraction() * 100
+					currentFile := truncateFilePath(prog.CurrentFile, 60)
+					fmt.Fprintf(out, "\r  Progress: [%-50s] %3.0f%% (%s) %s",
+						progressBar(percent),
+						percent,
+						progressCounts(prog),
+						currentFile)
+				}
+			}
+			fmt.Fprintf(out, "\r%s\r", strings.Repeat(" ", 150)) // Clear line
+		}()
+
+		// Ctrl-C finishes the file being moved and stops, instead of killing a copy halfway
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		result, err := ExecuteOrganization(ctx, albums, duplicates, config, execProgress, cache)
+		stop()
+		close(execProgress)
+		<-execDone // Let the progress line clear before printing more
+		if err != nil {
+			fatal("Error executing: %v", err)
+		}
+		printExecutionSummary(out, result, config)
+
+		exitCode := 0
+		postErr := ""
+		if result.Cancelled {
+			fmt.Fprintln(out, "Interrupted: the remaining files were left in place, run again with --resume to continue")
+			exitCode = 130
+		} else if config.PostCommand != "" {
+			// Hand off to downstream automation
+			fmt.Fprintf(out, "\nRunning post command: %s\n", config.PostCommand)
+			if err := RunPostCommand(config, len(albums), result, postOut); err != nil {
+				fmt.Fprintf(os.Stderr, "Post command failed: %v\n", err)
+				postErr = err.Error()
+				exitCode = 1
+			} else {
+				fmt.Fprintln(out, "Post command finished")
+			}
+		}
+		if result.Failed > 0 && exitCode == 0 {
+			exitCode = 1
+		}
+
+		resultSummary := resultEvent{
+			Event:        "result",
+			Moved:        result.Moved,
+			Failed:       result.Failed,
+			VerifyFailed: result.VerifyFailed,
+			Skipped:      result.Skipped,
+			Resumed:      result.Resumed,
+			LinkFailed:   result.LinkFailed,
+			Changed:      result.Changed,
+			Errors:       []string{},
+			Cancelled:    result.Cancelled,
+			PostCommand:  postErr,
+			ExitCode:     exitCode,
+		}
+		if resultSummary.Changed == nil {
+			resultSummary.Changed = []string{}
+		}
+		for _, err := range result.Errors {
+			resultSummary.Errors = append(resultSummary.Errors, err.Error())
+		}
+		events.emit(resultSummary)
+
+		if exitCode != 0 {
+			if cache != nil {
+				cache.Close() // os.Exit skips the deferred Close, commit queued cache updates first
+			}
+			os.Exit(exitCode)
+		}
+	}
+}
+
+// runWatch organizes the scan paths in batches as files arrive, until interrupted
+func runWatch(config *Config, interval time.Duration) {
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Printf("Warning: cache disabled: %v\n", err)
+		cache = nil
+	} else {
+		defer cache.Close()
+	}
+
+	mode := "dry run, plans only"
+	if config.Simulate {
+		mode = "simulate"
+	} else if !config.DryRun {
+		mode = "files are " + placedVerb(config.OrganizeMode)
+	}
+	fmt.Printf("Watching %s every %s (%s), Ctrl-C to stop\n", strings.Join(config.ScanPaths, ", "), interval, mode)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	watcher := NewWatcher(config)
+	for {
+		files, err := watcher.Poll()
+		if err != nil {
+			errorLog.Printf("watch: %v", err)
+		}
+		if len(files) > 0 {
+			watchBatch(ctx, files, config, cache)
+			watcher.Done(files)
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped watching")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchBatch plans one --watch batch and executes it unless this is a dry run
+func watchBatch(ctx context.Context, files []*MediaFile, config *Config, cache *Cache) {
+	fmt.Printf("\n[%s] %d new media files\n", time.Now().Format("15:04:05"), len(files))
+	albums, duplicates, err := PlanBatch(ctx, files, config, cache)
+	if err != nil {
+		errorLog.Printf("watch: %v", err)
+		return
+	}
+	if len(duplicates) > 0 {
+		fmt.Printf("  %d duplicate groups\n", len(duplicates))
+	}
+	for _, album := range albums {
+		fmt.Printf("  %s → %s (%d files)\n", album.Name, album.Destination, len(album.Files))
+	}
+	summary := summarizeExecution(albums, duplicates, config)
+	if config.DryRun || summary.Operations() == 0 || !confirmExecute(os.Stdout, config, summary) {
+		return
+	}
+
+	config.TrashRun = newTrashRun() // Each batch quarantines its duplicates separately
+	result, err := ExecuteOrganization(ctx, albums, duplicates, config, nil, cache)
+	if err != nil {
+		errorLog.Printf("watch: %v", err)
+		return
+	}
+	printExecutionSummary(os.Stdout, result, config)
+	if !result.Cancelled && config.PostCommand != "" {
+		if err := RunPostCommand(config, len(albums), result, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Post command failed: %v\n", err)
+		}
+	}
+}
+
+// pathList is a flag that may be repeated, collecting every value
+type pathList []string
+
+func (p *pathList) String() string { return strings.Join(*p, ", ") }
+
+func (p *pathList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// printExecutionSummary prints the counts of an execution and why files failed
+func printExecutionSummary(out io.Writer, result *ExecutionResult, config *Config) {
+	fmt.Fprintf(out, "\nExecution complete: %d files %s, %d failed\n", result.Moved, placedVerb(config.OrganizeMode), result.Failed)
+	if result.Skipped > 0 {
+		fmt.Fprintf(out, "%d files skipped (identical file already at destination)\n", result.Skipped)
+	}
+	if result.Resumed > 0 {
+		fmt.Fprintf(out, "%d files already placed by the interrupted run\n", result.Resumed)
+	}
+	if len(result.Changed) > 0 {
+		fmt.Fprintf(out, "%d files changed during the run and were left in place (run again to organize them):\n", len(result.Changed))
+		for i, path := range result.Changed {
+			if i == 10 {
+				fmt.Fprintf(out, "  ... and %d more\n", len(result.Changed)-10)
+				break
+			}
+			fmt.Fprintf(out, "  %s\n", path)
+		}
+	}
+	if result.VerifyFailed > 0 {
+		fmt.Fprintf(out, "%d files failed verification (the copy didn't match, the source was kept)\n", result.VerifyFailed)
+	}
+	if result.LinkFailed > 0 {
+		fmt.Fprintf(out, "%d files could not be hardlinked back to their source (hardlinks need the same filesystem)\n", result.LinkFailed)
+	}
+
+	const maxShown = 20
+	for i, err := range result.Errors {
+		if i == maxShown {
+			fmt.Fprintf(out, "  ... and %d more errors\n", len(result.Errors)-maxShown)
+			break
+		}
+		fmt.Fprintf(out, "  ✗ %v\n", err)
 	}
+}
 
-	// Command-line flags override config file
-	if *scanPath != "" {
-		config.ScanPath = *scanPath
+// runUndo reverses the most recent execution recorded in the journal
+func runUndo(config *Config) {
+	path, err := LatestJournal(config.LibraryBase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding journal: %v\n", err)
+		os.Exit(1)
 	}
-	if *libraryBase != "" {
-		config.LibraryBase = *libraryBase
+	if path == "" {
+		fmt.Println("Nothing to undo (no journal found)")
+		return
 	}
-	if *workers > 0 {
-		config.Workers = *workers
+
+	entries, err := ReadJournal(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading journal: %v\n", err)
+		os.Exit(1)
 	}
 
-	if *execute {
-		config.DryRun = false
+	fmt.Printf("Journal: %s\n", path)
+	if len(entries) > 0 {
+		fmt.Printf("Recorded %s: %d operations\n", entries[0].Time.Format("2006-01-02 15:04:05"), len(entries))
+	}
+	if config.DryRun {
+		for i := len(entries) - 1; i >= 0 && i >= len(entries)-10; i-- {
+			fmt.Printf("  [%s] %s → %s\n", entries[i].Operation, entries[i].NewPath, entries[i].OldPath)
+		}
+		if len(entries) > 10 {
+			fmt.Printf("  ... and %d more\n", len(entries)-10)
+		}
+		fmt.Println("\nThis was a DRY RUN. Use --undo --execute to reverse these operations.")
+		return
 	}
 
-	// Run with or without TUI
-	if *noTUI {
-		runCLI(config)
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Printf("Warning: cache disabled: %v\n", err)
+		cache = nil
 	} else {
-		runTUI(config)
+		defer cache.Close()
+	}
+
+	result, err := UndoJournal(path, config, cache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error undoing: %v\n", err)
+		os.Exit(1)
 	}
+	fmt.Printf("\nUndo complete: %d operations reversed, %d skipped, %d failed\n", result.Undone, result.Skipped, result.Failed)
 }
 
-func runCLI(config *Config) {
-	fmt.Println("Media Library Organizer")
-	fmt.Println("======================")
-	fmt.Println()
+// runEmptyTrash deletes the quarantine folders in the trash older than age
+func runEmptyTrash(config *Config, age time.Duration) {
+	cutoff := time.Now().Add(-age)
+	result, err := EmptyTrash(config.DuplicatesTrash, cutoff, config.DryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error emptying trash: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Configuration display
-	fmt.Println("Configuration:")
-	fmt.Printf("  Scan Path:    %s\n", config.ScanPath)
-	fmt.Printf("  Library:      %s\n", config.LibraryBase)
-	fmt.Printf("  Trash:        %s\n", config.DuplicatesTrash)
-	fmt.Printf("  Ollama Model: %s\n", config.OllamaModel)
-	fmt.Printf("  Workers:      %d\n", config.Workers)
-	if config.FileLimit > 0 {
-		fmt.Printf("  File Limit:   %d (testing mode)\n", config.FileLimit)
+	fmt.Printf("Trash: %s (runs before %s)\n", config.DuplicatesTrash, cutoff.Format("2006-01-02 15:04"))
+	for _, run := range result.Runs {
+		fmt.Printf("  %s\n", run)
 	}
-	if config.PruneCache {
-		fmt.Printf("  Cache Prune:  Enabled\n")
+	if config.DryRun {
+		fmt.Printf("Would delete %d runs: %d files, %s (%d newer runs kept)\n", len(result.Runs), result.Files, formatBytes(result.Bytes), result.Kept)
+		fmt.Println("\nThis was a DRY RUN. Use --empty-trash with --execute to delete these files.")
+		return
 	}
+	fmt.Printf("Deleted %d runs: %d files, %s (%d newer runs kept)\n", len(result.Runs), result.Files, formatBytes(result.Bytes), result.Kept)
+}
 
-	fmt.Println()
-	if config.DryRun {
-		fmt.Println("Mode: DRY RUN (no changes will be made)")
-	} else {
-		fmt.Println("Mode: EXECUTE (files will be moved)")
+// runClearSuggestions wipes the album suggestion cache
+func runClearSuggestions(config *Config) {
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Println()
+	defer cache.Close()
 
-	// Open cache
-	cache, err := OpenCache(config.LibraryBase)
+	albumCache, err := OpenAlbumSuggestionCache(cache)
 	if err != nil {
-		fmt.Printf("Warning: cache disabled: %v\n", err)
-		cache = nil
-	} else {
-		defer cache.Close()
-		total, withHash, withMetadata := cache.GetStats()
-		fmt.Printf("Cache: %d files (%d with hashes, %d with metadata)\n", total, withHash, withMetadata)
+		fmt.Fprintf(os.Stderr, "Error opening suggestion cache: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Println()
 
-	// Scan for media files
-	fmt.Println("Scanning for media files...")
-	files, err := ScanMediaFiles(config.ScanPath, config.FileLimit, nil)
+	cleared, err := albumCache.Clear()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error scanning: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error clearing suggestions: %v\n", err)
 		os.Exit(1)
 	}
+	fmt.Printf("Cleared %d album suggestions\n", cleared)
+}
 
-	fmt.Printf("Found %d media files\n", len(files))
+// runStats prints what the cache knows about the library
+func runStats(config *Config) {
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		os.Exit(1)
+	}
+	defer cache.Close()
 
-	// Prune deleted files from cache (auto when scanning all files, or when --prune-cache flag set)
-	if cache != nil && (config.FileLimit == 0 || config.PruneCache) {
-		validPaths := make(map[string]bool)
-		for _, f := range files {
-			validPaths[f.Path] = true
+	stats, err := cache.Stats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Library: %s (from the cache, as of the last scan)\n\n", config.LibraryBase)
+	fmt.Printf("Files:      %d (%s)\n", stats.Files, formatBytes(stats.TotalSize))
+	for _, t := range []MediaType{TypePhoto, TypeVideo, TypeMusic, TypeUnknown} {
+		if stats.ByType[t] > 0 {
+			fmt.Printf("  %-8s  %d\n", t.String()+":", stats.ByType[t])
 		}
-		pruned, err := cache.PruneDeleted(validPaths)
-		if err == nil && pruned > 0 {
-			fmt.Printf("  Pruned %d deleted files from cache\n", pruned)
+	}
+	if stats.Earliest != nil {
+		fmt.Printf("Dates:      %s to %s\n", stats.Earliest.Format("2006-01-02"), stats.Latest.Format("2006-01-02"))
+	}
+	if len(stats.Cameras) > 0 {
+		fmt.Println("Cameras:")
+		for _, camera := range stats.Cameras {
+			fmt.Printf("  %-30s %d\n", camera.Camera, camera.Files)
 		}
 	}
-	fmt.Printf("  Photos: %d\n", countByType(files, TypePhoto))
-	fmt.Printf("  Videos: %d\n", countByType(files, TypeVideo))
-	fmt.Printf("  Music:  %d\n", countByType(files, TypeMusic))
-	newCount := countNewFiles(files)
-	if cache != nil {
-		fmt.Printf("  New files: %d (rest already in library)\n", newCount)
+	fmt.Printf("Duplicates: %d groups (%d files)\n", stats.DuplicateGroups, stats.DuplicateFiles)
+	if stats.Files > 0 {
+		fmt.Printf("Cache:      %d with hashes (%d%%), %d with metadata (%d%%)\n",
+			stats.WithHash, stats.WithHash*100/stats.Files, stats.WithMetadata, stats.WithMetadata*100/stats.Files)
 	}
-	fmt.Println()
+}
 
-	// Extract metadata
-	fmt.Println("Extracting metadata...")
-	metadataProgress := make(chan ScanProgress, 10)
-	go func() {
-		for prog := range metadataProgress {
-			if prog.TotalFiles > 0 {
-				percent := float64(prog.ProcessedFiles) * 100 / float64(prog.TotalFiles)
-				currentFile := truncateFilePath(prog.CurrentFile, 60)
-				fmt.Printf("\r  Progress: [%-50s] %3.0f%% (%d/%d) %s",
-					progressBar(percent),
-					percent,
-					prog.ProcessedFiles,
-					prog.TotalFiles,
-					currentFile)
-			}
-		}
-		fmt.Printf("\r%s\r", strings.Repeat(" ", 150)) // Clear line
-	}()
+func runCompactCache(config *Config) {
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		os.Exit(1)
+	}
+	defer cache.Close()
 
-	metadataHits := ProcessMetadata(files, config.Workers, metadataProgress, cache)
-	close(metadataProgress)
+	reclaimed, err := cache.Compact()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error compacting cache: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Compacted cache, reclaimed %s\n", formatBytes(max(reclaimed, 0)))
+}
 
-	if cache != nil {
-		fmt.Printf("Done (%d from cache, %d processed)\n", metadataHits, len(files)-metadataHits)
-	} else {
-		fmt.Println("Done")
+func runExportCache(config *Config, path string) {
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Println()
+	defer cache.Close()
 
-	// Calculate hashes
-	fmt.Println("Calculating hashes for duplicate detection...")
-	hashProgress := make(chan ScanProgress, 10)
-	go func() {
-		for prog := range hashProgress {
-			if prog.TotalFiles > 0 {
-				percent := float64(prog.ProcessedFiles) * 100 / float64(prog.TotalFiles)
-				currentFile := truncateFilePath(prog.CurrentFile, 60)
-				fmt.Printf("\r  Progress: [%-50s] %3.0f%% (%d/%d) %s",
-					progressBar(percent),
-					percent,
-					prog.ProcessedFiles,
-					prog.TotalFiles,
-					currentFile)
-			}
+	out := os.Stdout
+	if path != "-" {
+		if out, err = os.Create(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating export: %v\n", err)
+			os.Exit(1)
 		}
-		fmt.Printf("\r%s\r", strings.Repeat(" ", 150)) // Clear line
-	}()
+	}
 
-	hashHits := CalculateHashes(files, config.Workers, hashProgress, cache)
-	close(hashProgress)
+	count, err := cache.ExportJSON(out)
+	if path != "-" {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting cache: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d cache entries\n", count)
+}
 
-	if cache != nil {
-		fmt.Printf("Done (%d from cache, %d calculated)\n", hashHits, len(files)-hashHits)
-	} else {
-		fmt.Println("Done")
+func runImportCache(config *Config, path string) {
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Println()
+	defer cache.Close()
 
-	// Find duplicates
-	fmt.Println("Finding duplicates...")
-	duplicates := FindDuplicates(files)
-	fmt.Printf("Found %d duplicate groups\n", len(duplicates))
+	in := os.Stdin
+	if path != "-" {
+		if in, err = os.Open(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening export: %v\n", err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	count, err := cache.ImportJSON(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing cache: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d cache entries\n", count)
+}
+
+// runFindDuplicates scans the library (and optionally the scan path) and reports duplicates without changing anything
+func runFindDuplicates(config *Config, includeScanPath bool) {
+	fmt.Println("Duplicate Report")
+	fmt.Println("================")
 	fmt.Println()
 
-	// Organize into albums
-	fmt.Println("Organizing into albums...")
-	var albumCache *AlbumSuggestionCache
-	if cache != nil {
-		albumCache, _ = OpenAlbumSuggestionCache(cache)
+	roots := config.libraryRoots()
+	if includeScanPath {
+		for _, path := range config.ScanPaths {
+			if !slices.Contains(roots, path) {
+				roots = append(roots, path)
+			}
+		}
+	}
+
+	cache, err := openConfiguredCache(config)
+	if err != nil {
+		fmt.Printf("Warning: cache disabled: %v\n", err)
+		cache = nil
+	} else {
+		defer cache.Close()
 	}
-	albums, err := OrganizeIntoAlbums(files, config, nil, albumCache)
+
+	// Scan all roots at once, files seen under several are counted once
+	fmt.Printf("Scanning %s...\n", strings.Join(roots, ", "))
+	rootConfig := *config
+	rootConfig.ScanPaths = roots
+	rootConfig.FilesFrom = ""
+	files, err := ScanMediaFiles(&rootConfig, nil, cache)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error organizing: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error scanning: %v\n", err)
 		os.Exit(1)
 	}
+	fmt.Printf("Found %d media files\n\n", len(files))
 
-	fmt.Printf("Created %d albums\n", len(albums))
-	fmt.Println()
+	// Ctrl-C stops hashing, keeping what was cached so far, instead of killing the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Show summary
-	if len(albums) == 0 {
-		fmt.Println("No new files to organize! All files are already in the library.")
-		return
+	// Metadata is used to pick the best copy in each group
+	fmt.Println("Extracting metadata...")
+	ProcessMetadata(ctx, files, config.Workers, nil, cache)
+
+	fmt.Println("Calculating hashes...")
+	hashHits := CalculateHashes(ctx, files, config.Workers, config.HashAlgorithm, nil, cache)
+	if cache != nil && ctx.Err() == nil {
+		fmt.Printf("Done (%d from cache, %d checked)\n", hashHits, len(files)-hashHits)
+	}
+	if config.ContentHash {
+		CalculateContentHashes(ctx, files, config.Workers, nil, cache)
+	}
+	if ctx.Err() != nil {
+		fmt.Println("\nInterrupted: the report would be incomplete")
+		if cache != nil {
+			cache.Close() // os.Exit skips the deferred Close
+		}
+		os.Exit(130)
 	}
+	fmt.Println()
 
-	totalFilesToMove := 0
-	for _, album := range albums {
-		totalFilesToMove += len(album.Files)
+	duplicates := FindDuplicates(files, config.DedupThreshold, &config.DuplicatePolicy)
+	reclaimable := ReclaimableBytes(duplicates)
+	dupFiles := 0
+	for _, group := range duplicates {
+		dupFiles += len(group.Files) - 1
 	}
 
-	fmt.Println("Organization Plan:")
-	fmt.Println("==================")
-	fmt.Printf("Found %d new/moved files to organize into %d albums\n\n", totalFilesToMove, len(albums))
-	for i, album := range albums {
-		if i >= 10 {
-			fmt.Printf("... and %d more albums\n", len(albums)-10)
-			break
+	// Largest waste first
+	sort.Slice(duplicates, func(i, j int) bool {
+		wi := duplicates[i].Best.Size * int64(len(duplicates[i].Files)-1)
+		wj := duplicates[j].Best.Size * int64(len(duplicates[j].Files)-1)
+		if wi != wj {
+			return wi > wj
+		}
+		return duplicates[i].Best.Path < duplicates[j].Best.Path
+	})
+
+	for _, group := range duplicates {
+		fmt.Printf("%s x%d\n", formatBytes(group.Best.Size), len(group.Files))
+		fmt.Printf("  keep: %s\n", group.Best.Path)
+		for _, f := range group.Files {
+			if f != group.Best {
+				fmt.Printf("  dup:  %s\n", f.Path)
+			}
 		}
-		fmt.Printf("%s\n", album.Name)
-		fmt.Printf("  → %s\n", album.Destination)
-		fmt.Printf("  → %d files\n", len(album.Files))
 		fmt.Println()
 	}
 
-	if config.DryRun {
-		fmt.Println("This was a DRY RUN. Use --execute to actually organize files.")
-	} else {
-		// Execute the organization
-		fmt.Println("\nExecuting organization...")
-		execProgress := make(chan ScanProgress, 10)
-		go func() {
-			for prog := range execProgress {
-				if prog.TotalFiles > 0 {
-					percent := float64(prog.ProcessedFiles) * 100 / float64(prog.TotalFiles)
-					currentFile := truncateFilePath(prog.CurrentFile, 60)
-					fmt.Printf("\r  Progress: [%-50s] %3.0f%% (%d/%d) %s",
-						progressBar(percent),
-						percent,
-						prog.ProcessedFiles,
-						prog.TotalFiles,
-						currentFile)
-				}
-			}
-			fmt.Printf("\r%s\r", strings.Repeat(" ", 150)) // Clear line
-		}()
+	fmt.Println("Summary:")
+	fmt.Printf("  Duplicate groups: %d\n", len(duplicates))
+	fmt.Printf("  Duplicate files:  %d\n", dupFiles)
+	fmt.Printf("  Reclaimable:      %s\n", formatBytes(reclaimable))
 
-		if err := ExecuteOrganization(albums, duplicates, config, execProgress, cache); err != nil {
-			close(execProgress)
-			fmt.Fprintf(os.Stderr, "Error executing: %v\n", err)
+	if config.DupReportPath != "" {
+		if err := WriteDuplicateReport(config.DupReportPath, duplicates, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing duplicate report: %v\n", err)
 			os.Exit(1)
 		}
-		close(execProgress)
+		fmt.Printf("\nDuplicate report written to %s\n", config.DupReportPath)
 	}
 }
 
 func runTUI(config *Config) {
-	p := tea.NewProgram(initialModel(config), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	// Cancelled on quit, so a slow naming request doesn't outlive the UI
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := tea.NewProgram(initialModel(ctx, config), tea.WithAltScreen())
+	final, err := p.Run()
+
+	// Commit queued cache updates (quitting waits for the workers writing to the cache)
+	if m, ok := final.(model); ok && m.cache != nil {
+		m.cache.Close()
+	}
+
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// executionSummary is what executing a plan changes on disk
+type executionSummary struct {
+	Files      int // Placed into the library (moved, copied or linked)
+	FileBytes  int64
+	Trash      int // Duplicates moved to the trash (move mode only)
+	TrashBytes int64
+}
+
+func summarizeExecution(albums []*Album, duplicates []*DuplicateGroup, config *Config) executionSummary {
+	var summary executionSummary
+	for _, album := range albums {
+		for _, file := range album.Files {
+			summary.Files++
+			summary.FileBytes += file.Size
+		}
+	}
+	if config.OrganizeMode == OrganizeMove {
+		for _, group := range duplicates {
+			for _, file := range group.Files {
+				if file != group.Best {
+					summary.Trash++
+					summary.TrashBytes += file.Size
+				}
+			}
+		}
+	}
+	return summary
+}
+
+// Operations is the number of files touched, checked against --max-moves
+func (s executionSummary) Operations() int {
+	return s.Files + s.Trash
+}
+
+// stdinIsTerminal reports whether someone can answer prompts (a variable so tests can
+// answer them from a file)
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmExecute enforces the max-moves guardrail and, unless --yes, shows what will be
+// changed and asks for confirmation
+func confirmExecute(out io.Writer, config *Config, summary executionSummary) bool {
+	operations := summary.Operations()
+	if config.MaxMoves > 0 && operations > config.MaxMoves && !config.Force {
+		fmt.Fprintf(out, "Plan has %d file operations, more than the limit of %d.\n", operations, config.MaxMoves)
+		fmt.Fprintln(out, "Re-run with --force to execute anyway. No files were changed.")
+		return false
+	}
+
+	if config.AssumeYes {
+		return true
+	}
+
+	// Without a terminal there is nobody to ask
+	if !stdinIsTerminal() {
+		fmt.Fprintln(out, "Not running interactively. Re-run with --yes to execute without prompting. No files were changed.")
+		return false
+	}
+
+	fmt.Fprintf(out, "About to %s %d files (%s) into the library", config.OrganizeMode, summary.Files, formatBytes(summary.FileBytes))
+	if summary.Trash > 0 {
+		fmt.Fprintf(out, " and move %d duplicates (%s) to the trash", summary.Trash, formatBytes(summary.TrashBytes))
+	}
+	fmt.Fprintln(out, ".")
+	fmt.Fprint(out, "Proceed? [y/N]: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(out, "\nNo answer. Re-run with --yes to execute without prompting. No files were changed.")
+		return false
+	}
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	if answer != "y" && answer != "yes" {
+		fmt.Fprintln(out, "Aborted. No files were changed.")
+		return false
+	}
+	return true
+}
+
+// openConfiguredCache opens the cache with the options from config
+func openConfiguredCache(config *Config) (*Cache, error) {
+	cache, err := OpenCache(config.LibraryBase, config.CachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ThreadsSQLite {
+		if err := cache.EnableReadPool(config.Workers); err != nil {
+			cache.Close()
+			return nil, err
+		}
+	}
+
+	return cache, nil
+}
+
 func countByType(files []*MediaFile, mediaType MediaType) int {
 	count := 0
 	for _, f := range files {
@@ -322,6 +1848,14 @@ func countNewFiles(files []*MediaFile) int {
 }
 
 // progressBar creates a text progress bar
+// progressCounts shows how many files a phase has done, and how many bytes if it counts them
+func progressCounts(prog ScanProgress) string {
+	if prog.TotalBytes > 0 {
+		return fmt.Sprintf("%d/%d, %s/%s", prog.ProcessedFiles, prog.TotalFiles, formatBytes(prog.ProcessedBytes), formatBytes(prog.TotalBytes))
+	}
+	return fmt.Sprintf("%d/%d", prog.ProcessedFiles, prog.TotalFiles)
+}
+
 func progressBar(percent float64) string {
 	const width = 50
 	filled := int(percent / 2) // 50 chars = 100%
@@ -341,6 +1875,49 @@ func progressBar(percent float64) string {
 	return bar
 }
 
+// formatBytes renders a byte count in human-readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseByteSize parses sizes like "512", "100KB", "1.5M" or "2GB" (binary units)
+func parseByteSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := int64(1)
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			str = str[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
 // truncateFilePath shortens a file path for display
 func truncateFilePath(path string, maxLen int) string {
 	if len(path) <= maxLen {
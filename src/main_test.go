@@ -0,0 +1,74 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeclinedPromptMovesNothing(t *testing.T) {
+	isTerminal := stdinIsTerminal
+	stdin := os.Stdin
+	t.Cleanup(func() { stdinIsTerminal, os.Stdin = isTerminal, stdin })
+	stdinIsTerminal = func() bool { return true }
+
+	tests := []struct {
+		answer string
+		moved  bool
+	}{
+		{"n\n", false},
+		{"\n", false}, // No is the default
+		{"", false},   // Stdin closed without an answer
+		{"YES\n", true},
+	}
+	for _, tt := range tests {
+		album, config := newTestAlbum(t, "a.jpg", "b.jpg", "c.jpg")
+		config.MinAlbumFiles = defaultMinAlbumFiles
+		config.NoDateFolder = defaultNoDateFolder
+		// A duplicate, so the prompt mentions the trash too
+		if err := os.WriteFile(filepath.Join(album.SourceDirs[0], "a copy.jpg"), []byte("a.jpg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		root := filepath.Dir(config.LibraryBase)
+		before := treeOf(t, root)
+
+		answer := filepath.Join(t.TempDir(), "answer")
+		if err := os.WriteFile(answer, []byte(tt.answer), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdin = f
+		output := string(captureStdout(t, func() { runCLI(config) }))
+		f.Close()
+
+		if !strings.Contains(output, "About to move 4 files (20 B) into the library and move 1 duplicates (5 B) to the trash.") {
+			t.Errorf("answer %q: prompt without the summary:\n%s", tt.answer, output)
+		}
+		if tt.moved {
+			if left := treeOf(t, album.SourceDirs[0]); len(left) != 0 {
+				t.Errorf("answer %q: %d files left in the scan folder", tt.answer, len(left))
+			}
+			continue
+		}
+		if !strings.Contains(output, "No files were changed.") {
+			t.Errorf("answer %q: no abort message:\n%s", tt.answer, output)
+		}
+		// Only the cache in the library may have been written
+		after := treeOf(t, root)
+		for path := range after {
+			if strings.Contains(path, ".media-organizer-cache") {
+				delete(after, path)
+			}
+		}
+		if !maps.Equal(after, before) {
+			t.Errorf("answer %q: tree changed from %v to %v", tt.answer,
+				slices.Sorted(maps.Keys(before)), slices.Sorted(maps.Keys(after)))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonEvents writes the CLI's newline-delimited JSON events (--output json), one per
+// phase. A nil *jsonEvents writes nothing, so the CLI emits unconditionally.
+type jsonEvents struct {
+	enc *json.Encoder
+}
+
+func newJSONEvents(w io.Writer) *jsonEvents {
+	return &jsonEvents{enc: json.NewEncoder(w)}
+}
+
+// emit writes one event (a struct whose first field is its "event" name)
+func (e *jsonEvents) emit(event any) {
+	if e == nil {
+		return
+	}
+	e.enc.Encode(event)
+}
+
+// Events in the order the CLI emits them
+type scanEvent struct {
+	Event  string `json:"event"` // "scan"
+	Files  int    `json:"files"`
+	Photos int    `json:"photos"`
+	Videos int    `json:"videos"`
+	Music  int    `json:"music"`
+	New    int    `json:"new"` // Not in the cache yet (all files without a cache)
+	Pruned int64  `json:"pruned"`
+}
+
+type phaseEvent struct {
+	Event     string `json:"event"` // "metadata", "hashes" or "perceptual_hashes"
+	Files     int    `json:"files"`
+	FromCache int    `json:"from_cache"`
+}
+
+type duplicatesEvent struct {
+	Event          string `json:"event"` // "duplicates"
+	Groups         int    `json:"groups"`
+	Files          int    `json:"files"`       // Files that would be trashed
+	Reclaimable    int64  `json:"reclaimable"` // Bytes freed by trashing them
+	NearDuplicates int    `json:"near_duplicate_groups"`
+}
+
+type planEvent struct {
+	Event         string           `json:"event"` // "plan"
+	DryRun        bool             `json:"dry_run"`
+	Files         int              `json:"files"`
+	NewAlbums     int              `json:"new_albums"`
+	GrowingAlbums int              `json:"growing_albums"`
+	NewFiles      int              `json:"new_files"`
+	Relocations   int              `json:"relocations"`
+	Conflicts     int              `json:"conflicts"`
+	Collisions    int              `json:"collisions"`
+	Renames       int              `json:"renames"`
+	Copies        int              `json:"copies"`
+	CopyBytes     int64            `json:"copy_bytes"`
+	Albums        []planEventAlbum `json:"albums"`
+}
+
+type planEventAlbum struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Type        string `json:"type"`
+	Files       int    `json:"files"`
+	Existing    bool   `json:"existing"`
+}
+
+type abortedEvent struct {
+	Event      string `json:"event"` // "aborted": over --max-moves, nothing was changed
+	Operations int    `json:"operations"`
+	MaxMoves   int    `json:"max_moves"`
+}
+
+type resultEvent struct {
+	Event        string   `json:"event"` // "result"
+	Moved        int      `json:"moved"`
+	Failed       int      `json:"failed"`
+	VerifyFailed int      `json:"verify_failed"`
+	Skipped      int      `json:"skipped"`
+	Resumed      int      `json:"resumed"`
+	LinkFailed   int      `json:"link_failed"`
+	Changed      []string `json:"changed"`
+	Errors       []string `json:"errors"`
+	Cancelled    bool     `json:"cancelled"`
+	PostCommand  string   `json:"post_command_error,omitempty"`
+	ExitCode     int      `json:"exit_code"`
+}
+
+type errorEvent struct {
+	Event   string `json:"event"` // "error", the run stops with exit code 1
+	Message string `json:"message"`
+}
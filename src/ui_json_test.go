@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// captureStdout runs fn with os.Stdout redirected to a file and returns what it wrote
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	saved := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = saved }()
+	fn()
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestJSONOutputEvents(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg", "b.jpg", "c.jpg")
+	source := album.SourceDirs[0]
+	// An identical copy of a.jpg, trashed as a duplicate
+	if err := os.WriteFile(filepath.Join(source, "a copy.jpg"), []byte("a.jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.Output = OutputJSON
+	config.AssumeYes = true
+	config.MinAlbumFiles = defaultMinAlbumFiles
+	config.NoDateFolder = defaultNoDateFolder
+
+	stdout := captureStdout(t, func() { runCLI(config) })
+
+	// Every line is one event, decoded strictly into its struct: no unknown or missing fields
+	var events []any
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	for scanner.Scan() {
+		var head struct {
+			Event string `json:"event"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &head); err != nil {
+			t.Fatalf("stdout line %q is not JSON: %v", scanner.Text(), err)
+		}
+		var event any
+		switch head.Event {
+		case "scan":
+			event = &scanEvent{}
+		case "metadata", "hashes":
+			event = &phaseEvent{}
+		case "duplicates":
+			event = &duplicatesEvent{}
+		case "plan":
+			event = &planEvent{}
+		case "result":
+			event = &resultEvent{}
+		default:
+			t.Fatalf("unexpected event %q", scanner.Text())
+		}
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(event); err != nil {
+			t.Fatalf("event %q: %v", scanner.Text(), err)
+		}
+		var fields map[string]any
+		json.Unmarshal(scanner.Bytes(), &fields)
+		want, _ := json.Marshal(event)
+		var wantFields map[string]any
+		json.Unmarshal(want, &wantFields)
+		for name := range wantFields {
+			if _, ok := fields[name]; !ok {
+				t.Errorf("event %q lacks field %q", head.Event, name)
+			}
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 6 {
+		t.Fatalf("got %d events, want scan, metadata, hashes, duplicates, plan, result:\n%s", len(events), stdout)
+	}
+	scan, ok := events[0].(*scanEvent)
+	if !ok || scan.Files != 4 || scan.Photos != 4 {
+		t.Errorf("first event %+v, want a scan of 4 photos", events[0])
+	}
+	for i, name := range []string{"metadata", "hashes"} {
+		if phase, ok := events[1+i].(*phaseEvent); !ok || phase.Event != name || phase.Files != 4 {
+			t.Errorf("event %d is %+v, want %s of 4 files", 1+i, events[1+i], name)
+		}
+	}
+	if dups, ok := events[3].(*duplicatesEvent); !ok || dups.Groups != 1 || dups.Files != 1 || dups.Reclaimable != 5 {
+		t.Errorf("event 3 is %+v, want 1 duplicate group reclaiming 5 bytes", events[3])
+	}
+	plan, ok := events[4].(*planEvent)
+	if !ok || plan.DryRun || plan.Files != 4 || len(plan.Albums) != 1 || plan.Albums[0].Type != "Photo" {
+		t.Errorf("event 4 is %+v, want a plan of 4 files in one photo album", events[4])
+	}
+	result, ok := events[5].(*resultEvent)
+	if !ok || result.Moved != 5 || result.Failed != 0 || result.ExitCode != 0 || result.Errors == nil {
+		t.Errorf("last event %+v, want 4 files moved and 1 trashed with exit code 0", events[5])
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/progress"
@@ -23,6 +25,10 @@ const (
 )
 
 type model struct {
+	ctx         context.Context // Cancelled when the program exits, or by quitting while files are processed
+	cancel      context.CancelFunc
+	cancelling  bool // Quit requested, waiting for the workers to finish their current file
+	pause       *Pauser // Holds the metadata, hashing and executing workers between files (p key)
 	config      *Config
 	currentPhase phase
 	spinner      spinner.Model
@@ -32,6 +38,9 @@ type model struct {
 	files       []*MediaFile
 	albums      []*Album
 	duplicates  []*DuplicateGroup
+	nearDuplicates []*DuplicateGroup
+	planDiff    *PlanDiff
+	planEstimate *PlanEstimate
 
 	// Progress tracking
 	scanProgress ScanProgress
@@ -44,7 +53,7 @@ type model struct {
 	// Progress channels for async updates
 	metadataProgress chan ScanProgress
 	hashProgress     chan ScanProgress
-	organizeProgress chan string
+	organizeProgress chan ScanProgress
 
 	// UI state
 	selectedAlbum int
@@ -52,8 +61,29 @@ type model struct {
 	width         int
 	height        int
 
+	// Albums left out of this run (everything is selected by default)
+	deselected map[*Album]bool
+
+	// Duplicate groups shown instead of the albums in review
+	showDuplicates bool
+	dupScroll int
+
+	// Album whose files are listed instead of the albums in review
+	expanded   *Album
+	fileScroll int
+
+	// Album rename in review
+	editing   bool
+	editInput []rune
+	editErr   string
+
+	// Album name filter in review; selectedAlbum and scrollOffset index the filtered list
+	filtering   bool
+	filterInput []rune
+
 	// Error
-	err error
+	err        error
+	execErrors []error // Per-file failures of the execution
 }
 
 type scanCompleteMsg struct {
@@ -63,20 +93,31 @@ type scanCompleteMsg struct {
 type metadataCompleteMsg struct{}
 type hashingCompleteMsg struct{}
 type executionCompleteMsg struct {
-	moved  int
-	failed int
+	moved     int
+	failed    int
+	changed   int     // Modified since the scan, left in place
+	errors    []error // Per-file failures
+	postErr   error   // Post command failure, if one ran
+	cancelled bool    // Stopped early by quitting, the rest was left in place
 }
 
 type albumsReadyMsg struct {
 	albums []*Album
 	duplicates []*DuplicateGroup
+	nearDuplicates []*DuplicateGroup // Similar photos, reported only
+	diff       *PlanDiff
+	estimate   *PlanEstimate
 }
 
 type progressMsg ScanProgress
 type statusMsg string
 type errMsg error
 
-func initialModel(config *Config) model {
+func initialModel(ctx context.Context, config *Config) model {
+	ctx, cancel := context.WithCancel(ctx)
+	pause := &Pauser{}
+	ctx = withPauser(ctx, pause)
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
@@ -89,13 +130,16 @@ func initialModel(config *Config) model {
 	p.Width = 60
 
 	// Open cache
-	cache, _ := OpenCache(config.LibraryBase)
+	cache, _ := openConfiguredCache(config)
 	var albumCache *AlbumSuggestionCache
 	if cache != nil {
 		albumCache, _ = OpenAlbumSuggestionCache(cache)
 	}
 
 	return model{
+		ctx:          ctx,
+		cancel:       cancel,
+		pause:        pause,
 		config:       config,
 		spinner:      s,
 		progress:     p,
@@ -108,7 +152,7 @@ func initialModel(config *Config) model {
 func (m model) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
-		scanFiles(m.config),
+		scanFiles(m.config, m.cache),
 	)
 }
 
@@ -126,16 +170,47 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.editing {
+			return m.updateEditing(msg)
+		}
+		if m.filtering {
+			return m.updateFiltering(msg)
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
+			switch m.currentPhase {
+			case phaseMetadata, phaseHashing, phaseExecuting:
+				// Let the workers finish the file at hand, their completion message quits
+				if !m.cancelling {
+					m.cancelling = true
+					m.cancel()
+					m.statusMsg = "Cancelling, finishing the current file..."
+				}
+				return m, nil
+			}
 			return m, tea.Quit
 
+		case "p":
+			// Pause between files, e.g. on battery; resuming also works after the phase moved on
+			switch m.currentPhase {
+			case phaseMetadata, phaseHashing, phaseExecuting:
+				if !m.cancelling {
+					m.pause.Toggle()
+				}
+			default:
+				if m.pause.Paused() {
+					m.pause.Toggle()
+				}
+			}
+			return m, nil
+
 		case "y", "a", "enter":
 			// Accept plan and execute
 			if m.currentPhase == phaseReview {
 				m.currentPhase = phaseExecuting
 				m.statusMsg = "Moving files..."
-				return m, executeOrganization(m.config, m.albums, m.duplicates, m.cache)
+				return m, executeOrganization(m.ctx, m.config, m.selectedAlbums(), m.duplicates, m.cache)
 			}
 			if m.currentPhase == phaseDone {
 				return m, tea.Quit
@@ -147,8 +222,73 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			}
 
+		case "e":
+			// Rename the selected album
+			if albums := m.visibleAlbums(); m.currentPhase == phaseReview && m.expanded == nil && len(albums) > 0 {
+				album := albums[m.selectedAlbum]
+				m.editErr = ""
+				if !album.CanRename() {
+					m.editErr = fmt.Sprintf("%s can't be renamed", album.Name)
+					return m, nil
+				}
+				m.editing = true
+				m.editInput = []rune(album.Name)
+			}
+
+		case " ":
+			// Select or deselect the current album
+			if albums := m.visibleAlbums(); m.currentPhase == phaseReview && len(albums) > 0 {
+				m.toggleAlbum(albums[m.selectedAlbum])
+			}
+
+		case "/":
+			// Filter the albums by name
+			if m.currentPhase == phaseReview && !m.showDuplicates && m.expanded == nil {
+				m.filtering = true
+				m.editErr = ""
+			}
+
+		case "right", "l":
+			// List the files of the selected album
+			if albums := m.visibleAlbums(); m.currentPhase == phaseReview && !m.showDuplicates && len(albums) > 0 {
+				m.expanded = albums[m.selectedAlbum]
+				m.fileScroll = 0
+				m.editErr = ""
+			}
+
+		case "left", "h":
+			// Back from the file list to the albums
+			m.expanded = nil
+
+		case "esc":
+			// Back from the file list, or clear the album filter
+			if m.expanded != nil {
+				m.expanded = nil
+			} else if m.currentPhase == phaseReview && len(m.filterInput) > 0 {
+				m.setFilter(nil)
+			}
+
+		case "t":
+			// Select all, or deselect all if everything is selected
+			if m.currentPhase == phaseReview {
+				m.toggleAllAlbums()
+			}
+
+		case "d":
+			// Switch between the albums and the duplicate groups
+			if m.currentPhase == phaseReview {
+				m.showDuplicates = !m.showDuplicates
+				m.dupScroll = 0
+				m.expanded = nil
+			}
+
 		case "up", "k":
-			if m.currentPhase == phaseReview && m.selectedAlbum > 0 {
+			m.editErr = ""
+			if m.showDuplicates {
+				m.dupScroll = max(m.dupScroll-1, 0)
+			} else if m.expanded != nil {
+				m.fileScroll = max(m.fileScroll-1, 0)
+			} else if m.currentPhase == phaseReview && m.selectedAlbum > 0 {
 				m.selectedAlbum--
 				if m.selectedAlbum < m.scrollOffset {
 					m.scrollOffset = m.selectedAlbum
@@ -156,13 +296,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "down", "j":
-			if m.currentPhase == phaseReview && m.selectedAlbum < len(m.albums)-1 {
+			m.editErr = ""
+			if m.showDuplicates {
+				lines := 0
+				for _, group := range m.duplicates {
+					lines += 1 + len(group.Files) // Header, kept and trashed files
+				}
+				m.dupScroll = min(m.dupScroll+1, max(lines-(m.height-15), 0))
+			} else if m.expanded != nil {
+				m.fileScroll = min(m.fileScroll+1, m.maxFileScroll())
+			} else if m.currentPhase == phaseReview && m.selectedAlbum < len(m.visibleAlbums())-1 {
 				m.selectedAlbum++
 				maxVisible := m.height - 15
 				if m.selectedAlbum >= m.scrollOffset+maxVisible {
 					m.scrollOffset = m.selectedAlbum - maxVisible + 1
 				}
 			}
+
+		case "pgup":
+			if m.expanded != nil {
+				m.fileScroll = max(m.fileScroll-(m.height-15), 0)
+			}
+
+		case "pgdown":
+			if m.expanded != nil {
+				m.fileScroll = min(m.fileScroll+(m.height-15), m.maxFileScroll())
+			}
 		}
 
 	case spinner.TickMsg:
@@ -179,6 +338,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.currentPhase == phaseHashing && m.hashProgress != nil {
 			return m, waitForProgress(m.hashProgress)
 		}
+		if m.currentPhase == phaseOrganizing && m.organizeProgress != nil {
+			return m, waitForProgress(m.organizeProgress)
+		}
 		return m, nil
 
 	case statusMsg:
@@ -191,13 +353,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.scanProgress.ProcessedFiles = 0
 		m.scanProgress.CurrentFile = ""
 
-		// Prune deleted files from cache (auto when scanning all files, or when --prune-cache flag set)
-		if m.cache != nil && (m.config.FileLimit == 0 || m.config.PruneCache) {
+		// Prune deleted files from cache (auto on full scans, or when --prune-cache flag set)
+		if m.cache != nil && (!m.config.IsPartialScan() || m.config.PruneCache) {
 			validPaths := make(map[string]bool)
 			for _, f := range m.files {
 				validPaths[f.Path] = true
 			}
-			m.cache.PruneDeleted(validPaths)
+			m.cache.PruneDeleted(validPaths, m.config.pruneRoots(), m.config.pruneKeep())
 		}
 
 		m.currentPhase = phaseMetadata
@@ -210,11 +372,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Create progress channel and start listening
 		m.metadataProgress = make(chan ScanProgress, 100)
 		return m, tea.Batch(
-			processMetadata(m.config, m.files, m.cache, m.metadataProgress),
+			processMetadata(m.ctx, m.config, m.files, m.cache, m.metadataProgress),
 			waitForProgress(m.metadataProgress),
 		)
 
 	case metadataCompleteMsg:
+		if m.cancelling {
+			return m, tea.Quit
+		}
 		m.currentPhase = phaseHashing
 		m.scanProgress.TotalFiles = 0     // Reset for next phase
 		m.scanProgress.ProcessedFiles = 0
@@ -228,25 +393,55 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Create progress channel and start listening
 		m.hashProgress = make(chan ScanProgress, 100)
 		return m, tea.Batch(
-			calculateHashes(m.config, m.files, m.cache, m.hashProgress),
+			calculateHashes(m.ctx, m.config, m.files, m.cache, m.hashProgress),
 			waitForProgress(m.hashProgress),
 		)
 
 	case hashingCompleteMsg:
+		if m.cancelling {
+			return m, tea.Quit
+		}
 		m.currentPhase = phaseOrganizing
+		m.scanProgress.TotalFiles = 0 // Reset for next phase
+		m.scanProgress.ProcessedFiles = 0
+		m.scanProgress.TotalBytes, m.scanProgress.ProcessedBytes = 0, 0
+		m.scanProgress.CurrentFile = ""
 		m.statusMsg = "Organizing into albums..."
-		return m, organizeFiles(m.config, m.files, m.albumCache)
+
+		// Progress counts folders whose album name is settled
+		m.organizeProgress = make(chan ScanProgress, 100)
+		return m, tea.Batch(
+			organizeFiles(m.ctx, m.config, m.files, m.cache, m.albumCache, m.organizeProgress),
+			waitForProgress(m.organizeProgress),
+		)
 
 	case albumsReadyMsg:
+		// A pause outlasting the processing phases doesn't carry over to executing
+		if m.pause.Paused() {
+			m.pause.Toggle()
+		}
 		m.albums = msg.albums
 		m.duplicates = msg.duplicates
+		m.nearDuplicates = msg.nearDuplicates
+		m.planDiff = msg.diff
+		m.planEstimate = msg.estimate
 		m.currentPhase = phaseReview
 		m.statusMsg = "Review organization plan"
 		return m, nil
 
 	case executionCompleteMsg:
 		m.currentPhase = phaseDone
-		m.statusMsg = fmt.Sprintf("Complete! %d files moved, %d failed", msg.moved, msg.failed)
+		m.statusMsg = fmt.Sprintf("Complete! %d files %s, %d failed", msg.moved, placedVerb(m.config.OrganizeMode), msg.failed)
+		if msg.cancelled {
+			m.statusMsg = fmt.Sprintf("Cancelled: %d files %s, %d failed, the rest were left in place (--resume continues)", msg.moved, placedVerb(m.config.OrganizeMode), msg.failed)
+		}
+		if msg.changed > 0 {
+			m.statusMsg += fmt.Sprintf(", %d changed during the run (left in place)", msg.changed)
+		}
+		m.execErrors = msg.errors
+		if msg.postErr != nil {
+			m.statusMsg += fmt.Sprintf(" (post command failed: %v)", msg.postErr)
+		}
 		return m, nil
 
 	case errMsg:
@@ -285,13 +480,19 @@ func (m model) View() string {
 			Foreground(lipgloss.Color("240")).
 			MarginLeft(2)
 		modeStr := map[bool]string{true: "DRY RUN", false: "EXECUTE"}[m.config.DryRun]
+		if m.config.Simulate {
+			modeStr = "SIMULATE"
+		}
 		limitStr := ""
 		if m.config.FileLimit > 0 {
 			limitStr = fmt.Sprintf(" | Limit: %d", m.config.FileLimit)
 		}
+		if m.config.ByteLimit > 0 {
+			limitStr += fmt.Sprintf(" | Limit: %s", formatBytes(m.config.ByteLimit))
+		}
 		b.WriteString(configStyle.Render(fmt.Sprintf(
 			"%s → %s | Workers: %d | %s%s",
-			truncatePath(m.config.ScanPath, 25),
+			truncatePath(strings.Join(m.config.ScanPaths, ", "), 25),
 			truncatePath(m.config.LibraryBase, 25),
 			m.config.Workers,
 			modeStr,
@@ -320,19 +521,33 @@ func (m model) View() string {
 	// Content based on phase
 	switch m.currentPhase {
 	case phaseScanning, phaseMetadata, phaseHashing, phaseOrganizing, phaseExecuting:
-		b.WriteString(fmt.Sprintf("  %s %s\n\n", m.spinner.View(), m.statusMsg))
+		if m.pause.Paused() && !m.cancelling {
+			pausedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+			b.WriteString(fmt.Sprintf("  %s %s\n\n", pausedStyle.Render("⏸ Paused"), m.statusMsg))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s %s\n\n", m.spinner.View(), m.statusMsg))
+		}
 
 		// Show progress bar if we have total files
 		if m.scanProgress.TotalFiles > 0 {
-			percent := float64(m.scanProgress.ProcessedFiles) / float64(m.scanProgress.TotalFiles)
+			// By bytes when the phase reports them (hashing, executing)
+			percent := m.scanProgress.Fraction()
 			percentDisplay := int(percent * 100)
 
 			b.WriteString("  ") // Left margin
 			b.WriteString(m.progress.ViewAs(percent))
-			b.WriteString(fmt.Sprintf(" %d%% (%d/%d files)\n\n",
+			unit := "files"
+			if m.currentPhase == phaseOrganizing {
+				unit = "folders"
+			}
+			if m.scanProgress.TotalBytes > 0 {
+				unit += fmt.Sprintf(", %s/%s", formatBytes(m.scanProgress.ProcessedBytes), formatBytes(m.scanProgress.TotalBytes))
+			}
+			b.WriteString(fmt.Sprintf(" %d%% (%d/%d %s)\n\n",
 				percentDisplay,
 				m.scanProgress.ProcessedFiles,
-				m.scanProgress.TotalFiles))
+				m.scanProgress.TotalFiles,
+				unit))
 		} else if len(m.files) > 0 {
 			// Show total files count during processing phases
 			b.WriteString(fmt.Sprintf("  Processing %d files...\n\n", len(m.files)))
@@ -370,6 +585,21 @@ func (m model) View() string {
 			MarginLeft(2)
 		b.WriteString(doneStyle.Render("✓ " + m.statusMsg))
 		b.WriteString("\n\n")
+
+		if len(m.execErrors) > 0 {
+			errorStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("196")).
+				MarginLeft(2)
+			for i, err := range m.execErrors {
+				if i == 5 {
+					b.WriteString(errorStyle.Render(fmt.Sprintf("... and %d more errors", len(m.execErrors)-5)))
+					b.WriteString("\n")
+					break
+				}
+				b.WriteString(errorStyle.Render("✗ " + truncatePath(err.Error(), max(m.width-6, 40))))
+				b.WriteString("\n")
+			}
+		}
 	}
 
 	// Footer
@@ -379,11 +609,21 @@ func (m model) View() string {
 		MarginLeft(2)
 	switch m.currentPhase {
 	case phaseReview:
-		b.WriteString(helpStyle.Render("↑/↓: navigate • y/a/enter: accept & execute • n/r: reject & quit • q: quit"))
+		if m.editing {
+			b.WriteString(helpStyle.Render("enter: save • esc: cancel"))
+		} else if m.filtering {
+			b.WriteString(helpStyle.Render("type to filter album names • enter: keep filter • esc: clear"))
+		} else if m.expanded != nil {
+			b.WriteString(helpStyle.Render("↑/↓: scroll • pgup/pgdn: page • ←/esc: back to albums • y/a/enter: accept & execute • q: quit"))
+		} else {
+			b.WriteString(helpStyle.Render("↑/↓: navigate • →: files • space: select • t: toggle all • e: rename • /: filter • d: duplicates • y/a/enter: accept & execute • n/r: reject & quit • q: quit"))
+		}
 	case phaseDone:
 		b.WriteString(helpStyle.Render("enter: quit • q: quit"))
+	case phaseMetadata, phaseHashing, phaseExecuting:
+		b.WriteString(helpStyle.Render(map[bool]string{true: "p: resume • q: quit", false: "p: pause • q: quit"}[m.pause.Paused()]))
 	default:
-		b.WriteString(helpStyle.Render("q: quit"))
+		b.WriteString(helpStyle.Render(map[bool]string{true: "p: resume • q: quit", false: "q: quit"}[m.pause.Paused()]))
 	}
 
 	// Bottom margin
@@ -392,6 +632,149 @@ func (m model) View() string {
 	return b.String()
 }
 
+// updateEditing handles keys while an album name is being edited
+func (m model) updateEditing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.editing = false
+		m.editErr = ""
+	case tea.KeyEnter:
+		if err := m.renameSelectedAlbum(string(m.editInput)); err != nil {
+			m.editErr = err.Error()
+			return m, nil
+		}
+		m.editing = false
+		m.editErr = ""
+	case tea.KeyBackspace:
+		if len(m.editInput) > 0 {
+			m.editInput = m.editInput[:len(m.editInput)-1]
+		}
+	case tea.KeySpace:
+		m.editInput = append(m.editInput, ' ')
+	case tea.KeyRunes:
+		m.editInput = append(m.editInput, msg.Runes...)
+	}
+	return m, nil
+}
+
+// updateFiltering handles keys while the album filter is being typed
+func (m model) updateFiltering(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.filtering = false
+		m.setFilter(nil)
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyBackspace:
+		if len(m.filterInput) > 0 {
+			m.setFilter(m.filterInput[:len(m.filterInput)-1])
+		}
+	case tea.KeySpace:
+		m.setFilter(append(m.filterInput, ' '))
+	case tea.KeyRunes:
+		m.setFilter(append(m.filterInput, msg.Runes...))
+	}
+	return m, nil
+}
+
+// setFilter changes the album filter, starting the filtered list at its top
+func (m *model) setFilter(filter []rune) {
+	m.filterInput = filter
+	m.selectedAlbum = 0
+	m.scrollOffset = 0
+}
+
+// visibleAlbums returns the albums listed in review: those whose name contains the
+// filter (case-insensitive), or all of them without one
+func (m model) visibleAlbums() []*Album {
+	if len(m.filterInput) == 0 {
+		return m.albums
+	}
+	filter := strings.ToLower(string(m.filterInput))
+	var albums []*Album
+	for _, album := range m.albums {
+		if strings.Contains(strings.ToLower(album.Name), filter) {
+			albums = append(albums, album)
+		}
+	}
+	return albums
+}
+
+// renameSelectedAlbum renames the selected album along with albums sharing its name (the
+// photo and video halves of a folder), and remembers the name for their source folders
+func (m *model) renameSelectedAlbum(name string) error {
+	oldName := m.visibleAlbums()[m.selectedAlbum].Name
+	for _, album := range m.albums {
+		if album.Name != oldName || !album.CanRename() {
+			continue
+		}
+		if err := RenameAlbum(album, name, m.config); err != nil {
+			return err
+		}
+		if m.albumCache != nil && !album.Location && !album.Event && album.Part == 0 {
+			for _, dir := range album.SourceDirs {
+				m.albumCache.PutEdited(dir, album.Name)
+			}
+		}
+	}
+
+	// The new name may not match the filter any more
+	if visible := len(m.visibleAlbums()); m.selectedAlbum >= visible {
+		m.selectedAlbum = max(visible-1, 0)
+		m.scrollOffset = min(m.scrollOffset, m.selectedAlbum)
+	}
+	m.updatePlan()
+	return nil
+}
+
+// updatePlan recomputes the library changes and the estimate for the selected albums
+func (m *model) updatePlan() {
+	albums := m.selectedAlbums()
+	m.planDiff = ComputePlanDiff(albums, m.config)
+	m.planEstimate = EstimatePlan(albums, m.duplicates, m.config)
+}
+
+// selectedAlbums returns the albums to organize in this run
+func (m model) selectedAlbums() []*Album {
+	var albums []*Album
+	for _, album := range m.albums {
+		if !m.deselected[album] {
+			albums = append(albums, album)
+		}
+	}
+	return albums
+}
+
+// toggleAlbum selects or deselects one album
+func (m *model) toggleAlbum(album *Album) {
+	if m.deselected == nil {
+		m.deselected = make(map[*Album]bool)
+	}
+	if m.deselected[album] {
+		delete(m.deselected, album)
+	} else {
+		m.deselected[album] = true
+	}
+	m.updatePlan()
+}
+
+// toggleAllAlbums selects every album, or deselects them all when none was left out
+func (m *model) toggleAllAlbums() {
+	if len(m.deselected) > 0 {
+		m.deselected = nil
+	} else {
+		m.deselected = make(map[*Album]bool)
+		for _, album := range m.albums {
+			m.deselected[album] = true
+		}
+	}
+	m.updatePlan()
+}
+
 func (m model) renderReview() string {
 	var b strings.Builder
 
@@ -402,33 +785,71 @@ func (m model) renderReview() string {
 		MarginLeft(2)
 
 	// Summary
+	duplicatesLine := fmt.Sprintf("Duplicates: %d groups", len(m.duplicates))
+	if m.config.NearDupBits > 0 {
+		duplicatesLine += fmt.Sprintf(" • Near-duplicates: %d groups (review only)", len(m.nearDuplicates))
+	}
+	albumsLine := fmt.Sprintf("Albums: %d", len(m.albums))
+	if len(m.deselected) > 0 {
+		albumsLine += fmt.Sprintf(" (%d skipped)", len(m.deselected))
+	}
 	b.WriteString(boxStyle.Render(fmt.Sprintf(
-		"Total: %d files • Photos: %d • Videos: %d • Music: %d\nAlbums: %d • Duplicates: %d groups",
+		"Total: %d files • Photos: %d • Videos: %d • Music: %d\n%s • %s\nLibrary: %s\n         %s\nEstimate: %s",
 		len(m.files),
 		countByType(m.files, TypePhoto),
 		countByType(m.files, TypeVideo),
 		countByType(m.files, TypeMusic),
-		len(m.albums),
-		len(m.duplicates),
+		albumsLine,
+		duplicatesLine,
+		m.planDiff,
+		m.planDiff.FilesString(),
+		m.planEstimate,
 	)))
 	b.WriteString("\n\n")
 
+	if m.showDuplicates {
+		b.WriteString(m.renderDuplicates())
+		return b.String()
+	}
+	if m.expanded != nil {
+		b.WriteString(m.renderAlbumFiles())
+		return b.String()
+	}
+
 	// Albums list
 	albumsHeaderStyle := lipgloss.NewStyle().
 		Bold(true).
 		MarginLeft(2)
-	b.WriteString(albumsHeaderStyle.Render("Albums:"))
+	albums := m.visibleAlbums()
+	header := "Albums:"
+	if m.filtering || len(m.filterInput) > 0 {
+		cursor := ""
+		if m.filtering {
+			cursor = "█"
+		}
+		header = fmt.Sprintf("Albums matching /%s%s (%d of %d):", string(m.filterInput), cursor, len(albums), len(m.albums))
+	}
+	b.WriteString(albumsHeaderStyle.Render(header))
 	b.WriteString("\n\n")
 
 	maxVisible := m.height - 15
 	start := m.scrollOffset
 	end := start + maxVisible
-	if end > len(m.albums) {
-		end = len(m.albums)
+	if end > len(albums) {
+		end = len(albums)
 	}
 
 	for i := start; i < end; i++ {
-		album := m.albums[i]
+		album := albums[i]
+		albumStatus := "new"
+		if m.planDiff.AlbumExists(album) {
+			albumStatus = "existing"
+		}
+		checkbox := "[x]"
+		if m.deselected[album] {
+			checkbox = "[ ]"
+			albumStatus = "skipped"
+		}
 
 		var line string
 		if i == m.selectedAlbum {
@@ -436,9 +857,9 @@ func (m model) renderReview() string {
 				Background(lipgloss.Color("62")).
 				Foreground(lipgloss.Color("230")).
 				MarginLeft(2)
-			line = selectedStyle.Render(fmt.Sprintf("► %s (%d files)", album.Name, len(album.Files)))
+			line = selectedStyle.Render(fmt.Sprintf("► %s %s (%d files, %s)", checkbox, album.Name, len(album.Files), albumStatus))
 		} else {
-			line = fmt.Sprintf("    %s (%d files)", album.Name, len(album.Files))
+			line = fmt.Sprintf("    %s %s (%d files, %s)", checkbox, album.Name, len(album.Files), albumStatus)
 		}
 
 		b.WriteString(line)
@@ -451,23 +872,140 @@ func (m model) renderReview() string {
 			dest := destStyle.Render(fmt.Sprintf("    → %s", album.Destination))
 			b.WriteString(dest)
 			b.WriteString("\n")
+			if bytes := m.planEstimate.CrossDeviceBytes(album); bytes > 0 {
+				b.WriteString(destStyle.Render(fmt.Sprintf("    will copy %s across devices", formatBytes(bytes))))
+				b.WriteString("\n")
+			}
+
+			if m.editing {
+				editStyle := lipgloss.NewStyle().
+					Foreground(lipgloss.Color("230")).
+					MarginLeft(2)
+				b.WriteString(editStyle.Render(fmt.Sprintf("    Name: %s█", string(m.editInput))))
+				b.WriteString("\n")
+			}
+			if m.editErr != "" {
+				errorStyle := lipgloss.NewStyle().
+					Foreground(lipgloss.Color("196")).
+					MarginLeft(2)
+				b.WriteString(errorStyle.Render("    ✗ " + m.editErr))
+				b.WriteString("\n")
+			}
 		}
 	}
 
-	if len(m.albums) > maxVisible {
+	if len(albums) > maxVisible {
 		moreStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240")).
 			MarginLeft(2)
-		b.WriteString(moreStyle.Render(fmt.Sprintf("\n... %d more albums ...", len(m.albums)-end)))
+		b.WriteString(moreStyle.Render(fmt.Sprintf("\n... %d more albums ...", len(albums)-end)))
 	}
 
 	return b.String()
 }
 
+// renderDuplicates lists the duplicate groups with the kept file and the files that would
+// be trashed, scrolled by line
+func (m model) renderDuplicates() string {
+	var b strings.Builder
+
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		MarginLeft(2)
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Duplicates (%s reclaimable):", formatBytes(ReclaimableBytes(m.duplicates)))))
+	b.WriteString("\n\n")
+
+	keepStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("42")).
+		MarginLeft(2)
+	trashStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		MarginLeft(2)
+	pathWidth := max(m.width-20, 40)
+
+	var lines []string
+	for _, group := range m.duplicates {
+		lines = append(lines, fmt.Sprintf("  %.12s x%d (%s each)", group.Hash, len(group.Files), formatBytes(group.Best.Size)))
+		lines = append(lines, keepStyle.Render("    keep:  "+truncatePath(group.Best.Path, pathWidth)))
+		for _, file := range group.Files {
+			if file != group.Best {
+				lines = append(lines, trashStyle.Render(fmt.Sprintf("    trash: %s (%s)", truncatePath(file.Path, pathWidth), formatBytes(file.Size))))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "  No duplicates found")
+	}
+
+	maxVisible := max(m.height-15, 1)
+	start := min(m.dupScroll, max(len(lines)-maxVisible, 0))
+	end := min(start+maxVisible, len(lines))
+	for _, line := range lines[start:end] {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if end < len(lines) {
+		b.WriteString(trashStyle.Render(fmt.Sprintf("\n... %d more lines ...", len(lines)-end)))
+	}
+
+	return b.String()
+}
+
+// renderAlbumFiles lists the files of the expanded album with their sizes and dates.
+// Only the visible window is rendered, albums can hold thousands of files.
+func (m model) renderAlbumFiles() string {
+	var b strings.Builder
+	album := m.expanded
+
+	var size int64
+	for _, file := range album.Files {
+		size += file.Size
+	}
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		MarginLeft(2)
+	b.WriteString(headerStyle.Render(fmt.Sprintf("%s (%d files, %s):", album.Name, len(album.Files), formatBytes(size))))
+	b.WriteString("\n")
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		MarginLeft(2)
+	b.WriteString(dimStyle.Render(fmt.Sprintf("    → %s", album.Destination)))
+	b.WriteString("\n\n")
+
+	maxVisible := max(m.height-15, 1)
+	start := min(m.fileScroll, m.maxFileScroll())
+	end := min(start+maxVisible, len(album.Files))
+	pathWidth := 0
+	for _, file := range album.Files[start:end] {
+		pathWidth = max(pathWidth, len(file.Path))
+	}
+	pathWidth = min(pathWidth, max(m.width-40, 40))
+	for _, file := range album.Files[start:end] {
+		date := "no date"
+		if file.DateTaken != nil {
+			date = file.DateTaken.Format("2006-01-02 15:04")
+		}
+		b.WriteString(fmt.Sprintf("    %-*s %10s  %s\n", pathWidth, truncatePath(file.Path, pathWidth), formatBytes(file.Size), date))
+	}
+	if end < len(album.Files) {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("\n... %d more files ...", len(album.Files)-end)))
+	}
+
+	return b.String()
+}
+
+// maxFileScroll is how far the expanded album's file list scrolls
+func (m model) maxFileScroll() int {
+	if m.expanded == nil {
+		return 0
+	}
+	return max(len(m.expanded.Files)-max(m.height-15, 1), 0)
+}
+
 // Commands
-func scanFiles(config *Config) tea.Cmd {
+func scanFiles(config *Config, cache *Cache) tea.Cmd {
 	return func() tea.Msg {
-		files, err := ScanMediaFiles(config.ScanPath, config.FileLimit, nil)
+		files, err := ScanMediaFiles(config, nil, cache)
 		if err != nil {
 			return errMsg(err)
 		}
@@ -475,11 +1013,11 @@ func scanFiles(config *Config) tea.Cmd {
 	}
 }
 
-func processMetadata(config *Config, files []*MediaFile, cache *Cache, progressChan chan ScanProgress) tea.Cmd {
+func processMetadata(ctx context.Context, config *Config, files []*MediaFile, cache *Cache, progressChan chan ScanProgress) tea.Cmd {
 	return func() tea.Msg {
 		// Start processing in background
 		go func() {
-			ProcessMetadata(files, config.Workers, progressChan, cache)
+			ProcessMetadata(ctx, files, config.Workers, progressChan, cache)
 			close(progressChan)
 		}()
 
@@ -503,11 +1041,17 @@ func waitForProgress(progressChan <-chan ScanProgress) tea.Cmd {
 	}
 }
 
-func calculateHashes(config *Config, files []*MediaFile, cache *Cache, progressChan chan ScanProgress) tea.Cmd {
+func calculateHashes(ctx context.Context, config *Config, files []*MediaFile, cache *Cache, progressChan chan ScanProgress) tea.Cmd {
 	return func() tea.Msg {
 		// Start processing in background
 		go func() {
-			CalculateHashes(files, config.Workers, progressChan, cache)
+			CalculateHashes(ctx, files, config.Workers, config.HashAlgorithm, progressChan, cache)
+			if config.ContentHash && ctx.Err() == nil {
+				CalculateContentHashes(ctx, files, config.Workers, progressChan, cache)
+			}
+			if config.NearDupBits > 0 && ctx.Err() == nil {
+				CalculatePerceptualHashes(files, config.Workers, progressChan, cache)
+			}
 			close(progressChan)
 		}()
 
@@ -519,32 +1063,51 @@ func calculateHashes(config *Config, files []*MediaFile, cache *Cache, progressC
 	}
 }
 
-func organizeFiles(config *Config, files []*MediaFile, albumCache *AlbumSuggestionCache) tea.Cmd {
+func organizeFiles(ctx context.Context, config *Config, files []*MediaFile, cache *Cache, albumCache *AlbumSuggestionCache, progressChan chan ScanProgress) tea.Cmd {
 	return func() tea.Msg {
-		albums, _ := OrganizeIntoAlbums(files, config, nil, albumCache)
-		duplicates := FindDuplicates(files)
-		return albumsReadyMsg{albums: albums, duplicates: duplicates}
+		duplicates := FindDuplicates(files, config.DedupThreshold, &config.DuplicatePolicy)
+		albumFiles := files
+		if config.DedupLibrary {
+			duplicates = AddLibraryDuplicates(ctx, duplicates, files, config, cache)
+			albumFiles = withoutLibraryDuplicates(files, duplicates, config)
+		}
+		albums, _ := OrganizeIntoAlbums(ctx, albumFiles, config, progressChan, albumCache)
+		close(progressChan)
+		nearDuplicates := FindNearDuplicates(files, config.NearDupBits, &config.DuplicatePolicy)
+		if config.ReportPath != "" {
+			if err := WritePlanReport(config.ReportPath, albums, duplicates, config); err != nil {
+				return errMsg(fmt.Errorf("write report: %w", err))
+			}
+		}
+		if config.DupReportPath != "" {
+			if err := WriteDuplicateReport(config.DupReportPath, duplicates, config); err != nil {
+				return errMsg(fmt.Errorf("write duplicate report: %w", err))
+			}
+		}
+		return albumsReadyMsg{
+			albums:         albums,
+			duplicates:     duplicates,
+			nearDuplicates: nearDuplicates,
+			diff:           ComputePlanDiff(albums, config),
+			estimate:       EstimatePlan(albums, duplicates, config),
+		}
 	}
 }
 
-func executeOrganization(config *Config, albums []*Album, duplicates []*DuplicateGroup, cache *Cache) tea.Cmd {
+func executeOrganization(ctx context.Context, config *Config, albums []*Album, duplicates []*DuplicateGroup, cache *Cache) tea.Cmd {
 	return func() tea.Msg {
 		// Execute without progress channel for TUI (uses spinner instead)
-		err := ExecuteOrganization(albums, duplicates, config, nil, cache)
-
-		// Count moved/failed from error or assume success
-		totalFiles := 0
-		for _, album := range albums {
-			totalFiles += len(album.Files)
-		}
-		for _, group := range duplicates {
-			totalFiles += len(group.Files) - 1
+		result, err := ExecuteOrganization(ctx, albums, duplicates, config, nil, cache)
+		if err != nil {
+			return errMsg(fmt.Errorf("execute: %w", err))
 		}
 
-		if err != nil {
-			return executionCompleteMsg{moved: 0, failed: totalFiles}
+		// Post command output would garble the TUI, only its status is shown
+		msg := executionCompleteMsg{moved: result.Moved, failed: result.Failed, changed: len(result.Changed), errors: result.Errors, cancelled: result.Cancelled}
+		if config.PostCommand != "" && !result.Cancelled {
+			msg.postErr = RunPostCommand(config, len(albums), result, io.Discard)
 		}
-		return executionCompleteMsg{moved: totalFiles, failed: 0}
+		return msg
 	}
 }
 
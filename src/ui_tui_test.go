@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reviewModel returns a model in the review phase for albums, on a 100x40 terminal
+func reviewModel(t *testing.T, config *Config, albums ...*Album) model {
+	m := model{
+		ctx:          t.Context(),
+		cancel:       func() {},
+		pause:        &Pauser{},
+		config:       config,
+		currentPhase: phaseReview,
+		albums:       albums,
+		width:        100,
+		height:       40,
+	}
+	m.updatePlan()
+	return m
+}
+
+// press sends keys to the model: special keys by name ("enter", "esc", "backspace",
+// "down", "up", "left", "right", "pgdown", "space"), anything else typed character by character
+func press(m model, keys ...string) (model, tea.Cmd) {
+	special := map[string]tea.KeyType{
+		"enter": tea.KeyEnter, "esc": tea.KeyEsc, "backspace": tea.KeyBackspace,
+		"down": tea.KeyDown, "up": tea.KeyUp, "left": tea.KeyLeft, "right": tea.KeyRight,
+		"pgdown": tea.KeyPgDown, "space": tea.KeySpace,
+	}
+	var cmd tea.Cmd
+	for _, key := range keys {
+		var msgs []tea.KeyMsg
+		if keyType, ok := special[key]; ok {
+			msgs = append(msgs, tea.KeyMsg{Type: keyType})
+		} else {
+			for _, r := range key {
+				if r == ' ' {
+					msgs = append(msgs, tea.KeyMsg{Type: tea.KeySpace})
+				} else {
+					msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+				}
+			}
+		}
+		for _, msg := range msgs {
+			var next tea.Model
+			next, cmd = m.Update(msg)
+			m = next.(model)
+		}
+	}
+	return m, cmd
+}
+
+func TestReviewRenameAlbum(t *testing.T) {
+	album, config := newTestAlbum(t, "a.jpg")
+	cache := openTestCache(t)
+	albumCache, err := OpenAlbumSuggestionCache(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := reviewModel(t, config, album)
+	m.albumCache = albumCache
+
+	// Escape cancels the edit
+	m, _ = press(m, "e", "backspace", "x", "esc")
+	if m.editing || album.Name != "trip" {
+		t.Fatalf("after esc: editing %v, name %q, want the edit dropped", m.editing, album.Name)
+	}
+
+	// An empty name is refused and the edit stays open
+	m, _ = press(m, "e", "backspace", "backspace", "backspace", "backspace", "enter")
+	if !m.editing || m.editErr == "" || album.Name != "trip" {
+		t.Fatalf("empty name: editing %v, error %q, name %q, want it refused", m.editing, m.editErr, album.Name)
+	}
+
+	m, _ = press(m, "Lake Como/2019", "enter")
+	if m.editing || m.editErr != "" {
+		t.Fatalf("rename: editing %v, error %q", m.editing, m.editErr)
+	}
+	if album.Name != "Lake Como-2019" {
+		t.Errorf("name = %q, want %q", album.Name, "Lake Como-2019")
+	}
+	if want := filepath.Join(config.LibraryBase, "Lake Como-2019"); album.Destination != want {
+		t.Errorf("destination = %q, want %q", album.Destination, want)
+	}
+	cache.flush()
+	if name, ok := albumCache.GetEdited(album.SourceDirs[0]); !ok || name != "Lake Como-2019" {
+		t.Errorf("remembered name = %q, %v, want %q", name, ok, "Lake Como-2019")
+	}
+}
+
+func TestReviewDeselectedAlbumNotExecuted(t *testing.T) {
+	trip, config := newTestAlbum(t, "a.jpg")
+	partyDir := filepath.Join(filepath.Dir(trip.SourceDirs[0]), "party")
+	if err := os.MkdirAll(partyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	partyFile := filepath.Join(partyDir, "b.jpg")
+	if err := os.WriteFile(partyFile, []byte("b.jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	party := &Album{
+		Name:        "party",
+		Destination: filepath.Join(config.LibraryBase, "party"),
+		SourceDirs:  []string{partyDir},
+		Files:       []*MediaFile{{Path: partyFile, Size: 5, Type: TypePhoto}},
+	}
+	m := reviewModel(t, config, trip, party)
+
+	// Toggle all off and on again, then leave out the second album
+	m, _ = press(m, "t")
+	if got := len(m.selectedAlbums()); got != 0 {
+		t.Errorf("after toggling all off: %d albums selected, want 0", got)
+	}
+	m, _ = press(m, "t", "down", "space")
+	if got := m.selectedAlbums(); len(got) != 1 || got[0] != trip {
+		t.Fatalf("selected %d albums, want only trip", len(got))
+	}
+
+	m, cmd := press(m, "y")
+	if m.currentPhase != phaseExecuting || cmd == nil {
+		t.Fatalf("phase %v, want executing", m.currentPhase)
+	}
+	msg, ok := cmd().(executionCompleteMsg)
+	if !ok || msg.moved != 1 || msg.failed != 0 {
+		t.Fatalf("execution = %+v, want 1 moved", msg)
+	}
+	if _, err := os.Stat(filepath.Join(trip.Destination, "a.jpg")); err != nil {
+		t.Errorf("selected album not organized: %v", err)
+	}
+	if _, err := os.Stat(partyFile); err != nil {
+		t.Errorf("deselected album's file moved: %v", err)
+	}
+	if _, err := os.Stat(party.Destination); !os.IsNotExist(err) {
+		t.Errorf("deselected album's folder created (%v)", err)
+	}
+}
+
+// albumNamesOf returns the names of albums in order
+func albumNamesOf(albums []*Album) []string {
+	var names []string
+	for _, album := range albums {
+		names = append(names, album.Name)
+	}
+	return names
+}
+
+func TestReviewFilterAlbums(t *testing.T) {
+	_, config := newTestAlbum(t)
+	var albums []*Album
+	for _, name := range []string{"Crete 2019", "Paris 2020", "Hiking in crete", "Rome"} {
+		albums = append(albums, &Album{Name: name, Destination: filepath.Join(config.LibraryBase, name)})
+	}
+	m := reviewModel(t, config, albums...)
+
+	m, _ = press(m, "/", "CRETE")
+	if want := []string{"Crete 2019", "Hiking in crete"}; !slices.Equal(albumNamesOf(m.visibleAlbums()), want) {
+		t.Errorf("visible %v, want %v", albumNamesOf(m.visibleAlbums()), want)
+	}
+	view := m.renderReview()
+	if !strings.Contains(view, "Albums matching /CRETE█ (2 of 4)") || strings.Contains(view, "Paris 2020") {
+		t.Errorf("filtered view:\n%s", view)
+	}
+
+	// The filter stays after enter; selection moves within the filtered list
+	m, _ = press(m, "enter", "down", "space")
+	if m.filtering || m.currentPhase != phaseReview {
+		t.Fatalf("after enter: filtering %v, phase %v", m.filtering, m.currentPhase)
+	}
+	if got := albumNamesOf(m.selectedAlbums()); !slices.Equal(got, []string{"Crete 2019", "Paris 2020", "Rome"}) {
+		t.Errorf("selected %v, want Hiking in crete left out", got)
+	}
+
+	// Typing more narrows further, esc clears the filter
+	m, _ = press(m, "/", " 2")
+	if want := []string{"Crete 2019"}; !slices.Equal(albumNamesOf(m.visibleAlbums()), want) || m.selectedAlbum != 0 {
+		t.Errorf("visible %v (selected %d), want %v", albumNamesOf(m.visibleAlbums()), m.selectedAlbum, want)
+	}
+	m, _ = press(m, "esc")
+	if m.filtering || len(m.visibleAlbums()) != 4 {
+		t.Errorf("after esc: filtering %v, %d visible, want all 4", m.filtering, len(m.visibleAlbums()))
+	}
+}
+
+func TestReviewAlbumFiles(t *testing.T) {
+	_, config := newTestAlbum(t)
+	date := time.Date(2019, 7, 4, 16, 20, 0, 0, time.Local)
+	big := &Album{Name: "Big trip", Destination: filepath.Join(config.LibraryBase, "Big trip")}
+	for i := range 1000 {
+		big.Files = append(big.Files, &MediaFile{Path: fmt.Sprintf("/scan/trip/IMG_%04d.jpg", i), Size: 2048, Type: TypePhoto, DateTaken: &date})
+	}
+	other := &Album{Name: "Other", Destination: filepath.Join(config.LibraryBase, "Other"),
+		Files: []*MediaFile{{Path: "/scan/other/x.jpg", Size: 1, Type: TypePhoto}}}
+	m := reviewModel(t, config, big, other)
+
+	m, _ = press(m, "right")
+	if m.expanded != big {
+		t.Fatalf("expanded %v, want the selected album", m.expanded)
+	}
+	view := m.renderReview()
+	for _, want := range []string{"Big trip (1000 files, 2.0 MB)", "/scan/trip/IMG_0000.jpg", "2019-07-04 16:20", "/scan/trip/IMG_0024.jpg", "975 more files"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("file list lacks %q:\n%s", want, view)
+		}
+	}
+	// Only the window is rendered
+	if strings.Contains(view, "IMG_0025.jpg") || strings.Contains(view, "x.jpg") {
+		t.Errorf("file list renders past its window:\n%s", view)
+	}
+
+	m, _ = press(m, "pgdown", "down")
+	if view := m.renderReview(); !strings.Contains(view, "IMG_0026.jpg") || strings.Contains(view, "IMG_0025.jpg") {
+		t.Errorf("scrolled file list:\n%s", view)
+	}
+
+	m, _ = press(m, "left")
+	if m.expanded != nil || !strings.Contains(m.renderReview(), "Albums:") {
+		t.Errorf("left didn't go back to the albums")
+	}
+	m, _ = press(m, "down", "right", "esc")
+	if m.expanded != nil || m.selectedAlbum != 1 {
+		t.Errorf("esc: expanded %v, selected %d, want back on the second album", m.expanded, m.selectedAlbum)
+	}
+}